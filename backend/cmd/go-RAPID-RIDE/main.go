@@ -62,6 +62,7 @@ func main() {
 
 	server.Hub.RideService = services.Ride
 	server.Hub.LocationService = services.Location
+	server.Hub.ChatService = services.Chat
 
 	handlers := handler.NewHandlers(server, services)
 
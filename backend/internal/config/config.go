@@ -22,6 +22,11 @@ type Config struct {
 	Redis         RedisConfig          `koanf:"redis" validate:"required"`
 	Observability *ObservabilityConfig `koanf:"observability"`
 	Integration   IntegrationConfig    `koanf:"integration" validate:"required"`
+	Notification  *NotificationConfig  `koanf:"notification"`
+	Location      *LocationConfig      `koanf:"location"`
+	Earnings      *EarningsConfig      `koanf:"earnings"`
+	Payment       *PaymentConfig       `koanf:"payment"`
+	Referral      *ReferralConfig      `koanf:"referral"`
 }
 
 type Primary struct {
@@ -64,31 +69,7 @@ type AuthConfig struct {
 func LoadConfig() (*Config, error) {
 	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
 	k := koanf.New(".")
-	err := k.Load(env.Provider("RAPID_RIDE_", ".", func(s string) string {
-		s = strings.ToLower(strings.TrimPrefix(s, "RAPID_RIDE_"))
-
-		// Handle specific nested structs with underscores in their koanf tags
-		replacements := map[string]string{
-			"observability_new_relic_":     "observability.new_relic.",
-			"observability_health_checks_": "observability.health_checks.",
-			"observability_logging_":       "observability.logging.",
-		}
-
-		for prefix, replacement := range replacements {
-			if strings.HasPrefix(s, prefix) {
-				return strings.Replace(s, prefix, replacement, 1)
-			}
-		}
-
-		// Map known top-level prefixes to dot notation
-		prefixes := []string{"primary", "server", "database", "auth", "redis", "observability", "integration"}
-		for _, p := range prefixes {
-			if strings.HasPrefix(s, p+"_") {
-				return strings.Replace(s, "_", ".", 1)
-			}
-		}
-		return s
-	}), nil)
+	err := k.Load(env.Provider("RAPID_RIDE_", ".", envKey), nil)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Could not load initial env variables")
 	}
@@ -99,20 +80,15 @@ func LoadConfig() (*Config, error) {
 	// Initialize with defaults
 	mainconfig := &Config{
 		Observability: DefaultObservabilityConfig(),
+		Notification:  DefaultNotificationConfig(),
+		Location:      DefaultLocationConfig(),
+		Earnings:      DefaultEarningsConfig(),
+		Payment:       DefaultPaymentConfig(),
+		Referral:      DefaultReferralConfig(),
 	}
 
 	// Use UnmarshalWithConf to support time.Duration and slice parsing
-	err = k.UnmarshalWithConf("", mainconfig, koanf.UnmarshalConf{
-		DecoderConfig: &mapstructure.DecoderConfig{
-			DecodeHook: mapstructure.ComposeDecodeHookFunc(
-				mapstructure.StringToTimeDurationHookFunc(),
-				mapstructure.StringToSliceHookFunc(","),
-			),
-			Metadata:         nil,
-			Result:           mainconfig,
-			WeaklyTypedInput: true,
-		},
-	})
+	err = unmarshal(k, "", mainconfig)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Could not unmarshal main config")
 	}
@@ -130,6 +106,73 @@ func LoadConfig() (*Config, error) {
 	if err := mainconfig.Observability.Validate(); err != nil {
 		logger.Fatal().Err(err).Msg("Observability config validation failed")
 	}
+
+	if err := mainconfig.Notification.Validate(); err != nil {
+		logger.Fatal().Err(err).Msg("Notification config validation failed")
+	}
+
+	if err := mainconfig.Location.Validate(); err != nil {
+		logger.Fatal().Err(err).Msg("Location config validation failed")
+	}
+
+	if err := mainconfig.Earnings.Validate(); err != nil {
+		logger.Fatal().Err(err).Msg("Earnings config validation failed")
+	}
+
+	if err := mainconfig.Payment.Validate(mainconfig.Primary.Env); err != nil {
+		logger.Fatal().Err(err).Msg("Payment config validation failed")
+	}
+
+	if err := mainconfig.Referral.Validate(); err != nil {
+		logger.Fatal().Err(err).Msg("Referral config validation failed")
+	}
 	return mainconfig, nil
 
 }
+
+// envKey maps a RAPID_RIDE_ environment variable to its config key, e.g.
+// RAPID_RIDE_REFERRAL_REWARD_TYPE to referral.reward_type
+func envKey(s string) string {
+	s = strings.ToLower(strings.TrimPrefix(s, "RAPID_RIDE_"))
+
+	// Handle specific nested structs with underscores in their koanf tags
+	replacements := map[string]string{
+		"observability_new_relic_":     "observability.new_relic.",
+		"observability_health_checks_": "observability.health_checks.",
+		"observability_logging_":       "observability.logging.",
+		"earnings_commission_rates_":   "earnings.commission_rates.",
+		"payment_razorpay_":            "payment.razorpay.",
+		"payment_fake_":                "payment.fake.",
+		"payment_reconciliation_":      "payment.reconciliation.",
+	}
+
+	for prefix, replacement := range replacements {
+		if strings.HasPrefix(s, prefix) {
+			return strings.Replace(s, prefix, replacement, 1)
+		}
+	}
+
+	// Map known top-level prefixes to dot notation
+	prefixes := []string{"primary", "server", "database", "auth", "redis", "observability", "integration", "notification", "location", "earnings", "payment", "referral"}
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p+"_") {
+			return strings.Replace(s, "_", ".", 1)
+		}
+	}
+	return s
+}
+
+// unmarshal decodes the config at path into out, supporting time.Duration and slice parsing
+func unmarshal(k *koanf.Koanf, path string, out any) error {
+	return k.UnmarshalWithConf(path, out, koanf.UnmarshalConf{
+		DecoderConfig: &mapstructure.DecoderConfig{
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				mapstructure.StringToSliceHookFunc(","),
+			),
+			Metadata:         nil,
+			Result:           out,
+			WeaklyTypedInput: true,
+		},
+	})
+}
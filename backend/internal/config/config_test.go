@@ -0,0 +1,57 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knadh/koanf/providers/env"
+	"github.com/knadh/koanf/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvKey(t *testing.T) {
+	tests := map[string]string{
+		"RAPID_RIDE_SERVER_READ_TIMEOUT":            "server.read_timeout",
+		"RAPID_RIDE_PAYMENT_RAZORPAY_KEY_ID":        "payment.razorpay.key_id",
+		"RAPID_RIDE_EARNINGS_COMMISSION_RATES_BIKE": "earnings.commission_rates.bike",
+		"RAPID_RIDE_REFERRAL_REWARD_TYPE":           "referral.reward_type",
+		"RAPID_RIDE_REFERRAL_PROMO_VALIDITY":        "referral.promo_validity",
+	}
+	for name, want := range tests {
+		assert.Equal(t, want, envKey(name), name)
+	}
+}
+
+func TestLoadReferralConfig(t *testing.T) {
+	t.Setenv("RAPID_RIDE_REFERRAL_REWARD_TYPE", ReferralRewardPromo)
+	t.Setenv("RAPID_RIDE_REFERRAL_REFERRER_REWARD", "150")
+	t.Setenv("RAPID_RIDE_REFERRAL_PROMO_VALIDITY", "48h")
+
+	k := koanf.New(".")
+	require.NoError(t, k.Load(env.Provider("RAPID_RIDE_", ".", envKey), nil))
+	cfg := DefaultReferralConfig()
+	require.NoError(t, unmarshal(k, "referral", cfg))
+
+	assert.Equal(t, ReferralRewardPromo, cfg.RewardType)
+	assert.Equal(t, 150.0, cfg.ReferrerReward)
+	assert.Equal(t, 48*time.Hour, cfg.PromoValidity)
+	// Unset variables keep their defaults
+	assert.Equal(t, DefaultReferralConfig().RefereeReward, cfg.RefereeReward)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestPaymentConfigValidateProvider(t *testing.T) {
+	cfg := DefaultPaymentConfig()
+	assert.NoError(t, cfg.Validate("production"))
+
+	cfg.Provider = "stripe"
+	assert.Error(t, cfg.Validate("development"))
+
+	cfg.Provider = ""
+	assert.Error(t, cfg.Validate("development"))
+
+	cfg.Provider = "fake"
+	assert.NoError(t, cfg.Validate("development"))
+	assert.Error(t, cfg.Validate("production"))
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+)
+
+// EarningsConfig is the commission plan applied to paid rides and how drivers are paid out
+type EarningsConfig struct {
+	// CommissionRate is the share of the fare the platform keeps, tips always go to the driver
+	CommissionRate float64 `koanf:"commission_rate"`
+	// CommissionRates overrides the commission rate per ride vehicle type
+	CommissionRates map[string]float64 `koanf:"commission_rates"`
+	// PayoutSchedule is the cron spec for settling driver balances into a payout batch
+	PayoutSchedule string `koanf:"payout_schedule"`
+	// MinimumPayout is the smallest balance in rupees that is paid out, smaller ones wait for the next batch
+	MinimumPayout float64 `koanf:"minimum_payout"`
+}
+
+func DefaultEarningsConfig() *EarningsConfig {
+	return &EarningsConfig{
+		CommissionRate: 0.20,
+		CommissionRates: map[string]float64{
+			"bike": 0.15,
+			"auto": 0.15,
+		},
+		PayoutSchedule: "0 4 * * MON",
+		MinimumPayout:  100,
+	}
+}
+
+// CommissionRateFor returns the commission rate of rides with the given vehicle type
+func (c *EarningsConfig) CommissionRateFor(vehicleType string) float64 {
+	if rate, ok := c.CommissionRates[vehicleType]; ok {
+		return rate
+	}
+	return c.CommissionRate
+}
+
+func (c *EarningsConfig) Validate() error {
+	if c.CommissionRate < 0 || c.CommissionRate >= 1 {
+		return fmt.Errorf("earnings commission_rate must be at least 0 and below 1")
+	}
+	for vehicleType, rate := range c.CommissionRates {
+		if rate < 0 || rate >= 1 {
+			return fmt.Errorf("earnings commission_rates.%s must be at least 0 and below 1", vehicleType)
+		}
+	}
+	if c.PayoutSchedule == "" {
+		return fmt.Errorf("earnings payout_schedule cannot be empty")
+	}
+	if c.MinimumPayout < 1 {
+		return fmt.Errorf("earnings minimum_payout must be at least 1")
+	}
+	return nil
+}
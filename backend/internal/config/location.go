@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// LocationConfig controls how driver locations are persisted to PostgreSQL
+type LocationConfig struct {
+	// FlushInterval is how often the latest position per driver is written to drivers.location
+	FlushInterval time.Duration `koanf:"flush_interval"`
+	// HistoryRetentionDays is how long driver_location_history partitions are kept
+	HistoryRetentionDays int `koanf:"history_retention_days"`
+	// HistoryMaintenanceSchedule is the cron spec for creating and pruning history partitions
+	HistoryMaintenanceSchedule string `koanf:"history_maintenance_schedule"`
+	// GeoReconcileSchedule is the cron spec for reconciling the redis geo indexes with the database
+	GeoReconcileSchedule string `koanf:"geo_reconcile_schedule"`
+	// FraudAlertThreshold is the fraud score at which admins are alerted about a driver
+	FraudAlertThreshold int `koanf:"fraud_alert_threshold"`
+	// FraudScoreWindow is how far back location flags count towards the fraud score
+	FraudScoreWindow time.Duration `koanf:"fraud_score_window"`
+	// HeatmapRefreshSchedule is the cron spec for rebuilding the cached demand heatmap
+	HeatmapRefreshSchedule string `koanf:"heatmap_refresh_schedule"`
+	// HeatmapHistoryWindow is how far back requested rides count as recent demand
+	HeatmapHistoryWindow time.Duration `koanf:"heatmap_history_window"`
+}
+
+func DefaultLocationConfig() *LocationConfig {
+	return &LocationConfig{
+		FlushInterval:              5 * time.Second,
+		HistoryRetentionDays:       90,
+		HistoryMaintenanceSchedule: "15 3 * * *",
+		GeoReconcileSchedule:       "@every 1m",
+		FraudAlertThreshold:        50,
+		FraudScoreWindow:           24 * time.Hour,
+		HeatmapRefreshSchedule:     "@every 1m",
+		HeatmapHistoryWindow:       time.Hour,
+	}
+}
+
+func (c *LocationConfig) Validate() error {
+	if c.FlushInterval < time.Second {
+		return fmt.Errorf("location flush_interval must be at least 1s")
+	}
+	if c.HistoryRetentionDays < 1 {
+		return fmt.Errorf("location history_retention_days must be at least 1")
+	}
+	if c.HistoryMaintenanceSchedule == "" {
+		return fmt.Errorf("location history_maintenance_schedule cannot be empty")
+	}
+	if c.GeoReconcileSchedule == "" {
+		return fmt.Errorf("location geo_reconcile_schedule cannot be empty")
+	}
+	if c.FraudAlertThreshold < 1 {
+		return fmt.Errorf("location fraud_alert_threshold must be at least 1")
+	}
+	if c.FraudScoreWindow < time.Hour {
+		return fmt.Errorf("location fraud_score_window must be at least 1h")
+	}
+	if c.HeatmapRefreshSchedule == "" {
+		return fmt.Errorf("location heatmap_refresh_schedule cannot be empty")
+	}
+	if c.HeatmapHistoryWindow < time.Minute {
+		return fmt.Errorf("location heatmap_history_window must be at least 1m")
+	}
+	return nil
+}
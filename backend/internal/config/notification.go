@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// NotificationConfig selects where push notifications are delivered
+type NotificationConfig struct {
+	// Provider is "http" for an FCM/APNs-style push gateway, "file" or "log" for local development and tests
+	Provider string        `koanf:"provider" validate:"required,oneof=log file http"`
+	Endpoint string        `koanf:"endpoint"`
+	APIKey   string        `koanf:"api_key"`
+	Timeout  time.Duration `koanf:"timeout"`
+	FilePath string        `koanf:"file_path"`
+}
+
+func DefaultNotificationConfig() *NotificationConfig {
+	return &NotificationConfig{
+		Provider: "log",
+		Timeout:  10 * time.Second,
+		FilePath: "push-notifications.log",
+	}
+}
+
+func (c *NotificationConfig) Validate() error {
+	switch c.Provider {
+	case "http":
+		if c.Endpoint == "" {
+			return fmt.Errorf("notification endpoint is required for the http provider")
+		}
+	case "file":
+		if c.FilePath == "" {
+			return fmt.Errorf("notification file_path is required for the file provider")
+		}
+	}
+	return nil
+}
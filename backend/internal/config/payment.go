@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// PaymentConfig selects the gateway online payments and wallet top-ups go through
+type PaymentConfig struct {
+	// Provider is "razorpay", "fake" for local development and tests, or "none" to run without a
+	// gateway, online payments and wallet top-ups are then refused
+	Provider string            `koanf:"provider"`
+	Razorpay RazorpayConfig    `koanf:"razorpay"`
+	Fake     FakeGatewayConfig `koanf:"fake"`
+	// Reconciliation compares payments left open with the gateway's records
+	Reconciliation ReconciliationConfig `koanf:"reconciliation"`
+}
+
+type RazorpayConfig struct {
+	KeyID     string `koanf:"key_id"`
+	KeySecret string `koanf:"key_secret"`
+	// WebhookSecret is set separately in the Razorpay dashboard, webhooks are refused without it
+	WebhookSecret string        `koanf:"webhook_secret"`
+	BaseURL       string        `koanf:"base_url"`
+	Timeout       time.Duration `koanf:"timeout"`
+	MaxRetries    int           `koanf:"max_retries"`
+}
+
+// FakeGatewayConfig holds the secrets the fake provider signs checkouts and webhooks with
+type FakeGatewayConfig struct {
+	KeySecret     string `koanf:"key_secret"`
+	WebhookSecret string `koanf:"webhook_secret"`
+}
+
+type ReconciliationConfig struct {
+	// Schedule is the cron spec of the reconciliation job
+	Schedule string `koanf:"schedule"`
+	// After is how long a payment stays open before it is reconciled, the checkout and the webhook
+	// usually settle it first
+	After time.Duration `koanf:"after"`
+	// FailedWindow is how long after its order was created a failed payment or top-up is still
+	// checked, the gateway may capture an attempt after it was marked failed
+	FailedWindow time.Duration `koanf:"failed_window"`
+	// BatchSize is the most payments, and the most top-ups, one run checks
+	BatchSize int `koanf:"batch_size"`
+}
+
+func DefaultPaymentConfig() *PaymentConfig {
+	return &PaymentConfig{
+		Provider: "none",
+		Razorpay: RazorpayConfig{
+			BaseURL:    "https://api.razorpay.com/v1",
+			Timeout:    10 * time.Second,
+			MaxRetries: 2,
+		},
+		Fake: FakeGatewayConfig{
+			KeySecret:     "fake_key_secret",
+			WebhookSecret: "fake_webhook_secret",
+		},
+		Reconciliation: ReconciliationConfig{
+			Schedule:     "@every 10m",
+			After:        30 * time.Minute,
+			FailedWindow: 24 * time.Hour,
+			BatchSize:    200,
+		},
+	}
+}
+
+// Validate checks the provider and its credentials. The fake provider accepts payments nobody
+// made, it is refused in production.
+func (c *PaymentConfig) Validate(env string) error {
+	switch c.Provider {
+	case "none":
+	case "razorpay":
+		if c.Razorpay.KeyID == "" || c.Razorpay.KeySecret == "" {
+			return fmt.Errorf("payment razorpay key_id and key_secret are required for the razorpay provider")
+		}
+	case "fake":
+		if env == "production" {
+			return fmt.Errorf("the fake payment provider cannot be used in production")
+		}
+		if c.Fake.KeySecret == "" {
+			return fmt.Errorf("payment fake key_secret is required for the fake provider")
+		}
+	default:
+		return fmt.Errorf("payment provider must be one of none, razorpay, fake, got %q", c.Provider)
+	}
+	if c.Reconciliation.Schedule == "" {
+		return fmt.Errorf("payment reconciliation schedule cannot be empty")
+	}
+	if c.Reconciliation.After < time.Minute {
+		return fmt.Errorf("payment reconciliation after must be at least 1m")
+	}
+	if c.Reconciliation.FailedWindow <= c.Reconciliation.After {
+		return fmt.Errorf("payment reconciliation failed_window must be longer than after")
+	}
+	if c.Reconciliation.BatchSize < 1 {
+		return fmt.Errorf("payment reconciliation batch_size must be at least 1")
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	ReferralRewardWallet = "wallet"
+	ReferralRewardPromo  = "promo"
+)
+
+// ReferralConfig is what the referral program gives a referrer and the rider they referred once
+// the rider's first ride is paid
+type ReferralConfig struct {
+	// RewardType is wallet for wallet credits or promo for a flat promo code
+	RewardType string `koanf:"reward_type"`
+	// ReferrerReward and RefereeReward are in rupees, 0 gives that side nothing
+	ReferrerReward float64 `koanf:"referrer_reward"`
+	RefereeReward  float64 `koanf:"referee_reward"`
+	// PromoValidity is how long a promo code reward can be redeemed
+	PromoValidity time.Duration `koanf:"promo_validity"`
+	// SweepSchedule is the cron spec for rewarding paid referrals whose reward job was lost
+	SweepSchedule string `koanf:"sweep_schedule"`
+}
+
+func DefaultReferralConfig() *ReferralConfig {
+	return &ReferralConfig{
+		RewardType:     ReferralRewardWallet,
+		ReferrerReward: 100,
+		RefereeReward:  50,
+		PromoValidity:  30 * 24 * time.Hour,
+		SweepSchedule:  "@every 1h",
+	}
+}
+
+func (c *ReferralConfig) Validate() error {
+	if c.RewardType != ReferralRewardWallet && c.RewardType != ReferralRewardPromo {
+		return fmt.Errorf("referral reward_type must be %s or %s", ReferralRewardWallet, ReferralRewardPromo)
+	}
+	if c.ReferrerReward < 0 || c.RefereeReward < 0 {
+		return fmt.Errorf("referral rewards cannot be negative")
+	}
+	if c.RewardType == ReferralRewardPromo && c.PromoValidity <= 0 {
+		return fmt.Errorf("referral promo_validity must be positive")
+	}
+	if c.SweepSchedule == "" {
+		return fmt.Errorf("referral sweep_schedule cannot be empty")
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS ride_messages (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ride_id UUID NOT NULL REFERENCES rides(id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users(id),
+    recipient_id UUID NOT NULL REFERENCES users(id),
+
+    body TEXT NOT NULL CHECK (char_length(body) BETWEEN 1 AND 500),
+    quick_reply_id VARCHAR(50),
+
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    read_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_ride_messages_ride ON ride_messages(ride_id, created_at);
+CREATE INDEX idx_ride_messages_unread ON ride_messages(recipient_id) WHERE read_at IS NULL;
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS ride_messages;
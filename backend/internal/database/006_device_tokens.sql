@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS device_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    token TEXT NOT NULL,
+    platform VARCHAR(20) NOT NULL CHECK (platform IN ('android', 'ios', 'web')),
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A token belongs to the last user who registered it on that device
+CREATE UNIQUE INDEX unique_device_tokens_token ON device_tokens(token);
+CREATE INDEX idx_device_tokens_user ON device_tokens(user_id);
+
+CREATE TRIGGER set_device_tokens_updated_at
+BEFORE UPDATE ON device_tokens
+FOR EACH ROW
+EXECUTE FUNCTION trigger_set_updated_at();
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS device_tokens;
@@ -0,0 +1,24 @@
+ALTER TABLE drivers ADD COLUMN IF NOT EXISTS location_updated_at TIMESTAMP WITH TIME ZONE;
+
+-- Raw location samples, partitioned by day so old data can be dropped cheaply.
+-- Daily partitions are created ahead of time and pruned by the location history maintenance job.
+CREATE TABLE IF NOT EXISTS driver_location_history (
+    driver_user_id UUID NOT NULL,
+    ride_id UUID,
+
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    heading DOUBLE PRECISION,
+    speed DOUBLE PRECISION,
+
+    recorded_at TIMESTAMP WITH TIME ZONE NOT NULL
+) PARTITION BY RANGE (recorded_at);
+
+CREATE INDEX idx_driver_location_history_driver ON driver_location_history(driver_user_id, recorded_at);
+CREATE INDEX idx_driver_location_history_ride ON driver_location_history(ride_id, recorded_at) WHERE ride_id IS NOT NULL;
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS driver_location_history;
+
+ALTER TABLE drivers DROP COLUMN IF EXISTS location_updated_at;
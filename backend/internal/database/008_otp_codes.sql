@@ -0,0 +1,13 @@
+-- Login OTPs while redis is unavailable, only a hash of the code is stored
+CREATE TABLE IF NOT EXISTS otp_codes (
+    email VARCHAR(255) PRIMARY KEY,
+    code_hash TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_otp_codes_expires_at ON otp_codes(expires_at);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS otp_codes;
@@ -0,0 +1,27 @@
+-- Suspicious driver location updates, the fraud score of a driver is the sum of recent flag scores
+CREATE TABLE IF NOT EXISTS driver_location_flags (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    driver_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    reason VARCHAR(30) NOT NULL CHECK (reason IN ('impossible_speed', 'sudden_jump', 'mock_location')),
+    rejected BOOLEAN NOT NULL DEFAULT FALSE,
+    score INTEGER NOT NULL CHECK (score > 0),
+
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    previous_latitude DOUBLE PRECISION,
+    previous_longitude DOUBLE PRECISION,
+    distance_km DOUBLE PRECISION,
+    elapsed_seconds DOUBLE PRECISION,
+    implied_speed_kmh DOUBLE PRECISION,
+
+    recorded_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_driver_location_flags_driver ON driver_location_flags(driver_user_id, created_at);
+CREATE INDEX idx_driver_location_flags_created_at ON driver_location_flags(created_at);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS driver_location_flags;
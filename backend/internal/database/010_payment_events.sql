@@ -0,0 +1,16 @@
+-- Razorpay webhook deliveries, keyed by event id so redelivered events are applied once
+CREATE TABLE IF NOT EXISTS payment_events (
+    id VARCHAR(100) PRIMARY KEY,
+    event_type VARCHAR(50) NOT NULL,
+    payment_id UUID REFERENCES payments(id) ON DELETE SET NULL,
+    payload JSONB NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_payment_events_payment ON payment_events(payment_id);
+CREATE INDEX idx_payment_events_unprocessed ON payment_events(received_at) WHERE processed_at IS NULL;
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS payment_events;
@@ -0,0 +1,29 @@
+-- Adjustments to the fare of a ride, the payable amount is fare - wallet_applied - promo_discount + tip_amount
+ALTER TABLE rides
+    ADD COLUMN IF NOT EXISTS tip_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (tip_amount >= 0),
+    ADD COLUMN IF NOT EXISTS wallet_applied DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (wallet_applied >= 0),
+    ADD COLUMN IF NOT EXISTS promo_discount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (promo_discount >= 0);
+
+-- Keep only the newest outstanding payment of a ride before enforcing one per ride
+UPDATE payments p
+SET status = 'failed'
+WHERE status IN ('created', 'pending', 'authorized')
+AND EXISTS (
+    SELECT 1 FROM payments newer
+    WHERE newer.ride_id = p.ride_id
+    AND newer.status IN ('created', 'pending', 'authorized')
+    AND (newer.created_at, newer.id) > (p.created_at, p.id)
+);
+
+CREATE UNIQUE INDEX unique_outstanding_payment
+ON payments(ride_id)
+WHERE status IN ('created', 'pending', 'authorized');
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS unique_outstanding_payment;
+
+ALTER TABLE rides
+    DROP COLUMN IF EXISTS tip_amount,
+    DROP COLUMN IF EXISTS wallet_applied,
+    DROP COLUMN IF EXISTS promo_discount;
@@ -0,0 +1,47 @@
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_status_check;
+ALTER TABLE payments ADD CONSTRAINT payments_status_check CHECK (status IN (
+    'created', 'pending', 'authorized',
+    'captured', 'failed', 'partially_refunded', 'refunded'
+));
+
+-- Refunds of captured payments, through the gateway or, for cash payments, to the rider's wallet
+-- account in the ledger
+CREATE TABLE IF NOT EXISTS refunds (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    payment_id UUID NOT NULL REFERENCES payments(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL DEFAULT 'INR',
+
+    destination VARCHAR(20) NOT NULL CHECK (destination IN ('gateway', 'wallet')),
+    source VARCHAR(20) NOT NULL CHECK (source IN ('admin', 'policy', 'gateway')),
+    reason VARCHAR(255) NOT NULL,
+    initiated_by UUID REFERENCES users(id),
+
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'processed', 'failed')) DEFAULT 'pending',
+    gateway_refund_id VARCHAR(100) UNIQUE,
+    failure_reason TEXT,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_refunds_payment ON refunds(payment_id);
+CREATE INDEX idx_refunds_pending ON refunds(created_at) WHERE status = 'pending';
+
+CREATE TRIGGER set_refunds_updated_at
+BEFORE UPDATE ON refunds
+FOR EACH ROW
+EXECUTE FUNCTION trigger_set_updated_at();
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS refunds;
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_status_check;
+ALTER TABLE payments ADD CONSTRAINT payments_status_check CHECK (status IN (
+    'created', 'pending', 'authorized',
+    'captured', 'failed', 'refunded'
+));
@@ -0,0 +1,118 @@
+-- Double-entry ledger. Amounts are in paise. A posting credits its account when positive and debits
+-- it when negative, the postings of a journal entry sum to zero.
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    type VARCHAR(30) NOT NULL CHECK (type IN (
+        'rider_wallet', 'driver_earnings', 'platform_commission', 'gateway_clearing'
+    )),
+    -- The rider's user id or the driver id, NULL for the platform accounts
+    owner_id UUID,
+    currency VARCHAR(3) NOT NULL DEFAULT 'INR',
+    -- Sum of the account's postings, kept with them in the same transaction
+    balance BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT ledger_accounts_owner_check CHECK ((owner_id IS NULL) = (type IN ('platform_commission', 'gateway_clearing'))),
+    -- Riders cannot spend more than they have
+    CONSTRAINT ledger_accounts_balance_check CHECK (type <> 'rider_wallet' OR balance >= 0)
+);
+
+CREATE UNIQUE INDEX unique_ledger_account
+ON ledger_accounts(type, COALESCE(owner_id, '00000000-0000-0000-0000-000000000000'::uuid), currency);
+
+CREATE TRIGGER set_ledger_accounts_updated_at
+BEFORE UPDATE ON ledger_accounts
+FOR EACH ROW
+EXECUTE FUNCTION trigger_set_updated_at();
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    type VARCHAR(30) NOT NULL CHECK (type IN ('wallet_topup', 'ride_payment', 'refund')),
+    -- The top-up, payment or refund the entry records, each is posted once
+    reference_id UUID NOT NULL,
+    description VARCHAR(255) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'INR',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT unique_journal_entry_reference UNIQUE (type, reference_id)
+);
+
+CREATE TABLE IF NOT EXISTS ledger_postings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    -- Order of the postings, an account's postings are written one at a time under its row lock
+    seq BIGINT GENERATED ALWAYS AS IDENTITY,
+    entry_id UUID NOT NULL REFERENCES journal_entries(id),
+    account_id UUID NOT NULL REFERENCES ledger_accounts(id),
+    amount BIGINT NOT NULL CHECK (amount <> 0),
+    -- Balance of the account after the posting, for statements
+    balance_after BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_ledger_postings_entry ON ledger_postings(entry_id);
+CREATE INDEX idx_ledger_postings_account ON ledger_postings(account_id, seq);
+
+-- Journal entries and postings are never changed, mistakes are corrected by new entries
+CREATE OR REPLACE FUNCTION trigger_ledger_immutable()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER journal_entries_immutable
+BEFORE UPDATE OR DELETE ON journal_entries
+FOR EACH ROW
+EXECUTE FUNCTION trigger_ledger_immutable();
+
+CREATE TRIGGER ledger_postings_immutable
+BEFORE UPDATE OR DELETE ON ledger_postings
+FOR EACH ROW
+EXECUTE FUNCTION trigger_ledger_immutable();
+
+-- Checked at commit, when all postings of the entry are written
+CREATE OR REPLACE FUNCTION trigger_journal_entry_balanced()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (SELECT SUM(amount) FROM ledger_postings WHERE entry_id = NEW.entry_id) <> 0 THEN
+        RAISE EXCEPTION 'journal entry % does not balance', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_postings_balanced
+AFTER INSERT ON ledger_postings
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW
+EXECUTE FUNCTION trigger_journal_entry_balanced();
+
+-- Wallet top-ups paid through the gateway
+CREATE TABLE IF NOT EXISTS wallet_topups (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL DEFAULT 'INR',
+    status VARCHAR(20) NOT NULL CHECK (status IN ('created', 'captured', 'failed')) DEFAULT 'created',
+    razorpay_order_id VARCHAR(100) UNIQUE,
+    razorpay_payment_id VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_wallet_topups_user ON wallet_topups(user_id, created_at DESC);
+
+CREATE TRIGGER set_wallet_topups_updated_at
+BEFORE UPDATE ON wallet_topups
+FOR EACH ROW
+EXECUTE FUNCTION trigger_set_updated_at();
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS wallet_topups;
+DROP TABLE IF EXISTS ledger_postings;
+DROP TABLE IF EXISTS journal_entries;
+DROP TABLE IF EXISTS ledger_accounts;
+DROP FUNCTION IF EXISTS trigger_journal_entry_balanced();
+DROP FUNCTION IF EXISTS trigger_ledger_immutable();
@@ -0,0 +1,85 @@
+-- Commission on cash rides is charged to the driver, payouts move driver balances to their banks
+ALTER TABLE ledger_accounts DROP CONSTRAINT IF EXISTS ledger_accounts_type_check;
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_type_check CHECK (type IN (
+    'rider_wallet', 'driver_earnings', 'platform_commission', 'gateway_clearing', 'driver_payouts'
+));
+ALTER TABLE ledger_accounts DROP CONSTRAINT IF EXISTS ledger_accounts_owner_check;
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_owner_check
+CHECK ((owner_id IS NULL) = (type IN ('platform_commission', 'gateway_clearing', 'driver_payouts')));
+
+ALTER TABLE journal_entries DROP CONSTRAINT IF EXISTS journal_entries_type_check;
+ALTER TABLE journal_entries ADD CONSTRAINT journal_entries_type_check CHECK (type IN (
+    'wallet_topup', 'ride_payment', 'refund', 'cash_commission', 'payout'
+));
+
+-- How each paid ride, and each refund of one, splits between the driver and the platform. Amounts
+-- are in paise like the ledger, negative for refunds.
+CREATE TABLE IF NOT EXISTS ride_earnings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('ride', 'refund')),
+    -- The payment or the refund, each is recorded once
+    reference_id UUID NOT NULL,
+    driver_id UUID NOT NULL REFERENCES drivers(id),
+    ride_id UUID NOT NULL REFERENCES rides(id),
+    payment_id UUID NOT NULL REFERENCES payments(id),
+    payment_method VARCHAR(20) NOT NULL,
+    fare BIGINT NOT NULL,
+    tip BIGINT NOT NULL DEFAULT 0,
+    commission BIGINT NOT NULL,
+    -- fare + tip - commission, what the driver earns
+    net BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT unique_ride_earning_reference UNIQUE (kind, reference_id),
+    CONSTRAINT ride_earnings_net_check CHECK (net = fare + tip - commission)
+);
+
+CREATE INDEX idx_ride_earnings_driver ON ride_earnings(driver_id, created_at);
+
+-- Driver balances settled together by the payout job
+CREATE TABLE IF NOT EXISTS payout_batches (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    status VARCHAR(10) NOT NULL CHECK (status IN ('open', 'ready')) DEFAULT 'open',
+    payout_count INT NOT NULL DEFAULT 0,
+    total_amount DECIMAL(12,2) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL DEFAULT 'INR',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- One settlement at a time, an interrupted one is resumed
+CREATE UNIQUE INDEX unique_open_payout_batch ON payout_batches(status) WHERE status = 'open';
+CREATE INDEX idx_payout_batches_created ON payout_batches(created_at DESC);
+
+CREATE TABLE IF NOT EXISTS payouts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    batch_id UUID NOT NULL REFERENCES payout_batches(id),
+    driver_id UUID NOT NULL REFERENCES drivers(id),
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL DEFAULT 'INR',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT unique_batch_driver_payout UNIQUE (batch_id, driver_id)
+);
+
+CREATE INDEX idx_payouts_driver ON payouts(driver_id, created_at DESC);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS payouts;
+DROP TABLE IF EXISTS payout_batches;
+DROP TABLE IF EXISTS ride_earnings;
+
+-- Entries of the new types stay in the append-only ledger, the old checks only apply to new rows
+ALTER TABLE journal_entries DROP CONSTRAINT IF EXISTS journal_entries_type_check;
+ALTER TABLE journal_entries ADD CONSTRAINT journal_entries_type_check CHECK (type IN (
+    'wallet_topup', 'ride_payment', 'refund'
+)) NOT VALID;
+
+ALTER TABLE ledger_accounts DROP CONSTRAINT IF EXISTS ledger_accounts_owner_check;
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_owner_check
+CHECK ((owner_id IS NULL) = (type IN ('platform_commission', 'gateway_clearing'))) NOT VALID;
+ALTER TABLE ledger_accounts DROP CONSTRAINT IF EXISTS ledger_accounts_type_check;
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_type_check CHECK (type IN (
+    'rider_wallet', 'driver_earnings', 'platform_commission', 'gateway_clearing'
+)) NOT VALID;
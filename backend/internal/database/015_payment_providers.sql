@@ -0,0 +1,28 @@
+-- Gateway ids are no longer Razorpay's only, provider names the gateway an order was created at
+ALTER TABLE payments RENAME COLUMN razorpay_order_id TO gateway_order_id;
+ALTER TABLE payments RENAME COLUMN razorpay_payment_id TO gateway_payment_id;
+ALTER TABLE payments RENAME COLUMN razorpay_signature TO gateway_signature;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS provider VARCHAR(20);
+ALTER INDEX idx_payments_razorpay_order RENAME TO idx_payments_gateway_order;
+
+UPDATE payments SET provider = 'razorpay' WHERE gateway_order_id IS NOT NULL;
+
+ALTER TABLE wallet_topups RENAME COLUMN razorpay_order_id TO gateway_order_id;
+ALTER TABLE wallet_topups RENAME COLUMN razorpay_payment_id TO gateway_payment_id;
+ALTER TABLE wallet_topups ADD COLUMN IF NOT EXISTS provider VARCHAR(20);
+ALTER TABLE wallet_topups RENAME CONSTRAINT wallet_topups_razorpay_order_id_key TO wallet_topups_gateway_order_id_key;
+
+UPDATE wallet_topups SET provider = 'razorpay' WHERE gateway_order_id IS NOT NULL;
+
+---- create above / drop below ----
+
+ALTER TABLE wallet_topups RENAME CONSTRAINT wallet_topups_gateway_order_id_key TO wallet_topups_razorpay_order_id_key;
+ALTER TABLE wallet_topups DROP COLUMN IF EXISTS provider;
+ALTER TABLE wallet_topups RENAME COLUMN gateway_payment_id TO razorpay_payment_id;
+ALTER TABLE wallet_topups RENAME COLUMN gateway_order_id TO razorpay_order_id;
+
+ALTER INDEX idx_payments_gateway_order RENAME TO idx_payments_razorpay_order;
+ALTER TABLE payments DROP COLUMN IF EXISTS provider;
+ALTER TABLE payments RENAME COLUMN gateway_signature TO razorpay_signature;
+ALTER TABLE payments RENAME COLUMN gateway_payment_id TO razorpay_payment_id;
+ALTER TABLE payments RENAME COLUMN gateway_order_id TO razorpay_order_id;
@@ -0,0 +1,56 @@
+-- When the reconciliation job last compared a payment or top-up with the gateway, the job checks
+-- the ones it saw least recently first. Failed ones are checked too, the gateway may capture an
+-- attempt after we gave up on it.
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS reconciled_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_payments_unsettled ON payments(reconciled_at NULLS FIRST, created_at)
+WHERE status IN ('created', 'pending', 'authorized', 'failed') AND gateway_order_id IS NOT NULL;
+
+ALTER TABLE wallet_topups ADD COLUMN IF NOT EXISTS reconciled_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_wallet_topups_unsettled ON wallet_topups(reconciled_at NULLS FIRST, created_at)
+WHERE status IN ('created', 'failed') AND gateway_order_id IS NOT NULL;
+
+-- Differences between our payments and the gateway's records found by the reconciliation job.
+-- Differences the job repaired are recorded as repaired, the others stay open until an admin
+-- resolves them.
+CREATE TABLE IF NOT EXISTS payment_discrepancies (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    payment_id UUID NOT NULL REFERENCES payments(id),
+    kind VARCHAR(30) NOT NULL CHECK (kind IN (
+        'missed_capture', 'missed_failure', 'uncaptured_authorization',
+        'amount_mismatch', 'duplicate_capture', 'order_not_found', 'provider_unavailable'
+    )),
+    status VARCHAR(10) NOT NULL CHECK (status IN ('open', 'repaired', 'resolved')),
+
+    -- Our side when the difference was found
+    payment_status VARCHAR(20) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    provider VARCHAR(20),
+    gateway_order_id VARCHAR(100),
+
+    -- The gateway's side, when it has a payment
+    gateway_payment_id VARCHAR(100),
+    gateway_status VARCHAR(20),
+    gateway_amount DECIMAL(10,2),
+
+    details TEXT NOT NULL,
+    resolved_by UUID REFERENCES users(id),
+    resolution_note TEXT,
+
+    detected_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP WITH TIME ZONE
+);
+
+-- A difference still open is reported once, later runs only update when it was last seen
+CREATE UNIQUE INDEX unique_open_payment_discrepancy ON payment_discrepancies(payment_id, kind) WHERE status = 'open';
+CREATE INDEX idx_payment_discrepancies_status ON payment_discrepancies(status, detected_at DESC);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS payment_discrepancies;
+DROP INDEX IF EXISTS idx_wallet_topups_unsettled;
+ALTER TABLE wallet_topups DROP COLUMN IF EXISTS reconciled_at;
+DROP INDEX IF EXISTS idx_payments_unsettled;
+ALTER TABLE payments DROP COLUMN IF EXISTS reconciled_at;
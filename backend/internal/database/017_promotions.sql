@@ -0,0 +1,95 @@
+-- Promo codes. The platform funds the discount, drivers earn on the full fare.
+CREATE TABLE IF NOT EXISTS promotions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    -- Stored upper case, riders may type it in any case
+    code VARCHAR(32) NOT NULL,
+    description VARCHAR(255) NOT NULL,
+
+    discount_type VARCHAR(20) NOT NULL CHECK (discount_type IN ('percentage', 'flat')),
+    -- Percent off the fare, or rupees off it
+    discount_value DECIMAL(10,2) NOT NULL CHECK (discount_value > 0),
+    max_discount DECIMAL(10,2) CHECK (max_discount > 0),
+    min_fare DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (min_fare >= 0),
+
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ends_at TIMESTAMP WITH TIME ZONE,
+
+    -- Redemptions allowed in total, NULL for no limit, and per rider
+    usage_limit INT CHECK (usage_limit > 0),
+    per_user_limit INT NOT NULL DEFAULT 1 CHECK (per_user_limit > 0),
+    usage_count INT NOT NULL DEFAULT 0 CHECK (usage_count >= 0),
+
+    -- Empty for every vehicle type
+    vehicle_types TEXT[] NOT NULL DEFAULT '{}',
+    -- Pickups within zone_radius_km of the zone center, no zone for everywhere
+    zone_name VARCHAR(100),
+    zone_lat DOUBLE PRECISION,
+    zone_lng DOUBLE PRECISION,
+    zone_radius_km DECIMAL(10,2) CHECK (zone_radius_km > 0),
+
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by UUID REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT promotions_percentage_check CHECK (discount_type <> 'percentage' OR discount_value <= 100),
+    CONSTRAINT promotions_window_check CHECK (ends_at IS NULL OR ends_at > starts_at),
+    CONSTRAINT promotions_usage_check CHECK (usage_limit IS NULL OR usage_count <= usage_limit),
+    CONSTRAINT promotions_zone_check CHECK (
+        (zone_lat IS NULL AND zone_lng IS NULL AND zone_radius_km IS NULL) OR
+        (zone_lat IS NOT NULL AND zone_lng IS NOT NULL AND zone_radius_km IS NOT NULL)
+    )
+);
+
+CREATE UNIQUE INDEX unique_promotion_code ON promotions(UPPER(code));
+CREATE INDEX idx_promotions_created ON promotions(created_at DESC);
+
+CREATE TRIGGER set_promotions_updated_at
+BEFORE UPDATE ON promotions
+FOR EACH ROW
+EXECUTE FUNCTION trigger_set_updated_at();
+
+-- A promo applied to a ride, written with the ride. Cancelling the ride releases it.
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    promotion_id UUID NOT NULL REFERENCES promotions(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    ride_id UUID NOT NULL REFERENCES rides(id),
+    discount DECIMAL(10,2) NOT NULL CHECK (discount > 0),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('redeemed', 'released')) DEFAULT 'redeemed',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    released_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT unique_ride_redemption UNIQUE (ride_id)
+);
+
+CREATE INDEX idx_promo_redemptions_user ON promo_redemptions(promotion_id, user_id) WHERE status = 'redeemed';
+
+-- The part of the fare the platform paid for with a promo, in paise like the other amounts
+ALTER TABLE ride_earnings ADD COLUMN IF NOT EXISTS promo BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE ledger_accounts DROP CONSTRAINT IF EXISTS ledger_accounts_type_check;
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_type_check CHECK (type IN (
+    'rider_wallet', 'driver_earnings', 'platform_commission', 'gateway_clearing', 'driver_payouts',
+    'platform_promotions'
+));
+ALTER TABLE ledger_accounts DROP CONSTRAINT IF EXISTS ledger_accounts_owner_check;
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_owner_check CHECK ((owner_id IS NULL) = (type IN (
+    'platform_commission', 'gateway_clearing', 'driver_payouts', 'platform_promotions'
+)));
+
+---- create above / drop below ----
+
+-- Accounts of the new type stay in the append-only ledger, the old checks only apply to new rows
+ALTER TABLE ledger_accounts DROP CONSTRAINT IF EXISTS ledger_accounts_owner_check;
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_owner_check
+CHECK ((owner_id IS NULL) = (type IN ('platform_commission', 'gateway_clearing', 'driver_payouts'))) NOT VALID;
+ALTER TABLE ledger_accounts DROP CONSTRAINT IF EXISTS ledger_accounts_type_check;
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_type_check CHECK (type IN (
+    'rider_wallet', 'driver_earnings', 'platform_commission', 'gateway_clearing', 'driver_payouts'
+)) NOT VALID;
+
+ALTER TABLE ride_earnings DROP COLUMN IF EXISTS promo;
+
+DROP TABLE IF EXISTS promo_redemptions;
+DROP TABLE IF EXISTS promotions;
@@ -0,0 +1,103 @@
+-- Referral codes are 8 characters from an alphabet without letters and digits that are easy to
+-- mix up. The random bytes come from a v4 uuid, byte 6 is skipped as it carries the version.
+CREATE OR REPLACE FUNCTION generate_referral_code()
+RETURNS VARCHAR AS $$
+DECLARE
+    alphabet CONSTANT TEXT := 'ABCDEFGHJKLMNPQRSTUVWXYZ23456789';
+    raw BYTEA := decode(REPLACE(uuid_generate_v4()::text, '-', ''), 'hex');
+    code TEXT := '';
+    i INT;
+BEGIN
+    FOREACH i IN ARRAY ARRAY[0, 1, 2, 3, 4, 5, 7, 8] LOOP
+        code := code || SUBSTRING(alphabet FROM get_byte(raw, i) % 32 + 1 FOR 1);
+    END LOOP;
+    RETURN code;
+END;
+$$ LANGUAGE plpgsql VOLATILE;
+
+-- Every user gets a code to refer riders with, existing users draw one like new users do
+ALTER TABLE users ADD COLUMN IF NOT EXISTS referral_code VARCHAR(16);
+
+DO $$
+DECLARE
+    u RECORD;
+    code VARCHAR;
+BEGIN
+    FOR u IN SELECT id FROM users WHERE referral_code IS NULL LOOP
+        LOOP
+            code := generate_referral_code();
+            EXIT WHEN NOT EXISTS (SELECT 1 FROM users WHERE referral_code = code);
+        END LOOP;
+        UPDATE users SET referral_code = code WHERE id = u.id;
+    END LOOP;
+END;
+$$;
+
+ALTER TABLE users ALTER COLUMN referral_code SET NOT NULL;
+ALTER TABLE users ADD CONSTRAINT unique_users_referral_code UNIQUE (referral_code);
+
+-- The install id the app signed up from, to tell referrals between accounts on one device
+ALTER TABLE users ADD COLUMN IF NOT EXISTS signup_device_id VARCHAR(128);
+CREATE INDEX idx_users_phone ON users(phone) WHERE phone IS NOT NULL;
+
+-- A rider who signed up with another user's code. Both are rewarded once the rider's first ride
+-- is paid, unless the fraud checks rejected the referral.
+CREATE TABLE IF NOT EXISTS referrals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    referrer_id UUID NOT NULL REFERENCES users(id),
+    referee_id UUID NOT NULL REFERENCES users(id),
+    code VARCHAR(16) NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'rewarded', 'rejected')) DEFAULT 'pending',
+    reject_reason VARCHAR(50),
+
+    -- The paid ride that earned the reward, and the reward as it was configured then
+    ride_id UUID REFERENCES rides(id),
+    reward_type VARCHAR(20) CHECK (reward_type IN ('wallet', 'promo')),
+    referrer_reward DECIMAL(10,2) NOT NULL DEFAULT 0,
+    referee_reward DECIMAL(10,2) NOT NULL DEFAULT 0,
+    -- The codes granted when the reward is a promo
+    referrer_promo_code VARCHAR(32),
+    referee_promo_code VARCHAR(32),
+    rewarded_at TIMESTAMP WITH TIME ZONE,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT unique_referee UNIQUE (referee_id),
+    CONSTRAINT referrals_self_check CHECK (referrer_id <> referee_id),
+    CONSTRAINT referrals_reject_check CHECK ((status = 'rejected') = (reject_reason IS NOT NULL))
+);
+
+CREATE INDEX idx_referrals_referrer ON referrals(referrer_id, created_at DESC);
+CREATE INDEX idx_referrals_pending ON referrals(created_at) WHERE status = 'pending';
+
+CREATE TRIGGER set_referrals_updated_at
+BEFORE UPDATE ON referrals
+FOR EACH ROW
+EXECUTE FUNCTION trigger_set_updated_at();
+
+-- Promo codes granted to one user, as referral rewards. NULL for codes anyone can use.
+ALTER TABLE promotions ADD COLUMN IF NOT EXISTS granted_to UUID REFERENCES users(id);
+
+ALTER TABLE journal_entries DROP CONSTRAINT IF EXISTS journal_entries_type_check;
+ALTER TABLE journal_entries ADD CONSTRAINT journal_entries_type_check CHECK (type IN (
+    'wallet_topup', 'ride_payment', 'refund', 'cash_commission', 'payout', 'referral_reward'
+));
+
+---- create above / drop below ----
+
+-- Entries of the new type stay in the append-only ledger, the old check only applies to new rows
+ALTER TABLE journal_entries DROP CONSTRAINT IF EXISTS journal_entries_type_check;
+ALTER TABLE journal_entries ADD CONSTRAINT journal_entries_type_check CHECK (type IN (
+    'wallet_topup', 'ride_payment', 'refund', 'cash_commission', 'payout'
+)) NOT VALID;
+
+ALTER TABLE promotions DROP COLUMN IF EXISTS granted_to;
+
+DROP TABLE IF EXISTS referrals;
+
+DROP INDEX IF EXISTS idx_users_phone;
+ALTER TABLE users DROP COLUMN IF EXISTS signup_device_id;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS unique_users_referral_code;
+ALTER TABLE users DROP COLUMN IF EXISTS referral_code;
+DROP FUNCTION IF EXISTS generate_referral_code();
@@ -108,6 +108,22 @@ func (h *AuthHandler) Me(c echo.Context) error {
 	})
 }
 
+// WSTicket issues a single-use ticket for opening the websocket
+func (h *AuthHandler) WSTicket(c echo.Context) error {
+	claims, ok := c.Get("user").(*service.JWTClaims)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Unauthorized",
+		})
+	}
+
+	resp, err := h.authService.IssueWSTicket(c.Request().Context(), claims)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
 func (h *AuthHandler) SendOTP(c echo.Context) error {
 	return Handle(
 		h.Handler,
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+)
+
+type ChatHandler struct {
+	Handler
+	chatService *service.ChatService
+}
+
+func NewChatHandler(s *server.Server, chatService *service.ChatService) *ChatHandler {
+	return &ChatHandler{
+		Handler:     NewHandler(s),
+		chatService: chatService,
+	}
+}
+
+// ListMessages returns the chat history of a ride, newest first
+func (h *ChatHandler) ListMessages(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.ListRideMessagesRequest) ([]*model.RideMessage, error) {
+			userID, ok := c.Get("user_id").(string)
+			if !ok {
+				return nil, echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+			}
+
+			var before *time.Time
+			if req.Before != "" {
+				t, _ := time.Parse(time.RFC3339, req.Before)
+				before = &t
+			}
+
+			return h.chatService.ListMessages(c.Request().Context(), userID, req.RideID, before, req.Limit)
+		},
+		http.StatusOK,
+		&model.ListRideMessagesRequest{},
+	)(c)
+}
+
+// SendMessage sends a chat message to the other party of the ride
+func (h *ChatHandler) SendMessage(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.SendRideMessageRequest) (*model.RideMessage, error) {
+			userID, ok := c.Get("user_id").(string)
+			if !ok {
+				return nil, echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+			}
+
+			rideID := c.Param("id")
+			if rideID == "" {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, "Ride ID required")
+			}
+
+			return h.chatService.SendMessage(c.Request().Context(), userID, rideID, req)
+		},
+		http.StatusCreated,
+		&model.SendRideMessageRequest{},
+	)(c)
+}
+
+// MarkMessages records delivery or read receipts for received messages
+func (h *ChatHandler) MarkMessages(c echo.Context) error {
+	return HandleNoContent(
+		h.Handler,
+		func(c echo.Context, req *model.RideMessageReceiptRequest) error {
+			userID, ok := c.Get("user_id").(string)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+			}
+
+			rideID := c.Param("id")
+			if rideID == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, "Ride ID required")
+			}
+
+			return h.chatService.MarkMessages(c.Request().Context(), userID, rideID, req)
+		},
+		http.StatusNoContent,
+		&model.RideMessageReceiptRequest{},
+	)(c)
+}
+
+// QuickReplies lists the canned messages offered to the caller's role
+func (h *ChatHandler) QuickReplies(c echo.Context) error {
+	role, _ := c.Get("role").(string)
+	return c.JSON(http.StatusOK, h.chatService.QuickReplies(model.UserRole(role)))
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+)
+
+type DeviceHandler struct {
+	Handler
+	notificationService *service.NotificationService
+}
+
+func NewDeviceHandler(s *server.Server, notificationService *service.NotificationService) *DeviceHandler {
+	return &DeviceHandler{
+		Handler:             NewHandler(s),
+		notificationService: notificationService,
+	}
+}
+
+// RegisterDevice registers a push notification token for the caller
+func (h *DeviceHandler) RegisterDevice(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.RegisterDeviceRequest) (*model.DeviceToken, error) {
+			userID, ok := c.Get("user_id").(string)
+			if !ok {
+				return nil, echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+			}
+
+			return h.notificationService.RegisterDevice(c.Request().Context(), userID, req)
+		},
+		http.StatusCreated,
+		&model.RegisterDeviceRequest{},
+	)(c)
+}
+
+// UnregisterDevice removes a push notification token of the caller
+func (h *DeviceHandler) UnregisterDevice(c echo.Context) error {
+	return HandleNoContent(
+		h.Handler,
+		func(c echo.Context, req *model.UnregisterDeviceRequest) error {
+			userID, ok := c.Get("user_id").(string)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+			}
+
+			return h.notificationService.UnregisterDevice(c.Request().Context(), userID, req)
+		},
+		http.StatusNoContent,
+		&model.UnregisterDeviceRequest{},
+	)(c)
+}
+
+// ListDevices lists the push notification tokens of the caller
+func (h *DeviceHandler) ListDevices(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	devices, err := h.notificationService.ListDevices(c.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, devices)
+}
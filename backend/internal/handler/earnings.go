@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+)
+
+type EarningsHandler struct {
+	Handler
+	earningsService *service.EarningsService
+}
+
+func NewEarningsHandler(s *server.Server, earningsService *service.EarningsService) *EarningsHandler {
+	return &EarningsHandler{
+		Handler:         NewHandler(s),
+		earningsService: earningsService,
+	}
+}
+
+// GetDailyEarnings returns the calling driver's earnings of a day
+func (h *EarningsHandler) GetDailyEarnings(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.EarningsRequest) (*model.EarningsSummary, error) {
+			userID, ok := c.Get("user_id").(string)
+			if !ok {
+				return nil, echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+			}
+			return h.earningsService.GetDailyEarnings(c.Request().Context(), userID, req)
+		},
+		http.StatusOK,
+		&model.EarningsRequest{},
+	)(c)
+}
+
+// GetWeeklyEarnings returns the calling driver's earnings of a week, day by day
+func (h *EarningsHandler) GetWeeklyEarnings(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.EarningsRequest) (*model.EarningsSummary, error) {
+			userID, ok := c.Get("user_id").(string)
+			if !ok {
+				return nil, echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+			}
+			return h.earningsService.GetWeeklyEarnings(c.Request().Context(), userID, req)
+		},
+		http.StatusOK,
+		&model.EarningsRequest{},
+	)(c)
+}
+
+// ListPayoutBatches returns the latest driver payout batches
+func (h *EarningsHandler) ListPayoutBatches(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.ListPayoutBatchesRequest) ([]*model.PayoutBatch, error) {
+			return h.earningsService.ListPayoutBatches(c.Request().Context(), req)
+		},
+		http.StatusOK,
+		&model.ListPayoutBatchesRequest{},
+	)(c)
+}
+
+// GetPayoutFile downloads the payouts of a batch as CSV
+func (h *EarningsHandler) GetPayoutFile(c echo.Context) error {
+	return Handlefile(
+		h.Handler,
+		func(c echo.Context, req *model.PayoutFileRequest) ([]byte, error) {
+			return h.earningsService.PayoutFile(c.Request().Context(), req.BatchID)
+		},
+		http.StatusOK,
+		&model.PayoutFileRequest{},
+		"payouts.csv",
+		"text/csv",
+	)(c)
+}
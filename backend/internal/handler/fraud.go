@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+)
+
+type FraudHandler struct {
+	Handler
+	fraudService *service.FraudService
+}
+
+func NewFraudHandler(s *server.Server, fraudService *service.FraudService) *FraudHandler {
+	return &FraudHandler{
+		Handler:      NewHandler(s),
+		fraudService: fraudService,
+	}
+}
+
+// ListScores returns the drivers with the highest location fraud score
+func (h *FraudHandler) ListScores(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.ListFraudScoresRequest) ([]model.DriverFraudScore, error) {
+			return h.fraudService.TopScores(c.Request().Context(), req)
+		},
+		http.StatusOK,
+		&model.ListFraudScoresRequest{},
+	)(c)
+}
+
+// ListFlags returns the suspicious location updates of a driver
+func (h *FraudHandler) ListFlags(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.ListLocationFlagsRequest) ([]*model.LocationFlag, error) {
+			return h.fraudService.ListFlags(c.Request().Context(), req)
+		},
+		http.StatusOK,
+		&model.ListLocationFlagsRequest{},
+	)(c)
+}
@@ -6,25 +6,39 @@ import (
 )
 
 type Handlers struct {
-	Health   *HealthHandler
-	OpenAPI  *OpenAPIHandler
-	Auth     *AuthHandler
-	Driver   *DriverHandler
-	Location *LocationHandler
-	Ride     *RideHandler
-	Payment  *PaymentHandler
-	Map      *MapHandler
+	Health    *HealthHandler
+	OpenAPI   *OpenAPIHandler
+	Auth      *AuthHandler
+	Driver    *DriverHandler
+	Location  *LocationHandler
+	Ride      *RideHandler
+	Payment   *PaymentHandler
+	Map       *MapHandler
+	Chat      *ChatHandler
+	Device    *DeviceHandler
+	Fraud     *FraudHandler
+	Heatmap   *HeatmapHandler
+	Earnings  *EarningsHandler
+	Promotion *PromotionHandler
+	Referral  *ReferralHandler
 }
 
 func NewHandlers(s *server.Server, services *service.Services) *Handlers {
 	return &Handlers{
-		Health:   NewHealthHandler(s),
-		OpenAPI:  NewOpenAPIHandler(s),
-		Auth:     NewAuthHandler(s, services.Auth),
-		Driver:   NewDriverHandler(s, services.Driver),
-		Location: NewLocationHandler(s, services.Location),
-		Ride:     NewRideHandler(s, services.Ride),
-		Payment:  NewPaymentHandler(services.Payment),
-		Map:      NewMapHandler(s),
+		Health:    NewHealthHandler(s),
+		OpenAPI:   NewOpenAPIHandler(s),
+		Auth:      NewAuthHandler(s, services.Auth),
+		Driver:    NewDriverHandler(s, services.Driver),
+		Location:  NewLocationHandler(s, services.Location),
+		Ride:      NewRideHandler(s, services.Ride),
+		Payment:   NewPaymentHandler(services.Payment),
+		Map:       NewMapHandler(s),
+		Chat:      NewChatHandler(s, services.Chat),
+		Device:    NewDeviceHandler(s, services.Notification),
+		Fraud:     NewFraudHandler(s, services.Fraud),
+		Heatmap:   NewHeatmapHandler(s, services.Heatmap),
+		Earnings:  NewEarningsHandler(s, services.Earnings),
+		Promotion: NewPromotionHandler(s, services.Promotion),
+		Referral:  NewReferralHandler(s, services.Referral),
 	}
 }
@@ -11,6 +11,14 @@ import (
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
 )
 
+// degradedFeatures lists what runs on the PostgreSQL fallback while redis is unavailable
+var degradedFeatures = []string{
+	"nearby_drivers_from_database",
+	"driver_availability_from_database",
+	"otp_from_database",
+	"no_live_location_streaming",
+}
+
 type HealthHandler struct {
 	Handler
 }
@@ -33,6 +41,8 @@ func (h *HealthHandler) CheckHealth(c echo.Context) error {
 	}
 	checks := response["checks"].(map[string]interface{})
 	isHealthy := true
+	// Without redis the server keeps serving from PostgreSQL, which is reported as degraded
+	isDegraded := false
 
 	// check database Connectivity
 
@@ -80,6 +90,7 @@ func (h *HealthHandler) CheckHealth(c echo.Context) error {
 				"response_time": time.Since(redisStart).String(),
 				"error":         cmd.Err().Error(),
 			}
+			isDegraded = true
 
 			logger.Error().Err(cmd.Err()).Dur("response_time", time.Since(redisStart)).Msg("Redis health check Failed")
 			if h.server.LoggerService != nil && h.server.LoggerService.GetApplication() != nil {
@@ -102,6 +113,11 @@ func (h *HealthHandler) CheckHealth(c echo.Context) error {
 
 		}
 
+	} else {
+		checks["redis"] = map[string]interface{}{
+			"status": "unavailable",
+		}
+		isDegraded = true
 	}
 
 	if !isHealthy {
@@ -120,7 +136,13 @@ func (h *HealthHandler) CheckHealth(c echo.Context) error {
 		return c.JSON(http.StatusServiceUnavailable, response)
 
 	}
-	logger.Info().Dur("Total_HealthCheck_duration", time.Since(start)).Msg("Health check passed")
+	if isDegraded {
+		response["status"] = "degraded"
+		response["degraded"] = degradedFeatures
+		logger.Warn().Dur("Total_HealthCheck_duration", time.Since(start)).Msg("Health check passed in degraded mode")
+	} else {
+		logger.Info().Dur("Total_HealthCheck_duration", time.Since(start)).Msg("Health check passed")
+	}
 	err := c.JSON(http.StatusOK, response)
 
 	if err != nil {
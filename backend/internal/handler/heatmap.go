@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+)
+
+type HeatmapHandler struct {
+	Handler
+	heatmapService *service.HeatmapService
+}
+
+func NewHeatmapHandler(s *server.Server, heatmapService *service.HeatmapService) *HeatmapHandler {
+	return &HeatmapHandler{
+		Handler:        NewHandler(s),
+		heatmapService: heatmapService,
+	}
+}
+
+// GetHeatmap returns ride demand around a point as GeoJSON grid cells
+func (h *HeatmapHandler) GetHeatmap(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.HeatmapRequest) (*model.HeatmapResponse, error) {
+			return h.heatmapService.GetHeatmap(c.Request().Context(), req)
+		},
+		http.StatusOK,
+		&model.HeatmapRequest{},
+	)(c)
+}
@@ -45,12 +45,33 @@ func (h *LocationHandler) UpdateLocation(c echo.Context) error {
 	)(c)
 }
 
+// UploadLocationBatch handles fixes buffered by the driver app while offline
+func (h *LocationHandler) UploadLocationBatch(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.LocationBatchRequest) (*model.LocationBatchResponse, error) {
+			driverID, ok := c.Get("user_id").(string)
+			if !ok {
+				return nil, echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+			}
+
+			return h.locationService.UploadLocationBatch(c.Request().Context(), driverID, req)
+		},
+		http.StatusOK,
+		&model.LocationBatchRequest{},
+	)(c)
+}
+
 // FindNearbyDrivers finds drivers near a location
 func (h *LocationHandler) FindNearbyDrivers(c echo.Context) error {
 	return Handle(
 		h.Handler,
 		func(c echo.Context, req *model.NearbyDriversRequest) (*map[string]interface{}, error) {
-			drivers, err := h.locationService.FindNearbyDrivers(c.Request().Context(), req)
+			// Admins see exact positions, everyone else a jittered one
+			role, _ := c.Get("role").(string)
+			exact := role == string(model.RoleAdmin)
+
+			drivers, err := h.locationService.FindNearbyDrivers(c.Request().Context(), req, exact)
 			if err != nil {
 				return nil, err
 			}
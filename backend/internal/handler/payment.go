@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"io"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -54,9 +55,9 @@ func (h *PaymentHandler) CreatePaymentOrder(c echo.Context) error {
 	return c.JSON(http.StatusOK, response)
 }
 
-// VerifyPayment verifies a Razorpay payment
+// VerifyPayment verifies an online payment
 // @Summary Verify payment
-// @Description Verify a Razorpay payment signature
+// @Description Verify the checkout signature of an online payment with its gateway
 // @Tags payments
 // @Accept json
 // @Produce json
@@ -79,7 +80,8 @@ func (h *PaymentHandler) VerifyPayment(c echo.Context) error {
 		return err
 	}
 
-	if err := h.paymentService.VerifyPayment(ctx, &req); err != nil {
+	userID := c.Get(middleware.UserIDKey).(string)
+	if err := h.paymentService.VerifyPayment(ctx, userID, &req); err != nil {
 		return err
 	}
 
@@ -181,3 +183,277 @@ func (h *PaymentHandler) GetPaymentByRideID(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, payment)
 }
+
+// Webhook payloads are a few KB, anything much larger is not from the gateway
+const maxWebhookBodyBytes = 1 << 20
+
+// Webhook receives payment events of the configured gateway
+// @Summary Payment gateway webhook
+// @Description Apply a webhook event of the configured gateway, authenticated by its signature header (X-Razorpay-Signature for Razorpay, X-Webhook-Signature for the fake provider)
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /api/v1/payments/webhook [post]
+func (h *PaymentHandler) Webhook(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	// The signature covers the exact bytes, so the body is read raw instead of bound
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxWebhookBodyBytes))
+	if err != nil {
+		return errs.NewBadRequest("invalid request body")
+	}
+
+	if err := h.paymentService.HandleWebhook(ctx, body, c.Request().Header); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// CreateRefund refunds all or part of a payment
+// @Summary Refund a payment
+// @Description Refund a captured payment, without an amount everything not yet refunded. Cash payments are refunded to the rider's wallet.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param id path string true "Payment ID"
+// @Param request body model.CreateRefundRequest true "Refund request"
+// @Success 201 {object} model.Refund
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/payments/{id}/refunds [post]
+func (h *PaymentHandler) CreateRefund(c echo.Context) error {
+	ctx := c.Request().Context()
+	adminID := c.Get(middleware.UserIDKey).(string)
+
+	var req model.CreateRefundRequest
+	if err := c.Bind(&req); err != nil {
+		return errs.NewBadRequest("invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	refund, err := h.paymentService.CreateRefund(ctx, adminID, c.Param("id"), &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, refund)
+}
+
+// ListRefunds lists the refunds of a payment
+// @Summary List payment refunds
+// @Description List the refunds of a payment, newest first
+// @Tags payments
+// @Produce json
+// @Param id path string true "Payment ID"
+// @Success 200 {array} model.Refund
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/payments/{id}/refunds [get]
+func (h *PaymentHandler) ListRefunds(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	refunds, err := h.paymentService.ListRefunds(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, refunds)
+}
+
+// ListDiscrepancies lists the payment reconciliation discrepancies
+// @Summary List payment discrepancies
+// @Description List the differences between payments and the gateway's records found by the reconciliation job, most recent first. Open ones need review, repaired ones were fixed by the job.
+// @Tags payments
+// @Produce json
+// @Param status query string false "open (default), repaired or resolved"
+// @Param limit query int false "At most 200, default 50"
+// @Success 200 {array} model.PaymentDiscrepancy
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/payments/discrepancies [get]
+func (h *PaymentHandler) ListDiscrepancies(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req model.ListPaymentDiscrepanciesRequest
+	if err := c.Bind(&req); err != nil {
+		return errs.NewBadRequest("invalid query parameters")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	discrepancies, err := h.paymentService.ListDiscrepancies(ctx, &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, discrepancies)
+}
+
+// ResolveDiscrepancy closes an open payment discrepancy
+// @Summary Resolve a payment discrepancy
+// @Description Close an open reconciliation discrepancy with a note on what was done about it
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param id path string true "Discrepancy ID"
+// @Param request body model.ResolvePaymentDiscrepancyRequest true "Resolution"
+// @Success 200 {object} model.PaymentDiscrepancy
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/payments/discrepancies/{id}/resolve [post]
+func (h *PaymentHandler) ResolveDiscrepancy(c echo.Context) error {
+	ctx := c.Request().Context()
+	adminID := c.Get(middleware.UserIDKey).(string)
+
+	var req model.ResolvePaymentDiscrepancyRequest
+	if err := c.Bind(&req); err != nil {
+		return errs.NewBadRequest("invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	discrepancy, err := h.paymentService.ResolveDiscrepancy(ctx, adminID, &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, discrepancy)
+}
+
+// GetWallet returns the rider's wallet
+// @Summary Get wallet
+// @Description Get the wallet balance and latest transactions of the current user
+// @Tags payments
+// @Produce json
+// @Success 200 {object} model.Wallet
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/payments/wallet [get]
+func (h *PaymentHandler) GetWallet(c echo.Context) error {
+	ctx := c.Request().Context()
+	userID := c.Get(middleware.UserIDKey).(string)
+
+	wallet, err := h.paymentService.GetWallet(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, wallet)
+}
+
+// GetWalletStatement returns the wallet statement of a period
+// @Summary Get wallet statement
+// @Description Get the wallet transactions of a period, oldest first, with opening and closing balances. Defaults to the last 30 days, at most 92 days.
+// @Tags payments
+// @Produce json
+// @Param from query string false "First day (YYYY-MM-DD)"
+// @Param to query string false "Last day (YYYY-MM-DD)"
+// @Success 200 {object} model.WalletStatement
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/payments/wallet/statement [get]
+func (h *PaymentHandler) GetWalletStatement(c echo.Context) error {
+	ctx := c.Request().Context()
+	userID := c.Get(middleware.UserIDKey).(string)
+
+	var req model.WalletStatementRequest
+	if err := c.Bind(&req); err != nil {
+		return errs.NewBadRequest("invalid query parameters")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	statement, err := h.paymentService.GetWalletStatement(ctx, userID, &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, statement)
+}
+
+// CreateWalletTopup starts a wallet top-up
+// @Summary Top up wallet
+// @Description Create a gateway order adding money to the current user's wallet
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param request body model.CreateWalletTopupRequest true "Top-up request"
+// @Success 201 {object} model.CreateWalletTopupResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/payments/wallet/topup [post]
+func (h *PaymentHandler) CreateWalletTopup(c echo.Context) error {
+	ctx := c.Request().Context()
+	userID := c.Get(middleware.UserIDKey).(string)
+
+	var req model.CreateWalletTopupRequest
+	if err := c.Bind(&req); err != nil {
+		return errs.NewBadRequest("invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	response, err := h.paymentService.CreateWalletTopup(ctx, userID, &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, response)
+}
+
+// VerifyWalletTopup confirms a wallet top-up
+// @Summary Verify wallet top-up
+// @Description Verify the gateway checkout of a top-up and credit the wallet
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param request body model.VerifyWalletTopupRequest true "Top-up verification request"
+// @Success 200 {object} model.Wallet
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/payments/wallet/topup/verify [post]
+func (h *PaymentHandler) VerifyWalletTopup(c echo.Context) error {
+	ctx := c.Request().Context()
+	userID := c.Get(middleware.UserIDKey).(string)
+
+	var req model.VerifyWalletTopupRequest
+	if err := c.Bind(&req); err != nil {
+		return errs.NewBadRequest("invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	wallet, err := h.paymentService.VerifyWalletTopup(ctx, userID, &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, wallet)
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+)
+
+type PromotionHandler struct {
+	Handler
+	promotionService *service.PromotionService
+}
+
+func NewPromotionHandler(s *server.Server, promotionService *service.PromotionService) *PromotionHandler {
+	return &PromotionHandler{
+		Handler:          NewHandler(s),
+		promotionService: promotionService,
+	}
+}
+
+// QuoteFare estimates the fare of a trip with the rider's promo code applied
+func (h *PromotionHandler) QuoteFare(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.FareQuoteRequest) (*model.FareQuote, error) {
+			userID, ok := c.Get("user_id").(string)
+			if !ok {
+				return nil, echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+			}
+			return h.promotionService.Quote(c.Request().Context(), userID, req)
+		},
+		http.StatusOK,
+		&model.FareQuoteRequest{},
+	)(c)
+}
+
+// CreatePromotion adds a promo code
+func (h *PromotionHandler) CreatePromotion(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.PromotionRequest) (*model.Promotion, error) {
+			adminID, ok := c.Get("user_id").(string)
+			if !ok {
+				return nil, echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+			}
+			return h.promotionService.CreatePromotion(c.Request().Context(), adminID, req)
+		},
+		http.StatusCreated,
+		&model.PromotionRequest{},
+	)(c)
+}
+
+// ListPromotions returns the latest promotions
+func (h *PromotionHandler) ListPromotions(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.ListPromotionsRequest) ([]*model.Promotion, error) {
+			return h.promotionService.ListPromotions(c.Request().Context(), req)
+		},
+		http.StatusOK,
+		&model.ListPromotionsRequest{},
+	)(c)
+}
+
+// GetPromotion returns a promotion with its usage count
+func (h *PromotionHandler) GetPromotion(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.PromotionIDRequest) (*model.Promotion, error) {
+			return h.promotionService.GetPromotion(c.Request().Context(), req.ID)
+		},
+		http.StatusOK,
+		&model.PromotionIDRequest{},
+	)(c)
+}
+
+// UpdatePromotion replaces the terms of a promotion
+func (h *PromotionHandler) UpdatePromotion(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, req *model.UpdatePromotionRequest) (*model.Promotion, error) {
+			return h.promotionService.UpdatePromotion(c.Request().Context(), req)
+		},
+		http.StatusOK,
+		&model.UpdatePromotionRequest{},
+	)(c)
+}
+
+// DeletePromotion deactivates a promotion
+func (h *PromotionHandler) DeletePromotion(c echo.Context) error {
+	return HandleNoContent(
+		h.Handler,
+		func(c echo.Context, req *model.PromotionIDRequest) error {
+			return h.promotionService.DeletePromotion(c.Request().Context(), req.ID)
+		},
+		http.StatusNoContent,
+		&model.PromotionIDRequest{},
+	)(c)
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/middleware"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+)
+
+type ReferralHandler struct {
+	Handler
+	referralService *service.ReferralService
+}
+
+func NewReferralHandler(s *server.Server, referralService *service.ReferralService) *ReferralHandler {
+	return &ReferralHandler{
+		Handler:         NewHandler(s),
+		referralService: referralService,
+	}
+}
+
+// GetReferralStatus returns the user's referral code and referrals
+// @Summary Get referral status
+// @Description Get the referral code of the current user, the referrals they made with their status and reward, their own referral and the current offer
+// @Tags referrals
+// @Produce json
+// @Success 200 {object} model.ReferralSummary
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/referrals [get]
+func (h *ReferralHandler) GetReferralStatus(c echo.Context) error {
+	userID := c.Get(middleware.UserIDKey).(string)
+
+	summary, err := h.referralService.Status(c.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, summary)
+}
@@ -0,0 +1,309 @@
+package gateway
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DeclinedSuffix marks the fake checkout's payments that fail
+const DeclinedSuffix = "_declined"
+
+// Fake is an in-memory payment provider for local development and tests. It is deterministic:
+// order and refund ids derive from their receipts, and the payment of an order is pay_<id> for the
+// order order_<id>, captured as soon as the gateway sees it, or pay_<id>_declined, which fails.
+// Checkout signatures and webhooks are signed like Razorpay's, with the fake's secrets.
+type Fake struct {
+	keySecret     string
+	webhookSecret string
+
+	mu       sync.Mutex
+	seq      int
+	orders   map[string]*Order
+	payments map[string]*Payment
+	refunds  map[string]*Refund
+	// orderPayments lists the payment ids of an order in attempt order
+	orderPayments map[string][]string
+	// paymentRefunds lists the refund ids of a payment in creation order
+	paymentRefunds map[string][]string
+}
+
+func NewFake(keySecret, webhookSecret string) *Fake {
+	return &Fake{
+		keySecret:      keySecret,
+		webhookSecret:  webhookSecret,
+		orders:         make(map[string]*Order),
+		payments:       make(map[string]*Payment),
+		refunds:        make(map[string]*Refund),
+		orderPayments:  make(map[string][]string),
+		paymentRefunds: make(map[string][]string),
+	}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) KeyID() string {
+	return "fake_key"
+}
+
+// fakeID derives an id from a receipt, requests without one are numbered
+func (f *Fake) fakeID(prefix, receipt string) string {
+	if receipt == "" {
+		f.seq++
+		receipt = fmt.Sprintf("seq-%d", f.seq)
+	}
+	sum := sha256.Sum256([]byte(receipt))
+	return prefix + hex.EncodeToString(sum[:7])
+}
+
+// CreateOrder returns the order of a receipt, creating it on first use
+func (f *Fake) CreateOrder(ctx context.Context, req OrderRequest) (*Order, error) {
+	if req.Amount <= 0 {
+		return nil, &RejectedError{Reason: "order amount must be positive"}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.fakeID("order_", req.Receipt)
+	order, ok := f.orders[id]
+	if !ok {
+		order = &Order{ID: id, Amount: req.Amount, Currency: req.Currency, Receipt: req.Receipt}
+		f.orders[id] = order
+	}
+	copied := *order
+	return &copied, nil
+}
+
+func (f *Fake) FetchOrder(ctx context.Context, orderID string) (*Order, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	order, ok := f.orders[orderID]
+	if !ok {
+		return nil, fmt.Errorf("%w: order %s", ErrNotFound, orderID)
+	}
+	copied := *order
+	return &copied, nil
+}
+
+func (f *Fake) FetchOrderPayments(ctx context.Context, orderID string) ([]Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.orders[orderID]; !ok {
+		return nil, fmt.Errorf("%w: order %s", ErrNotFound, orderID)
+	}
+	payments := make([]Payment, 0, len(f.orderPayments[orderID]))
+	for _, id := range f.orderPayments[orderID] {
+		payments = append(payments, *f.payments[id])
+	}
+	return payments, nil
+}
+
+// Checkout simulates the checkout of an order, the gateway sees the payment at once. It returns
+// the payment id and the signature the checkout hands to the app.
+func (f *Fake) Checkout(orderID string, declined bool) (string, string) {
+	paymentID := "pay_" + strings.TrimPrefix(orderID, "order_")
+	if declined {
+		paymentID += DeclinedSuffix
+	}
+	f.mu.Lock()
+	// Unknown orders are not paid, fetching the payment reports it missing
+	_, _ = f.payment(paymentID)
+	f.mu.Unlock()
+	return paymentID, f.PaymentSignature(orderID, paymentID)
+}
+
+// PaymentSignature is the hex HMAC-SHA256 of "<order id>|<payment id>" with the key secret
+func (f *Fake) PaymentSignature(orderID, paymentID string) string {
+	mac := hmac.New(sha256.New, []byte(f.keySecret))
+	mac.Write([]byte(orderID + "|" + paymentID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (f *Fake) VerifyPaymentSignature(orderID, paymentID, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(f.PaymentSignature(orderID, paymentID)))
+}
+
+func (f *Fake) FetchPayment(ctx context.Context, paymentID string) (*Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	payment, err := f.payment(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	copied := *payment
+	return &copied, nil
+}
+
+// payment returns a payment, attempting it against its order when the gateway sees it first
+func (f *Fake) payment(paymentID string) (*Payment, error) {
+	if payment, ok := f.payments[paymentID]; ok {
+		return payment, nil
+	}
+
+	id, declined := strings.CutSuffix(strings.TrimPrefix(paymentID, "pay_"), DeclinedSuffix)
+	order, ok := f.orders["order_"+id]
+	if !ok || !strings.HasPrefix(paymentID, "pay_") {
+		return nil, fmt.Errorf("%w: payment %s", ErrNotFound, paymentID)
+	}
+	payment := &Payment{
+		ID:       paymentID,
+		OrderID:  order.ID,
+		Amount:   order.Amount,
+		Currency: order.Currency,
+		Status:   PaymentStatusCaptured,
+	}
+	if declined {
+		payment.Status = PaymentStatusFailed
+	}
+	order.Attempts++
+	f.payments[paymentID] = payment
+	f.orderPayments[order.ID] = append(f.orderPayments[order.ID], paymentID)
+	return payment, nil
+}
+
+// CapturePayment rejects every capture, the fake's payments are captured already or failed
+func (f *Fake) CapturePayment(ctx context.Context, paymentID string, amount int64, currency string) (*Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	payment, err := f.payment(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	return nil, &RejectedError{Reason: fmt.Sprintf("payment is %s, only authorized payments can be captured", payment.Status)}
+}
+
+// CreateRefund refunds a captured payment at once. The same receipt returns the same refund.
+func (f *Fake) CreateRefund(ctx context.Context, paymentID string, req RefundRequest) (*Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	payment, err := f.payment(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	id := f.fakeID("rfnd_", req.Receipt)
+	if refund, ok := f.refunds[id]; ok {
+		copied := *refund
+		return &copied, nil
+	}
+	if payment.Status != PaymentStatusCaptured {
+		return nil, &RejectedError{Reason: fmt.Sprintf("a %s payment cannot be refunded", payment.Status)}
+	}
+
+	var refunded int64
+	for _, rid := range f.paymentRefunds[paymentID] {
+		refunded += f.refunds[rid].Amount
+	}
+	amount := req.Amount
+	if amount == 0 {
+		amount = payment.Amount - refunded
+	}
+	if amount <= 0 || refunded+amount > payment.Amount {
+		return nil, &RejectedError{Reason: "refund exceeds the amount left to refund"}
+	}
+
+	refund := &Refund{
+		ID:        id,
+		PaymentID: paymentID,
+		Amount:    amount,
+		Currency:  payment.Currency,
+		Receipt:   req.Receipt,
+		Status:    RefundStatusProcessed,
+	}
+	f.refunds[id] = refund
+	f.paymentRefunds[paymentID] = append(f.paymentRefunds[paymentID], id)
+	copied := *refund
+	return &copied, nil
+}
+
+func (f *Fake) FetchRefund(ctx context.Context, refundID string) (*Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	refund, ok := f.refunds[refundID]
+	if !ok {
+		return nil, fmt.Errorf("%w: refund %s", ErrNotFound, refundID)
+	}
+	copied := *refund
+	return &copied, nil
+}
+
+func (f *Fake) FetchPaymentRefunds(ctx context.Context, paymentID string) ([]Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.payment(paymentID); err != nil {
+		return nil, err
+	}
+	refunds := make([]Refund, 0, len(f.paymentRefunds[paymentID]))
+	for _, id := range f.paymentRefunds[paymentID] {
+		refunds = append(refunds, *f.refunds[id])
+	}
+	return refunds, nil
+}
+
+// WebhookSignature is the X-Webhook-Signature of a fake webhook body
+func (f *Fake) WebhookSignature(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(f.webhookSecret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ParseWebhook accepts a WebhookEvent as JSON, signed in the X-Webhook-Signature header
+func (f *Fake) ParseWebhook(body []byte, header http.Header) (*WebhookEvent, error) {
+	if f.webhookSecret == "" {
+		return nil, ErrWebhookNotConfigured
+	}
+	if !hmac.Equal([]byte(header.Get("X-Webhook-Signature")), []byte(f.WebhookSignature(body))) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode fake webhook: %w", err)
+	}
+	if event.Type == "" {
+		return nil, fmt.Errorf("fake webhook without event type")
+	}
+	return &event, nil
+}
+
+// Webhook builds a signed delivery of an event about a payment and, for refund events, one of its
+// refunds. The event id is derived from the event, so building it again gives a redelivery.
+func (f *Fake) Webhook(eventType, paymentID, refundID string) ([]byte, http.Header, error) {
+	f.mu.Lock()
+	payment, err := f.payment(paymentID)
+	event := WebhookEvent{Type: eventType}
+	if err == nil {
+		copied := *payment
+		event.Payment = &copied
+		if refundID != "" {
+			refund, ok := f.refunds[refundID]
+			if !ok {
+				err = fmt.Errorf("%w: refund %s", ErrNotFound, refundID)
+			} else {
+				copied := *refund
+				event.Refund = &copied
+			}
+		}
+	}
+	f.mu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sum := sha256.Sum256([]byte(eventType + "|" + paymentID + "|" + refundID))
+	event.ID = "evt_" + hex.EncodeToString(sum[:7])
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := http.Header{}
+	header.Set("X-Webhook-Signature", f.WebhookSignature(body))
+	return body, header, nil
+}
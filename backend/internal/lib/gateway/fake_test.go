@@ -0,0 +1,153 @@
+package gateway_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Orders are derived from their receipt", func(t *testing.T) {
+		fake := gateway.NewFake("secret", "webhook-secret")
+		order, err := fake.CreateOrder(ctx, gateway.OrderRequest{Amount: 24950, Currency: "INR", Receipt: "payment-1"})
+		require.NoError(t, err)
+		again, err := gateway.NewFake("secret", "webhook-secret").CreateOrder(ctx, gateway.OrderRequest{Amount: 24950, Currency: "INR", Receipt: "payment-1"})
+		require.NoError(t, err)
+		assert.Equal(t, order.ID, again.ID)
+
+		other, err := fake.CreateOrder(ctx, gateway.OrderRequest{Amount: 24950, Currency: "INR", Receipt: "payment-2"})
+		require.NoError(t, err)
+		assert.NotEqual(t, order.ID, other.ID)
+
+		_, err = fake.CreateOrder(ctx, gateway.OrderRequest{Currency: "INR", Receipt: "payment-3"})
+		var rejected *gateway.RejectedError
+		assert.ErrorAs(t, err, &rejected)
+	})
+
+	t.Run("Checkout, verify and refund", func(t *testing.T) {
+		fake := gateway.NewFake("secret", "webhook-secret")
+		order, err := fake.CreateOrder(ctx, gateway.OrderRequest{Amount: 10000, Currency: "INR", Receipt: "payment-1"})
+		require.NoError(t, err)
+
+		paymentID, signature := fake.Checkout(order.ID, false)
+		assert.True(t, fake.VerifyPaymentSignature(order.ID, paymentID, signature))
+		assert.False(t, fake.VerifyPaymentSignature(order.ID, paymentID, fake.PaymentSignature(order.ID, "pay_other")))
+
+		payment, err := fake.FetchPayment(ctx, paymentID)
+		require.NoError(t, err)
+		assert.Equal(t, gateway.PaymentStatusCaptured, payment.Status)
+		assert.Equal(t, int64(10000), payment.Amount)
+		order, err = fake.FetchOrder(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, order.Attempts)
+
+		partial, err := fake.CreateRefund(ctx, paymentID, gateway.RefundRequest{Amount: 4000, Receipt: "refund-1"})
+		require.NoError(t, err)
+		assert.Equal(t, gateway.RefundStatusProcessed, partial.Status)
+		retried, err := fake.CreateRefund(ctx, paymentID, gateway.RefundRequest{Amount: 4000, Receipt: "refund-1"})
+		require.NoError(t, err)
+		assert.Equal(t, partial.ID, retried.ID)
+
+		_, err = fake.CreateRefund(ctx, paymentID, gateway.RefundRequest{Amount: 7000, Receipt: "refund-2"})
+		var rejected *gateway.RejectedError
+		require.ErrorAs(t, err, &rejected)
+
+		rest, err := fake.CreateRefund(ctx, paymentID, gateway.RefundRequest{Receipt: "refund-3"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(6000), rest.Amount)
+
+		refunds, err := fake.FetchPaymentRefunds(ctx, paymentID)
+		require.NoError(t, err)
+		require.Len(t, refunds, 2)
+		assert.Equal(t, partial.ID, refunds[0].ID)
+		assert.Equal(t, rest.ID, refunds[1].ID)
+	})
+
+	t.Run("Declined payments fail and cannot be refunded", func(t *testing.T) {
+		fake := gateway.NewFake("secret", "webhook-secret")
+		order, err := fake.CreateOrder(ctx, gateway.OrderRequest{Amount: 10000, Currency: "INR", Receipt: "payment-1"})
+		require.NoError(t, err)
+
+		paymentID, _ := fake.Checkout(order.ID, true)
+		payment, err := fake.FetchPayment(ctx, paymentID)
+		require.NoError(t, err)
+		assert.Equal(t, gateway.PaymentStatusFailed, payment.Status)
+
+		_, err = fake.CreateRefund(ctx, paymentID, gateway.RefundRequest{Receipt: "refund-1"})
+		var rejected *gateway.RejectedError
+		assert.ErrorAs(t, err, &rejected)
+		_, err = fake.CapturePayment(ctx, paymentID, 10000, "INR")
+		assert.ErrorAs(t, err, &rejected)
+	})
+
+	t.Run("Unknown ids are not found", func(t *testing.T) {
+		fake := gateway.NewFake("secret", "webhook-secret")
+		_, err := fake.FetchOrder(ctx, "order_missing")
+		assert.ErrorIs(t, err, gateway.ErrNotFound)
+		_, err = fake.FetchPayment(ctx, "pay_missing")
+		assert.ErrorIs(t, err, gateway.ErrNotFound)
+		_, err = fake.FetchRefund(ctx, "rfnd_missing")
+		assert.ErrorIs(t, err, gateway.ErrNotFound)
+	})
+
+	t.Run("Webhooks are signed and redelivered with the same id", func(t *testing.T) {
+		fake := gateway.NewFake("secret", "webhook-secret")
+		order, err := fake.CreateOrder(ctx, gateway.OrderRequest{Amount: 10000, Currency: "INR", Receipt: "payment-1"})
+		require.NoError(t, err)
+		paymentID, _ := fake.Checkout(order.ID, false)
+
+		body, header, err := fake.Webhook(gateway.EventPaymentCaptured, paymentID, "")
+		require.NoError(t, err)
+		event, err := fake.ParseWebhook(body, header)
+		require.NoError(t, err)
+		assert.Equal(t, gateway.EventPaymentCaptured, event.Type)
+		require.NotNil(t, event.Payment)
+		assert.Equal(t, order.ID, event.Payment.OrderID)
+		assert.Nil(t, event.Refund)
+
+		redelivered, _, err := fake.Webhook(gateway.EventPaymentCaptured, paymentID, "")
+		require.NoError(t, err)
+		again, err := fake.ParseWebhook(redelivered, header)
+		require.NoError(t, err)
+		assert.Equal(t, event.ID, again.ID)
+
+		forged := http.Header{}
+		forged.Set("X-Webhook-Signature", gateway.NewFake("secret", "guessed").WebhookSignature(body))
+		_, err = fake.ParseWebhook(body, forged)
+		assert.ErrorIs(t, err, gateway.ErrInvalidSignature)
+
+		_, err = gateway.NewFake("secret", "").ParseWebhook(body, header)
+		assert.ErrorIs(t, err, gateway.ErrWebhookNotConfigured)
+	})
+}
+
+func TestNew(t *testing.T) {
+	cfg := config.DefaultPaymentConfig()
+	gw, err := gateway.New(cfg)
+	require.NoError(t, err)
+	assert.Nil(t, gw)
+
+	cfg.Provider = "fake"
+	gw, err = gateway.New(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "fake", gw.Name())
+
+	cfg.Provider = "razorpay"
+	cfg.Razorpay.KeyID = "rzp_test_key"
+	cfg.Razorpay.KeySecret = "secret"
+	gw, err = gateway.New(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "razorpay", gw.Name())
+	assert.Equal(t, "rzp_test_key", gw.KeyID())
+
+	cfg.Provider = "paypal"
+	_, err = gateway.New(cfg)
+	assert.Error(t, err)
+}
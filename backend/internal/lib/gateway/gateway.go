@@ -0,0 +1,168 @@
+// Package gateway abstracts the payment providers online payments and wallet top-ups go through.
+// Amounts are in the smallest currency unit (paise for INR).
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/razorpay"
+)
+
+var (
+	// ErrNotFound means the provider does not know the order, payment or refund
+	ErrNotFound = errors.New("gateway: not found")
+	// ErrWebhookNotConfigured means no webhook secret is set, deliveries cannot be authenticated
+	ErrWebhookNotConfigured = errors.New("gateway: webhooks are not configured")
+	// ErrInvalidSignature means a webhook delivery was not signed with the webhook secret
+	ErrInvalidSignature = errors.New("gateway: invalid webhook signature")
+)
+
+// RejectedError is a request the provider refused, repeating it will not help
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return "gateway: request rejected: " + e.Reason
+}
+
+type PaymentStatus string
+
+const (
+	PaymentStatusCreated    PaymentStatus = "created"
+	PaymentStatusAuthorized PaymentStatus = "authorized"
+	PaymentStatusCaptured   PaymentStatus = "captured"
+	PaymentStatusRefunded   PaymentStatus = "refunded"
+	PaymentStatusFailed     PaymentStatus = "failed"
+)
+
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusProcessed RefundStatus = "processed"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+// Webhook events the payment service handles
+const (
+	EventPaymentCaptured = "payment.captured"
+	EventPaymentFailed   = "payment.failed"
+	EventRefundProcessed = "refund.processed"
+	EventRefundFailed    = "refund.failed"
+)
+
+type OrderRequest struct {
+	Amount   int64
+	Currency string
+	// Receipt is our id of what the order pays for
+	Receipt string
+	Notes   map[string]string
+}
+
+// Order collects the payment attempts of the checkout
+type Order struct {
+	ID       string `json:"id"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	Receipt  string `json:"receipt"`
+	// Attempts counts the payments tried against the order
+	Attempts int `json:"attempts"`
+}
+
+// Payment is a payment attempt made through the checkout
+type Payment struct {
+	ID       string        `json:"id"`
+	OrderID  string        `json:"order_id"`
+	Amount   int64         `json:"amount"`
+	Currency string        `json:"currency"`
+	Status   PaymentStatus `json:"status"`
+}
+
+type RefundRequest struct {
+	// Amount to refund, zero refunds the whole remaining amount
+	Amount  int64
+	Receipt string
+	Notes   map[string]string
+}
+
+type Refund struct {
+	ID        string `json:"id"`
+	PaymentID string `json:"payment_id"`
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+	// Receipt is our refund id, empty for refunds made outside the app
+	Receipt string       `json:"receipt,omitempty"`
+	Status  RefundStatus `json:"status"`
+}
+
+// WebhookEvent is a verified webhook delivery. Refund events carry the refunded payment as well.
+type WebhookEvent struct {
+	// ID identifies the delivery for deduplication, empty when the provider sends none
+	ID      string   `json:"id"`
+	Type    string   `json:"type"`
+	Payment *Payment `json:"payment,omitempty"`
+	Refund  *Refund  `json:"refund,omitempty"`
+}
+
+// PaymentGateway is a payment provider. Creating a refund must not be retried by implementations,
+// callers reconcile an ambiguous failure through the payment's refunds.
+type PaymentGateway interface {
+	// Name is the provider stored with the payments made through it
+	Name() string
+	// KeyID is the public key the checkout needs
+	KeyID() string
+	CreateOrder(ctx context.Context, req OrderRequest) (*Order, error)
+	FetchOrder(ctx context.Context, orderID string) (*Order, error)
+	// FetchOrderPayments lists the payment attempts of an order
+	FetchOrderPayments(ctx context.Context, orderID string) ([]Payment, error)
+	// VerifyPaymentSignature checks the signature the checkout returns for a payment of an order
+	VerifyPaymentSignature(orderID, paymentID, signature string) bool
+	FetchPayment(ctx context.Context, paymentID string) (*Payment, error)
+	CapturePayment(ctx context.Context, paymentID string, amount int64, currency string) (*Payment, error)
+	CreateRefund(ctx context.Context, paymentID string, req RefundRequest) (*Refund, error)
+	FetchRefund(ctx context.Context, refundID string) (*Refund, error)
+	FetchPaymentRefunds(ctx context.Context, paymentID string) ([]Refund, error)
+	// ParseWebhook authenticates a webhook delivery by its headers and decodes it
+	ParseWebhook(body []byte, header http.Header) (*WebhookEvent, error)
+}
+
+// New returns the gateway configured by cfg, nil when online payments run without one
+func New(cfg *config.PaymentConfig) (PaymentGateway, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	switch cfg.Provider {
+	case "razorpay":
+		return NewRazorpay(razorpay.NewClient(razorpay.Config{
+			KeyID:         cfg.Razorpay.KeyID,
+			KeySecret:     cfg.Razorpay.KeySecret,
+			WebhookSecret: cfg.Razorpay.WebhookSecret,
+			BaseURL:       cfg.Razorpay.BaseURL,
+			Timeout:       cfg.Razorpay.Timeout,
+			MaxRetries:    cfg.Razorpay.MaxRetries,
+		})), nil
+	case "fake":
+		return NewFake(cfg.Fake.KeySecret, cfg.Fake.WebhookSecret), nil
+	case "none", "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", cfg.Provider)
+	}
+}
+
+// ToPaise converts a rupee amount to paise
+func ToPaise(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// FromPaise converts paise back to rupees
+func FromPaise(amount int64) float64 {
+	return float64(amount) / 100
+}
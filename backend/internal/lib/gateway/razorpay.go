@@ -0,0 +1,189 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/razorpay"
+)
+
+// Razorpay is the PaymentGateway of the Razorpay API
+type Razorpay struct {
+	client *razorpay.Client
+}
+
+func NewRazorpay(client *razorpay.Client) *Razorpay {
+	return &Razorpay{client: client}
+}
+
+func (r *Razorpay) Name() string {
+	return "razorpay"
+}
+
+func (r *Razorpay) KeyID() string {
+	return r.client.KeyID()
+}
+
+func (r *Razorpay) CreateOrder(ctx context.Context, req OrderRequest) (*Order, error) {
+	order, err := r.client.CreateOrder(ctx, razorpay.CreateOrderRequest{
+		Amount:   req.Amount,
+		Currency: req.Currency,
+		Receipt:  req.Receipt,
+		Notes:    req.Notes,
+	})
+	if err != nil {
+		return nil, razorpayError(err)
+	}
+	return razorpayOrder(order), nil
+}
+
+func (r *Razorpay) FetchOrder(ctx context.Context, orderID string) (*Order, error) {
+	order, err := r.client.FetchOrder(ctx, orderID)
+	if err != nil {
+		return nil, razorpayError(err)
+	}
+	return razorpayOrder(order), nil
+}
+
+func (r *Razorpay) FetchOrderPayments(ctx context.Context, orderID string) ([]Payment, error) {
+	payments, err := r.client.FetchOrderPayments(ctx, orderID)
+	if err != nil {
+		return nil, razorpayError(err)
+	}
+	converted := make([]Payment, 0, len(payments))
+	for i := range payments {
+		converted = append(converted, *razorpayPayment(&payments[i]))
+	}
+	return converted, nil
+}
+
+func (r *Razorpay) VerifyPaymentSignature(orderID, paymentID, signature string) bool {
+	return r.client.VerifyPaymentSignature(orderID, paymentID, signature)
+}
+
+func (r *Razorpay) FetchPayment(ctx context.Context, paymentID string) (*Payment, error) {
+	payment, err := r.client.FetchPayment(ctx, paymentID)
+	if err != nil {
+		return nil, razorpayError(err)
+	}
+	return razorpayPayment(payment), nil
+}
+
+func (r *Razorpay) CapturePayment(ctx context.Context, paymentID string, amount int64, currency string) (*Payment, error) {
+	payment, err := r.client.CapturePayment(ctx, paymentID, amount, currency)
+	if err != nil {
+		return nil, razorpayError(err)
+	}
+	return razorpayPayment(payment), nil
+}
+
+func (r *Razorpay) CreateRefund(ctx context.Context, paymentID string, req RefundRequest) (*Refund, error) {
+	refund, err := r.client.CreateRefund(ctx, paymentID, razorpay.CreateRefundRequest{
+		Amount:  req.Amount,
+		Receipt: req.Receipt,
+		Notes:   req.Notes,
+	})
+	if err != nil {
+		return nil, razorpayError(err)
+	}
+	return razorpayRefund(refund), nil
+}
+
+func (r *Razorpay) FetchRefund(ctx context.Context, refundID string) (*Refund, error) {
+	refund, err := r.client.FetchRefund(ctx, refundID)
+	if err != nil {
+		return nil, razorpayError(err)
+	}
+	return razorpayRefund(refund), nil
+}
+
+func (r *Razorpay) FetchPaymentRefunds(ctx context.Context, paymentID string) ([]Refund, error) {
+	list, err := r.client.FetchPaymentRefunds(ctx, paymentID)
+	if err != nil {
+		return nil, razorpayError(err)
+	}
+	refunds := make([]Refund, len(list))
+	for i := range list {
+		refunds[i] = *razorpayRefund(&list[i])
+	}
+	return refunds, nil
+}
+
+// ParseWebhook checks the X-Razorpay-Signature header, the delivery is identified by
+// X-Razorpay-Event-Id
+func (r *Razorpay) ParseWebhook(body []byte, header http.Header) (*WebhookEvent, error) {
+	if !r.client.WebhookConfigured() {
+		return nil, ErrWebhookNotConfigured
+	}
+	if !r.client.VerifyWebhookSignature(body, header.Get("X-Razorpay-Signature")) {
+		return nil, ErrInvalidSignature
+	}
+
+	event, err := razorpay.ParseWebhookEvent(body)
+	if err != nil {
+		return nil, err
+	}
+	parsed := &WebhookEvent{
+		ID:   header.Get("X-Razorpay-Event-Id"),
+		Type: event.Event,
+	}
+	if event.Payload.Payment != nil {
+		parsed.Payment = razorpayPayment(&event.Payload.Payment.Entity)
+	}
+	if event.Payload.Refund != nil {
+		parsed.Refund = razorpayRefund(&event.Payload.Refund.Entity)
+	}
+	return parsed, nil
+}
+
+// razorpayError maps API errors to the gateway errors, 404s to ErrNotFound and other client errors
+// but rate limiting to a RejectedError
+func razorpayError(err error) error {
+	var apiErr *razorpay.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	switch {
+	case apiErr.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, apiErr.Description)
+	case apiErr.StatusCode < 500 && apiErr.StatusCode != http.StatusTooManyRequests:
+		return &RejectedError{Reason: apiErr.Description}
+	}
+	return err
+}
+
+func razorpayOrder(o *razorpay.Order) *Order {
+	return &Order{
+		ID:       o.ID,
+		Amount:   o.Amount,
+		Currency: o.Currency,
+		Receipt:  o.Receipt,
+		Attempts: o.Attempts,
+	}
+}
+
+func razorpayPayment(p *razorpay.Payment) *Payment {
+	return &Payment{
+		ID:       p.ID,
+		OrderID:  p.OrderID,
+		Amount:   p.Amount,
+		Currency: p.Currency,
+		Status:   PaymentStatus(p.Status),
+	}
+}
+
+func razorpayRefund(r *razorpay.Refund) *Refund {
+	refund := &Refund{
+		ID:        r.ID,
+		PaymentID: r.PaymentID,
+		Amount:    r.Amount,
+		Currency:  r.Currency,
+		Status:    RefundStatus(r.Status),
+	}
+	if r.Receipt != nil {
+		refund.Receipt = *r.Receipt
+	}
+	return refund
+}
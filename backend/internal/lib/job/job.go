@@ -1,15 +1,20 @@
 package job
 
 import (
+	"context"
+	"time"
+
 	"github.com/hibiken/asynq"
 	"github.com/rs/zerolog"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
 )
 
 type JobService struct {
-	Client *asynq.Client
-	Server *asynq.Server
-	logger *zerolog.Logger
+	Client    *asynq.Client
+	Server    *asynq.Server
+	Scheduler *asynq.Scheduler
+	logger    *zerolog.Logger
+	mux       *asynq.ServeMux
 }
 
 func NewJobService(logger *zerolog.Logger, cfg *config.Config) *JobService {
@@ -30,28 +35,54 @@ func NewJobService(logger *zerolog.Logger, cfg *config.Config) *JobService {
 		},
 	)
 
+	// Periodic tasks are enqueued by the scheduler and processed by the server like any other task
+	scheduler := asynq.NewScheduler(asynq.RedisClientOpt{Addr: redisAddr}, &asynq.SchedulerOpts{
+		Location: time.UTC,
+	})
+
 	return &JobService{
-		Client: client,
-		Server: server,
-		logger: logger,
+		Client:    client,
+		Server:    server,
+		Scheduler: scheduler,
+		logger:    logger,
+		mux:       asynq.NewServeMux(),
 	}
 }
 
 func (j *JobService) Start() error {
 	// Register task handlers
-	mux := asynq.NewServeMux()
-
-	mux.HandleFunc(TaskWelcome, j.handleWelcomeEmailTask)
-	mux.HandleFunc(TaskOTP, j.handleOTPEmailTask)
+	j.mux.HandleFunc(TaskWelcome, j.handleWelcomeEmailTask)
+	j.mux.HandleFunc(TaskOTP, j.handleOTPEmailTask)
 	j.logger.Info().Msg("Starting Backgrond Job Server")
-	if err := j.Server.Start(mux); err != nil {
+	if err := j.Server.Start(j.mux); err != nil {
+		return err
+	}
+	if err := j.Scheduler.Start(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// HandleFunc registers a task handler owned by another package. Handlers may be added after
+// Start, tasks of an unregistered type are retried until their handler exists.
+func (j *JobService) HandleFunc(taskType string, handler func(context.Context, *asynq.Task) error) {
+	j.mux.HandleFunc(taskType, handler)
+}
+
+// Schedule enqueues the task periodically on the given cron spec (UTC). Every instance runs
+// a scheduler, so periodic tasks should be created with asynq.Unique to avoid duplicates.
+func (j *JobService) Schedule(cronspec string, task *asynq.Task) error {
+	entryID, err := j.Scheduler.Register(cronspec, task)
+	if err != nil {
 		return err
 	}
+	j.logger.Info().Str("task", task.Type()).Str("cronspec", cronspec).Str("entry_id", entryID).Msg("Scheduled periodic task")
 	return nil
 }
 
 func (j *JobService) Stop() {
 	j.logger.Info().Msg("Stopping background job server")
+	j.Scheduler.Shutdown()
 	j.Server.Shutdown()
 	j.Client.Close()
 }
@@ -0,0 +1,50 @@
+package job
+
+import (
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const (
+	TaskLocationHistoryMaintenance = "location:history_maintenance"
+)
+
+// NewLocationHistoryMaintenanceTask creates the task that adds upcoming driver_location_history
+// partitions and drops the ones past retention
+func NewLocationHistoryMaintenanceTask() *asynq.Task {
+	return asynq.NewTask(TaskLocationHistoryMaintenance, nil,
+		asynq.MaxRetry(3),
+		asynq.Queue("low"),
+		asynq.Timeout(5*time.Minute),
+		// Every instance schedules the task, only one run per window is enqueued
+		asynq.Unique(time.Hour))
+}
+
+const (
+	TaskGeoIndexReconcile = "location:geo_reconcile"
+)
+
+// NewGeoIndexReconcileTask creates the task that repairs the redis geo indexes from the database
+func NewGeoIndexReconcileTask() *asynq.Task {
+	return asynq.NewTask(TaskGeoIndexReconcile, nil,
+		// A missed run is simply repaired by the next one
+		asynq.MaxRetry(0),
+		asynq.Queue("low"),
+		asynq.Timeout(time.Minute),
+		asynq.Unique(time.Minute))
+}
+
+const (
+	TaskHeatmapRefresh = "location:heatmap_refresh"
+)
+
+// NewHeatmapRefreshTask creates the task that rebuilds the cached demand heatmap cells
+func NewHeatmapRefreshTask() *asynq.Task {
+	return asynq.NewTask(TaskHeatmapRefresh, nil,
+		// Cells outlive a few missed runs, the next run catches up
+		asynq.MaxRetry(0),
+		asynq.Queue("low"),
+		asynq.Timeout(time.Minute),
+		asynq.Unique(time.Minute))
+}
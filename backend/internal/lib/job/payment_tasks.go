@@ -0,0 +1,49 @@
+package job
+
+import (
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const (
+	TaskRefundSync = "payment:refund_sync"
+)
+
+// NewRefundSyncTask creates the task that settles refunds still pending at the gateway
+func NewRefundSyncTask() *asynq.Task {
+	return asynq.NewTask(TaskRefundSync, nil,
+		// Pending refunds stay pending, the next run picks them up
+		asynq.MaxRetry(0),
+		asynq.Queue("low"),
+		asynq.Timeout(2*time.Minute),
+		asynq.Unique(time.Minute))
+}
+
+const (
+	TaskPayoutSettlement = "payment:payout_settlement"
+)
+
+// NewPayoutSettlementTask creates the task that pays out driver balances in a payout batch
+func NewPayoutSettlementTask() *asynq.Task {
+	return asynq.NewTask(TaskPayoutSettlement, nil,
+		// An interrupted settlement is resumed by the retry in the same batch
+		asynq.MaxRetry(3),
+		asynq.Queue("low"),
+		asynq.Timeout(10*time.Minute),
+		asynq.Unique(time.Hour))
+}
+
+const (
+	TaskPaymentReconcile = "payment:reconcile"
+)
+
+// NewPaymentReconcileTask creates the task that reconciles open payments with the gateway
+func NewPaymentReconcileTask() *asynq.Task {
+	return asynq.NewTask(TaskPaymentReconcile, nil,
+		// Payments left open are picked up by the next run
+		asynq.MaxRetry(0),
+		asynq.Queue("low"),
+		asynq.Timeout(5*time.Minute),
+		asynq.Unique(time.Minute))
+}
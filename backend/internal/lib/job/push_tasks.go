@@ -0,0 +1,34 @@
+package job
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const (
+	TaskPushNotification = "push:send"
+)
+
+// PushNotificationPayload targets a single device so retries never resend to devices that already got it
+type PushNotificationPayload struct {
+	UserID   string            `json:"user_id"`
+	Token    string            `json:"token"`
+	Platform string            `json:"platform"`
+	Title    string            `json:"title"`
+	Body     string            `json:"body"`
+	Data     map[string]string `json:"data,omitempty"`
+}
+
+func NewPushNotificationTask(p PushNotificationPayload) (*asynq.Task, error) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return asynq.NewTask(TaskPushNotification, payload,
+		asynq.MaxRetry(5),
+		asynq.Queue("critical"), // Ride updates are time sensitive
+		asynq.Timeout(30*time.Second)), nil
+}
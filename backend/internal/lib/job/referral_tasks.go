@@ -0,0 +1,43 @@
+package job
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const (
+	TaskReferralReward = "referral:reward"
+	TaskReferralSweep  = "referral:sweep"
+)
+
+// ReferralRewardPayload names the rider whose first ride was paid
+type ReferralRewardPayload struct {
+	RefereeID string `json:"referee_id"`
+}
+
+// NewReferralRewardTask creates the task that rewards a referral once the referred rider paid a ride
+func NewReferralRewardTask(refereeID string) (*asynq.Task, error) {
+	payload, err := json.Marshal(ReferralRewardPayload{RefereeID: refereeID})
+	if err != nil {
+		return nil, err
+	}
+
+	return asynq.NewTask(TaskReferralReward, payload,
+		asynq.MaxRetry(5),
+		asynq.Queue("default"),
+		asynq.Timeout(time.Minute),
+		// Later rides of the rider enqueue nothing while the reward is queued
+		asynq.TaskID(TaskReferralReward+":"+refereeID)), nil
+}
+
+// NewReferralSweepTask creates the task that rewards paid referrals whose reward task was lost
+func NewReferralSweepTask() *asynq.Task {
+	return asynq.NewTask(TaskReferralSweep, nil,
+		// Referrals left pending are picked up by the next run
+		asynq.MaxRetry(0),
+		asynq.Queue("low"),
+		asynq.Timeout(5*time.Minute),
+		asynq.Unique(time.Minute))
+}
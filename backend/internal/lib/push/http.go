@@ -0,0 +1,88 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPNotifier sends notifications to an FCM/APNs-style HTTP push gateway
+type HTTPNotifier struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+// httpRequest is the body posted to the push gateway
+type httpRequest struct {
+	Token        string            `json:"token"`
+	Platform     Platform          `json:"platform"`
+	Priority     string            `json:"priority"`
+	Notification httpNotification  `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+}
+
+type httpNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+func NewHTTPNotifier(endpoint, apiKey string, timeout time.Duration) *HTTPNotifier {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &HTTPNotifier{
+		endpoint: endpoint,
+		apiKey:   apiKey,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// Send posts the notification. Unknown or expired tokens map to ErrInvalidToken, other client
+// errors to ErrRejected. Server errors and rate limiting return plain errors so callers retry.
+func (n *HTTPNotifier) Send(ctx context.Context, target Target, notification Notification) error {
+	body, err := json.Marshal(httpRequest{
+		Token:    target.Token,
+		Platform: target.Platform,
+		Priority: "high",
+		Notification: httpNotification{
+			Title: notification.Title,
+			Body:  notification.Body,
+		},
+		Data: notification.Data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal push request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+n.apiKey)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send push request: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrInvalidToken
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("push gateway returned %d: %s", resp.StatusCode, respBody)
+	default:
+		return fmt.Errorf("%w: status %d: %s", ErrRejected, resp.StatusCode, respBody)
+	}
+}
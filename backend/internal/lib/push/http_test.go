@@ -0,0 +1,63 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPNotifierSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr error
+		retry   bool
+	}{
+		{name: "delivered", status: http.StatusOK},
+		{name: "unregistered token", status: http.StatusGone, wantErr: ErrInvalidToken},
+		{name: "unknown token", status: http.StatusNotFound, wantErr: ErrInvalidToken},
+		{name: "bad request", status: http.StatusBadRequest, wantErr: ErrRejected},
+		{name: "rate limited", status: http.StatusTooManyRequests, retry: true},
+		{name: "gateway error", status: http.StatusBadGateway, retry: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got httpRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "Bearer key-1", r.Header.Get("Authorization"))
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			n := NewHTTPNotifier(srv.URL, "key-1", time.Second)
+			err := n.Send(context.Background(), Target{Token: "tok-1", Platform: PlatformAndroid}, Notification{
+				Title: "Driver on the way",
+				Body:  "Your driver accepted the ride",
+				Data:  map[string]string{"ride_id": "ride-1"},
+			})
+
+			assert.Equal(t, "tok-1", got.Token)
+			assert.Equal(t, "Driver on the way", got.Notification.Title)
+			assert.Equal(t, "ride-1", got.Data["ride_id"])
+
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.retry:
+				require.Error(t, err)
+				assert.NotErrorIs(t, err, ErrRejected)
+				assert.NotErrorIs(t, err, ErrInvalidToken)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
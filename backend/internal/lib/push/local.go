@@ -0,0 +1,73 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// LogNotifier writes notifications to the application log instead of delivering them
+type LogNotifier struct {
+	logger *zerolog.Logger
+}
+
+func NewLogNotifier(logger *zerolog.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) Send(ctx context.Context, target Target, notification Notification) error {
+	n.logger.Info().
+		Str("platform", string(target.Platform)).
+		Str("title", notification.Title).
+		Str("body", notification.Body).
+		Interface("data", notification.Data).
+		Msg("Push notification (log provider)")
+	return nil
+}
+
+// FileNotifier appends every notification as a JSON line to a file, for local development and tests
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+// FileRecord is one line written by FileNotifier
+type FileRecord struct {
+	Target       Target       `json:"target"`
+	Notification Notification `json:"notification"`
+	SentAt       time.Time    `json:"sent_at"`
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+func (n *FileNotifier) Send(ctx context.Context, target Target, notification Notification) error {
+	line, err := json.Marshal(FileRecord{
+		Target:       target,
+		Notification: notification,
+		SentAt:       time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal push record: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open push file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write push record: %w", err)
+	}
+	return nil
+}
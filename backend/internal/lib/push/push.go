@@ -0,0 +1,62 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+)
+
+// Platform is the operating system a device token was issued for
+type Platform string
+
+const (
+	PlatformAndroid Platform = "android"
+	PlatformIOS     Platform = "ios"
+	PlatformWeb     Platform = "web"
+)
+
+var (
+	// ErrInvalidToken means the provider no longer accepts the device token and it should be removed
+	ErrInvalidToken = errors.New("push: device token is no longer valid")
+	// ErrRejected means the provider refused the notification and retrying will not help
+	ErrRejected = errors.New("push: notification rejected by provider")
+)
+
+// Target is the device a notification is delivered to
+type Target struct {
+	Token    string   `json:"token"`
+	Platform Platform `json:"platform"`
+}
+
+// Notification is a platform-neutral push message
+type Notification struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// Notifier delivers push notifications to devices
+type Notifier interface {
+	Send(ctx context.Context, target Target, n Notification) error
+}
+
+// NewNotifier returns the notifier configured by cfg
+func NewNotifier(cfg *config.NotificationConfig, logger *zerolog.Logger) (Notifier, error) {
+	if cfg == nil {
+		return NewLogNotifier(logger), nil
+	}
+
+	switch cfg.Provider {
+	case "http":
+		return NewHTTPNotifier(cfg.Endpoint, cfg.APIKey, cfg.Timeout), nil
+	case "file":
+		return NewFileNotifier(cfg.FilePath), nil
+	case "log", "":
+		return NewLogNotifier(logger), nil
+	default:
+		return nil, fmt.Errorf("unknown notification provider %q", cfg.Provider)
+	}
+}
@@ -0,0 +1,164 @@
+// Package razorpay is a minimal client for the Razorpay Orders, Payments and Refunds APIs.
+// Amounts are in the smallest currency unit (paise for INR), as in the Razorpay API.
+package razorpay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const DefaultBaseURL = "https://api.razorpay.com/v1"
+
+// Config configures a Client. Zero values fall back to the defaults.
+type Config struct {
+	KeyID     string
+	KeySecret string
+	// WebhookSecret signs webhook deliveries, set separately in the Razorpay dashboard
+	WebhookSecret string
+	// BaseURL is the API root including the version, tests point it at a razorpaytest.Server
+	BaseURL string
+	// Timeout bounds a single HTTP attempt
+	Timeout time.Duration
+	// MaxRetries is how often a retryable request is repeated after a network error, 429 or 5xx
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubled for every further one
+	RetryBackoff time.Duration
+}
+
+// Client calls the Razorpay REST API with basic auth
+type Client struct {
+	cfg  Config
+	http *http.Client
+}
+
+func NewClient(cfg Config) *Client {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 200 * time.Millisecond
+	}
+	return &Client{
+		cfg:  cfg,
+		http: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// KeyID is the public key the checkout needs
+func (c *Client) KeyID() string {
+	return c.cfg.KeyID
+}
+
+// Error is an error response of the Razorpay API
+type Error struct {
+	StatusCode  int
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Field       string `json:"field,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("razorpay returned %d %s: %s", e.StatusCode, e.Code, e.Description)
+}
+
+// IsNotFound reports whether err is a Razorpay 404
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// do sends a request and decodes the response into out. Only requests that are safe to repeat are
+// retried, creating a refund twice would refund twice.
+func (c *Client) do(ctx context.Context, method, path string, body, out any, retryable bool) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to marshal razorpay request: %w", err)
+		}
+	}
+
+	attempts := 1
+	if retryable {
+		attempts += c.cfg.MaxRetries
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			wait := c.cfg.RetryBackoff << (attempt - 1)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+
+		var retry bool
+		retry, lastErr = c.attempt(ctx, method, path, payload, out)
+		if lastErr == nil || !retry {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+// attempt performs one HTTP round trip, retry reports whether a failure is worth repeating
+func (c *Client) attempt(ctx context.Context, method, path string, payload []byte, out any) (retry bool, err error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.cfg.BaseURL+path, body)
+	if err != nil {
+		return false, fmt.Errorf("failed to create razorpay request: %w", err)
+	}
+	req.SetBasicAuth(c.cfg.KeyID, c.cfg.KeySecret)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("failed to call razorpay %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return true, fmt.Errorf("failed to read razorpay response: %w", err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if out == nil {
+			return false, nil
+		}
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return false, fmt.Errorf("failed to decode razorpay response: %w", err)
+		}
+		return false, nil
+	}
+
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	var envelope struct {
+		Error *Error `json:"error"`
+	}
+	if json.Unmarshal(respBody, &envelope) == nil && envelope.Error != nil {
+		apiErr = envelope.Error
+		apiErr.StatusCode = resp.StatusCode
+	} else {
+		apiErr.Description = string(respBody)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, apiErr
+}
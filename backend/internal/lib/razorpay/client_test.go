@@ -0,0 +1,117 @@
+package razorpay_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/razorpay"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/razorpay/razorpaytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	srv := razorpaytest.NewServer("rzp_test_key", "secret")
+	defer srv.Close()
+	ctx := context.Background()
+
+	cfg := srv.Config()
+	cfg.MaxRetries = 2
+	client := razorpay.NewClient(cfg)
+
+	t.Run("Order, checkout and refund", func(t *testing.T) {
+		order, err := client.CreateOrder(ctx, razorpay.CreateOrderRequest{
+			Amount:   gateway.ToPaise(249.5),
+			Currency: "INR",
+			Receipt:  "payment-1",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(24950), order.Amount)
+		assert.Equal(t, razorpay.OrderStatusCreated, order.Status)
+
+		authorized, signature, err := srv.Authorize(order.ID, "upi")
+		require.NoError(t, err)
+		assert.True(t, client.VerifyPaymentSignature(order.ID, authorized.ID, signature))
+		assert.False(t, client.VerifyPaymentSignature(order.ID, authorized.ID, "forged"))
+
+		captured, err := client.CapturePayment(ctx, authorized.ID, order.Amount, "INR")
+		require.NoError(t, err)
+		assert.Equal(t, razorpay.PaymentStatusCaptured, captured.Status)
+
+		order, err = client.FetchOrder(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, razorpay.OrderStatusPaid, order.Status)
+
+		payments, err := client.FetchOrderPayments(ctx, order.ID)
+		require.NoError(t, err)
+		require.Len(t, payments, 1)
+		assert.Equal(t, captured.ID, payments[0].ID)
+
+		refund, err := client.CreateRefund(ctx, captured.ID, razorpay.CreateRefundRequest{Amount: 5000})
+		require.NoError(t, err)
+		assert.Equal(t, razorpay.RefundStatusProcessed, refund.Status)
+
+		fetched, err := client.FetchRefund(ctx, refund.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5000), fetched.Amount)
+
+		refunds, err := client.FetchPaymentRefunds(ctx, captured.ID)
+		require.NoError(t, err)
+		require.Len(t, refunds, 1)
+		assert.Equal(t, refund.ID, refunds[0].ID)
+
+		payment, err := client.FetchPayment(ctx, captured.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5000), payment.AmountRefunded)
+	})
+
+	t.Run("API errors are decoded", func(t *testing.T) {
+		_, err := client.FetchPayment(ctx, "pay_missing")
+		assert.True(t, razorpay.IsNotFound(err))
+
+		var apiErr *razorpay.Error
+		_, err = client.CreateOrder(ctx, razorpay.CreateOrderRequest{Amount: 10, Currency: "INR"})
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+		assert.Equal(t, "BAD_REQUEST_ERROR", apiErr.Code)
+
+		wrongKey := srv.Config()
+		wrongKey.KeySecret = "wrong"
+		_, err = razorpay.NewClient(wrongKey).FetchOrder(ctx, "order_x")
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	})
+
+	t.Run("Server errors are retried", func(t *testing.T) {
+		before := srv.Requests()
+		srv.FailNext(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+
+		order, err := client.CreateOrder(ctx, razorpay.CreateOrderRequest{Amount: 10000, Currency: "INR"})
+		require.NoError(t, err)
+		assert.NotEmpty(t, order.ID)
+		assert.Equal(t, before+3, srv.Requests())
+	})
+
+	t.Run("Retries give up after MaxRetries", func(t *testing.T) {
+		srv.FailNext(http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+		_, err := client.FetchOrder(ctx, "order_x")
+		var apiErr *razorpay.Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	})
+
+	t.Run("Refunds are not retried", func(t *testing.T) {
+		order, err := client.CreateOrder(ctx, razorpay.CreateOrderRequest{Amount: 10000, Currency: "INR"})
+		require.NoError(t, err)
+		payment, _, err := srv.Pay(order.ID, "card")
+		require.NoError(t, err)
+
+		before := srv.Requests()
+		srv.FailNext(http.StatusServiceUnavailable)
+		_, err = client.CreateRefund(ctx, payment.ID, razorpay.CreateRefundRequest{})
+		require.Error(t, err)
+		assert.Equal(t, before+1, srv.Requests())
+	})
+}
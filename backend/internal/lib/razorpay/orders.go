@@ -0,0 +1,65 @@
+package razorpay
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Order statuses
+const (
+	OrderStatusCreated   = "created"
+	OrderStatusAttempted = "attempted"
+	OrderStatusPaid      = "paid"
+)
+
+// Order is a Razorpay order, the checkout collects payments against it
+type Order struct {
+	ID         string            `json:"id"`
+	Entity     string            `json:"entity"`
+	Amount     int64             `json:"amount"`
+	AmountPaid int64             `json:"amount_paid"`
+	AmountDue  int64             `json:"amount_due"`
+	Currency   string            `json:"currency"`
+	Receipt    string            `json:"receipt"`
+	Status     string            `json:"status"`
+	Attempts   int               `json:"attempts"`
+	Notes      map[string]string `json:"notes,omitempty"`
+	CreatedAt  int64             `json:"created_at"`
+}
+
+type CreateOrderRequest struct {
+	Amount   int64             `json:"amount"`
+	Currency string            `json:"currency"`
+	Receipt  string            `json:"receipt,omitempty"`
+	Notes    map[string]string `json:"notes,omitempty"`
+}
+
+// CreateOrder creates an order. A retried request may leave an unused order behind, which
+// Razorpay expires on its own.
+func (c *Client) CreateOrder(ctx context.Context, req CreateOrderRequest) (*Order, error) {
+	var order Order
+	if err := c.do(ctx, http.MethodPost, "/orders", req, &order, true); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (c *Client) FetchOrder(ctx context.Context, orderID string) (*Order, error) {
+	var order Order
+	if err := c.do(ctx, http.MethodGet, "/orders/"+url.PathEscape(orderID), nil, &order, true); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// FetchOrderPayments lists every payment attempt made against an order
+func (c *Client) FetchOrderPayments(ctx context.Context, orderID string) ([]Payment, error) {
+	var list struct {
+		Items []Payment `json:"items"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/orders/"+url.PathEscape(orderID)+"/payments", nil, &list, true); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
@@ -0,0 +1,58 @@
+package razorpay
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Payment statuses
+const (
+	PaymentStatusCreated    = "created"
+	PaymentStatusAuthorized = "authorized"
+	PaymentStatusCaptured   = "captured"
+	PaymentStatusRefunded   = "refunded"
+	PaymentStatusFailed     = "failed"
+)
+
+// Payment is a payment attempt made through the checkout
+type Payment struct {
+	ID               string            `json:"id"`
+	Entity           string            `json:"entity"`
+	Amount           int64             `json:"amount"`
+	Currency         string            `json:"currency"`
+	Status           string            `json:"status"`
+	OrderID          string            `json:"order_id"`
+	Method           string            `json:"method"`
+	Captured         bool              `json:"captured"`
+	AmountRefunded   int64             `json:"amount_refunded"`
+	RefundStatus     *string           `json:"refund_status"`
+	ErrorCode        *string           `json:"error_code"`
+	ErrorDescription *string           `json:"error_description"`
+	Notes            map[string]string `json:"notes,omitempty"`
+	CreatedAt        int64             `json:"created_at"`
+}
+
+func (c *Client) FetchPayment(ctx context.Context, paymentID string) (*Payment, error) {
+	var payment Payment
+	if err := c.do(ctx, http.MethodGet, "/payments/"+url.PathEscape(paymentID), nil, &payment, true); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+type capturePaymentRequest struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// CapturePayment captures an authorized payment. Capturing is idempotent on Razorpay's side,
+// a repeated capture fails with a BAD_REQUEST_ERROR instead of charging twice.
+func (c *Client) CapturePayment(ctx context.Context, paymentID string, amount int64, currency string) (*Payment, error) {
+	var payment Payment
+	path := "/payments/" + url.PathEscape(paymentID) + "/capture"
+	if err := c.do(ctx, http.MethodPost, path, capturePaymentRequest{Amount: amount, Currency: currency}, &payment, true); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
@@ -0,0 +1,447 @@
+// Package razorpaytest provides an in-memory Razorpay API for tests and local development.
+// It implements the endpoints the razorpay client uses and can simulate the checkout.
+package razorpaytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/razorpay"
+)
+
+// Server is a fake Razorpay API backed by maps
+type Server struct {
+	*httptest.Server
+	KeyID         string
+	KeySecret     string
+	WebhookSecret string
+	// RefundStatus is the status new refunds start in, processed unless set
+	RefundStatus string
+
+	mu       sync.Mutex
+	seq      int
+	orders   map[string]*razorpay.Order
+	payments map[string]*razorpay.Payment
+	refunds  map[string]*razorpay.Refund
+	failures []int
+	requests int
+}
+
+// NewServer starts a fake API accepting the given credentials
+func NewServer(keyID, keySecret string) *Server {
+	s := &Server{
+		KeyID:         keyID,
+		KeySecret:     keySecret,
+		WebhookSecret: keySecret + "_webhook",
+		RefundStatus:  razorpay.RefundStatusProcessed,
+		orders:        make(map[string]*razorpay.Order),
+		payments:      make(map[string]*razorpay.Payment),
+		refunds:       make(map[string]*razorpay.Refund),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /orders", s.createOrder)
+	mux.HandleFunc("GET /orders/{id}", s.fetchOrder)
+	mux.HandleFunc("GET /orders/{id}/payments", s.fetchOrderPayments)
+	mux.HandleFunc("GET /payments/{id}", s.fetchPayment)
+	mux.HandleFunc("POST /payments/{id}/capture", s.capturePayment)
+	mux.HandleFunc("POST /payments/{id}/refund", s.createRefund)
+	mux.HandleFunc("GET /payments/{id}/refunds", s.fetchPaymentRefunds)
+	mux.HandleFunc("GET /refunds/{id}", s.fetchRefund)
+	s.Server = httptest.NewServer(s.middleware(mux))
+	return s
+}
+
+// Config returns a client config pointing at the fake API, without retry delays
+func (s *Server) Config() razorpay.Config {
+	return razorpay.Config{
+		KeyID:         s.KeyID,
+		KeySecret:     s.KeySecret,
+		WebhookSecret: s.WebhookSecret,
+		BaseURL:       s.URL,
+		Timeout:       time.Second,
+		RetryBackoff:  time.Millisecond,
+	}
+}
+
+// FailNext makes the next requests fail with the given statuses, one per request
+func (s *Server) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// Requests counts the requests received, including rejected ones
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Pay simulates a successful checkout with automatic capture and returns the payment and the
+// signature the checkout hands to the app
+func (s *Server) Pay(orderID, method string) (*razorpay.Payment, string, error) {
+	return s.checkout(orderID, method, razorpay.PaymentStatusCaptured)
+}
+
+// Authorize simulates a checkout whose payment still has to be captured
+func (s *Server) Authorize(orderID, method string) (*razorpay.Payment, string, error) {
+	return s.checkout(orderID, method, razorpay.PaymentStatusAuthorized)
+}
+
+// Decline simulates a failed payment attempt
+func (s *Server) Decline(orderID, method string) (*razorpay.Payment, error) {
+	payment, _, err := s.checkout(orderID, method, razorpay.PaymentStatusFailed)
+	return payment, err
+}
+
+// Order returns a copy of a stored order
+func (s *Server) Order(orderID string) (razorpay.Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[orderID]
+	if !ok {
+		return razorpay.Order{}, false
+	}
+	return *order, true
+}
+
+// SetRefundStatus moves a refund along its lifecycle
+func (s *Server) SetRefundStatus(refundID, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if refund, ok := s.refunds[refundID]; ok {
+		refund.Status = status
+	}
+}
+
+// Webhook builds a signed webhook delivery for a payment, and for a refund on refund events.
+// It returns the body, the X-Razorpay-Signature and the X-Razorpay-Event-Id header values.
+func (s *Server) Webhook(event, paymentID, refundID string) (body []byte, signature, eventID string, err error) {
+	s.mu.Lock()
+	payment, ok := s.payments[paymentID]
+	if !ok {
+		s.mu.Unlock()
+		return nil, "", "", fmt.Errorf("payment %s not found", paymentID)
+	}
+	payload := razorpay.WebhookPayload{Payment: &razorpay.PaymentEntity{Entity: *payment}}
+	if refund, ok := s.refunds[refundID]; ok {
+		payload.Refund = &razorpay.RefundEntity{Entity: *refund}
+	}
+	eventID = s.nextID("evt")
+	s.mu.Unlock()
+
+	contains := []string{"payment"}
+	if payload.Refund != nil {
+		contains = append(contains, "refund")
+	}
+	body, err = json.Marshal(razorpay.WebhookEvent{
+		Entity:    "event",
+		AccountID: "acc_test",
+		Event:     event,
+		Contains:  contains,
+		Payload:   payload,
+		CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, "", "", err
+	}
+	return body, razorpay.WebhookSignature(body, s.WebhookSecret), eventID, nil
+}
+
+func (s *Server) checkout(orderID, method, status string) (*razorpay.Payment, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderID]
+	if !ok {
+		return nil, "", fmt.Errorf("order %s not found", orderID)
+	}
+	if order.Status == razorpay.OrderStatusPaid {
+		return nil, "", fmt.Errorf("order %s is already paid", orderID)
+	}
+
+	payment := &razorpay.Payment{
+		ID:        s.nextID("pay"),
+		Entity:    "payment",
+		Amount:    order.Amount,
+		Currency:  order.Currency,
+		Status:    status,
+		OrderID:   order.ID,
+		Method:    method,
+		Captured:  status == razorpay.PaymentStatusCaptured,
+		Notes:     order.Notes,
+		CreatedAt: time.Now().Unix(),
+	}
+	if status == razorpay.PaymentStatusFailed {
+		code, description := "BAD_REQUEST_ERROR", "Payment declined by the bank"
+		payment.ErrorCode, payment.ErrorDescription = &code, &description
+	}
+	s.payments[payment.ID] = payment
+
+	order.Attempts++
+	order.Status = razorpay.OrderStatusAttempted
+	if payment.Captured {
+		s.markPaid(order, payment)
+	}
+	return s.copyPayment(payment), razorpay.PaymentSignature(order.ID, payment.ID, s.KeySecret), nil
+}
+
+func (s *Server) markPaid(order *razorpay.Order, payment *razorpay.Payment) {
+	order.Status = razorpay.OrderStatusPaid
+	order.AmountPaid = payment.Amount
+	order.AmountDue = order.Amount - payment.Amount
+}
+
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		var failure int
+		if len(s.failures) > 0 {
+			failure, s.failures = s.failures[0], s.failures[1:]
+		}
+		s.mu.Unlock()
+
+		if failure != 0 {
+			writeError(w, failure, "SERVER_ERROR", "injected failure")
+			return
+		}
+		if key, secret, ok := r.BasicAuth(); !ok || key != s.KeyID || secret != s.KeySecret {
+			writeError(w, http.StatusUnauthorized, "BAD_REQUEST_ERROR", "Authentication failed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) createOrder(w http.ResponseWriter, r *http.Request) {
+	var req razorpay.CreateOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST_ERROR", "invalid request body")
+		return
+	}
+	if req.Amount < 100 {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST_ERROR", "Order amount less than minimum amount allowed")
+		return
+	}
+	if req.Currency == "" {
+		req.Currency = "INR"
+	}
+
+	s.mu.Lock()
+	order := &razorpay.Order{
+		ID:        s.nextID("order"),
+		Entity:    "order",
+		Amount:    req.Amount,
+		AmountDue: req.Amount,
+		Currency:  req.Currency,
+		Receipt:   req.Receipt,
+		Status:    razorpay.OrderStatusCreated,
+		Notes:     req.Notes,
+		CreatedAt: time.Now().Unix(),
+	}
+	s.orders[order.ID] = order
+	resp := *order
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) fetchOrder(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	order, ok := s.orders[r.PathValue("id")]
+	var resp razorpay.Order
+	if ok {
+		resp = *order
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "BAD_REQUEST_ERROR", "The id provided does not exist")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) fetchOrderPayments(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("id")
+	s.mu.Lock()
+	_, ok := s.orders[orderID]
+	items := []razorpay.Payment{}
+	for _, payment := range s.payments {
+		if payment.OrderID == orderID {
+			items = append(items, *payment)
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "BAD_REQUEST_ERROR", "The id provided does not exist")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"entity": "collection", "count": len(items), "items": items})
+}
+
+func (s *Server) fetchPayment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	payment, ok := s.payments[r.PathValue("id")]
+	var resp *razorpay.Payment
+	if ok {
+		resp = s.copyPayment(payment)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "BAD_REQUEST_ERROR", "The id provided does not exist")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) capturePayment(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Amount   int64  `json:"amount"`
+		Currency string `json:"currency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST_ERROR", "invalid request body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payment, ok := s.payments[r.PathValue("id")]
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound, "BAD_REQUEST_ERROR", "The id provided does not exist")
+	case payment.Status != razorpay.PaymentStatusAuthorized:
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST_ERROR", "This payment has already been captured")
+	case req.Amount != payment.Amount:
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST_ERROR", "Capture amount must be equal to the amount authorized")
+	default:
+		payment.Status = razorpay.PaymentStatusCaptured
+		payment.Captured = true
+		if order, ok := s.orders[payment.OrderID]; ok {
+			s.markPaid(order, payment)
+		}
+		writeJSON(w, http.StatusOK, s.copyPayment(payment))
+	}
+}
+
+func (s *Server) createRefund(w http.ResponseWriter, r *http.Request) {
+	var req razorpay.CreateRefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST_ERROR", "invalid request body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payment, ok := s.payments[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "BAD_REQUEST_ERROR", "The id provided does not exist")
+		return
+	}
+	remaining := payment.Amount - payment.AmountRefunded
+	amount := req.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+	switch {
+	case !payment.Captured:
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST_ERROR", "The payment has not been captured")
+		return
+	case amount <= 0 || amount > remaining:
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST_ERROR", "The refund amount provided is greater than amount captured")
+		return
+	}
+
+	refund := &razorpay.Refund{
+		ID:        s.nextID("rfnd"),
+		Entity:    "refund",
+		Amount:    amount,
+		Currency:  payment.Currency,
+		PaymentID: payment.ID,
+		Status:    s.RefundStatus,
+		Notes:     req.Notes,
+		CreatedAt: time.Now().Unix(),
+	}
+	if req.Receipt != "" {
+		receipt := req.Receipt
+		refund.Receipt = &receipt
+	}
+	s.refunds[refund.ID] = refund
+
+	payment.AmountRefunded += amount
+	refundStatus := "partial"
+	if payment.AmountRefunded == payment.Amount {
+		refundStatus = "full"
+		payment.Status = razorpay.PaymentStatusRefunded
+	}
+	payment.RefundStatus = &refundStatus
+
+	writeJSON(w, http.StatusOK, *refund)
+}
+
+func (s *Server) fetchPaymentRefunds(w http.ResponseWriter, r *http.Request) {
+	paymentID := r.PathValue("id")
+	s.mu.Lock()
+	_, ok := s.payments[paymentID]
+	items := []razorpay.Refund{}
+	for _, refund := range s.refunds {
+		if refund.PaymentID == paymentID {
+			items = append(items, *refund)
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "BAD_REQUEST_ERROR", "The id provided does not exist")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"entity": "collection", "count": len(items), "items": items})
+}
+
+func (s *Server) fetchRefund(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	refund, ok := s.refunds[r.PathValue("id")]
+	var resp razorpay.Refund
+	if ok {
+		resp = *refund
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "BAD_REQUEST_ERROR", "The id provided does not exist")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// nextID returns a Razorpay style id, callers hold the lock
+func (s *Server) nextID(prefix string) string {
+	s.seq++
+	return fmt.Sprintf("%s_test%010d", prefix, s.seq)
+}
+
+func (s *Server) copyPayment(payment *razorpay.Payment) *razorpay.Payment {
+	cp := *payment
+	return &cp
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]string{"code": code, "description": description},
+	})
+}
@@ -0,0 +1,64 @@
+package razorpay
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Refund statuses
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusProcessed = "processed"
+	RefundStatusFailed    = "failed"
+)
+
+type Refund struct {
+	ID        string            `json:"id"`
+	Entity    string            `json:"entity"`
+	Amount    int64             `json:"amount"`
+	Currency  string            `json:"currency"`
+	PaymentID string            `json:"payment_id"`
+	Receipt   *string           `json:"receipt"`
+	Status    string            `json:"status"`
+	Notes     map[string]string `json:"notes,omitempty"`
+	CreatedAt int64             `json:"created_at"`
+}
+
+type CreateRefundRequest struct {
+	// Amount to refund, zero refunds the whole remaining amount
+	Amount  int64             `json:"amount,omitempty"`
+	Receipt string            `json:"receipt,omitempty"`
+	Notes   map[string]string `json:"notes,omitempty"`
+}
+
+// CreateRefund refunds a captured payment. It is never retried, callers reconcile an ambiguous
+// failure with FetchRefund or the payment's refunds instead.
+func (c *Client) CreateRefund(ctx context.Context, paymentID string, req CreateRefundRequest) (*Refund, error) {
+	var refund Refund
+	path := "/payments/" + url.PathEscape(paymentID) + "/refund"
+	if err := c.do(ctx, http.MethodPost, path, req, &refund, false); err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (c *Client) FetchRefund(ctx context.Context, refundID string) (*Refund, error) {
+	var refund Refund
+	if err := c.do(ctx, http.MethodGet, "/refunds/"+url.PathEscape(refundID), nil, &refund, true); err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// FetchPaymentRefunds lists the refunds of a payment
+func (c *Client) FetchPaymentRefunds(ctx context.Context, paymentID string) ([]Refund, error) {
+	var list struct {
+		Items []Refund `json:"items"`
+	}
+	path := "/payments/" + url.PathEscape(paymentID) + "/refunds"
+	if err := c.do(ctx, http.MethodGet, path, nil, &list, true); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
@@ -0,0 +1,20 @@
+package razorpay
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// PaymentSignature is the signature the checkout returns for a successful payment
+func PaymentSignature(orderID, paymentID, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(orderID + "|" + paymentID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyPaymentSignature checks a checkout signature in constant time
+func (c *Client) VerifyPaymentSignature(orderID, paymentID, signature string) bool {
+	expected := PaymentSignature(orderID, paymentID, c.cfg.KeySecret)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
@@ -0,0 +1,73 @@
+package razorpay
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// Webhook events handled by the payment service
+const (
+	EventPaymentCaptured = "payment.captured"
+	EventPaymentFailed   = "payment.failed"
+	EventRefundProcessed = "refund.processed"
+	EventRefundFailed    = "refund.failed"
+)
+
+// WebhookEvent is the body Razorpay posts to the webhook URL
+type WebhookEvent struct {
+	Entity    string         `json:"entity"`
+	AccountID string         `json:"account_id"`
+	Event     string         `json:"event"`
+	Contains  []string       `json:"contains"`
+	Payload   WebhookPayload `json:"payload"`
+	CreatedAt int64          `json:"created_at"`
+}
+
+// WebhookPayload holds the entities an event is about, refund events carry the payment as well
+type WebhookPayload struct {
+	Payment *PaymentEntity `json:"payment,omitempty"`
+	Refund  *RefundEntity  `json:"refund,omitempty"`
+}
+
+type PaymentEntity struct {
+	Entity Payment `json:"entity"`
+}
+
+type RefundEntity struct {
+	Entity Refund `json:"entity"`
+}
+
+// WebhookSignature is the X-Razorpay-Signature of a webhook body
+func WebhookSignature(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookConfigured reports whether a webhook secret is set
+func (c *Client) WebhookConfigured() bool {
+	return c.cfg.WebhookSecret != ""
+}
+
+// VerifyWebhookSignature checks the X-Razorpay-Signature header against the raw body
+func (c *Client) VerifyWebhookSignature(body []byte, signature string) bool {
+	if c.cfg.WebhookSecret == "" {
+		return false
+	}
+	expected := WebhookSignature(body, c.cfg.WebhookSecret)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+func ParseWebhookEvent(body []byte) (*WebhookEvent, error) {
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode razorpay webhook: %w", err)
+	}
+	if event.Event == "" {
+		return nil, fmt.Errorf("razorpay webhook without event type")
+	}
+	return &event, nil
+}
@@ -57,14 +57,6 @@ func (m *AuthMiddleware) RequireAuth(next echo.HandlerFunc) echo.HandlerFunc {
 			}
 		}
 
-		if token == "" {
-			// Try to get from query param (for WebSockets)
-			token = c.QueryParam("token")
-			if token != "" {
-				m.server.Logger.Debug().Msg("Token extracted from query param")
-			}
-		}
-
 		if token == "" {
 			m.server.Logger.Warn().
 				Str("path", c.Request().URL.Path).
@@ -91,16 +83,45 @@ func (m *AuthMiddleware) RequireAuth(next echo.HandlerFunc) echo.HandlerFunc {
 			Str("role", string(claims.Role)).
 			Msg("Authentication successful")
 
-		// Set claims in context for use in handlers
-		c.Set("user", claims)
-		c.Set("user_id", claims.UserID.String())
-		c.Set("role", string(claims.Role))
-		c.Set("email", claims.Email)
+		setClaims(c, claims)
 		return next(c)
 	}
 }
 
+// RequireWSTicket authenticates a websocket upgrade with a single-use ticket from the ticket
+// query param. Browsers cannot set headers on websockets and a JWT in the URL would leak into logs.
+func (m *AuthMiddleware) RequireWSTicket(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ticket := c.QueryParam("ticket")
+		if ticket == "" {
+			return c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": "Missing websocket ticket",
+			})
+		}
 
+		claims, err := m.authService.RedeemWSTicket(c.Request().Context(), ticket)
+		if err != nil {
+			m.server.Logger.Warn().
+				Err(err).
+				Str("path", c.Request().URL.Path).
+				Msg("Websocket ticket redemption failed")
+			return c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": "Invalid or expired ticket",
+			})
+		}
+
+		setClaims(c, claims)
+		return next(c)
+	}
+}
+
+// setClaims sets user claims in context for use in handlers
+func setClaims(c echo.Context, claims *service.JWTClaims) {
+	c.Set("user", claims)
+	c.Set("user_id", claims.UserID.String())
+	c.Set("role", string(claims.Role))
+	c.Set("email", claims.Email)
+}
 
 // RequireRole validates that the authenticated user has one of the specified roles
 func (m *AuthMiddleware) RequireRole(roles ...model.UserRole) echo.MiddlewareFunc {
@@ -0,0 +1,95 @@
+package model
+
+import "time"
+
+// ReceiptStatus is the delivery state a recipient acknowledges for chat messages
+type ReceiptStatus string
+
+const (
+	ReceiptStatusDelivered ReceiptStatus = "delivered"
+	ReceiptStatusRead      ReceiptStatus = "read"
+)
+
+// RideMessage is a chat message between the rider and the driver of a ride
+type RideMessage struct {
+	ID           string     `json:"id" db:"id"`
+	RideID       string     `json:"ride_id" db:"ride_id"`
+	SenderID     string     `json:"sender_id" db:"sender_id"`
+	RecipientID  string     `json:"recipient_id" db:"recipient_id"`
+	Body         string     `json:"body" db:"body"`
+	QuickReplyID *string    `json:"quick_reply_id,omitempty" db:"quick_reply_id"`
+	DeliveredAt  *time.Time `json:"delivered_at,omitempty" db:"delivered_at"`
+	ReadAt       *time.Time `json:"read_at,omitempty" db:"read_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+// SendRideMessageRequest sends either free text or a canned quick reply
+type SendRideMessageRequest struct {
+	Body         string `json:"body,omitempty" validate:"required_without=QuickReplyID,max=500"`
+	QuickReplyID string `json:"quick_reply_id,omitempty" validate:"omitempty,max=50"`
+}
+
+func (r *SendRideMessageRequest) Validate() error {
+	return validate.Struct(r)
+}
+
+// ListRideMessagesRequest pages through a ride's chat history, newest first
+type ListRideMessagesRequest struct {
+	RideID string `param:"id" validate:"required,uuid"`
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Before string `query:"before" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+func (r *ListRideMessagesRequest) Validate() error {
+	return validate.Struct(r)
+}
+
+// RideMessageReceiptRequest acknowledges messages received by the caller
+type RideMessageReceiptRequest struct {
+	MessageIDs []string      `json:"message_ids" validate:"required,min=1,max=100,dive,uuid"`
+	Status     ReceiptStatus `json:"status" validate:"required,oneof=delivered read"`
+}
+
+func (r *RideMessageReceiptRequest) Validate() error {
+	return validate.Struct(r)
+}
+
+// RideMessageReceipt tells the sender which of their messages were delivered or read
+type RideMessageReceipt struct {
+	RideID     string        `json:"ride_id"`
+	MessageIDs []string      `json:"message_ids"`
+	Status     ReceiptStatus `json:"status"`
+	At         time.Time     `json:"at"`
+}
+
+// QuickReply is a canned chat message
+type QuickReply struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// QuickReplies lists the canned messages offered to each role
+var QuickReplies = map[UserRole][]QuickReply{
+	RoleRider: {
+		{ID: "coming_out", Text: "Coming out now"},
+		{ID: "wait_two_minutes", Text: "Please wait 2 minutes"},
+		{ID: "where_are_you", Text: "Where are you?"},
+		{ID: "at_pickup", Text: "I'm at the pickup point"},
+	},
+	RoleDriver: {
+		{ID: "arrived", Text: "I have arrived at the pickup point"},
+		{ID: "on_my_way", Text: "On my way"},
+		{ID: "running_late", Text: "Running a few minutes late"},
+		{ID: "cant_find_you", Text: "I can't find you, please call me"},
+	},
+}
+
+// FindQuickReply returns the quick reply with the given ID offered to the role
+func FindQuickReply(role UserRole, id string) (QuickReply, bool) {
+	for _, reply := range QuickReplies[role] {
+		if reply.ID == id {
+			return reply, true
+		}
+	}
+	return QuickReply{}, false
+}
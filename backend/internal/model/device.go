@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// DeviceToken is a push notification token registered by a user's device
+type DeviceToken struct {
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Token     string    `json:"token" db:"token"`
+	Platform  string    `json:"platform" db:"platform"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// RegisterDeviceRequest registers or refreshes a push token for the caller
+type RegisterDeviceRequest struct {
+	Token    string `json:"token" validate:"required,max=4096"`
+	Platform string `json:"platform" validate:"required,oneof=android ios web"`
+}
+
+func (r *RegisterDeviceRequest) Validate() error {
+	return validate.Struct(r)
+}
+
+// UnregisterDeviceRequest removes a push token of the caller, typically on logout
+type UnregisterDeviceRequest struct {
+	Token string `json:"token" validate:"required,max=4096"`
+}
+
+func (r *UnregisterDeviceRequest) Validate() error {
+	return validate.Struct(r)
+}
@@ -0,0 +1,137 @@
+package model
+
+import "time"
+
+// EarningKind says whether a driver earning comes from a paid ride or is taken back by a refund
+type EarningKind string
+
+const (
+	EarningKindRide   EarningKind = "ride"
+	EarningKindRefund EarningKind = "refund"
+)
+
+// RideEarning is how a ride payment, or a refund of it, splits between the driver and the
+// platform. Amounts are in paise and negative for refunds. Net is what the driver earns, Fare +
+// Tip - Commission; for cash rides the driver already holds it and only owes the commission.
+// Promo is the part of the fare the platform paid for with a promo code, the rider paid the rest.
+type RideEarning struct {
+	ID   string      `json:"id" db:"id"`
+	Kind EarningKind `json:"kind" db:"kind"`
+	// The payment of a ride earning or the refund taking it back, each is recorded once
+	ReferenceID   string    `json:"reference_id" db:"reference_id"`
+	DriverID      string    `json:"driver_id" db:"driver_id"`
+	RideID        string    `json:"ride_id" db:"ride_id"`
+	PaymentID     string    `json:"payment_id" db:"payment_id"`
+	PaymentMethod string    `json:"payment_method" db:"payment_method"`
+	Fare          int64     `json:"fare" db:"fare"`
+	Tip           int64     `json:"tip" db:"tip"`
+	Commission    int64     `json:"commission" db:"commission"`
+	Net           int64     `json:"net" db:"net"`
+	Promo         int64     `json:"promo" db:"promo"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// EarningsTotals add up the ride earnings of a UTC day, in paise
+type EarningsTotals struct {
+	Day           time.Time
+	Rides         int
+	Fares         int64
+	Tips          int64
+	Commission    int64
+	Refunds       int64
+	Net           int64
+	CashCollected int64
+	PlatformDues  int64
+}
+
+// EarningsRequest asks for the earnings of the day, or the Monday to Sunday week, containing a
+// date. Today by default.
+type EarningsRequest struct {
+	Date string `query:"date" validate:"omitempty,datetime=2006-01-02"`
+}
+
+func (r *EarningsRequest) Validate() error {
+	return validate.Struct(r)
+}
+
+// EarningsBreakdown is what a driver earned in a period, in rupees
+type EarningsBreakdown struct {
+	Rides       int     `json:"rides"`
+	Fares       float64 `json:"fares"`
+	Tips        float64 `json:"tips"`
+	Commission  float64 `json:"commission"`
+	Refunds     float64 `json:"refunds"`
+	NetEarnings float64 `json:"net_earnings"`
+	// Cash the driver collected from riders and the commission on it, owed to the platform
+	CashCollected float64 `json:"cash_collected"`
+	PlatformDues  float64 `json:"platform_dues"`
+}
+
+type EarningsDay struct {
+	Date string `json:"date"`
+	EarningsBreakdown
+}
+
+// EarningsSummary is a driver's earnings over a day or a week. Days are UTC.
+type EarningsSummary struct {
+	DriverID string `json:"driver_id"`
+	Currency string `json:"currency"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	EarningsBreakdown
+	// Balance is what the platform owes the driver now, negative while the driver owes dues
+	Balance float64 `json:"balance"`
+	// Days break a week down, empty for a single day
+	Days []*EarningsDay `json:"days,omitempty"`
+}
+
+// PayoutBatchStatus says whether a payout batch is complete
+type PayoutBatchStatus string
+
+const (
+	// PayoutBatchStatusOpen is a batch still being settled, an interrupted settlement resumes it
+	PayoutBatchStatusOpen  PayoutBatchStatus = "open"
+	PayoutBatchStatusReady PayoutBatchStatus = "ready"
+)
+
+// PayoutBatch settles the balances of all drivers owed at least the minimum payout at once
+type PayoutBatch struct {
+	ID          string            `json:"id" db:"id"`
+	Status      PayoutBatchStatus `json:"status" db:"status"`
+	PayoutCount int               `json:"payout_count" db:"payout_count"`
+	TotalAmount float64           `json:"total_amount" db:"total_amount"`
+	Currency    string            `json:"currency" db:"currency"`
+	CreatedAt   time.Time         `json:"created_at" db:"created_at"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// Payout pays a driver's balance out to their bank account
+type Payout struct {
+	ID        string    `json:"id" db:"id"`
+	BatchID   string    `json:"batch_id" db:"batch_id"`
+	DriverID  string    `json:"driver_id" db:"driver_id"`
+	Amount    float64   `json:"amount" db:"amount"`
+	Currency  string    `json:"currency" db:"currency"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Set when payouts are listed for a payout file
+	DriverName    string  `json:"driver_name,omitempty" db:"name"`
+	Phone         *string `json:"phone,omitempty" db:"phone"`
+	VehicleNumber string  `json:"vehicle_number,omitempty" db:"vechile_number"`
+}
+
+type ListPayoutBatchesRequest struct {
+	Limit int `query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+func (r *ListPayoutBatchesRequest) Validate() error {
+	return validate.Struct(r)
+}
+
+type PayoutFileRequest struct {
+	BatchID string `param:"id" validate:"required,uuid"`
+}
+
+func (r *PayoutFileRequest) Validate() error {
+	return validate.Struct(r)
+}
@@ -0,0 +1,65 @@
+package model
+
+import "time"
+
+// LocationFlagReason says why a driver location update looked spoofed
+type LocationFlagReason string
+
+const (
+	// FlagImpossibleSpeed is a move no vehicle can make, the update is rejected
+	FlagImpossibleSpeed LocationFlagReason = "impossible_speed"
+	// FlagSuddenJump is a long move without any update in between, the update is kept
+	FlagSuddenJump LocationFlagReason = "sudden_jump"
+	// FlagMockLocation is reported by the driver app when the OS says the fix is mocked, the update is rejected
+	FlagMockLocation LocationFlagReason = "mock_location"
+)
+
+// LocationFlag records a suspicious location update of a driver
+type LocationFlag struct {
+	ID               string             `json:"id"`
+	DriverUserID     string             `json:"driver_user_id"`
+	Reason           LocationFlagReason `json:"reason"`
+	Rejected         bool               `json:"rejected"`
+	Score            int                `json:"score"`
+	Location         Location           `json:"location"`
+	PreviousLocation *Location          `json:"previous_location,omitempty"`
+	DistanceKm       *float64           `json:"distance_km,omitempty"`
+	ElapsedSeconds   *float64           `json:"elapsed_seconds,omitempty"`
+	ImpliedSpeedKmh  *float64           `json:"implied_speed_kmh,omitempty"`
+	RecordedAt       time.Time          `json:"recorded_at"`
+	CreatedAt        time.Time          `json:"created_at"`
+}
+
+// DriverFraudScore is the sum of a driver's flag scores within the scoring window
+type DriverFraudScore struct {
+	DriverUserID  string    `json:"driver_user_id"`
+	Score         int       `json:"score"`
+	Flags         int       `json:"flags"`
+	LastFlaggedAt time.Time `json:"last_flagged_at"`
+}
+
+// DriverFraudAlert is sent to admins when a driver's fraud score reaches the alert threshold
+type DriverFraudAlert struct {
+	DriverUserID string             `json:"driver_user_id"`
+	Score        int                `json:"score"`
+	Threshold    int                `json:"threshold"`
+	Reason       LocationFlagReason `json:"reason"`
+	Timestamp    time.Time          `json:"timestamp"`
+}
+
+type ListFraudScoresRequest struct {
+	Limit int `query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+func (r *ListFraudScoresRequest) Validate() error {
+	return validate.Struct(r)
+}
+
+type ListLocationFlagsRequest struct {
+	DriverID string `param:"id" validate:"required,uuid"`
+	Limit    int    `query:"limit" validate:"omitempty,min=1,max=200"`
+}
+
+func (r *ListLocationFlagsRequest) Validate() error {
+	return validate.Struct(r)
+}
@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// GridCell identifies a square cell of the demand grid by its row (latitude) and column (longitude)
+type GridCell struct {
+	Row int
+	Col int
+}
+
+// HeatmapCellStats is the demand and supply aggregated for one grid cell
+type HeatmapCellStats struct {
+	// OpenRequests are rides currently waiting for a driver
+	OpenRequests int `json:"open_requests"`
+	// RecentRequests are rides requested within the history window that are no longer open
+	RecentRequests int `json:"recent_requests"`
+	// AvailableDrivers are online drivers accepting rides in the cell
+	AvailableDrivers int     `json:"available_drivers"`
+	DemandScore      float64 `json:"demand_score"`
+	SurgeMultiplier  float64 `json:"surge_multiplier"`
+}
+
+// HeatmapRequest asks for the demand heatmap around a point
+type HeatmapRequest struct {
+	Latitude  float64 `query:"lat" validate:"required,latitude"`
+	Longitude float64 `query:"lng" validate:"required,longitude"`
+	RadiusKm  float64 `query:"radius_km" validate:"omitempty,gt=0,max=10"`
+}
+
+func (r *HeatmapRequest) Validate() error {
+	return validate.Struct(r)
+}
+
+// HeatmapResponse is a GeoJSON FeatureCollection with one polygon per cell with demand
+type HeatmapResponse struct {
+	Type        string           `json:"type"`
+	Features    []HeatmapFeature `json:"features"`
+	GeneratedAt time.Time        `json:"generated_at"`
+}
+
+// HeatmapFeature is a GeoJSON Feature for one grid cell
+type HeatmapFeature struct {
+	Type       string           `json:"type"`
+	ID         string           `json:"id"`
+	Geometry   GeoJSONPolygon   `json:"geometry"`
+	Properties HeatmapCellStats `json:"properties"`
+}
+
+// GeoJSONPolygon is a GeoJSON Polygon, coordinates are [longitude, latitude] rings
+type GeoJSONPolygon struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
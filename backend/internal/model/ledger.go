@@ -0,0 +1,161 @@
+package model
+
+import "time"
+
+// LedgerAccountType is what a ledger account holds money for
+type LedgerAccountType string
+
+const (
+	// LedgerAccountRiderWallet is a rider's wallet balance, it cannot go below zero
+	LedgerAccountRiderWallet LedgerAccountType = "rider_wallet"
+	// LedgerAccountDriverEarnings is what the platform owes a driver, negative when the driver owes
+	LedgerAccountDriverEarnings     LedgerAccountType = "driver_earnings"
+	LedgerAccountPlatformCommission LedgerAccountType = "platform_commission"
+	// LedgerAccountGatewayClearing is the money held for us by the payment gateway
+	LedgerAccountGatewayClearing LedgerAccountType = "gateway_clearing"
+	// LedgerAccountDriverPayouts is the money paid out to the drivers' bank accounts
+	LedgerAccountDriverPayouts LedgerAccountType = "driver_payouts"
+	// LedgerAccountPlatformPromotions is what the platform spent on promo discounts and referral rewards
+	LedgerAccountPlatformPromotions LedgerAccountType = "platform_promotions"
+)
+
+// JournalEntryType is the business event a journal entry records
+type JournalEntryType string
+
+const (
+	JournalEntryWalletTopup JournalEntryType = "wallet_topup"
+	JournalEntryRidePayment JournalEntryType = "ride_payment"
+	JournalEntryRefund      JournalEntryType = "refund"
+	// JournalEntryCashCommission charges the driver the commission of a ride paid in cash
+	JournalEntryCashCommission JournalEntryType = "cash_commission"
+	JournalEntryPayout         JournalEntryType = "payout"
+	// JournalEntryReferralReward credits the wallets of a referrer and the rider they referred
+	JournalEntryReferralReward JournalEntryType = "referral_reward"
+)
+
+// LedgerAccount holds a balance in paise. Rider and driver accounts are owned by the rider's user
+// id or the driver id, platform accounts have no owner.
+type LedgerAccount struct {
+	ID        string            `json:"id" db:"id"`
+	Type      LedgerAccountType `json:"type" db:"type"`
+	OwnerID   *string           `json:"owner_id,omitempty" db:"owner_id"`
+	Currency  string            `json:"currency" db:"currency"`
+	Balance   int64             `json:"balance" db:"balance"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// JournalEntry moves money between ledger accounts. Its postings sum to zero and it is never
+// changed once posted. Each top-up, payment, refund or payout is posted at most once.
+type JournalEntry struct {
+	ID          string           `json:"id" db:"id"`
+	Type        JournalEntryType `json:"type" db:"type"`
+	ReferenceID string           `json:"reference_id" db:"reference_id"`
+	Description string           `json:"description" db:"description"`
+	Currency    string           `json:"currency" db:"currency"`
+	Postings    []*LedgerPosting `json:"postings"`
+	CreatedAt   time.Time        `json:"created_at" db:"created_at"`
+}
+
+// LedgerPosting credits an account with an amount in paise, or debits it when the amount is
+// negative. The account is addressed by type and owner and created on first use.
+type LedgerPosting struct {
+	ID           string            `json:"id" db:"id"`
+	EntryID      string            `json:"entry_id" db:"entry_id"`
+	AccountID    string            `json:"account_id" db:"account_id"`
+	AccountType  LedgerAccountType `json:"account_type" db:"type"`
+	OwnerID      *string           `json:"owner_id,omitempty" db:"owner_id"`
+	Amount       int64             `json:"amount" db:"amount"`
+	BalanceAfter int64             `json:"balance_after" db:"balance_after"`
+	CreatedAt    time.Time         `json:"created_at" db:"created_at"`
+
+	// Set when postings are listed for a statement
+	EntryType   JournalEntryType `json:"entry_type,omitempty" db:"entry_type"`
+	ReferenceID string           `json:"reference_id,omitempty" db:"reference_id"`
+	Description string           `json:"description,omitempty" db:"description"`
+}
+
+// Wallet is the rider's in-app balance, kept in the ledger
+type Wallet struct {
+	UserID       string               `json:"user_id"`
+	Balance      float64              `json:"balance"`
+	Currency     string               `json:"currency"`
+	Transactions []*WalletTransaction `json:"transactions"`
+}
+
+// WalletTransaction is a change of a wallet balance, a credit when positive
+type WalletTransaction struct {
+	ID           string           `json:"id"`
+	Type         JournalEntryType `json:"type"`
+	ReferenceID  string           `json:"reference_id"`
+	Description  string           `json:"description"`
+	Amount       float64          `json:"amount"`
+	BalanceAfter float64          `json:"balance_after"`
+	CreatedAt    time.Time        `json:"created_at"`
+}
+
+// WalletStatementRequest asks for the wallet statement of a period, the last 30 days by default.
+// Dates are inclusive.
+type WalletStatementRequest struct {
+	From string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To   string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+}
+
+// WalletStatement lists the wallet transactions of a period, oldest first
+type WalletStatement struct {
+	UserID         string               `json:"user_id"`
+	Currency       string               `json:"currency"`
+	From           string               `json:"from"`
+	To             string               `json:"to"`
+	OpeningBalance float64              `json:"opening_balance"`
+	ClosingBalance float64              `json:"closing_balance"`
+	TotalCredits   float64              `json:"total_credits"`
+	TotalDebits    float64              `json:"total_debits"`
+	Transactions   []*WalletTransaction `json:"transactions"`
+}
+
+// WalletTopupStatus follows the gateway payment of a top-up
+type WalletTopupStatus string
+
+const (
+	WalletTopupStatusCreated  WalletTopupStatus = "created"
+	WalletTopupStatusCaptured WalletTopupStatus = "captured"
+	WalletTopupStatusFailed   WalletTopupStatus = "failed"
+)
+
+// WalletTopup adds money to a rider's wallet through the payment gateway
+type WalletTopup struct {
+	ID               string            `json:"id" db:"id"`
+	UserID           string            `json:"user_id" db:"user_id"`
+	Amount           float64           `json:"amount" db:"amount"`
+	Currency         string            `json:"currency" db:"currency"`
+	Status           WalletTopupStatus `json:"status" db:"status"`
+	Provider         *string           `json:"provider,omitempty" db:"provider"`
+	GatewayOrderID   *string           `json:"gateway_order_id,omitempty" db:"gateway_order_id"`
+	GatewayPaymentID *string           `json:"gateway_payment_id,omitempty" db:"gateway_payment_id"`
+	CreatedAt        time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// CreateWalletTopupRequest starts a top-up of the caller's wallet
+type CreateWalletTopupRequest struct {
+	Amount float64 `json:"amount" validate:"required,gte=10,lte=10000"`
+}
+
+// CreateWalletTopupResponse carries what the checkout needs to pay the top-up
+type CreateWalletTopupResponse struct {
+	TopupID        string  `json:"topup_id"`
+	Provider       string  `json:"provider"`
+	GatewayOrderID string  `json:"gateway_order_id"`
+	GatewayKeyID   string  `json:"gateway_key_id"`
+	Amount         float64 `json:"amount"`
+	Currency       string  `json:"currency"`
+}
+
+// VerifyWalletTopupRequest confirms the checkout of a top-up
+type VerifyWalletTopupRequest struct {
+	TopupID          string `json:"topup_id" validate:"required,uuid"`
+	GatewayOrderID   string `json:"gateway_order_id" validate:"required"`
+	GatewayPaymentID string `json:"gateway_payment_id" validate:"required"`
+	GatewaySignature string `json:"gateway_signature" validate:"required"`
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 // Location represents a geographic coordinate
 type Location struct {
 	Latitude  float64 `json:"latitude" validate:"required,latitude"`
@@ -12,6 +14,50 @@ type LocationUpdate struct {
 	Location Location `json:"location" validate:"required"`
 	Heading  float64  `json:"heading,omitempty" validate:"omitempty,min=0,max=360"`
 	Speed    float64  `json:"speed,omitempty" validate:"omitempty,min=0"`
+	// IsMock is set by the driver app when the OS reports the fix as coming from a mock provider
+	IsMock bool `json:"is_mock,omitempty"`
+}
+
+// LocationFix is a timestamped GPS fix, buffered by the driver app while offline
+type LocationFix struct {
+	Location   Location  `json:"location" validate:"required"`
+	Heading    float64   `json:"heading,omitempty" validate:"omitempty,min=0,max=360"`
+	Speed      float64   `json:"speed,omitempty" validate:"omitempty,min=0"`
+	RecordedAt time.Time `json:"recorded_at" validate:"required"`
+	IsMock     bool      `json:"is_mock,omitempty"`
+}
+
+// LocationBatchRequest uploads buffered fixes, oldest first
+type LocationBatchRequest struct {
+	Fixes []LocationFix `json:"fixes" validate:"required,min=1,max=500,dive"`
+}
+
+func (r *LocationBatchRequest) Validate() error {
+	return validate.Struct(r)
+}
+
+// RejectedFix reports a fix of a batch that was dropped
+type RejectedFix struct {
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
+}
+
+// LocationBatchResponse summarises what happened to an uploaded batch
+type LocationBatchResponse struct {
+	Accepted int           `json:"accepted"`
+	Rejected []RejectedFix `json:"rejected"`
+	// LiveUpdated is false when even the newest fix is too old to be the driver's live position
+	LiveUpdated bool `json:"live_updated"`
+}
+
+// DriverLocationEvent is streamed to the rider while their assigned driver is on the way or on trip
+type DriverLocationEvent struct {
+	RideID    string    `json:"ride_id"`
+	DriverID  string    `json:"driver_id"`
+	Location  Location  `json:"location"`
+	Heading   float64   `json:"heading"`
+	Speed     float64   `json:"speed"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 // NearbyDriver represents a driver near a location
@@ -28,15 +74,17 @@ type NearbyDriver struct {
 
 // NearbyDriversRequest represents a request to find nearby drivers
 type NearbyDriversRequest struct {
-	Location Location `json:"location"`
-	RadiusKm float64  `json:"radius_km,omitempty"` // Optional, defaults to 5km
-	Limit    int      `json:"limit,omitempty"`     // Optional, defaults to 20
+	Location    Location `json:"location"`
+	RadiusKm    float64  `json:"radius_km,omitempty"` // Optional, defaults to 5km
+	Limit       int      `json:"limit,omitempty"`     // Optional, defaults to 20
+	VehicleType string   `json:"vehicle_type,omitempty" validate:"omitempty,oneof=bike car auto suv"`
+	MinRating   float64  `json:"min_rating,omitempty" validate:"omitempty,min=0,max=5"`
 }
 
-type NearByDriversResponseFromRedis struct{
-	ID   string `json:"id"`
-	Distance float64 `json:"distance"`
-	Latitude float64 `json:"latitude"`
+type NearByDriversResponseFromRedis struct {
+	ID        string  `json:"id"`
+	Distance  float64 `json:"distance"`
+	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
 }
 
@@ -46,5 +94,15 @@ func (l *LocationUpdate) Validate() error {
 }
 
 func (n *NearbyDriversRequest) Validate() error {
-	return nil
+	return validate.Struct(n)
+}
+
+// DriverLocationSample is a driver position queued for persistence in PostgreSQL
+type DriverLocationSample struct {
+	DriverUserID string
+	RideID       *string
+	Location     Location
+	Heading      float64
+	Speed        float64
+	RecordedAt   time.Time
 }
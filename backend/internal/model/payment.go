@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
 
 // PaymentStatus represents the status of a payment
 type PaymentStatusType string
@@ -11,54 +15,82 @@ const (
 	PaymentStatusTypeAuthorized PaymentStatusType = "authorized"
 	PaymentStatusTypeCaptured   PaymentStatusType = "captured"
 	PaymentStatusTypeFailed     PaymentStatusType = "failed"
-	PaymentStatusTypeRefunded   PaymentStatusType = "refunded"
+	// Some but not all of the captured amount was refunded
+	PaymentStatusTypePartiallyRefunded PaymentStatusType = "partially_refunded"
+	PaymentStatusTypeRefunded          PaymentStatusType = "refunded"
 )
 
 // Payment represents a payment in the system
 type Payment struct {
-	ID                string            `json:"id" db:"id"`
-	RideID            string            `json:"ride_id" db:"ride_id"`
-	UserID            string            `json:"user_id" db:"user_id"`
-	Amount            float64           `json:"amount" db:"amount"`
-	Currency          string            `json:"currency" db:"currency"`
-	RazorpayOrderID   *string           `json:"razorpay_order_id,omitempty" db:"razorpay_order_id"`
-	RazorpayPaymentID *string           `json:"razorpay_payment_id,omitempty" db:"razorpay_payment_id"`
-	RazorpaySignature *string           `json:"razorpay_signature,omitempty" db:"razorpay_signature"`
-	Status            PaymentStatusType `json:"status" db:"status"`
-	PaymentMethod     string            `json:"payment_method" db:"payment_method"`
-	CreatedAt         time.Time         `json:"created_at" db:"created_at"`
-	UpdatedAt         time.Time         `json:"updated_at" db:"updated_at"`
+	ID       string  `json:"id" db:"id"`
+	RideID   string  `json:"ride_id" db:"ride_id"`
+	UserID   string  `json:"user_id" db:"user_id"`
+	Amount   float64 `json:"amount" db:"amount"`
+	Currency string  `json:"currency" db:"currency"`
+	// Provider is the payment gateway the order was created at
+	Provider         *string           `json:"provider,omitempty" db:"provider"`
+	GatewayOrderID   *string           `json:"gateway_order_id,omitempty" db:"gateway_order_id"`
+	GatewayPaymentID *string           `json:"gateway_payment_id,omitempty" db:"gateway_payment_id"`
+	GatewaySignature *string           `json:"gateway_signature,omitempty" db:"gateway_signature"`
+	Status           PaymentStatusType `json:"status" db:"status"`
+	PaymentMethod    string            `json:"payment_method" db:"payment_method"`
+	CreatedAt        time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// PaymentEvent is a gateway webhook delivery
+type PaymentEvent struct {
+	ID          string          `json:"id" db:"id"`
+	EventType   string          `json:"event_type" db:"event_type"`
+	PaymentID   *string         `json:"payment_id,omitempty" db:"payment_id"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	ReceivedAt  time.Time       `json:"received_at" db:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at,omitempty" db:"processed_at"`
 }
 
-// CreatePaymentOrderRequest represents a request to create a payment order
+// RideCharges are the fare of a ride and the adjustments that make up the amount to pay
+type RideCharges struct {
+	Fare          float64 `json:"fare" db:"fare"`
+	Tip           float64 `json:"tip" db:"tip_amount"`
+	WalletApplied float64 `json:"wallet_applied" db:"wallet_applied"`
+	PromoDiscount float64 `json:"promo_discount" db:"promo_discount"`
+}
+
+// Payable is the amount left to pay. Wallet and promo only reduce the fare, the tip is always paid.
+func (c RideCharges) Payable() float64 {
+	fare := math.Max(c.Fare-c.WalletApplied-c.PromoDiscount, 0)
+	return math.Round((fare+c.Tip)*100) / 100
+}
+
+// CreatePaymentOrderRequest represents a request to create a payment order. The amount is
+// computed from the ride, the rider only chooses the tip.
 type CreatePaymentOrderRequest struct {
 	RideID        string  `json:"ride_id" validate:"required"`
-	Amount        float64 `json:"amount" validate:"required,gt=0"`
 	PaymentMethod string  `json:"payment_method" validate:"required,oneof=cash upi card wallet"`
+	Tip           float64 `json:"tip" validate:"omitempty,gte=0,max=1000"`
 }
 
 // CreatePaymentOrderResponse represents the response for creating a payment order
 type CreatePaymentOrderResponse struct {
-	PaymentID       string  `json:"payment_id"`
-	RazorpayOrderID *string `json:"razorpay_order_id,omitempty"`
-	RazorpayKeyID   *string `json:"razorpay_key_id,omitempty"`
-	Amount          float64 `json:"amount"`
-	Currency        string  `json:"currency"`
-	PaymentMethod   string  `json:"payment_method"`
+	PaymentID      string      `json:"payment_id"`
+	Provider       *string     `json:"provider,omitempty"`
+	GatewayOrderID *string     `json:"gateway_order_id,omitempty"`
+	GatewayKeyID   *string     `json:"gateway_key_id,omitempty"`
+	Amount         float64     `json:"amount"`
+	Currency       string      `json:"currency"`
+	PaymentMethod  string      `json:"payment_method"`
+	Status         string      `json:"status"`
+	Breakdown      RideCharges `json:"breakdown"`
 }
 
-
-
 // VerifyPaymentRequest represents a request to verify payment
 type VerifyPaymentRequest struct {
-	PaymentID         string `json:"payment_id" validate:"required"`
-	RazorpayOrderID   string `json:"razorpay_order_id" validate:"required"`
-	RazorpayPaymentID string `json:"razorpay_payment_id" validate:"required"`
-	RazorpaySignature string `json:"razorpay_signature" validate:"required"`
+	PaymentID        string `json:"payment_id" validate:"required"`
+	GatewayOrderID   string `json:"gateway_order_id" validate:"required"`
+	GatewayPaymentID string `json:"gateway_payment_id" validate:"required"`
+	GatewaySignature string `json:"gateway_signature" validate:"required"`
 }
 
-
-
 // UPIPaymentRequest represents a UPI payment request
 type UPIPaymentRequest struct {
 	PaymentID string `json:"payment_id" validate:"required"`
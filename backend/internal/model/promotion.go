@@ -0,0 +1,166 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+// DiscountType is how a promo takes money off the fare
+type DiscountType string
+
+const (
+	// DiscountTypePercentage takes a percentage of the fare off, up to the promo's cap
+	DiscountTypePercentage DiscountType = "percentage"
+	// DiscountTypeFlat takes a fixed amount off the fare
+	DiscountTypeFlat DiscountType = "flat"
+)
+
+// PromoRedemptionStatus says whether a redemption still counts towards the promo's limits
+type PromoRedemptionStatus string
+
+const (
+	PromoRedemptionRedeemed PromoRedemptionStatus = "redeemed"
+	// PromoRedemptionReleased is the redemption of a cancelled ride, the rider can use the code again
+	PromoRedemptionReleased PromoRedemptionStatus = "released"
+)
+
+// PromoZone restricts a promo to pickups within a radius of a point
+type PromoZone struct {
+	Name     string   `json:"name" validate:"required,max=100"`
+	Center   Location `json:"center" validate:"required"`
+	RadiusKm float64  `json:"radius_km" validate:"required,gt=0,max=100"`
+}
+
+// Promotion is a promo code riders apply to a fare. The platform funds the discount, drivers earn
+// on the full fare. Amounts are in rupees.
+type Promotion struct {
+	ID            string       `json:"id" db:"id"`
+	Code          string       `json:"code" db:"code"`
+	Description   string       `json:"description" db:"description"`
+	DiscountType  DiscountType `json:"discount_type" db:"discount_type"`
+	DiscountValue float64      `json:"discount_value" db:"discount_value"`
+	MaxDiscount   *float64     `json:"max_discount,omitempty" db:"max_discount"`
+	MinFare       float64      `json:"min_fare" db:"min_fare"`
+	StartsAt      time.Time    `json:"starts_at" db:"starts_at"`
+	EndsAt        *time.Time   `json:"ends_at,omitempty" db:"ends_at"`
+	// UsageLimit caps the redemptions of all riders together, nil for no cap
+	UsageLimit   *int `json:"usage_limit,omitempty" db:"usage_limit"`
+	PerUserLimit int  `json:"per_user_limit" db:"per_user_limit"`
+	UsageCount   int  `json:"usage_count" db:"usage_count"`
+	// VehicleTypes the promo applies to, every type when empty
+	VehicleTypes []string   `json:"vehicle_types" db:"vehicle_types"`
+	Zone         *PromoZone `json:"zone,omitempty"`
+	Active       bool       `json:"active" db:"active"`
+	// GrantedTo is the only user who can redeem a promo given as a reward, nil for everyone
+	GrantedTo *string   `json:"granted_to,omitempty" db:"granted_to"`
+	CreatedBy *string   `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Discount is what the promo takes off a fare, never more than the fare. Minimum fare and the
+// other restrictions are checked by the caller.
+func (p *Promotion) Discount(fare float64) float64 {
+	discount := p.DiscountValue
+	if p.DiscountType == DiscountTypePercentage {
+		discount = fare * p.DiscountValue / 100
+		if p.MaxDiscount != nil {
+			discount = math.Min(discount, *p.MaxDiscount)
+		}
+	}
+	return math.Round(math.Min(discount, fare)*100) / 100
+}
+
+// PromoRedemption is a promo applied to a ride
+type PromoRedemption struct {
+	ID          string                `json:"id" db:"id"`
+	PromotionID string                `json:"promotion_id" db:"promotion_id"`
+	UserID      string                `json:"user_id" db:"user_id"`
+	RideID      string                `json:"ride_id" db:"ride_id"`
+	Discount    float64               `json:"discount" db:"discount"`
+	Status      PromoRedemptionStatus `json:"status" db:"status"`
+	CreatedAt   time.Time             `json:"created_at" db:"created_at"`
+	ReleasedAt  *time.Time            `json:"released_at,omitempty" db:"released_at"`
+}
+
+// PromotionRequest creates a promotion, or replaces one when updating. Percentages are 1-100.
+type PromotionRequest struct {
+	Code          string       `json:"code" validate:"required,alphanum,min=3,max=32"`
+	Description   string       `json:"description" validate:"required,max=255"`
+	DiscountType  DiscountType `json:"discount_type" validate:"required,oneof=percentage flat"`
+	DiscountValue float64      `json:"discount_value" validate:"required,gt=0"`
+	MaxDiscount   *float64     `json:"max_discount,omitempty" validate:"omitempty,gt=0"`
+	MinFare       float64      `json:"min_fare" validate:"gte=0"`
+	// Defaults to now, or the current start when updating
+	StartsAt     *time.Time `json:"starts_at,omitempty"`
+	EndsAt       *time.Time `json:"ends_at,omitempty"`
+	UsageLimit   *int       `json:"usage_limit,omitempty" validate:"omitempty,min=1"`
+	PerUserLimit int        `json:"per_user_limit" validate:"omitempty,min=1"`
+	VehicleTypes []string   `json:"vehicle_types" validate:"omitempty,dive,oneof=bike auto sedan suv"`
+	Zone         *PromoZone `json:"zone,omitempty"`
+	// Defaults to true, or the current state when updating
+	Active *bool `json:"active,omitempty"`
+}
+
+func (r *PromotionRequest) Validate() error {
+	return validate.Struct(r)
+}
+
+type UpdatePromotionRequest struct {
+	ID string `param:"id" json:"-" validate:"required,uuid"`
+	PromotionRequest
+}
+
+func (r *UpdatePromotionRequest) Validate() error {
+	return validate.Struct(r)
+}
+
+type PromotionIDRequest struct {
+	ID string `param:"id" validate:"required,uuid"`
+}
+
+func (r *PromotionIDRequest) Validate() error {
+	return validate.Struct(r)
+}
+
+type ListPromotionsRequest struct {
+	// Only active, or only inactive, promotions. All by default.
+	Active *bool `query:"active"`
+	Limit  int   `query:"limit" validate:"omitempty,min=1,max=200"`
+}
+
+func (r *ListPromotionsRequest) Validate() error {
+	return validate.Struct(r)
+}
+
+// FareQuoteRequest asks for the fare of a trip before requesting the ride
+type FareQuoteRequest struct {
+	PickupLocation  Location    `json:"pickup_location" validate:"required"`
+	DropoffLocation Location    `json:"dropoff_location" validate:"required"`
+	VehicleType     VehicleType `json:"vehicle_type" validate:"required,oneof=bike auto sedan suv"`
+	PromoCode       string      `json:"promo_code,omitempty" validate:"omitempty,max=32"`
+}
+
+func (r *FareQuoteRequest) Validate() error {
+	return validate.Struct(r)
+}
+
+// FareBreakdown is how a fare adds up and what a promo takes off it, in rupees. Payable is the
+// fare after the promo, before any tip.
+type FareBreakdown struct {
+	BaseFare      float64 `json:"base_fare"`
+	DistanceFare  float64 `json:"distance_fare"`
+	TimeFare      float64 `json:"time_fare"`
+	Fare          float64 `json:"fare"`
+	PromoDiscount float64 `json:"promo_discount"`
+	Payable       float64 `json:"payable"`
+}
+
+// FareQuote is the estimated fare of a trip with the promo applied
+type FareQuote struct {
+	DistanceKm      float64       `json:"distance_km"`
+	DurationMinutes int           `json:"duration_minutes"`
+	VehicleType     VehicleType   `json:"vehicle_type"`
+	PromoCode       *string       `json:"promo_code,omitempty"`
+	Breakdown       FareBreakdown `json:"fare_breakdown"`
+}
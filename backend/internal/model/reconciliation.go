@@ -0,0 +1,78 @@
+package model
+
+import "time"
+
+// DiscrepancyKind is how a payment differs from the gateway's records
+type DiscrepancyKind string
+
+const (
+	// DiscrepancyMissedCapture is a payment the gateway captured while ours was still open, repaired
+	DiscrepancyMissedCapture DiscrepancyKind = "missed_capture"
+	// DiscrepancyMissedFailure is an order whose every attempt failed while our payment was still
+	// open, repaired
+	DiscrepancyMissedFailure DiscrepancyKind = "missed_failure"
+	// DiscrepancyUncapturedAuthorization is a payment authorized at the gateway and never captured,
+	// repaired by capturing it
+	DiscrepancyUncapturedAuthorization DiscrepancyKind = "uncaptured_authorization"
+	// DiscrepancyAmountMismatch is a gateway payment of another amount than our payment
+	DiscrepancyAmountMismatch DiscrepancyKind = "amount_mismatch"
+	// DiscrepancyDuplicateCapture is an order the rider paid more than once
+	DiscrepancyDuplicateCapture DiscrepancyKind = "duplicate_capture"
+	// DiscrepancyOrderNotFound is an order the gateway does not know
+	DiscrepancyOrderNotFound DiscrepancyKind = "order_not_found"
+	// DiscrepancyProviderUnavailable is an order of a provider that is no longer configured
+	DiscrepancyProviderUnavailable DiscrepancyKind = "provider_unavailable"
+)
+
+type DiscrepancyStatus string
+
+const (
+	// DiscrepancyStatusOpen waits for an admin
+	DiscrepancyStatusOpen DiscrepancyStatus = "open"
+	// DiscrepancyStatusRepaired was fixed by the reconciliation job
+	DiscrepancyStatusRepaired DiscrepancyStatus = "repaired"
+	// DiscrepancyStatusResolved was reviewed by an admin
+	DiscrepancyStatusResolved DiscrepancyStatus = "resolved"
+)
+
+// PaymentDiscrepancy is a difference between a payment and the gateway's records found by the
+// reconciliation job
+type PaymentDiscrepancy struct {
+	ID            string            `json:"id" db:"id"`
+	PaymentID     string            `json:"payment_id" db:"payment_id"`
+	Kind          DiscrepancyKind   `json:"kind" db:"kind"`
+	Status        DiscrepancyStatus `json:"status" db:"status"`
+	PaymentStatus PaymentStatusType `json:"payment_status" db:"payment_status"`
+	Amount        float64           `json:"amount" db:"amount"`
+	Provider      *string           `json:"provider,omitempty" db:"provider"`
+	// Gateway side, set when the gateway has a payment for the order
+	GatewayOrderID   *string    `json:"gateway_order_id,omitempty" db:"gateway_order_id"`
+	GatewayPaymentID *string    `json:"gateway_payment_id,omitempty" db:"gateway_payment_id"`
+	GatewayStatus    *string    `json:"gateway_status,omitempty" db:"gateway_status"`
+	GatewayAmount    *float64   `json:"gateway_amount,omitempty" db:"gateway_amount"`
+	Details          string     `json:"details" db:"details"`
+	ResolvedBy       *string    `json:"resolved_by,omitempty" db:"resolved_by"`
+	ResolutionNote   *string    `json:"resolution_note,omitempty" db:"resolution_note"`
+	DetectedAt       time.Time  `json:"detected_at" db:"detected_at"`
+	LastSeenAt       time.Time  `json:"last_seen_at" db:"last_seen_at"`
+	ResolvedAt       *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+}
+
+// ReconciliationResult counts what a reconciliation run did
+type ReconciliationResult struct {
+	Checked  int `json:"checked"`
+	Repaired int `json:"repaired"`
+	Flagged  int `json:"flagged"`
+}
+
+type ListPaymentDiscrepanciesRequest struct {
+	// Status defaults to open
+	Status string `query:"status" validate:"omitempty,oneof=open repaired resolved"`
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=200"`
+}
+
+// ResolvePaymentDiscrepancyRequest closes an open discrepancy with what was done about it
+type ResolvePaymentDiscrepancyRequest struct {
+	ID   string `param:"id" json:"-" validate:"required,uuid"`
+	Note string `json:"note" validate:"required,min=3,max=500"`
+}
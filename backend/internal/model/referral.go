@@ -0,0 +1,64 @@
+package model
+
+import "time"
+
+// ReferralStatus is where a referral is in the program
+type ReferralStatus string
+
+const (
+	// ReferralStatusPending waits for the referred rider's first paid ride
+	ReferralStatusPending  ReferralStatus = "pending"
+	ReferralStatusRewarded ReferralStatus = "rewarded"
+	// ReferralStatusRejected failed a fraud check, nobody is rewarded
+	ReferralStatusRejected ReferralStatus = "rejected"
+)
+
+// Why a referral was rejected
+const (
+	ReferralRejectSelf      = "self_referral"
+	ReferralRejectPhone     = "same_phone"
+	ReferralRejectDevice    = "same_device"
+	ReferralRejectRideDrive = "referrer_drove_ride"
+)
+
+// Referral is a rider who signed up with another user's referral code. Rewards are in rupees.
+type Referral struct {
+	ID           string         `json:"id" db:"id"`
+	ReferrerID   string         `json:"-" db:"referrer_id"`
+	RefereeID    string         `json:"-" db:"referee_id"`
+	Code         string         `json:"code" db:"code"`
+	Status       ReferralStatus `json:"status" db:"status"`
+	RejectReason *string        `json:"-" db:"reject_reason"`
+	RideID       *string        `json:"-" db:"ride_id"`
+	// RewardType, the rewards and promo codes are set once the referral is rewarded
+	RewardType        *string    `json:"reward_type,omitempty" db:"reward_type"`
+	ReferrerReward    float64    `json:"-" db:"referrer_reward"`
+	RefereeReward     float64    `json:"-" db:"referee_reward"`
+	ReferrerPromoCode *string    `json:"-" db:"referrer_promo_code"`
+	RefereePromoCode  *string    `json:"-" db:"referee_promo_code"`
+	RewardedAt        *time.Time `json:"rewarded_at,omitempty" db:"rewarded_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Name is the first name of the other party, Reward and PromoCode what the caller got
+	Name      string  `json:"name"`
+	Reward    float64 `json:"reward"`
+	PromoCode *string `json:"promo_code,omitempty"`
+}
+
+// ReferralSummary is a user's referral code with the referrals they made and their own
+type ReferralSummary struct {
+	ReferralCode string `json:"referral_code"`
+	// The current offer for both sides
+	RewardType     string  `json:"reward_type"`
+	ReferrerReward float64 `json:"referrer_reward"`
+	RefereeReward  float64 `json:"referee_reward"`
+	// ReferredBy is the user's own referral if they signed up with a code
+	ReferredBy *Referral   `json:"referred_by,omitempty"`
+	Referrals  []*Referral `json:"referrals"`
+	Pending    int         `json:"pending"`
+	Rewarded   int         `json:"rewarded"`
+	Rejected   int         `json:"rejected"`
+	// Earned is the total of the caller's referrer rewards
+	Earned float64 `json:"earned"`
+}
@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+// RefundStatus follows the gateway's refund lifecycle
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusProcessed RefundStatus = "processed"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+// RefundDestination is where the refunded money goes
+type RefundDestination string
+
+const (
+	// RefundDestinationGateway returns the money to the instrument the rider paid with
+	RefundDestinationGateway RefundDestination = "gateway"
+	// RefundDestinationWallet credits the rider's in-app wallet, used for cash payments
+	RefundDestinationWallet RefundDestination = "wallet"
+)
+
+// RefundSource is what started a refund
+type RefundSource string
+
+const (
+	RefundSourceAdmin  RefundSource = "admin"
+	RefundSourcePolicy RefundSource = "policy"
+	// RefundSourceGateway is a refund made outside the app, e.g. in the Razorpay dashboard
+	RefundSourceGateway RefundSource = "gateway"
+)
+
+// Refund returns all or part of a captured payment
+type Refund struct {
+	ID              string            `json:"id" db:"id"`
+	PaymentID       string            `json:"payment_id" db:"payment_id"`
+	UserID          string            `json:"user_id" db:"user_id"`
+	Amount          float64           `json:"amount" db:"amount"`
+	Currency        string            `json:"currency" db:"currency"`
+	Destination     RefundDestination `json:"destination" db:"destination"`
+	Source          RefundSource      `json:"source" db:"source"`
+	Reason          string            `json:"reason" db:"reason"`
+	InitiatedBy     *string           `json:"initiated_by,omitempty" db:"initiated_by"`
+	Status          RefundStatus      `json:"status" db:"status"`
+	GatewayRefundID *string           `json:"gateway_refund_id,omitempty" db:"gateway_refund_id"`
+	FailureReason   *string           `json:"failure_reason,omitempty" db:"failure_reason"`
+	CreatedAt       time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at" db:"updated_at"`
+	ProcessedAt     *time.Time        `json:"processed_at,omitempty" db:"processed_at"`
+}
+
+// CreateRefundRequest asks for a refund of a payment, without an amount the whole remaining amount
+// is refunded
+type CreateRefundRequest struct {
+	Amount float64 `json:"amount" validate:"omitempty,gte=0.01"`
+	Reason string  `json:"reason" validate:"required,min=3,max=255"`
+}
@@ -21,6 +21,9 @@ const (
 	PaymentStatusPending   PaymentStatus = "pending"
 	PaymentStatusCompleted PaymentStatus = "completed"
 	PaymentStatusFailed    PaymentStatus = "failed"
+	PaymentStatusRefunded  PaymentStatus = "refunded"
+	// PaymentStatusPartiallyRefunded is a paid ride of which part was refunded
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
 )
 
 // VehicleType represents the type of vehicle for a ride
@@ -59,16 +62,18 @@ type Ride struct {
 	Fare            *float64       `json:"fare,omitempty" db:"fare"`
 	DistanceKm      *float64       `json:"distance_km,omitempty" db:"distance_km"`
 	DurationMinutes *int           `json:"duration_minutes,omitempty" db:"duration_minutes"`
-	RequestedAt     time.Time      `json:"requested_at" db:"requested_at"`
-	AcceptedAt      *time.Time     `json:"accepted_at,omitempty" db:"accepted_at"`
-	StartedAt       *time.Time     `json:"started_at,omitempty" db:"started_at"`
-	CompletedAt     *time.Time     `json:"completed_at,omitempty" db:"completed_at"`
-	PaymentStatus   PaymentStatus  `json:"payment_status" db:"payment_status"`
-	PaymentID       *string        `json:"payment_id,omitempty" db:"payment_id"`
-	Rating          *int           `json:"rating,omitempty" db:"rating"`
-	Feedback        *string        `json:"feedback,omitempty" db:"feedback"`
-	CreatedAt       time.Time      `json:"created_at" db:"created_at"`
-	UpdatedAt       time.Time      `json:"updated_at" db:"updated_at"`
+	// PromoDiscount is taken off the fare by the promo code applied when the ride was requested
+	PromoDiscount float64       `json:"promo_discount" db:"promo_discount"`
+	RequestedAt   time.Time     `json:"requested_at" db:"requested_at"`
+	AcceptedAt    *time.Time    `json:"accepted_at,omitempty" db:"accepted_at"`
+	StartedAt     *time.Time    `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt   *time.Time    `json:"completed_at,omitempty" db:"completed_at"`
+	PaymentStatus PaymentStatus `json:"payment_status" db:"payment_status"`
+	PaymentID     *string       `json:"payment_id,omitempty" db:"payment_id"`
+	Rating        *int          `json:"rating,omitempty" db:"rating"`
+	Feedback      *string       `json:"feedback,omitempty" db:"feedback"`
+	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at" db:"updated_at"`
 }
 
 // RideRequest represents a request to create a new ride
@@ -79,30 +84,32 @@ type RideRequest struct {
 	DropoffAddress  string        `json:"dropoff_address" validate:"required,min=5,max=500"`
 	VehicleType     VehicleType   `json:"vehicle_type" validate:"required,oneof=bike auto sedan suv"`
 	PaymentMethod   PaymentMethod `json:"payment_method" validate:"required,oneof=cash upi card wallet"`
+	PromoCode       string        `json:"promo_code,omitempty" validate:"omitempty,max=32"`
 }
 
 // RideResponse represents a ride with additional driver information
 type RideResponse struct {
-	ID              string        `json:"id"`
-	Status          RideStatus    `json:"status"`
-	PickupAddress   string        `json:"pickup_address"`
-	DropoffAddress  string        `json:"dropoff_address"`
-	PickupLocation  Location      `json:"pickup_location"`
-	DropoffLocation Location      `json:"dropoff_location"`
-	VehicleType     VehicleType   `json:"vehicle_type"`
-	PaymentMethod   PaymentMethod `json:"payment_method"`
-	OTP             string        `json:"otp,omitempty"`
-	Fare            *float64      `json:"fare,omitempty"`
-	DistanceKm      *float64      `json:"distance_km,omitempty"`
-	DurationMinutes *int          `json:"duration_minutes,omitempty"`
-	Driver          *DriverInfo   `json:"driver,omitempty"`
-	RequestedAt     time.Time     `json:"requested_at"`
-	AcceptedAt      *time.Time    `json:"accepted_at,omitempty"`
-	StartedAt       *time.Time    `json:"started_at,omitempty"`
-	CompletedAt     *time.Time    `json:"completed_at,omitempty"`
-	PaymentStatus   PaymentStatus `json:"payment_status"`
-	Rating          *int          `json:"rating,omitempty"`
-	Feedback        *string       `json:"feedback,omitempty"`
+	ID              string         `json:"id"`
+	Status          RideStatus     `json:"status"`
+	PickupAddress   string         `json:"pickup_address"`
+	DropoffAddress  string         `json:"dropoff_address"`
+	PickupLocation  Location       `json:"pickup_location"`
+	DropoffLocation Location       `json:"dropoff_location"`
+	VehicleType     VehicleType    `json:"vehicle_type"`
+	PaymentMethod   PaymentMethod  `json:"payment_method"`
+	OTP             string         `json:"otp,omitempty"`
+	Fare            *float64       `json:"fare,omitempty"`
+	DistanceKm      *float64       `json:"distance_km,omitempty"`
+	DurationMinutes *int           `json:"duration_minutes,omitempty"`
+	FareBreakdown   *FareBreakdown `json:"fare_breakdown,omitempty"`
+	Driver          *DriverInfo    `json:"driver,omitempty"`
+	RequestedAt     time.Time      `json:"requested_at"`
+	AcceptedAt      *time.Time     `json:"accepted_at,omitempty"`
+	StartedAt       *time.Time     `json:"started_at,omitempty"`
+	CompletedAt     *time.Time     `json:"completed_at,omitempty"`
+	PaymentStatus   PaymentStatus  `json:"payment_status"`
+	Rating          *int           `json:"rating,omitempty"`
+	Feedback        *string        `json:"feedback,omitempty"`
 }
 
 // RideStartRequest represents a request to start a ride with OTP verification
@@ -21,6 +21,10 @@ type User struct {
 	PasswordHash string   `db:"password_hash" json:"-"`
 	Phone        *string  `db:"phone" json:"phone,omitempty"`
 	Role         UserRole `db:"role" json:"role"`
+	// ReferralCode is the code the user shares to refer riders
+	ReferralCode string `db:"referral_code" json:"referral_code"`
+	// SignupDeviceID is the install id of the app the user signed up from, if it sent one
+	SignupDeviceID *string `db:"signup_device_id" json:"-"`
 }
 
 type SignupRequest struct {
@@ -29,6 +33,10 @@ type SignupRequest struct {
 	Password string   `json:"password" validate:"required,min=8,max=72"`
 	Phone    string   `json:"phone,omitempty" validate:"omitempty,e164"`
 	Role     UserRole `json:"role" validate:"required,oneof=rider driver"`
+	// ReferralCode is the code of the user who referred a rider
+	ReferralCode string `json:"referral_code,omitempty" validate:"omitempty,alphanum,max=16"`
+	// DeviceID is the app's install id, used to catch referrals between accounts on one device
+	DeviceID string `json:"device_id,omitempty" validate:"omitempty,max=128"`
 }
 
 func (r *SignupRequest) Validate() error {
@@ -67,3 +75,9 @@ type AuthResponse struct {
 	Token string `json:"token"`
 	User  *User  `json:"user"`
 }
+
+// WSTicketResponse holds a single-use credential for opening a websocket
+type WSTicketResponse struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int    `json:"expires_in"`
+}
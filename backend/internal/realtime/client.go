@@ -3,24 +3,25 @@ package realtime
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog"
-	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
 )
 
 const (
-	writeWait      = 10 * time.Second
-	pongWait       = 60 * time.Second
-	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 512
-)
-
-var (
-	newline = []byte{'\n'}
-	space   = []byte{' '}
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = (pongWait * 9) / 10
+	// Room for a 500 character chat message of multi-byte text plus the envelope
+	maxMessageSize = 4096
+	// Upper bound for processing a single client request
+	requestTimeout = 10 * time.Second
 )
 
 type Client struct {
@@ -29,14 +30,47 @@ type Client struct {
 	send   chan []byte
 	userID string
 	logger zerolog.Logger
+
+	// Guards send against the hub closing it and holds live messages back while replaying
+	mu        sync.Mutex
+	closed    bool
+	replaying bool
+	pending   []pendingMessage
+}
+
+// pendingMessage is a live message received while the client was still replaying
+type pendingMessage struct {
+	bytes []byte
+	seq   int64
 }
 
 var Upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
+	CheckOrigin:     checkOrigin,
+}
+
+// allowedOrigins mirrors the CORS allow list, set once at startup through SetAllowedOrigins
+var allowedOrigins []string
+
+// SetAllowedOrigins restricts websocket upgrades to the given origins. "*" allows any origin.
+func SetAllowedOrigins(origins []string) {
+	allowedOrigins = origins
+}
+
+// checkOrigin rejects cross-site upgrades from origins outside the CORS allow list. Requests
+// without an Origin header come from native clients, which browsers' same-origin rules do not cover.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
 		return true
-	},
+	}
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *Client) readPump() {
@@ -61,88 +95,132 @@ func (c *Client) readPump() {
 			break
 		}
 
-		var msg Message
-		if err := json.Unmarshal(messageBytes, &msg); err != nil {
-			c.logger.Error().Err(err).Msg("failed to unmarshal message")
-			continue
+		c.handleFrame(messageBytes)
+	}
+}
+
+// handleFrame decodes one client frame, dispatches it and replies with an ack or an error
+func (c *Client) handleFrame(frame []byte) {
+	var env Envelope
+	if err := json.Unmarshal(frame, &env); err != nil {
+		c.reply("", MessageTypeError, errs.NewBadRequestError("message is not a valid envelope", false, &codeMalformedMessage, nil, nil))
+		return
+	}
+
+	if env.V == 0 {
+		env.V = ProtocolVersion
+	}
+	if env.V > ProtocolVersion {
+		c.reply(env.ID, MessageTypeError, errs.NewBadRequestError(
+			fmt.Sprintf("protocol version %d is not supported, latest is %d", env.V, ProtocolVersion),
+			false, &codeUnsupportedVersion, nil, nil))
+		return
+	}
+
+	handler, ok := requestHandlers[env.Type]
+	if !ok {
+		c.reply(env.ID, MessageTypeError, errs.NewBadRequestError(
+			fmt.Sprintf("unknown message type %q", env.Type), false, &codeUnknownMessageType, nil, nil))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	result, err := handler(ctx, c, env.Payload)
+	if err != nil {
+		httpErr := toHTTPError(err)
+		if httpErr.Status >= http.StatusInternalServerError {
+			c.logger.Error().Err(err).Str("type", string(env.Type)).Str("request_id", env.ID).Msg("websocket request failed")
 		}
+		c.reply(env.ID, MessageTypeError, httpErr)
+		return
+	}
 
-		ctx := context.Background()
+	c.reply(env.ID, MessageTypeAck, AckPayload{Type: env.Type, Result: result})
+}
 
-		switch MessageTypes(msg.Type) {
-		case RideAccept:
-			if c.hub.RideService == nil {
-				continue
-			}
-			payload, ok := msg.Payload.(map[string]interface{})
-			if !ok {
-				continue
-			}
-			rideID, _ := payload["ride_id"].(string)
-			// Driver ID is the current user ID
-			if _, err := c.hub.RideService.AcceptRide(ctx, c.userID, rideID); err != nil {
-				c.logger.Error().Err(err).Msg("failed to accept ride")
-			}
+// reply queues an ack or error frame for this client only
+func (c *Client) reply(requestID, msgType string, payload interface{}) {
+	bytes, err := json.Marshal(Message{
+		V:       ProtocolVersion,
+		ID:      requestID,
+		Type:    msgType,
+		Payload: payload,
+	})
+	if err != nil {
+		c.logger.Error().Err(err).Msg("failed to marshal reply")
+		return
+	}
 
-		case RideStart:
-			if c.hub.RideService == nil {
-				continue
-			}
-			payload, ok := msg.Payload.(map[string]interface{})
-			if !ok {
-				continue
-			}
-			rideID, _ := payload["ride_id"].(string)
-			if _, err := c.hub.RideService.StartRide(ctx, c.userID, rideID); err != nil {
-				c.logger.Error().Err(err).Msg("failed to start ride")
-			}
+	if !c.enqueue(bytes, 0) {
+		c.logger.Warn().Str("request_id", requestID).Msg("send buffer full, dropping reply")
+	}
+}
 
-		case RideComplete:
-			if c.hub.RideService == nil {
-				continue
-			}
-			payload, ok := msg.Payload.(map[string]interface{})
-			if !ok {
-				continue
-			}
-			rideID, _ := payload["ride_id"].(string)
-			if _, err := c.hub.RideService.CompleteRide(ctx, c.userID, rideID); err != nil {
-				c.logger.Error().Err(err).Msg("failed to complete ride")
-			}
+// enqueue queues a frame without blocking. It reports false when the send buffer is full.
+func (c *Client) enqueue(bytes []byte, seq int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-		case RideCancel:
-			if c.hub.RideService == nil {
-				continue
-			}
-			payload, ok := msg.Payload.(map[string]interface{})
-			if !ok {
-				continue
-			}
-			rideID, _ := payload["ride_id"].(string)
-			if err := c.hub.RideService.CancelRide(ctx, c.userID, rideID); err != nil {
-				c.logger.Error().Err(err).Msg("failed to cancel ride")
-			}
+	if c.closed {
+		return true
+	}
+	if c.replaying {
+		c.pending = append(c.pending, pendingMessage{bytes: bytes, seq: seq})
+		return true
+	}
 
-		case DriverLocationUpdate:
-			if c.hub.LocationService == nil {
-				continue
-			}
-			payloadBytes, _ := json.Marshal(msg.Payload)
-			var locationUpdate model.LocationUpdate
-			if err := json.Unmarshal(payloadBytes, &locationUpdate); err != nil {
-				c.logger.Error().Err(err).Msg("failed to unmarshal location update")
-				continue
-			}
+	select {
+	case c.send <- bytes:
+		return true
+	default:
+		return false
+	}
+}
 
-			// Enforce security: DriverID must match authenticated user
-			locationUpdate.DriverID = c.userID
+// finishReplay queues the replayed frames followed by the live messages held back meanwhile,
+// skipping those already replayed, and switches the client to live delivery.
+func (c *Client) finishReplay(frames [][]byte, replayedUpTo int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-			if err := c.hub.LocationService.UpdateDriverLocation(ctx, &locationUpdate); err != nil {
-				// Log sampling could be good here to avoid spam
-				c.logger.Error().Err(err).Msg("failed to update driver location")
-			}
+	pending := c.pending
+	c.pending = nil
+	c.replaying = false
+	if c.closed {
+		return true
+	}
+
+	for _, bytes := range frames {
+		select {
+		case c.send <- bytes:
+		default:
+			return false
+		}
+	}
+	for _, msg := range pending {
+		if msg.seq > 0 && msg.seq <= replayedUpTo {
+			continue
+		}
+		select {
+		case c.send <- msg.bytes:
+		default:
+			return false
 		}
 	}
+	return true
+}
+
+// close closes the send channel once, telling writePump to end the connection
+func (c *Client) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
 }
 
 func (c *Client) writePump() {
@@ -161,20 +239,8 @@ func (c *Client) writePump() {
 				return
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-
-			// Add queued chat messages to the current websocket message.
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write(newline)
-				w.Write(<-c.send)
-			}
-
-			if err := w.Close(); err != nil {
+			// One JSON message per frame so clients can parse every frame on its own
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				return
 			}
 		case <-ticker.C:
@@ -0,0 +1,195 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Redis stream holding the recent events of a user, keyed by sequence number
+	eventStreamPrefix = "ws:events:"
+	// Redis counter handing out the per-user sequence numbers, kept without a TTL so sequence
+	// numbers never restart while clients may still resume from an older one
+	eventSeqPrefix = "ws:seq:"
+	// Approximate number of events kept per user
+	eventStreamMaxLen = 200
+	// Events of idle users expire after this long
+	eventStreamTTL = 24 * time.Hour
+	// Upper bound for reading the missed events on reconnect
+	replayTimeout = 5 * time.Second
+
+	// MessageTypeSession is the first frame of every connection and carries the latest sequence number
+	MessageTypeSession = "session"
+	// MessageTypeReplayGap tells the client that some missed events are no longer available
+	MessageTypeReplayGap = "replay_gap"
+)
+
+// transientMessageTypes are delivered live only. A stale driver position is useless after a reconnect.
+var transientMessageTypes = map[string]bool{
+	"driver_location": true,
+}
+
+// appendEventScript assigns the next sequence number and stores the event under it in one step,
+// so stream IDs always grow in sequence order even with concurrent writers
+var appendEventScript = redis.NewScript(`
+local seq = redis.call('INCR', KEYS[1])
+redis.call('XADD', KEYS[2], 'MAXLEN', '~', ARGV[2], seq .. '-0', 'data', ARGV[1])
+redis.call('EXPIRE', KEYS[2], ARGV[3])
+return seq
+`)
+
+// SessionPayload is sent on connect so clients always know which sequence to resume from
+type SessionPayload struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	LastSeq         int64  `json:"last_seq"`
+	InstanceID      string `json:"instance_id"`
+}
+
+// ReplayGapPayload reports missed events that were trimmed or expired. Clients should refetch state over REST.
+// A FirstAvailableSeq not above LastSeq means the sequence restarted, clients then resume from the new numbers.
+type ReplayGapPayload struct {
+	LastSeq           int64 `json:"last_seq"`
+	FirstAvailableSeq int64 `json:"first_available_seq"`
+}
+
+// appendEvent stores a message in the user's event log and returns its sequence number.
+// It returns 0 when the message is not logged.
+func (h *Hub) appendEvent(userID string, message Message) int64 {
+	if h.redis == nil || transientMessageTypes[message.Type] {
+		return 0
+	}
+
+	bytes, err := json.Marshal(message)
+	if err != nil {
+		h.Logger.Err(err).Msg("Failed to Marshal event log entry")
+		return 0
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+
+	seq, err := appendEventScript.Run(ctx, h.redis,
+		[]string{eventSeqPrefix + userID, eventStreamPrefix + userID},
+		bytes, eventStreamMaxLen, int(eventStreamTTL.Seconds()),
+	).Int64()
+	if err != nil {
+		h.Logger.Error().Err(err).Str("user_id", userID).Str("type", message.Type).Msg("Failed to append event log entry")
+		return 0
+	}
+	return seq
+}
+
+// latestSeq returns the last sequence number handed out for the user
+func (h *Hub) latestSeq(ctx context.Context, userID string) int64 {
+	if h.redis == nil {
+		return 0
+	}
+	seq, err := h.redis.Get(ctx, eventSeqPrefix+userID).Int64()
+	if err != nil && err != redis.Nil {
+		h.Logger.Error().Err(err).Str("user_id", userID).Msg("Failed to read event sequence")
+	}
+	return seq
+}
+
+// startSession sends the session frame and, when the client resumes from lastSeq, replays the
+// events it missed before releasing the live messages queued meanwhile.
+func (h *Hub) startSession(c *Client, lastSeq int64, resume bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), replayTimeout)
+	defer cancel()
+
+	frames := make([][]byte, 0, 1)
+	session, err := json.Marshal(Message{
+		V:    ProtocolVersion,
+		Type: MessageTypeSession,
+		Payload: SessionPayload{
+			ProtocolVersion: ProtocolVersion,
+			LastSeq:         h.latestSeq(ctx, c.userID),
+			InstanceID:      h.instanceID,
+		},
+	})
+	if err == nil {
+		frames = append(frames, session)
+	}
+
+	replayedUpTo := int64(0)
+	if resume {
+		missed, upTo := h.readMissed(ctx, c.userID, lastSeq)
+		frames = append(frames, missed...)
+		replayedUpTo = upTo
+	}
+
+	if !c.finishReplay(frames, replayedUpTo) {
+		c.logger.Warn().Int64("last_seq", lastSeq).Msg("send buffer full while replaying, closing connection")
+		h.evict(c)
+	}
+}
+
+// readMissed returns the logged events after lastSeq in order and the sequence of the last one
+func (h *Hub) readMissed(ctx context.Context, userID string, lastSeq int64) ([][]byte, int64) {
+	if h.redis == nil {
+		return nil, lastSeq
+	}
+
+	// A client ahead of the counter resumes from a sequence that was lost with redis data, it gets
+	// everything still logged after a gap telling it to start over
+	latest := h.latestSeq(ctx, userID)
+	from := lastSeq
+	if lastSeq > latest {
+		from = 0
+	}
+
+	entries, err := h.redis.XRange(ctx, eventStreamPrefix+userID, fmt.Sprintf("(%d-0", from), "+").Result()
+	if err != nil {
+		h.Logger.Error().Err(err).Str("user_id", userID).Int64("last_seq", lastSeq).Msg("Failed to read missed events")
+		return nil, lastSeq
+	}
+
+	frames := make([][]byte, 0, len(entries)+1)
+	upTo := from
+
+	firstAvailable := latest + 1
+	if len(entries) > 0 {
+		firstAvailable = streamSeq(entries[0].ID)
+	}
+	if firstAvailable > from+1 || from != lastSeq {
+		if gap, err := json.Marshal(Message{
+			V:       ProtocolVersion,
+			Type:    MessageTypeReplayGap,
+			Payload: ReplayGapPayload{LastSeq: lastSeq, FirstAvailableSeq: firstAvailable},
+		}); err == nil {
+			frames = append(frames, gap)
+		}
+	}
+
+	for _, entry := range entries {
+		seq := streamSeq(entry.ID)
+		data, _ := entry.Values["data"].(string)
+
+		var message Message
+		if err := json.Unmarshal([]byte(data), &message); err != nil {
+			h.Logger.Error().Err(err).Str("user_id", userID).Str("entry_id", entry.ID).Msg("Failed to unmarshal event log entry")
+			continue
+		}
+		message.Seq = seq
+
+		bytes, err := json.Marshal(message)
+		if err != nil {
+			continue
+		}
+		frames = append(frames, bytes)
+		upTo = seq
+	}
+	return frames, upTo
+}
+
+// streamSeq extracts the sequence number from a "<seq>-0" stream entry ID
+func streamSeq(id string) int64 {
+	seq, _ := strconv.ParseInt(strings.SplitN(id, "-", 2)[0], 10, 64)
+	return seq
+}
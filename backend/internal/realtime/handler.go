@@ -2,6 +2,7 @@ package realtime
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 )
@@ -17,6 +18,17 @@ func Handler(hub *Hub) echo.HandlerFunc {
 			return echo.NewHTTPError(http.StatusUnauthorized, "missing user_id")
 		}
 
+		// Clients resuming after a drop pass the last sequence number they processed
+		var lastSeq int64
+		resume := false
+		if raw := c.QueryParam("last_seq"); raw != "" {
+			seq, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || seq < 0 {
+				return echo.NewHTTPError(http.StatusBadRequest, "last_seq must be a non-negative integer")
+			}
+			lastSeq, resume = seq, true
+		}
+
 		conn, err := Upgrader.Upgrade(c.Response(), c.Request(), nil)
 		if err != nil {
 			hub.Logger.Error().Err(err).Msg("Failed to upgrade websocket")
@@ -29,8 +41,11 @@ func Handler(hub *Hub) echo.HandlerFunc {
 			send:   make(chan []byte, 256),
 			userID: uid,
 			logger: hub.Logger.With().Str("component", "websocket_client").Logger(),
+			// Live messages wait until the session frame and any replay are queued
+			replaying: true,
 		}
 		client.hub.Register <- client
+		go hub.startSession(client, lastSeq, resume)
 
 		// Allow collection of memory referenced by the caller by doing all work in
 		// new goroutines.
@@ -4,17 +4,44 @@ import (
 	"context"
 	"encoding/json"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
 )
 
+const (
+	// Redis channel prefix carrying messages for the users connected to any instance
+	userChannelPrefix = "ws:user:"
+	// Redis channel prefix addressing a single hub instance
+	instanceChannelPrefix = "ws:instance:"
+	// Timeout for subscribe/unsubscribe/publish round trips to Redis
+	clusterOpTimeout = 2 * time.Second
+	// Bounds of the backoff before a failed user channel subscription change is retried
+	subRetryMin = 500 * time.Millisecond
+	subRetryMax = 30 * time.Second
+)
+
+// Message is a frame sent by the server. ID echoes the client request ID on ack and error replies.
 type Message struct {
+	V       int         `json:"v"`
+	ID      string      `json:"id,omitempty"`
+	Seq     int64       `json:"seq,omitempty"`
 	Type    string      `json:"type"`
 	Payload interface{} `json:"payload"`
 	Target  string      `json:"target,omitempty"`
 }
 
+// clusterEnvelope wraps a marshalled Message relayed to the other hub instances through Redis
+type clusterEnvelope struct {
+	Origin  string          `json:"origin"`
+	UserID  string          `json:"user_id"`
+	Seq     int64           `json:"seq,omitempty"`
+	Message json.RawMessage `json:"message"`
+}
+
 type MessageTypes string
 
 const (
@@ -23,6 +50,8 @@ const (
 	RideComplete         MessageTypes = "complete_ride"
 	RideCancel           MessageTypes = "cancel_ride"
 	DriverLocationUpdate MessageTypes = "driver_location_update"
+	ChatMessage          MessageTypes = "chat_message"
+	ChatReceipt          MessageTypes = "chat_receipt"
 )
 
 type RideService interface {
@@ -36,9 +65,15 @@ type LocationService interface {
 	UpdateDriverLocation(ctx context.Context, update *model.LocationUpdate) error
 }
 
+type ChatService interface {
+	SendMessage(ctx context.Context, userID, rideID string, req *model.SendRideMessageRequest) (*model.RideMessage, error)
+	MarkMessages(ctx context.Context, userID, rideID string, req *model.RideMessageReceiptRequest) error
+}
+
 type Hub struct {
 	RideService     RideService
 	LocationService LocationService
+	ChatService     ChatService
 	Logger          *zerolog.Logger
 	Broadcast       chan []byte
 	Register        chan *Client
@@ -46,37 +81,77 @@ type Hub struct {
 	Clients         map[*Client]bool
 	UserClients     map[string][]*Client
 	mu              sync.RWMutex
+
+	// Cluster fan-out, disabled when redis is nil. pubsub is created with the hub and
+	// never reassigned, so it is read without locking.
+	redis      *redis.Client
+	pubsub     *redis.PubSub
+	instanceID string
+
+	// User channel subscriptions are changed by syncSubscriptions, off the Run loop, so a slow
+	// redis never holds up connects and disconnects. Run marks the users whose first connection
+	// arrived or last one left in subPending and wakes it through subSignal.
+	subMu      sync.Mutex
+	subPending map[string]struct{}
+	subSignal  chan struct{}
+	done       chan struct{}
+	closeOnce  sync.Once
 }
 
-func NewHub(logger *zerolog.Logger) *Hub {
-	return &Hub{
+// NewHub creates a hub. When a redis client is given the hub relays BroadcastToUser
+// messages to the other instances so users connected elsewhere still receive them.
+func NewHub(logger *zerolog.Logger, redisClient *redis.Client) *Hub {
+	h := &Hub{
 		Logger:      logger,
 		Broadcast:   make(chan []byte),
 		Register:    make(chan *Client),
 		Unregister:  make(chan *Client),
 		Clients:     make(map[*Client]bool),
 		UserClients: make(map[string][]*Client),
+		redis:       redisClient,
+		instanceID:  uuid.NewString(),
+		subPending:  make(map[string]struct{}),
+		subSignal:   make(chan struct{}, 1),
+		done:        make(chan struct{}),
 	}
+	if redisClient != nil {
+		// Without channels Subscribe does not connect, startCluster joins the instance channel
+		h.pubsub = redisClient.Subscribe(context.Background())
+	}
+	return h
+}
+
+// InstanceID identifies this hub within the cluster
+func (h *Hub) InstanceID() string {
+	return h.instanceID
 }
 
 func (h *Hub) Run() {
+	h.startCluster()
+
 	for {
 		select {
 		case client := <-h.Register:
 			h.mu.Lock()
 			h.Clients[client] = true
+			firstForUser := false
 			if client.userID != "" {
+				firstForUser = len(h.UserClients[client.userID]) == 0
 				h.UserClients[client.userID] = append(h.UserClients[client.userID], client)
 			}
 			h.mu.Unlock()
+			if firstForUser {
+				h.markSubscription(client.userID)
+			}
 			h.Logger.Info().Msg("Client registered")
 
 		case client := <-h.Unregister:
 			h.mu.Lock()
 			if _, ok := h.Clients[client]; ok {
 				delete(h.Clients, client)
-				close(client.send)
+				client.close()
 			}
+			lastForUser := false
 			if client.userID != "" {
 				clients := h.UserClients[client.userID]
 				for i, c := range clients {
@@ -85,8 +160,15 @@ func (h *Hub) Run() {
 						break
 					}
 				}
+				if len(h.UserClients[client.userID]) == 0 {
+					delete(h.UserClients, client.userID)
+					lastForUser = true
+				}
 			}
 			h.mu.Unlock()
+			if lastForUser {
+				h.markSubscription(client.userID)
+			}
 			h.Logger.Info().Msg("Client unregistered")
 
 		case message := <-h.Broadcast:
@@ -95,7 +177,7 @@ func (h *Hub) Run() {
 				select {
 				case client.send <- message:
 				default:
-					close(client.send)
+					client.close()
 					delete(h.Clients, client)
 				}
 			}
@@ -104,11 +186,43 @@ func (h *Hub) Run() {
 	}
 }
 
+// IsOnline reports whether the user has a live connection on this or any other instance.
+// Instances subscribe to a user's channel while they hold a connection of that user.
+func (h *Hub) IsOnline(ctx context.Context, userID string) bool {
+	h.mu.RLock()
+	local := len(h.UserClients[userID]) > 0
+	h.mu.RUnlock()
+	if local || h.redis == nil {
+		return local
+	}
+
+	channel := userChannelPrefix + userID
+	counts, err := h.redis.PubSubNumSub(ctx, channel).Result()
+	if err != nil {
+		h.Logger.Error().Err(err).Str("user_id", userID).Msg("Failed to check user presence")
+		return false
+	}
+	return counts[channel] > 0
+}
+
+// Close stops relaying cluster messages
+func (h *Hub) Close() error {
+	h.closeOnce.Do(func() { close(h.done) })
+	if h.pubsub == nil {
+		return nil
+	}
+	return h.pubsub.Close()
+}
+
+// BroadcastToUser sends a message to every connection of the user. Unless the type is transient the
+// message is first stored in the user's event log so clients can replay it after reconnecting.
 func (h *Hub) BroadcastToUser(userID string, msgType string, payload interface{}) {
 	message := Message{
+		V:       ProtocolVersion,
 		Type:    msgType,
 		Payload: payload,
 	}
+	message.Seq = h.appendEvent(userID, message)
 
 	bytes, err := json.Marshal(message)
 	if err != nil {
@@ -116,14 +230,181 @@ func (h *Hub) BroadcastToUser(userID string, msgType string, payload interface{}
 		return
 	}
 
+	h.deliverLocal(userID, bytes, message.Seq)
+	h.publish(userID, bytes, message.Seq)
+}
+
+// deliverLocal hands a marshalled message to every client of the user connected to this instance.
+// A client too slow to take a logged message is disconnected so it reconnects and replays instead.
+func (h *Hub) deliverLocal(userID string, bytes []byte, seq int64) {
 	h.mu.RLock()
 	clients := h.UserClients[userID]
 	h.mu.RUnlock()
 
 	for _, client := range clients {
+		if !client.enqueue(bytes, seq) && seq > 0 {
+			client.logger.Warn().Int64("seq", seq).Msg("send buffer full, closing connection for replay")
+			h.evict(client)
+		}
+	}
+}
+
+// evict unregisters a client without blocking the caller
+func (h *Hub) evict(c *Client) {
+	go func() { h.Unregister <- c }()
+}
+
+// startCluster subscribes to this instance's channel and starts relaying messages published by other instances
+func (h *Hub) startCluster() {
+	if h.redis == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+
+	// Subscribing to the instance channel up front keeps the connection in pub/sub mode
+	// even while no user is connected to this instance
+	if err := h.pubsub.Subscribe(ctx, instanceChannelPrefix+h.instanceID); err != nil {
+		h.Logger.Error().Err(err).Msg("Failed to subscribe hub to redis, cluster delivery disabled until reconnect")
+	}
+
+	go h.relay(h.pubsub.Channel())
+	go h.syncSubscriptions()
+	h.Logger.Info().Str("instance_id", h.instanceID).Msg("Websocket hub joined cluster")
+}
+
+// relay delivers messages published by other instances to the local clients
+func (h *Hub) relay(messages <-chan *redis.Message) {
+	for msg := range messages {
+		var envelope clusterEnvelope
+		if err := json.Unmarshal([]byte(msg.Payload), &envelope); err != nil {
+			h.Logger.Error().Err(err).Str("channel", msg.Channel).Msg("Failed to unmarshal cluster message")
+			continue
+		}
+		if envelope.Origin == h.instanceID {
+			continue
+		}
+		h.deliverLocal(envelope.UserID, envelope.Message, envelope.Seq)
+	}
+}
+
+// publish relays a message to the instances holding the user's other connections
+func (h *Hub) publish(userID string, bytes []byte, seq int64) {
+	if h.redis == nil {
+		return
+	}
+
+	envelope, err := json.Marshal(clusterEnvelope{
+		Origin:  h.instanceID,
+		UserID:  userID,
+		Seq:     seq,
+		Message: bytes,
+	})
+	if err != nil {
+		h.Logger.Err(err).Msg("Failed to Marshal cluster message")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+	if err := h.redis.Publish(ctx, userChannelPrefix+userID, envelope).Err(); err != nil {
+		h.Logger.Error().Err(err).Str("user_id", userID).Msg("Failed to publish cluster message")
+	}
+}
+
+// markSubscription queues a check of the user's channel subscription without blocking
+func (h *Hub) markSubscription(userID string) {
+	if h.redis == nil {
+		return
+	}
+	h.subMu.Lock()
+	h.subPending[userID] = struct{}{}
+	h.subMu.Unlock()
+
+	select {
+	case h.subSignal <- struct{}{}:
+	default:
+	}
+}
+
+// syncSubscriptions keeps this instance subscribed to the channels of the users connected to it.
+// Marks are coalesced, a user who reconnects before their mark is handled is left subscribed.
+// A failed change is marked again and retried with backoff until redis accepts it.
+func (h *Hub) syncSubscriptions() {
+	subscribed := make(map[string]bool)
+	backoff := subRetryMin
+	var retry <-chan time.Time
+	for {
 		select {
-		case client.send <- bytes:
-		default:
+		case <-h.done:
+			return
+		case <-h.subSignal:
+		case <-retry:
+			retry = nil
+		}
+
+		h.subMu.Lock()
+		pending := h.subPending
+		h.subPending = make(map[string]struct{})
+		h.subMu.Unlock()
+
+		var failed []string
+		for userID := range pending {
+			h.mu.RLock()
+			connected := len(h.UserClients[userID]) > 0
+			h.mu.RUnlock()
+
+			confirmed, tracked := subscribed[userID]
+			switch {
+			case connected && !confirmed:
+				// A failed subscribe stays tracked, the pubsub connection keeps the channel
+				// and resubscribes it after reconnecting
+				subscribed[userID] = h.subscribeUser(userID)
+				if !subscribed[userID] {
+					failed = append(failed, userID)
+				}
+			case !connected && tracked:
+				if h.unsubscribeUser(userID) {
+					delete(subscribed, userID)
+				} else {
+					failed = append(failed, userID)
+				}
+			}
 		}
+
+		if len(failed) == 0 {
+			backoff = subRetryMin
+			continue
+		}
+		h.subMu.Lock()
+		for _, userID := range failed {
+			h.subPending[userID] = struct{}{}
+		}
+		h.subMu.Unlock()
+		if retry == nil {
+			retry = time.After(backoff)
+			backoff = min(backoff*2, subRetryMax)
+		}
+	}
+}
+
+func (h *Hub) subscribeUser(userID string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+	if err := h.pubsub.Subscribe(ctx, userChannelPrefix+userID); err != nil {
+		h.Logger.Error().Err(err).Str("user_id", userID).Msg("Failed to subscribe to user channel")
+		return false
+	}
+	return true
+}
+
+func (h *Hub) unsubscribeUser(userID string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+	if err := h.pubsub.Unsubscribe(ctx, userChannelPrefix+userID); err != nil {
+		h.Logger.Error().Err(err).Str("user_id", userID).Msg("Failed to unsubscribe from user channel")
+		return false
 	}
+	return true
 }
@@ -0,0 +1,278 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(hub *Hub, userID string) *Client {
+	logger := zerolog.Nop()
+	return &Client{
+		hub:    hub,
+		send:   make(chan []byte, 8),
+		userID: userID,
+		logger: logger,
+	}
+}
+
+func receive(t *testing.T, c *Client) Message {
+	t.Helper()
+	select {
+	case raw := <-c.send:
+		var msg Message
+		require.NoError(t, json.Unmarshal(raw, &msg))
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for message")
+		return Message{}
+	}
+}
+
+func TestHubBroadcastAcrossInstances(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	logger := zerolog.Nop()
+	newHub := func() *Hub {
+		hub := NewHub(&logger, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		go hub.Run()
+		t.Cleanup(func() { hub.Close() })
+		return hub
+	}
+
+	hubA := newHub()
+	hubB := newHub()
+
+	rider := newTestClient(hubB, "rider-1")
+	hubB.Register <- rider
+
+	// Wait until instance B has subscribed to the rider's channel
+	require.Eventually(t, func() bool {
+		return mr.PubSubNumSub(userChannelPrefix + "rider-1")[userChannelPrefix+"rider-1"] == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	hubA.BroadcastToUser("rider-1", "ride_accepted", map[string]string{"ride_id": "ride-1"})
+
+	msg := receive(t, rider)
+	assert.Equal(t, "ride_accepted", msg.Type)
+	assert.Equal(t, "ride-1", msg.Payload.(map[string]interface{})["ride_id"])
+}
+
+func TestHubBroadcastDeliversLocallyOnce(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	logger := zerolog.Nop()
+	hub := NewHub(&logger, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	go hub.Run()
+	defer hub.Close()
+
+	driver := newTestClient(hub, "driver-1")
+	hub.Register <- driver
+
+	require.Eventually(t, func() bool {
+		return mr.PubSubNumSub(userChannelPrefix + "driver-1")[userChannelPrefix+"driver-1"] == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	hub.BroadcastToUser("driver-1", "new_ride_request", map[string]string{"ride_id": "ride-2"})
+
+	msg := receive(t, driver)
+	assert.Equal(t, "new_ride_request", msg.Type)
+
+	// The copy relayed back through redis must be ignored by the origin instance
+	select {
+	case <-driver.send:
+		t.Fatal("message delivered twice")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestHubReplaysMissedEvents(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	logger := zerolog.Nop()
+	hub := NewHub(&logger, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	go hub.Run()
+	defer hub.Close()
+
+	// Sent while the rider is offline
+	hub.BroadcastToUser("rider-1", "ride_accepted", map[string]string{"ride_id": "ride-1"})
+	hub.BroadcastToUser("rider-1", "driver_location", map[string]string{"ride_id": "ride-1"})
+	hub.BroadcastToUser("rider-1", "ride_completed", map[string]string{"ride_id": "ride-1"})
+
+	rider := newTestClient(hub, "rider-1")
+	rider.replaying = true
+	hub.Register <- rider
+	hub.startSession(rider, 1, true)
+
+	session := receive(t, rider)
+	assert.Equal(t, MessageTypeSession, session.Type)
+	assert.EqualValues(t, 2, session.Payload.(map[string]interface{})["last_seq"])
+
+	// Only the logged event after last_seq is replayed
+	msg := receive(t, rider)
+	assert.Equal(t, "ride_completed", msg.Type)
+	assert.EqualValues(t, 2, msg.Seq)
+
+	hub.BroadcastToUser("rider-1", "ride_cancelled", map[string]string{"ride_id": "ride-1"})
+	msg = receive(t, rider)
+	assert.Equal(t, "ride_cancelled", msg.Type)
+	assert.EqualValues(t, 3, msg.Seq)
+}
+
+func TestHubReportsReplayGap(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	logger := zerolog.Nop()
+	hub := NewHub(&logger, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	go hub.Run()
+	defer hub.Close()
+
+	hub.BroadcastToUser("rider-1", "ride_accepted", map[string]string{"ride_id": "ride-1"})
+	hub.BroadcastToUser("rider-1", "ride_started", map[string]string{"ride_id": "ride-1"})
+	mr.Del(eventStreamPrefix + "rider-1")
+
+	rider := newTestClient(hub, "rider-1")
+	rider.replaying = true
+	hub.Register <- rider
+	hub.startSession(rider, 0, true)
+
+	assert.Equal(t, MessageTypeSession, receive(t, rider).Type)
+	gap := receive(t, rider)
+	assert.Equal(t, MessageTypeReplayGap, gap.Type)
+	assert.EqualValues(t, 3, gap.Payload.(map[string]interface{})["first_available_seq"])
+}
+
+func TestHubKeepsSequenceOfIdleUser(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	logger := zerolog.Nop()
+	hub := NewHub(&logger, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	hub.BroadcastToUser("rider-1", "ride_accepted", map[string]string{"ride_id": "ride-1"})
+	mr.FastForward(eventStreamTTL + time.Hour)
+	hub.BroadcastToUser("rider-1", "ride_started", map[string]string{"ride_id": "ride-1"})
+
+	assert.EqualValues(t, 2, hub.latestSeq(context.Background(), "rider-1"))
+}
+
+func TestHubRestartsReplayAfterLostSequence(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	logger := zerolog.Nop()
+	hub := NewHub(&logger, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	go hub.Run()
+	defer hub.Close()
+
+	hub.BroadcastToUser("rider-1", "ride_accepted", map[string]string{"ride_id": "ride-1"})
+
+	// The client last saw seq 42 before the server's data was lost
+	rider := newTestClient(hub, "rider-1")
+	rider.replaying = true
+	hub.Register <- rider
+	hub.startSession(rider, 42, true)
+
+	assert.Equal(t, MessageTypeSession, receive(t, rider).Type)
+	gap := receive(t, rider)
+	require.Equal(t, MessageTypeReplayGap, gap.Type)
+	assert.EqualValues(t, 42, gap.Payload.(map[string]interface{})["last_seq"])
+	assert.EqualValues(t, 1, gap.Payload.(map[string]interface{})["first_available_seq"])
+	msg := receive(t, rider)
+	assert.Equal(t, "ride_accepted", msg.Type)
+	assert.EqualValues(t, 1, msg.Seq)
+}
+
+func TestHubIsOnlineAcrossInstances(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	logger := zerolog.Nop()
+	hubA := NewHub(&logger, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	hubB := NewHub(&logger, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	go hubA.Run()
+	go hubB.Run()
+	defer hubA.Close()
+	defer hubB.Close()
+
+	ctx := context.Background()
+	assert.False(t, hubA.IsOnline(ctx, "rider-1"))
+
+	hubB.Register <- newTestClient(hubB, "rider-1")
+
+	assert.Eventually(t, func() bool { return hubA.IsOnline(ctx, "rider-1") }, 2*time.Second, 10*time.Millisecond)
+	assert.True(t, hubB.IsOnline(ctx, "rider-1"))
+}
+
+func TestHubUnsubscribesAfterLastConnection(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	logger := zerolog.Nop()
+	hub := NewHub(&logger, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	go hub.Run()
+	defer hub.Close()
+
+	channel := userChannelPrefix + "rider-1"
+	first := newTestClient(hub, "rider-1")
+	second := newTestClient(hub, "rider-1")
+	hub.Register <- first
+	hub.Register <- second
+	require.Eventually(t, func() bool { return mr.PubSubNumSub(channel)[channel] == 1 }, 2*time.Second, 10*time.Millisecond)
+
+	// The channel is kept while the rider has another connection
+	hub.Unregister <- first
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, mr.PubSubNumSub(channel)[channel])
+
+	hub.Unregister <- second
+	assert.Eventually(t, func() bool { return mr.PubSubNumSub(channel)[channel] == 0 }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestHubSubscriptionsSurviveRedisOutage(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	logger := zerolog.Nop()
+	hub := NewHub(&logger, redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1}))
+	go hub.Run()
+	defer hub.Close()
+
+	// One rider connects and another comes and goes while redis is down
+	mr.Close()
+	hub.Register <- newTestClient(hub, "rider-1")
+	gone := newTestClient(hub, "rider-2")
+	hub.Register <- gone
+	time.Sleep(100 * time.Millisecond)
+	hub.Unregister <- gone
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, mr.Restart())
+
+	connected := userChannelPrefix + "rider-1"
+	left := userChannelPrefix + "rider-2"
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumSub(connected)[connected] == 1 && mr.PubSubNumSub(left)[left] == 0
+	}, 5*time.Second, 20*time.Millisecond)
+	time.Sleep(2 * subRetryMin)
+	assert.Equal(t, 0, mr.PubSubNumSub(left)[left])
+}
@@ -0,0 +1,211 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/sqlerr"
+)
+
+// ProtocolVersion is the websocket protocol version spoken by this server.
+// Frames without a version are treated as version 1.
+const ProtocolVersion = 1
+
+const (
+	// MessageTypeAck acknowledges a client request that was processed successfully
+	MessageTypeAck = "ack"
+	// MessageTypeError reports why a client request was rejected
+	MessageTypeError = "error"
+)
+
+// Error codes specific to the websocket protocol, service errors keep their own codes
+var (
+	codeMalformedMessage   = "MALFORMED_MESSAGE"
+	codeUnsupportedVersion = "UNSUPPORTED_PROTOCOL_VERSION"
+	codeUnknownMessageType = "UNKNOWN_MESSAGE_TYPE"
+)
+
+var validate = validator.New()
+
+// Envelope is a frame sent by a client
+type Envelope struct {
+	V       int             `json:"v"`
+	ID      string          `json:"id,omitempty"`
+	Type    MessageTypes    `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// AckPayload is the payload of an ack reply
+type AckPayload struct {
+	Type   MessageTypes `json:"type"`
+	Result interface{}  `json:"result,omitempty"`
+}
+
+// RidePayload is the payload of accept_ride, start_ride, complete_ride and cancel_ride
+type RidePayload struct {
+	RideID string `json:"ride_id" validate:"required,uuid"`
+}
+
+// LocationPayload is the payload of driver_location_update
+type LocationPayload struct {
+	Location model.Location `json:"location" validate:"required"`
+	Heading  float64        `json:"heading,omitempty" validate:"omitempty,min=0,max=360"`
+	Speed    float64        `json:"speed,omitempty" validate:"omitempty,min=0"`
+	IsMock   bool           `json:"is_mock,omitempty"`
+}
+
+// ChatMessagePayload is the payload of chat_message
+type ChatMessagePayload struct {
+	RideID string `json:"ride_id" validate:"required,uuid"`
+	model.SendRideMessageRequest
+}
+
+// ChatReceiptPayload is the payload of chat_receipt
+type ChatReceiptPayload struct {
+	RideID string `json:"ride_id" validate:"required,uuid"`
+	model.RideMessageReceiptRequest
+}
+
+// requestHandler processes the decoded payload of a client request and returns the ack result
+type requestHandler func(ctx context.Context, c *Client, payload json.RawMessage) (interface{}, error)
+
+var requestHandlers = map[MessageTypes]requestHandler{
+	RideAccept:           handleAcceptRide,
+	RideStart:            handleStartRide,
+	RideComplete:         handleCompleteRide,
+	RideCancel:           handleCancelRide,
+	DriverLocationUpdate: handleDriverLocationUpdate,
+	ChatMessage:          handleChatMessage,
+	ChatReceipt:          handleChatReceipt,
+}
+
+// decodePayload unmarshals and validates a typed request payload
+func decodePayload(raw json.RawMessage, dst interface{}) error {
+	if len(raw) == 0 {
+		return errs.NewBadRequestError("payload is required", false, &codeMalformedMessage, nil, nil)
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return errs.NewBadRequestError("invalid payload: "+err.Error(), false, &codeMalformedMessage, nil, nil)
+	}
+	if err := validate.Struct(dst); err != nil {
+		var fieldErrors []errs.FieldError
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			for _, fe := range validationErrors {
+				fieldErrors = append(fieldErrors, errs.FieldError{Field: fe.Field(), Error: fe.Tag()})
+			}
+		}
+		return errs.NewBadRequestError("Validation Failed", true, nil, fieldErrors, nil)
+	}
+	return nil
+}
+
+// toHTTPError maps any service error onto the error reply sent to the client
+func toHTTPError(err error) *errs.HTTPError {
+	var httpErr *errs.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	if errors.As(sqlerr.HandleError(err), &httpErr) {
+		return httpErr
+	}
+	return errs.NewInternalServerError()
+}
+
+func serviceUnavailable() *errs.HTTPError {
+	return &errs.HTTPError{
+		Code:    errs.MakeUpperCaseWithUnderscores(http.StatusText(http.StatusServiceUnavailable)),
+		Message: "service not available on this connection",
+		Status:  http.StatusServiceUnavailable,
+	}
+}
+
+func handleAcceptRide(ctx context.Context, c *Client, raw json.RawMessage) (interface{}, error) {
+	var p RidePayload
+	if err := decodePayload(raw, &p); err != nil {
+		return nil, err
+	}
+	if c.hub.RideService == nil {
+		return nil, serviceUnavailable()
+	}
+	// Driver ID is the current user ID
+	return c.hub.RideService.AcceptRide(ctx, c.userID, p.RideID)
+}
+
+func handleStartRide(ctx context.Context, c *Client, raw json.RawMessage) (interface{}, error) {
+	var p RidePayload
+	if err := decodePayload(raw, &p); err != nil {
+		return nil, err
+	}
+	if c.hub.RideService == nil {
+		return nil, serviceUnavailable()
+	}
+	return c.hub.RideService.StartRide(ctx, c.userID, p.RideID)
+}
+
+func handleCompleteRide(ctx context.Context, c *Client, raw json.RawMessage) (interface{}, error) {
+	var p RidePayload
+	if err := decodePayload(raw, &p); err != nil {
+		return nil, err
+	}
+	if c.hub.RideService == nil {
+		return nil, serviceUnavailable()
+	}
+	return c.hub.RideService.CompleteRide(ctx, c.userID, p.RideID)
+}
+
+func handleCancelRide(ctx context.Context, c *Client, raw json.RawMessage) (interface{}, error) {
+	var p RidePayload
+	if err := decodePayload(raw, &p); err != nil {
+		return nil, err
+	}
+	if c.hub.RideService == nil {
+		return nil, serviceUnavailable()
+	}
+	return nil, c.hub.RideService.CancelRide(ctx, c.userID, p.RideID)
+}
+
+func handleDriverLocationUpdate(ctx context.Context, c *Client, raw json.RawMessage) (interface{}, error) {
+	var p LocationPayload
+	if err := decodePayload(raw, &p); err != nil {
+		return nil, err
+	}
+	if c.hub.LocationService == nil {
+		return nil, serviceUnavailable()
+	}
+	// Enforce security: DriverID must match authenticated user
+	return nil, c.hub.LocationService.UpdateDriverLocation(ctx, &model.LocationUpdate{
+		DriverID: c.userID,
+		Location: p.Location,
+		Heading:  p.Heading,
+		Speed:    p.Speed,
+		IsMock:   p.IsMock,
+	})
+}
+
+func handleChatMessage(ctx context.Context, c *Client, raw json.RawMessage) (interface{}, error) {
+	var p ChatMessagePayload
+	if err := decodePayload(raw, &p); err != nil {
+		return nil, err
+	}
+	if c.hub.ChatService == nil {
+		return nil, serviceUnavailable()
+	}
+	return c.hub.ChatService.SendMessage(ctx, c.userID, p.RideID, &p.SendRideMessageRequest)
+}
+
+func handleChatReceipt(ctx context.Context, c *Client, raw json.RawMessage) (interface{}, error) {
+	var p ChatReceiptPayload
+	if err := decodePayload(raw, &p); err != nil {
+		return nil, err
+	}
+	if c.hub.ChatService == nil {
+		return nil, serviceUnavailable()
+	}
+	return nil, c.hub.ChatService.MarkMessages(ctx, c.userID, p.RideID, &p.RideMessageReceiptRequest)
+}
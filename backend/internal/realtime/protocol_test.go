@@ -0,0 +1,174 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRideService struct {
+	acceptErr error
+	accepted  []string
+}
+
+func (f *fakeRideService) AcceptRide(ctx context.Context, driverID, rideID string) (*model.RideResponse, error) {
+	if f.acceptErr != nil {
+		return nil, f.acceptErr
+	}
+	f.accepted = append(f.accepted, driverID+":"+rideID)
+	return &model.RideResponse{ID: rideID, Status: model.RideStatusAccepted}, nil
+}
+
+func (f *fakeRideService) StartRide(ctx context.Context, driverID, rideID string) (*model.RideResponse, error) {
+	return nil, nil
+}
+
+func (f *fakeRideService) CompleteRide(ctx context.Context, driverID, rideID string) (*model.RideResponse, error) {
+	return nil, nil
+}
+
+func (f *fakeRideService) CancelRide(ctx context.Context, userID, rideID string) error {
+	return nil
+}
+
+type rawReply struct {
+	V       int             `json:"v"`
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+func nextReply(t *testing.T, c *Client) rawReply {
+	t.Helper()
+	require.Len(t, c.send, 1)
+	var r rawReply
+	require.NoError(t, json.Unmarshal(<-c.send, &r))
+	return r
+}
+
+func TestHandleFrame(t *testing.T) {
+	logger := zerolog.Nop()
+	rides := &fakeRideService{}
+	hub := NewHub(&logger, nil)
+	hub.RideService = rides
+	client := newTestClient(hub, "driver-user")
+
+	const rideID = "0b8f2f8e-7a43-4a4e-9d0c-2a6c1d5b2f10"
+
+	t.Run("ack echoes request id and result", func(t *testing.T) {
+		client.handleFrame([]byte(`{"v":1,"id":"req-1","type":"accept_ride","payload":{"ride_id":"` + rideID + `"}}`))
+
+		r := nextReply(t, client)
+		assert.Equal(t, ProtocolVersion, r.V)
+		assert.Equal(t, "req-1", r.ID)
+		assert.Equal(t, MessageTypeAck, r.Type)
+
+		var ack struct {
+			Type   string             `json:"type"`
+			Result model.RideResponse `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal(r.Payload, &ack))
+		assert.Equal(t, "accept_ride", ack.Type)
+		assert.Equal(t, rideID, ack.Result.ID)
+		assert.Equal(t, []string{"driver-user:" + rideID}, rides.accepted)
+	})
+
+	t.Run("service error is returned with its code", func(t *testing.T) {
+		rides.acceptErr = errs.NewBadRequest("ride cannot be accepted in current status ride is already accepted")
+		defer func() { rides.acceptErr = nil }()
+
+		client.handleFrame([]byte(`{"id":"req-2","type":"accept_ride","payload":{"ride_id":"` + rideID + `"}}`))
+
+		r := nextReply(t, client)
+		assert.Equal(t, "req-2", r.ID)
+		assert.Equal(t, MessageTypeError, r.Type)
+
+		var httpErr errs.HTTPError
+		require.NoError(t, json.Unmarshal(r.Payload, &httpErr))
+		assert.Equal(t, "BAD_REQUEST", httpErr.Code)
+		assert.Equal(t, 400, httpErr.Status)
+	})
+
+	tests := []struct {
+		name  string
+		frame string
+		code  string
+	}{
+		{"malformed json", `{"type":`, codeMalformedMessage},
+		{"unsupported version", `{"v":99,"id":"req-3","type":"accept_ride"}`, codeUnsupportedVersion},
+		{"unknown type", `{"id":"req-4","type":"teleport"}`, codeUnknownMessageType},
+		{"missing payload", `{"id":"req-5","type":"start_ride"}`, codeMalformedMessage},
+		{"invalid payload", `{"id":"req-6","type":"cancel_ride","payload":{"ride_id":"not-a-uuid"}}`, "BAD_REQUEST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.handleFrame([]byte(tt.frame))
+
+			r := nextReply(t, client)
+			assert.Equal(t, MessageTypeError, r.Type)
+
+			var httpErr errs.HTTPError
+			require.NoError(t, json.Unmarshal(r.Payload, &httpErr))
+			assert.Equal(t, tt.code, httpErr.Code)
+		})
+	}
+}
+
+type fakeChatService struct {
+	sent []*model.SendRideMessageRequest
+}
+
+func (f *fakeChatService) SendMessage(ctx context.Context, userID, rideID string, req *model.SendRideMessageRequest) (*model.RideMessage, error) {
+	f.sent = append(f.sent, req)
+	return &model.RideMessage{ID: "msg-1", RideID: rideID, SenderID: userID, Body: req.Body}, nil
+}
+
+func (f *fakeChatService) MarkMessages(ctx context.Context, userID, rideID string, req *model.RideMessageReceiptRequest) error {
+	return nil
+}
+
+func TestHandleChatFrames(t *testing.T) {
+	logger := zerolog.Nop()
+	chat := &fakeChatService{}
+	hub := NewHub(&logger, nil)
+	hub.ChatService = chat
+	client := newTestClient(hub, "rider-user")
+
+	const rideID = "0b8f2f8e-7a43-4a4e-9d0c-2a6c1d5b2f10"
+
+	t.Run("chat message is acked with the stored message", func(t *testing.T) {
+		client.handleFrame([]byte(`{"id":"c-1","type":"chat_message","payload":{"ride_id":"` + rideID + `","body":"At gate 2"}}`))
+
+		r := nextReply(t, client)
+		assert.Equal(t, MessageTypeAck, r.Type)
+
+		var ack struct {
+			Result model.RideMessage `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal(r.Payload, &ack))
+		assert.Equal(t, "At gate 2", ack.Result.Body)
+		assert.Equal(t, "rider-user", ack.Result.SenderID)
+	})
+
+	t.Run("chat message needs a body or quick reply", func(t *testing.T) {
+		client.handleFrame([]byte(`{"id":"c-2","type":"chat_message","payload":{"ride_id":"` + rideID + `"}}`))
+
+		r := nextReply(t, client)
+		assert.Equal(t, MessageTypeError, r.Type)
+		assert.Len(t, chat.sent, 1)
+	})
+
+	t.Run("receipt status is validated", func(t *testing.T) {
+		client.handleFrame([]byte(`{"id":"c-3","type":"chat_receipt","payload":{"ride_id":"` + rideID + `","message_ids":["` + rideID + `"],"status":"seen"}}`))
+
+		r := nextReply(t, client)
+		assert.Equal(t, MessageTypeError, r.Type)
+	})
+}
@@ -0,0 +1,117 @@
+package realtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// sseEvent is the part of a Message needed to frame it as a server-sent event
+type sseEvent struct {
+	Type string `json:"type"`
+	Seq  int64  `json:"seq"`
+}
+
+// SSEHandler streams the user's hub messages as server-sent events for clients that cannot
+// open a websocket. The stream is registered with the hub like a websocket Client and resumes
+// from the Last-Event-ID header the same way a websocket resumes from last_seq.
+func SSEHandler(hub *Hub) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		uid, ok := c.Get("user_id").(string)
+		if !ok || uid == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "missing user_id")
+		}
+
+		// Browsers send Last-Event-ID on automatic reconnects, manual reconnects may use the query param
+		var lastSeq int64
+		resume := false
+		raw := c.Request().Header.Get("Last-Event-ID")
+		if raw == "" {
+			raw = c.QueryParam("last_event_id")
+		}
+		if raw != "" {
+			seq, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || seq < 0 {
+				return echo.NewHTTPError(http.StatusBadRequest, "Last-Event-ID must be a non-negative integer")
+			}
+			lastSeq, resume = seq, true
+		}
+
+		res := c.Response()
+		rc := http.NewResponseController(res)
+
+		res.Header().Set(echo.HeaderContentType, "text/event-stream")
+		res.Header().Set(echo.HeaderCacheControl, "no-cache")
+		res.Header().Set(echo.HeaderConnection, "keep-alive")
+		// Stop reverse proxies from buffering the stream
+		res.Header().Set("X-Accel-Buffering", "no")
+		res.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			return err
+		}
+
+		client := &Client{
+			hub:       hub,
+			send:      make(chan []byte, 256),
+			userID:    uid,
+			logger:    hub.Logger.With().Str("component", "sse_client").Logger(),
+			replaying: true,
+		}
+		hub.Register <- client
+		defer func() { hub.Unregister <- client }()
+		go hub.startSession(client, lastSeq, resume)
+
+		ticker := time.NewTicker(pingPeriod)
+		defer ticker.Stop()
+
+		ctx := c.Request().Context()
+		for {
+			var frame []byte
+			select {
+			case <-ctx.Done():
+				return nil
+
+			case message, ok := <-client.send:
+				if !ok {
+					// The hub closed the stream, the client reconnects with Last-Event-ID
+					return nil
+				}
+				frame = encodeSSE(message)
+
+			case <-ticker.C:
+				frame = []byte(": ping\n\n")
+			}
+
+			// The server write timeout would otherwise cut every stream after a few seconds
+			_ = rc.SetWriteDeadline(time.Now().Add(writeWait))
+			if _, err := res.Write(frame); err != nil {
+				return nil
+			}
+			if err := rc.Flush(); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// encodeSSE frames a marshalled Message as an event named after its type, using the sequence as event ID
+func encodeSSE(message []byte) []byte {
+	var event sseEvent
+	_ = json.Unmarshal(message, &event)
+
+	var buf bytes.Buffer
+	if event.Seq > 0 {
+		fmt.Fprintf(&buf, "id: %d\n", event.Seq)
+	}
+	if event.Type != "" {
+		fmt.Fprintf(&buf, "event: %s\n", event.Type)
+	}
+	// Marshalled JSON never contains raw newlines, so one data line is enough
+	fmt.Fprintf(&buf, "data: %s\n\n", message)
+	return buf.Bytes()
+}
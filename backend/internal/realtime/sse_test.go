@@ -0,0 +1,76 @@
+package realtime
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSEHandlerResumesFromLastEventID(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	logger := zerolog.Nop()
+	hub := NewHub(&logger, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	go hub.Run()
+	defer hub.Close()
+
+	hub.BroadcastToUser("rider-1", "ride_accepted", map[string]string{"ride_id": "ride-1"})
+	hub.BroadcastToUser("rider-1", "ride_started", map[string]string{"ride_id": "ride-1"})
+
+	e := echo.New()
+	e.GET("/events", SSEHandler(hub), func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("user_id", "rider-1")
+			return next(c)
+		}
+	})
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "1")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get(echo.HeaderContentType))
+
+	reader := bufio.NewReader(resp.Body)
+	nextEvent := func() string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	assert.Contains(t, nextEvent(), "event: session\n")
+
+	replayed := nextEvent()
+	assert.Contains(t, replayed, "id: 2\nevent: ride_started\n")
+
+	hub.BroadcastToUser("rider-1", "driver_location", map[string]string{"ride_id": "ride-1"})
+	live := nextEvent()
+	assert.NotContains(t, live, "id:")
+	assert.Contains(t, live, "event: driver_location\n")
+}
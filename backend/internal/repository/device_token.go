@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+type DeviceTokenRepository interface {
+	Upsert(ctx context.Context, device *model.DeviceToken) error
+	Delete(ctx context.Context, userID, token string) error
+	DeleteByToken(ctx context.Context, token string) error
+	ListByUser(ctx context.Context, userID string) ([]*model.DeviceToken, error)
+}
+
+type deviceTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDeviceTokenRepository(db *pgxpool.Pool) DeviceTokenRepository {
+	return &deviceTokenRepository{db: db}
+}
+
+// Upsert registers a token, moving it to the given user if another user registered it before
+func (r *deviceTokenRepository) Upsert(ctx context.Context, device *model.DeviceToken) error {
+	query := `
+		INSERT INTO device_tokens (user_id, token, platform)
+		VALUES (@user_id, @token, @platform)
+		ON CONFLICT (token) DO UPDATE
+		SET user_id = EXCLUDED.user_id, platform = EXCLUDED.platform, updated_at = NOW()
+		RETURNING id, created_at, updated_at
+	`
+
+	return r.db.QueryRow(ctx, query, pgx.NamedArgs{
+		"user_id":  device.UserID,
+		"token":    device.Token,
+		"platform": device.Platform,
+	}).Scan(&device.ID, &device.CreatedAt, &device.UpdatedAt)
+}
+
+func (r *deviceTokenRepository) Delete(ctx context.Context, userID, token string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM device_tokens WHERE user_id = $1 AND token = $2`, userID, token)
+	return err
+}
+
+func (r *deviceTokenRepository) DeleteByToken(ctx context.Context, token string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM device_tokens WHERE token = $1`, token)
+	return err
+}
+
+func (r *deviceTokenRepository) ListByUser(ctx context.Context, userID string) ([]*model.DeviceToken, error) {
+	query := `
+		SELECT id, user_id, token, platform, created_at, updated_at
+		FROM device_tokens
+		WHERE user_id = $1
+		ORDER BY updated_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []*model.DeviceToken
+	for rows.Next() {
+		var device model.DeviceToken
+		if err := rows.Scan(
+			&device.ID,
+			&device.UserID,
+			&device.Token,
+			&device.Platform,
+			&device.CreatedAt,
+			&device.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		devices = append(devices, &device)
+	}
+
+	return devices, rows.Err()
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model/driver"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
 )
@@ -148,3 +150,61 @@ func (r *DriverRepository) Update(ctx context.Context, d *driver.Driver) error {
 
 	return nil
 }
+
+// SetAvailability records whether the driver accepts rides, it is the source of truth for
+// availability while redis is unavailable
+func (r *DriverRepository) SetAvailability(ctx context.Context, userID uuid.UUID, available bool) error {
+	query := `
+		UPDATE drivers
+		SET is_available = $1, updated_at = NOW()
+		WHERE user_id = $2
+	`
+
+	result, err := r.server.DB.Pool.Exec(ctx, query, available, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update driver availability: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return errs.NewNotFoundError("driver profile not found", false, nil)
+	}
+
+	return nil
+}
+
+// GetNearbyProfiles loads the public profile of several drivers in one query, keyed by user id.
+// Only name, vehicle, rating and availability are filled in.
+func (r *DriverRepository) GetNearbyProfiles(ctx context.Context, userIDs []string) (map[string]model.NearbyDriver, error) {
+	profiles := make(map[string]model.NearbyDriver, len(userIDs))
+	if len(userIDs) == 0 {
+		return profiles, nil
+	}
+
+	query := `
+		SELECT
+			d.user_id::text, u.name, d.vechile_type, d.vechile_number,
+			COALESCE(d.rating, 0)::float8, d.is_available
+		FROM drivers d
+		JOIN users u ON u.id = d.user_id
+		WHERE d.user_id = ANY($1::uuid[])
+	`
+
+	rows, err := r.server.DB.Pool.Query(ctx, query, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load nearby driver profiles: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p model.NearbyDriver
+		if err := rows.Scan(&p.DriverID, &p.Name, &p.VehicleType, &p.VehicleNumber, &p.Rating, &p.IsAvailable); err != nil {
+			return nil, fmt.Errorf("failed to scan nearby driver profile: %w", err)
+		}
+		profiles[p.DriverID] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load nearby driver profiles: %w", err)
+	}
+
+	return profiles, nil
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+const (
+	locationHistoryTable = "driver_location_history"
+	// Partition names carry their day, e.g. driver_location_history_20260101
+	locationHistoryPartitionLayout = "20060102"
+)
+
+type DriverLocationRepository interface {
+	UpdateLatest(ctx context.Context, samples []model.DriverLocationSample) error
+	InsertHistory(ctx context.Context, samples []model.DriverLocationSample) error
+	EnsureHistoryPartitions(ctx context.Context, from time.Time, days int) error
+	DropHistoryPartitionsBefore(ctx context.Context, cutoff time.Time) ([]string, error)
+	FindNearby(ctx context.Context, location model.Location, radiusKm float64, limit int, updatedSince time.Time) ([]model.NearByDriversResponseFromRedis, error)
+	GetLatest(ctx context.Context, driverUserID string, updatedSince time.Time) (*model.Location, error)
+}
+
+type driverLocationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDriverLocationRepository(db *pgxpool.Pool) DriverLocationRepository {
+	return &driverLocationRepository{db: db}
+}
+
+// UpdateLatest writes the latest position of each driver to drivers.location in one statement.
+// Samples are keyed by the driver's user id.
+func (r *driverLocationRepository) UpdateLatest(ctx context.Context, samples []model.DriverLocationSample) error {
+	userIDs := make([]string, len(samples))
+	lats := make([]float64, len(samples))
+	lngs := make([]float64, len(samples))
+	recordedAt := make([]time.Time, len(samples))
+	for i, s := range samples {
+		userIDs[i] = s.DriverUserID
+		lats[i] = s.Location.Latitude
+		lngs[i] = s.Location.Longitude
+		recordedAt[i] = s.RecordedAt
+	}
+
+	query := `
+		UPDATE drivers d
+		SET location = ST_SetSRID(ST_MakePoint(v.lng, v.lat), 4326)::geography,
+			location_updated_at = v.recorded_at
+		FROM unnest(@user_ids::text[], @lats::float8[], @lngs::float8[], @recorded_at::timestamptz[])
+			AS v(user_id, lat, lng, recorded_at)
+		WHERE d.user_id = v.user_id::uuid
+			AND (d.location_updated_at IS NULL OR d.location_updated_at < v.recorded_at)
+	`
+
+	_, err := r.db.Exec(ctx, query, pgx.NamedArgs{
+		"user_ids":    userIDs,
+		"lats":        lats,
+		"lngs":        lngs,
+		"recorded_at": recordedAt,
+	})
+	return err
+}
+
+// InsertHistory appends location samples to the partitioned history table
+func (r *driverLocationRepository) InsertHistory(ctx context.Context, samples []model.DriverLocationSample) error {
+	userIDs := make([]string, len(samples))
+	rideIDs := make([]*string, len(samples))
+	lats := make([]float64, len(samples))
+	lngs := make([]float64, len(samples))
+	headings := make([]float64, len(samples))
+	speeds := make([]float64, len(samples))
+	recordedAt := make([]time.Time, len(samples))
+	for i, s := range samples {
+		userIDs[i] = s.DriverUserID
+		rideIDs[i] = s.RideID
+		lats[i] = s.Location.Latitude
+		lngs[i] = s.Location.Longitude
+		headings[i] = s.Heading
+		speeds[i] = s.Speed
+		recordedAt[i] = s.RecordedAt
+	}
+
+	query := `
+		INSERT INTO driver_location_history (
+			driver_user_id, ride_id, latitude, longitude, heading, speed, recorded_at
+		)
+		SELECT v.user_id::uuid, v.ride_id::uuid, v.lat, v.lng, v.heading, v.speed, v.recorded_at
+		FROM unnest(
+			@user_ids::text[], @ride_ids::text[], @lats::float8[], @lngs::float8[],
+			@headings::float8[], @speeds::float8[], @recorded_at::timestamptz[]
+		) AS v(user_id, ride_id, lat, lng, heading, speed, recorded_at)
+	`
+
+	_, err := r.db.Exec(ctx, query, pgx.NamedArgs{
+		"user_ids":    userIDs,
+		"ride_ids":    rideIDs,
+		"lats":        lats,
+		"lngs":        lngs,
+		"headings":    headings,
+		"speeds":      speeds,
+		"recorded_at": recordedAt,
+	})
+	return err
+}
+
+// EnsureHistoryPartitions creates the daily partitions for the given number of days starting at from
+func (r *driverLocationRepository) EnsureHistoryPartitions(ctx context.Context, from time.Time, days int) error {
+	start := from.UTC().Truncate(24 * time.Hour)
+	for i := 0; i < days; i++ {
+		day := start.AddDate(0, 0, i)
+		query := fmt.Sprintf(
+			`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`,
+			pgx.Identifier{historyPartitionName(day)}.Sanitize(),
+			locationHistoryTable,
+			day.Format(time.RFC3339),
+			day.AddDate(0, 0, 1).Format(time.RFC3339),
+		)
+		if _, err := r.db.Exec(ctx, query); err != nil {
+			return fmt.Errorf("failed to create partition for %s: %w", day.Format(time.DateOnly), err)
+		}
+	}
+	return nil
+}
+
+// DropHistoryPartitionsBefore drops the daily partitions that end on or before cutoff and returns their names
+func (r *driverLocationRepository) DropHistoryPartitionsBefore(ctx context.Context, cutoff time.Time) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = $1
+	`, locationHistoryTable)
+	if err != nil {
+		return nil, err
+	}
+	partitions, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []string
+	for _, name := range partitions {
+		day, ok := historyPartitionDay(name)
+		if !ok || day.AddDate(0, 0, 1).After(cutoff) {
+			continue
+		}
+		if _, err := r.db.Exec(ctx, "DROP TABLE IF EXISTS "+pgx.Identifier{name}.Sanitize()); err != nil {
+			return dropped, fmt.Errorf("failed to drop partition %s: %w", name, err)
+		}
+		dropped = append(dropped, name)
+	}
+	return dropped, nil
+}
+
+// FindNearby returns the available drivers whose persisted position is within radiusKm, closest first.
+// Drivers without a position newer than updatedSince are treated as offline.
+func (r *driverLocationRepository) FindNearby(ctx context.Context, location model.Location, radiusKm float64, limit int, updatedSince time.Time) ([]model.NearByDriversResponseFromRedis, error) {
+	query := `
+		SELECT
+			d.user_id::text,
+			ST_Distance(d.location, p.point) / 1000 AS distance_km,
+			ST_Y(d.location::geometry) AS latitude,
+			ST_X(d.location::geometry) AS longitude
+		FROM drivers d,
+			(SELECT ST_SetSRID(ST_MakePoint(@lng, @lat), 4326)::geography AS point) p
+		WHERE d.is_available = TRUE
+			AND d.location IS NOT NULL
+			AND d.location_updated_at >= @updated_since
+			AND ST_DWithin(d.location, p.point, @radius_m)
+		ORDER BY distance_km ASC
+		LIMIT @limit
+	`
+
+	rows, err := r.db.Query(ctx, query, pgx.NamedArgs{
+		"lat":           location.Latitude,
+		"lng":           location.Longitude,
+		"radius_m":      radiusKm * 1000,
+		"updated_since": updatedSince,
+		"limit":         limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.NearByDriversResponseFromRedis, error) {
+		var d model.NearByDriversResponseFromRedis
+		err := row.Scan(&d.ID, &d.Distance, &d.Latitude, &d.Longitude)
+		return d, err
+	})
+}
+
+// GetLatest returns the persisted position of an available driver, or nil when it is older than updatedSince
+func (r *driverLocationRepository) GetLatest(ctx context.Context, driverUserID string, updatedSince time.Time) (*model.Location, error) {
+	query := `
+		SELECT ST_Y(location::geometry), ST_X(location::geometry)
+		FROM drivers
+		WHERE user_id = @user_id
+			AND is_available = TRUE
+			AND location IS NOT NULL
+			AND location_updated_at >= @updated_since
+	`
+
+	var location model.Location
+	err := r.db.QueryRow(ctx, query, pgx.NamedArgs{
+		"user_id":       driverUserID,
+		"updated_since": updatedSince,
+	}).Scan(&location.Latitude, &location.Longitude)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+func historyPartitionName(day time.Time) string {
+	return locationHistoryTable + "_" + day.Format(locationHistoryPartitionLayout)
+}
+
+func historyPartitionDay(name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, locationHistoryTable+"_")
+	if !ok {
+		return time.Time{}, false
+	}
+	day, err := time.Parse(locationHistoryPartitionLayout, suffix)
+	return day, err == nil
+}
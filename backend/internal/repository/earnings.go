@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+type EarningsRepository interface {
+	Record(ctx context.Context, earning *model.RideEarning) error
+	GetRideEarning(ctx context.Context, paymentID string) (*model.RideEarning, error)
+	DailyTotals(ctx context.Context, driverID string, from, to time.Time) ([]*model.EarningsTotals, error)
+}
+
+type earningsRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewEarningsRepository(db *pgxpool.Pool) EarningsRepository {
+	return &earningsRepository{db: db}
+}
+
+// Record stores a ride earning, recording the same payment or refund again changes nothing
+func (r *earningsRepository) Record(ctx context.Context, earning *model.RideEarning) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO ride_earnings (
+			kind, reference_id, driver_id, ride_id, payment_id, payment_method,
+			fare, tip, commission, net, promo
+		) VALUES (
+			@kind, @reference_id, @driver_id, @ride_id, @payment_id, @payment_method,
+			@fare, @tip, @commission, @net, @promo
+		)
+		ON CONFLICT (kind, reference_id) DO NOTHING
+	`, pgx.NamedArgs{
+		"kind":           earning.Kind,
+		"reference_id":   earning.ReferenceID,
+		"driver_id":      earning.DriverID,
+		"ride_id":        earning.RideID,
+		"payment_id":     earning.PaymentID,
+		"payment_method": earning.PaymentMethod,
+		"fare":           earning.Fare,
+		"tip":            earning.Tip,
+		"commission":     earning.Commission,
+		"net":            earning.Net,
+		"promo":          earning.Promo,
+	})
+	return err
+}
+
+// GetRideEarning returns the earning recorded for a paid ride, nil when the payment has none
+func (r *earningsRepository) GetRideEarning(ctx context.Context, paymentID string) (*model.RideEarning, error) {
+	query := `
+		SELECT id, kind, reference_id, driver_id, ride_id, payment_id, payment_method,
+			fare, tip, commission, net, promo, created_at
+		FROM ride_earnings
+		WHERE kind = 'ride' AND reference_id = $1
+	`
+
+	var e model.RideEarning
+	err := r.db.QueryRow(ctx, query, paymentID).Scan(
+		&e.ID,
+		&e.Kind,
+		&e.ReferenceID,
+		&e.DriverID,
+		&e.RideID,
+		&e.PaymentID,
+		&e.PaymentMethod,
+		&e.Fare,
+		&e.Tip,
+		&e.Commission,
+		&e.Net,
+		&e.Promo,
+		&e.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// DailyTotals adds up a driver's earnings in [from, to) per UTC day, oldest first. Days without
+// earnings are left out. Refunds and cash collected are what riders paid, without the promo the
+// platform paid for; the promo on a cash ride is owed to the driver and offsets the dues.
+func (r *earningsRepository) DailyTotals(ctx context.Context, driverID string, from, to time.Time) ([]*model.EarningsTotals, error) {
+	query := `
+		SELECT
+			date_trunc('day', created_at AT TIME ZONE 'UTC') AS day,
+			COUNT(*) FILTER (WHERE kind = 'ride'),
+			COALESCE(SUM(fare) FILTER (WHERE kind = 'ride'), 0),
+			COALESCE(SUM(tip), 0),
+			COALESCE(SUM(commission), 0),
+			COALESCE(-SUM(fare + tip - promo) FILTER (WHERE kind = 'refund'), 0),
+			COALESCE(SUM(net), 0),
+			COALESCE(SUM(fare + tip - promo) FILTER (WHERE kind = 'ride' AND payment_method = 'cash'), 0),
+			COALESCE(SUM(commission - promo) FILTER (WHERE payment_method = 'cash'), 0)
+		FROM ride_earnings
+		WHERE driver_id = $1 AND created_at >= $2 AND created_at < $3
+		GROUP BY day
+		ORDER BY day
+	`
+
+	rows, err := r.db.Query(ctx, query, driverID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []*model.EarningsTotals
+	for rows.Next() {
+		var t model.EarningsTotals
+		err := rows.Scan(
+			&t.Day,
+			&t.Rides,
+			&t.Fares,
+			&t.Tips,
+			&t.Commission,
+			&t.Refunds,
+			&t.Net,
+			&t.CashCollected,
+			&t.PlatformDues,
+		)
+		if err != nil {
+			return nil, err
+		}
+		t.Day = time.Date(t.Day.Year(), t.Day.Month(), t.Day.Day(), 0, 0, 0, 0, time.UTC)
+		totals = append(totals, &t)
+	}
+	return totals, rows.Err()
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
@@ -11,6 +12,7 @@ import (
 // RideRepository defines the interface for ride-related data operations
 type RiddeRepository interface {
 	Create(ctx context.Context, ride *model.Ride) error
+	CreateWithRedemption(ctx context.Context, ride *model.Ride, redemption *model.PromoRedemption) error
 	GetByID(ctx context.Context, rideID string) (*model.Ride, error)
 	UpdateStatus(ctx context.Context, rideID string, status model.RideStatus) error
 	UpdatePaymentStatus(ctx context.Context, rideID string, paymentStatus model.PaymentStatus, paymentID string) error
@@ -18,6 +20,9 @@ type RiddeRepository interface {
 	GetActiveRideForUser(ctx context.Context, userID string) (*model.Ride, error)
 	GetActiveRideForDriver(ctx context.Context, driverID string) (*model.Ride, error)
 	FindNearbyRides(ctx context.Context, lat, lng, radiusKm float64) ([]model.Ride, error)
+	ListRequestedPickups(ctx context.Context) (map[string]model.Location, error)
+	CountRequestsByCell(ctx context.Context, since time.Time, cellDeg float64) (map[model.GridCell]int, error)
+	GetCharges(ctx context.Context, rideID string) (*model.RideCharges, error)
 }
 
 // DriverRepository defines the interface for driver-related data operations
@@ -26,4 +31,6 @@ type DriverrRepository interface {
 	GetByUserID(ctx context.Context, userID uuid.UUID) (*driver.Driver, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*driver.Driver, error)
 	Update(ctx context.Context, d *driver.Driver) error
+	SetAvailability(ctx context.Context, userID uuid.UUID, available bool) error
+	GetNearbyProfiles(ctx context.Context, userIDs []string) (map[string]model.NearbyDriver, error)
 }
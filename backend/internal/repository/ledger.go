@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+var (
+	// ErrInsufficientBalance is returned by Post when the entry would take a rider wallet below zero
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	// ErrUnbalancedEntry is returned by Post when the postings of an entry do not sum to zero
+	ErrUnbalancedEntry = errors.New("journal entry does not balance")
+)
+
+type LedgerRepository interface {
+	Post(ctx context.Context, entry *model.JournalEntry) (bool, error)
+	GetAccount(ctx context.Context, accountType model.LedgerAccountType, ownerID string) (*model.LedgerAccount, error)
+	BalanceAt(ctx context.Context, accountType model.LedgerAccountType, ownerID string, at time.Time) (int64, error)
+	ListPostings(ctx context.Context, accountType model.LedgerAccountType, ownerID string, from, to time.Time, limit int) ([]*model.LedgerPosting, error)
+	ListRecentPostings(ctx context.Context, accountType model.LedgerAccountType, ownerID string, limit int) ([]*model.LedgerPosting, error)
+	ListAccountsWithBalance(ctx context.Context, accountType model.LedgerAccountType, minBalance int64) ([]*model.LedgerAccount, error)
+}
+
+type ledgerRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewLedgerRepository(db *pgxpool.Pool) LedgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+// Post writes a journal entry and applies its postings to the account balances in one transaction.
+// It reports false, without changes, when an entry of the same type and reference was posted before.
+func (r *ledgerRepository) Post(ctx context.Context, entry *model.JournalEntry) (bool, error) {
+	var total int64
+	for _, p := range entry.Postings {
+		if p.Amount == 0 {
+			return false, ErrUnbalancedEntry
+		}
+		total += p.Amount
+	}
+	if total != 0 || len(entry.Postings) < 2 {
+		return false, ErrUnbalancedEntry
+	}
+
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO journal_entries (type, reference_id, description, currency)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (type, reference_id) DO NOTHING
+		RETURNING id, created_at
+	`, entry.Type, entry.ReferenceID, entry.Description, entry.Currency).Scan(&entry.ID, &entry.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Accounts are locked in the same order by every entry so concurrent entries cannot deadlock
+	postings := slices.Clone(entry.Postings)
+	slices.SortFunc(postings, func(a, b *model.LedgerPosting) int {
+		return cmp.Or(cmp.Compare(a.AccountType, b.AccountType), cmp.Compare(ownerKey(a.OwnerID), ownerKey(b.OwnerID)))
+	})
+
+	for _, p := range postings {
+		err = tx.QueryRow(ctx, `
+			INSERT INTO ledger_accounts (type, owner_id, currency, balance)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (type, COALESCE(owner_id, '00000000-0000-0000-0000-000000000000'::uuid), currency)
+			DO UPDATE SET balance = ledger_accounts.balance + EXCLUDED.balance
+			RETURNING id, balance
+		`, p.AccountType, p.OwnerID, entry.Currency, p.Amount).Scan(&p.AccountID, &p.BalanceAfter)
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "ledger_accounts_balance_check" {
+			return false, ErrInsufficientBalance
+		}
+		if err != nil {
+			return false, err
+		}
+
+		p.EntryID = entry.ID
+		err = tx.QueryRow(ctx, `
+			INSERT INTO ledger_postings (entry_id, account_id, amount, balance_after)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at
+		`, p.EntryID, p.AccountID, p.Amount, p.BalanceAfter).Scan(&p.ID, &p.CreatedAt)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func ownerKey(ownerID *string) string {
+	if ownerID == nil {
+		return ""
+	}
+	return *ownerID
+}
+
+// GetAccount returns the account of an owner, with a zero balance when nothing was posted to it yet
+func (r *ledgerRepository) GetAccount(ctx context.Context, accountType model.LedgerAccountType, ownerID string) (*model.LedgerAccount, error) {
+	query := `
+		SELECT id, type, owner_id, currency, balance, created_at, updated_at
+		FROM ledger_accounts
+		WHERE type = $1 AND owner_id = $2 AND currency = 'INR'
+	`
+
+	var account model.LedgerAccount
+	err := r.db.QueryRow(ctx, query, accountType, ownerID).Scan(
+		&account.ID,
+		&account.Type,
+		&account.OwnerID,
+		&account.Currency,
+		&account.Balance,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &model.LedgerAccount{Type: accountType, OwnerID: &ownerID, Currency: "INR"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ListAccountsWithBalance returns the accounts of a type holding at least minBalance
+func (r *ledgerRepository) ListAccountsWithBalance(ctx context.Context, accountType model.LedgerAccountType, minBalance int64) ([]*model.LedgerAccount, error) {
+	query := `
+		SELECT id, type, owner_id, currency, balance, created_at, updated_at
+		FROM ledger_accounts
+		WHERE type = $1 AND currency = 'INR' AND balance >= $2
+		ORDER BY balance DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, accountType, minBalance)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*model.LedgerAccount
+	for rows.Next() {
+		var account model.LedgerAccount
+		err := rows.Scan(
+			&account.ID,
+			&account.Type,
+			&account.OwnerID,
+			&account.Currency,
+			&account.Balance,
+			&account.CreatedAt,
+			&account.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, &account)
+	}
+	return accounts, rows.Err()
+}
+
+// BalanceAt returns the balance an account had just before a point in time
+func (r *ledgerRepository) BalanceAt(ctx context.Context, accountType model.LedgerAccountType, ownerID string, at time.Time) (int64, error) {
+	query := `
+		SELECT p.balance_after
+		FROM ledger_postings p
+		JOIN ledger_accounts a ON a.id = p.account_id
+		WHERE a.type = $1 AND a.owner_id = $2 AND a.currency = 'INR' AND p.created_at < $3
+		ORDER BY p.seq DESC
+		LIMIT 1
+	`
+
+	var balance int64
+	err := r.db.QueryRow(ctx, query, accountType, ownerID, at).Scan(&balance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return balance, err
+}
+
+const postingColumns = `
+	p.id, p.entry_id, p.account_id, a.type, a.owner_id, p.amount, p.balance_after, p.created_at,
+	e.type, e.reference_id, e.description`
+
+// ListPostings returns the postings of an account made in [from, to), oldest first, with the
+// entries they belong to
+func (r *ledgerRepository) ListPostings(ctx context.Context, accountType model.LedgerAccountType, ownerID string, from, to time.Time, limit int) ([]*model.LedgerPosting, error) {
+	query := `
+		SELECT ` + postingColumns + `
+		FROM ledger_postings p
+		JOIN ledger_accounts a ON a.id = p.account_id
+		JOIN journal_entries e ON e.id = p.entry_id
+		WHERE a.type = $1 AND a.owner_id = $2 AND a.currency = 'INR'
+		AND p.created_at >= $3 AND p.created_at < $4
+		ORDER BY p.seq
+		LIMIT $5
+	`
+	return r.listPostings(ctx, query, accountType, ownerID, from, to, limit)
+}
+
+// ListRecentPostings returns the latest postings of an account, newest first
+func (r *ledgerRepository) ListRecentPostings(ctx context.Context, accountType model.LedgerAccountType, ownerID string, limit int) ([]*model.LedgerPosting, error) {
+	query := `
+		SELECT ` + postingColumns + `
+		FROM ledger_postings p
+		JOIN ledger_accounts a ON a.id = p.account_id
+		JOIN journal_entries e ON e.id = p.entry_id
+		WHERE a.type = $1 AND a.owner_id = $2 AND a.currency = 'INR'
+		ORDER BY p.seq DESC
+		LIMIT $3
+	`
+	return r.listPostings(ctx, query, accountType, ownerID, limit)
+}
+
+func (r *ledgerRepository) listPostings(ctx context.Context, query string, args ...any) ([]*model.LedgerPosting, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var postings []*model.LedgerPosting
+	for rows.Next() {
+		var p model.LedgerPosting
+		err := rows.Scan(
+			&p.ID,
+			&p.EntryID,
+			&p.AccountID,
+			&p.AccountType,
+			&p.OwnerID,
+			&p.Amount,
+			&p.BalanceAfter,
+			&p.CreatedAt,
+			&p.EntryType,
+			&p.ReferenceID,
+			&p.Description,
+		)
+		if err != nil {
+			return nil, err
+		}
+		postings = append(postings, &p)
+	}
+	return postings, rows.Err()
+}
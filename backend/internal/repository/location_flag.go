@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+type LocationFlagRepository interface {
+	Create(ctx context.Context, flag *model.LocationFlag, scoreSince time.Time) (int, error)
+	TopScores(ctx context.Context, since time.Time, limit int) ([]model.DriverFraudScore, error)
+	ListByDriver(ctx context.Context, driverUserID string, limit int) ([]*model.LocationFlag, error)
+}
+
+type locationFlagRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewLocationFlagRepository(db *pgxpool.Pool) LocationFlagRepository {
+	return &locationFlagRepository{db: db}
+}
+
+// Create stores a flag and returns the driver's score since scoreSince including it. The flags of a
+// driver are created one at a time, so each sees exactly the score its own flag produced.
+func (r *locationFlagRepository) Create(ctx context.Context, flag *model.LocationFlag, scoreSince time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('driver_location_flags:' || $1::text))`, flag.DriverUserID); err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO driver_location_flags (
+			driver_user_id, reason, rejected, score, latitude, longitude,
+			previous_latitude, previous_longitude, distance_km, elapsed_seconds, implied_speed_kmh,
+			recorded_at
+		) VALUES (
+			@driver_user_id, @reason, @rejected, @score, @latitude, @longitude,
+			@previous_latitude, @previous_longitude, @distance_km, @elapsed_seconds, @implied_speed_kmh,
+			@recorded_at
+		) RETURNING id, created_at
+	`
+
+	var prevLat, prevLng *float64
+	if flag.PreviousLocation != nil {
+		prevLat, prevLng = &flag.PreviousLocation.Latitude, &flag.PreviousLocation.Longitude
+	}
+
+	err = tx.QueryRow(ctx, query, pgx.NamedArgs{
+		"driver_user_id":     flag.DriverUserID,
+		"reason":             flag.Reason,
+		"rejected":           flag.Rejected,
+		"score":              flag.Score,
+		"latitude":           flag.Location.Latitude,
+		"longitude":          flag.Location.Longitude,
+		"previous_latitude":  prevLat,
+		"previous_longitude": prevLng,
+		"distance_km":        flag.DistanceKm,
+		"elapsed_seconds":    flag.ElapsedSeconds,
+		"implied_speed_kmh":  flag.ImpliedSpeedKmh,
+		"recorded_at":        flag.RecordedAt,
+	}).Scan(&flag.ID, &flag.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	var score int
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(score), 0)
+		FROM driver_location_flags
+		WHERE driver_user_id = $1 AND created_at > $2
+	`, flag.DriverUserID, scoreSince).Scan(&score)
+	if err != nil {
+		return 0, err
+	}
+
+	return score, tx.Commit(ctx)
+}
+
+// TopScores returns the drivers with the highest fraud score since the given time
+func (r *locationFlagRepository) TopScores(ctx context.Context, since time.Time, limit int) ([]model.DriverFraudScore, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT driver_user_id::text, SUM(score), COUNT(*), MAX(created_at)
+		FROM driver_location_flags
+		WHERE created_at > $1
+		GROUP BY driver_user_id
+		ORDER BY SUM(score) DESC, MAX(created_at) DESC
+		LIMIT $2
+	`, since, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.DriverFraudScore, error) {
+		var s model.DriverFraudScore
+		err := row.Scan(&s.DriverUserID, &s.Score, &s.Flags, &s.LastFlaggedAt)
+		return s, err
+	})
+}
+
+// ListByDriver returns the most recent flags of a driver, newest first
+func (r *locationFlagRepository) ListByDriver(ctx context.Context, driverUserID string, limit int) ([]*model.LocationFlag, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, driver_user_id::text, reason, rejected, score, latitude, longitude,
+			previous_latitude, previous_longitude, distance_km, elapsed_seconds, implied_speed_kmh,
+			recorded_at, created_at
+		FROM driver_location_flags
+		WHERE driver_user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, driverUserID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*model.LocationFlag, error) {
+		var f model.LocationFlag
+		var prevLat, prevLng *float64
+		err := row.Scan(&f.ID, &f.DriverUserID, &f.Reason, &f.Rejected, &f.Score,
+			&f.Location.Latitude, &f.Location.Longitude, &prevLat, &prevLng,
+			&f.DistanceKm, &f.ElapsedSeconds, &f.ImpliedSpeedKmh,
+			&f.RecordedAt, &f.CreatedAt)
+		if prevLat != nil && prevLng != nil {
+			f.PreviousLocation = &model.Location{Latitude: *prevLat, Longitude: *prevLng}
+		}
+		return &f, err
+	})
+}
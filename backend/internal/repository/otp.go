@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OTPRepository stores login OTPs in PostgreSQL, used when redis is unavailable
+type OTPRepository interface {
+	Save(ctx context.Context, email, codeHash string, expiresAt time.Time) error
+	Get(ctx context.Context, email string) (string, error)
+	Delete(ctx context.Context, email string) error
+}
+
+type otpRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOTPRepository(db *pgxpool.Pool) OTPRepository {
+	return &otpRepository{db: db}
+}
+
+// Save replaces the pending OTP of the email and clears expired ones
+func (r *otpRepository) Save(ctx context.Context, email, codeHash string, expiresAt time.Time) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM otp_codes WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO otp_codes (email, code_hash, expires_at)
+		VALUES (@email, @code_hash, @expires_at)
+		ON CONFLICT (email) DO UPDATE
+		SET code_hash = EXCLUDED.code_hash, expires_at = EXCLUDED.expires_at, created_at = NOW()
+	`
+	_, err := r.db.Exec(ctx, query, pgx.NamedArgs{
+		"email":      email,
+		"code_hash":  codeHash,
+		"expires_at": expiresAt,
+	})
+	return err
+}
+
+// Get returns the hash of the unexpired OTP of the email, or an empty string when there is none
+func (r *otpRepository) Get(ctx context.Context, email string) (string, error) {
+	var codeHash string
+	err := r.db.QueryRow(ctx,
+		`SELECT code_hash FROM otp_codes WHERE email = $1 AND expires_at > NOW()`, email,
+	).Scan(&codeHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return codeHash, err
+}
+
+func (r *otpRepository) Delete(ctx context.Context, email string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM otp_codes WHERE email = $1`, email)
+	return err
+}
@@ -2,17 +2,32 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
 )
 
+// ErrOutstandingPayment is returned by Create when the ride already has a payment that is not
+// captured or failed yet
+var ErrOutstandingPayment = errors.New("ride already has an outstanding payment")
+
 type PaymentRepository interface {
 	Create(ctx context.Context, payment *model.Payment) error
+	CreateWithTip(ctx context.Context, payment *model.Payment, tip float64) error
 	GetByID(ctx context.Context, id string) (*model.Payment, error)
 	GetByRideID(ctx context.Context, rideID string) (*model.Payment, error)
+	GetOutstandingByRideID(ctx context.Context, rideID string) (*model.Payment, error)
 	Update(ctx context.Context, payment *model.Payment) error
+	SetGatewayOrder(ctx context.Context, id, provider, orderID string) error
+	GetByGatewayOrderID(ctx context.Context, orderID string) (*model.Payment, error)
+	TransitionStatus(ctx context.Context, payment *model.Payment, from model.PaymentStatusType) (bool, error)
 	GetUserPayments(ctx context.Context, userID string, limit, offset int) ([]*model.Payment, error)
+	ListUnsettled(ctx context.Context, createdBefore, failedAfter time.Time, limit int) ([]*model.Payment, error)
+	MarkReconciled(ctx context.Context, id string) error
 }
 
 type paymentRepository struct {
@@ -26,29 +41,61 @@ func NewPaymentRepository(db *pgxpool.Pool) PaymentRepository {
 func (r *paymentRepository) Create(ctx context.Context, payment *model.Payment) error {
 	query := `
 		INSERT INTO payments (
-			id, ride_id, user_id, amount, currency, 
-			razorpay_order_id, status, payment_method
+			id, ride_id, user_id, amount, currency,
+			provider, gateway_order_id, status, payment_method
 		) VALUES (
-			gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7
+			gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8
 		) RETURNING id, created_at, updated_at
 	`
 
-	return r.db.QueryRow(ctx, query,
+	return r.create(ctx, query, payment)
+}
+
+// CreateWithTip creates the payment and saves the ride's new tip in one statement, a payment refused
+// with ErrOutstandingPayment leaves the tip unchanged
+func (r *paymentRepository) CreateWithTip(ctx context.Context, payment *model.Payment, tip float64) error {
+	query := `
+		WITH tip AS (
+			UPDATE rides
+			SET tip_amount = $9, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+		)
+		INSERT INTO payments (
+			id, ride_id, user_id, amount, currency,
+			provider, gateway_order_id, status, payment_method
+		) VALUES (
+			gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8
+		) RETURNING id, created_at, updated_at
+	`
+
+	return r.create(ctx, query, payment, tip)
+}
+
+func (r *paymentRepository) create(ctx context.Context, query string, payment *model.Payment, extra ...any) error {
+	args := append([]any{
 		payment.RideID,
 		payment.UserID,
 		payment.Amount,
 		payment.Currency,
-		payment.RazorpayOrderID,
+		payment.Provider,
+		payment.GatewayOrderID,
 		payment.Status,
 		payment.PaymentMethod,
-	).Scan(&payment.ID, &payment.CreatedAt, &payment.UpdatedAt)
+	}, extra...)
+	err := r.db.QueryRow(ctx, query, args...).Scan(&payment.ID, &payment.CreatedAt, &payment.UpdatedAt)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == "unique_outstanding_payment" {
+		return ErrOutstandingPayment
+	}
+	return err
 }
 
 func (r *paymentRepository) GetByID(ctx context.Context, id string) (*model.Payment, error) {
 	var payment model.Payment
 	query := `
 		SELECT id, ride_id, user_id, amount, currency,
-			razorpay_order_id, razorpay_payment_id, razorpay_signature,
+			provider, gateway_order_id, gateway_payment_id, gateway_signature,
 			status, payment_method, created_at, updated_at
 		FROM payments
 		WHERE id = $1
@@ -60,9 +107,10 @@ func (r *paymentRepository) GetByID(ctx context.Context, id string) (*model.Paym
 		&payment.UserID,
 		&payment.Amount,
 		&payment.Currency,
-		&payment.RazorpayOrderID,
-		&payment.RazorpayPaymentID,
-		&payment.RazorpaySignature,
+		&payment.Provider,
+		&payment.GatewayOrderID,
+		&payment.GatewayPaymentID,
+		&payment.GatewaySignature,
 		&payment.Status,
 		&payment.PaymentMethod,
 		&payment.CreatedAt,
@@ -80,7 +128,7 @@ func (r *paymentRepository) GetByRideID(ctx context.Context, rideID string) (*mo
 	var payment model.Payment
 	query := `
 		SELECT id, ride_id, user_id, amount, currency,
-			razorpay_order_id, razorpay_payment_id, razorpay_signature,
+			provider, gateway_order_id, gateway_payment_id, gateway_signature,
 			status, payment_method, created_at, updated_at
 		FROM payments
 		WHERE ride_id = $1
@@ -94,9 +142,10 @@ func (r *paymentRepository) GetByRideID(ctx context.Context, rideID string) (*mo
 		&payment.UserID,
 		&payment.Amount,
 		&payment.Currency,
-		&payment.RazorpayOrderID,
-		&payment.RazorpayPaymentID,
-		&payment.RazorpaySignature,
+		&payment.Provider,
+		&payment.GatewayOrderID,
+		&payment.GatewayPaymentID,
+		&payment.GatewaySignature,
 		&payment.Status,
 		&payment.PaymentMethod,
 		&payment.CreatedAt,
@@ -110,11 +159,48 @@ func (r *paymentRepository) GetByRideID(ctx context.Context, rideID string) (*mo
 	return &payment, nil
 }
 
+// GetOutstandingByRideID returns the payment of a ride that is still open, nil when there is none
+func (r *paymentRepository) GetOutstandingByRideID(ctx context.Context, rideID string) (*model.Payment, error) {
+	var payment model.Payment
+	query := `
+		SELECT id, ride_id, user_id, amount, currency,
+			provider, gateway_order_id, gateway_payment_id, gateway_signature,
+			status, payment_method, created_at, updated_at
+		FROM payments
+		WHERE ride_id = $1 AND status IN ('created', 'pending', 'authorized')
+	`
+
+	err := r.db.QueryRow(ctx, query, rideID).Scan(
+		&payment.ID,
+		&payment.RideID,
+		&payment.UserID,
+		&payment.Amount,
+		&payment.Currency,
+		&payment.Provider,
+		&payment.GatewayOrderID,
+		&payment.GatewayPaymentID,
+		&payment.GatewaySignature,
+		&payment.Status,
+		&payment.PaymentMethod,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
 func (r *paymentRepository) Update(ctx context.Context, payment *model.Payment) error {
 	query := `
 		UPDATE payments
-		SET razorpay_payment_id = $1,
-			razorpay_signature = $2,
+		SET gateway_payment_id = $1,
+			gateway_signature = $2,
 			status = $3,
 			updated_at = CURRENT_TIMESTAMP
 		WHERE id = $4
@@ -122,25 +208,130 @@ func (r *paymentRepository) Update(ctx context.Context, payment *model.Payment)
 	`
 
 	return r.db.QueryRow(ctx, query,
-		payment.RazorpayPaymentID,
-		payment.RazorpaySignature,
+		payment.GatewayPaymentID,
+		payment.GatewaySignature,
 		payment.Status,
 		payment.ID,
 	).Scan(&payment.UpdatedAt)
 }
 
+// SetGatewayOrder links a payment to the order created for it at a gateway
+func (r *paymentRepository) SetGatewayOrder(ctx context.Context, id, provider, orderID string) error {
+	query := `
+		UPDATE payments
+		SET provider = $1,
+			gateway_order_id = $2,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+	`
+
+	_, err := r.db.Exec(ctx, query, provider, orderID, id)
+	return err
+}
+
+// GetByGatewayOrderID finds the payment an order was created for, nil when there is none
+func (r *paymentRepository) GetByGatewayOrderID(ctx context.Context, orderID string) (*model.Payment, error) {
+	var payment model.Payment
+	query := `
+		SELECT id, ride_id, user_id, amount, currency,
+			provider, gateway_order_id, gateway_payment_id, gateway_signature,
+			status, payment_method, created_at, updated_at
+		FROM payments
+		WHERE gateway_order_id = $1
+	`
+
+	err := r.db.QueryRow(ctx, query, orderID).Scan(
+		&payment.ID,
+		&payment.RideID,
+		&payment.UserID,
+		&payment.Amount,
+		&payment.Currency,
+		&payment.Provider,
+		&payment.GatewayOrderID,
+		&payment.GatewayPaymentID,
+		&payment.GatewaySignature,
+		&payment.Status,
+		&payment.PaymentMethod,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
+// TransitionStatus writes the payment's status and gateway ids only if the stored status is still
+// from, so concurrent webhook and checkout updates cannot overwrite each other. It reports whether
+// the row was updated.
+func (r *paymentRepository) TransitionStatus(ctx context.Context, payment *model.Payment, from model.PaymentStatusType) (bool, error) {
+	query := `
+		UPDATE payments
+		SET status = $1,
+			gateway_payment_id = COALESCE($2, gateway_payment_id),
+			gateway_signature = COALESCE($3, gateway_signature),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 AND status = $5
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRow(ctx, query,
+		payment.Status,
+		payment.GatewayPaymentID,
+		payment.GatewaySignature,
+		payment.ID,
+		from,
+	).Scan(&payment.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func (r *paymentRepository) GetUserPayments(ctx context.Context, userID string, limit, offset int) ([]*model.Payment, error) {
 	query := `
 		SELECT id, ride_id, user_id, amount, currency,
-			razorpay_order_id, razorpay_payment_id, razorpay_signature,
+			provider, gateway_order_id, gateway_payment_id, gateway_signature,
 			status, payment_method, created_at, updated_at
 		FROM payments
 		WHERE user_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
 	`
+	return r.list(ctx, query, userID, limit, offset)
+}
+
+// ListUnsettled returns open payments with a gateway order created before createdBefore, and the
+// failed ones whose order was also created after failedAfter, the ones reconciled least recently first
+func (r *paymentRepository) ListUnsettled(ctx context.Context, createdBefore, failedAfter time.Time, limit int) ([]*model.Payment, error) {
+	query := `
+		SELECT id, ride_id, user_id, amount, currency,
+			provider, gateway_order_id, gateway_payment_id, gateway_signature,
+			status, payment_method, created_at, updated_at
+		FROM payments
+		WHERE status IN ('created', 'pending', 'authorized', 'failed')
+			AND (status <> 'failed' OR created_at > $2)
+			AND gateway_order_id IS NOT NULL
+			AND created_at < $1
+		ORDER BY reconciled_at NULLS FIRST, created_at
+		LIMIT $3
+	`
+	return r.list(ctx, query, createdBefore, failedAfter, limit)
+}
+
+// MarkReconciled records that a payment was compared with the gateway
+func (r *paymentRepository) MarkReconciled(ctx context.Context, id string) error {
+	_, err := r.db.Exec(ctx, `UPDATE payments SET reconciled_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+	return err
+}
 
-	rows, err := r.db.Query(ctx, query, userID, limit, offset)
+func (r *paymentRepository) list(ctx context.Context, query string, args ...any) ([]*model.Payment, error) {
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -155,9 +346,10 @@ func (r *paymentRepository) GetUserPayments(ctx context.Context, userID string,
 			&payment.UserID,
 			&payment.Amount,
 			&payment.Currency,
-			&payment.RazorpayOrderID,
-			&payment.RazorpayPaymentID,
-			&payment.RazorpaySignature,
+			&payment.Provider,
+			&payment.GatewayOrderID,
+			&payment.GatewayPaymentID,
+			&payment.GatewaySignature,
 			&payment.Status,
 			&payment.PaymentMethod,
 			&payment.CreatedAt,
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+type PaymentDiscrepancyRepository interface {
+	Record(ctx context.Context, discrepancy *model.PaymentDiscrepancy) (bool, error)
+	List(ctx context.Context, status model.DiscrepancyStatus, limit int) ([]*model.PaymentDiscrepancy, error)
+	Resolve(ctx context.Context, id, resolvedBy, note string) (*model.PaymentDiscrepancy, error)
+}
+
+type paymentDiscrepancyRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPaymentDiscrepancyRepository(db *pgxpool.Pool) PaymentDiscrepancyRepository {
+	return &paymentDiscrepancyRepository{db: db}
+}
+
+const paymentDiscrepancyColumns = `
+	id, payment_id, kind, status, payment_status, amount, provider, gateway_order_id,
+	gateway_payment_id, gateway_status, gateway_amount, details, resolved_by, resolution_note,
+	detected_at, last_seen_at, resolved_at`
+
+func scanPaymentDiscrepancy(row pgx.Row) (*model.PaymentDiscrepancy, error) {
+	var d model.PaymentDiscrepancy
+	err := row.Scan(
+		&d.ID,
+		&d.PaymentID,
+		&d.Kind,
+		&d.Status,
+		&d.PaymentStatus,
+		&d.Amount,
+		&d.Provider,
+		&d.GatewayOrderID,
+		&d.GatewayPaymentID,
+		&d.GatewayStatus,
+		&d.GatewayAmount,
+		&d.Details,
+		&d.ResolvedBy,
+		&d.ResolutionNote,
+		&d.DetectedAt,
+		&d.LastSeenAt,
+		&d.ResolvedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Record stores a discrepancy and reports whether it is new. An open discrepancy of the same kind
+// for the payment is updated instead, so a difference that persists is reported once.
+func (r *paymentDiscrepancyRepository) Record(ctx context.Context, d *model.PaymentDiscrepancy) (bool, error) {
+	var inserted bool
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO payment_discrepancies (
+			payment_id, kind, status, payment_status, amount, provider, gateway_order_id,
+			gateway_payment_id, gateway_status, gateway_amount, details
+		) VALUES (
+			@payment_id, @kind, @status, @payment_status, @amount, @provider, @gateway_order_id,
+			@gateway_payment_id, @gateway_status, @gateway_amount, @details
+		)
+		ON CONFLICT (payment_id, kind) WHERE status = 'open' DO UPDATE
+		SET payment_status = EXCLUDED.payment_status,
+			gateway_payment_id = EXCLUDED.gateway_payment_id,
+			gateway_status = EXCLUDED.gateway_status,
+			gateway_amount = EXCLUDED.gateway_amount,
+			details = EXCLUDED.details,
+			last_seen_at = CURRENT_TIMESTAMP
+		RETURNING id, detected_at, last_seen_at, xmax = 0
+	`, pgx.NamedArgs{
+		"payment_id":         d.PaymentID,
+		"kind":               d.Kind,
+		"status":             d.Status,
+		"payment_status":     d.PaymentStatus,
+		"amount":             d.Amount,
+		"provider":           d.Provider,
+		"gateway_order_id":   d.GatewayOrderID,
+		"gateway_payment_id": d.GatewayPaymentID,
+		"gateway_status":     d.GatewayStatus,
+		"gateway_amount":     d.GatewayAmount,
+		"details":            d.Details,
+	}).Scan(&d.ID, &d.DetectedAt, &d.LastSeenAt, &inserted)
+	return inserted, err
+}
+
+// List returns the discrepancies in a status, most recently detected first
+func (r *paymentDiscrepancyRepository) List(ctx context.Context, status model.DiscrepancyStatus, limit int) ([]*model.PaymentDiscrepancy, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+paymentDiscrepancyColumns+`
+		FROM payment_discrepancies
+		WHERE status = $1
+		ORDER BY detected_at DESC
+		LIMIT $2
+	`, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var discrepancies []*model.PaymentDiscrepancy
+	for rows.Next() {
+		d, err := scanPaymentDiscrepancy(rows)
+		if err != nil {
+			return nil, err
+		}
+		discrepancies = append(discrepancies, d)
+	}
+	return discrepancies, rows.Err()
+}
+
+// Resolve closes an open discrepancy, nil when there is no open discrepancy with the id
+func (r *paymentDiscrepancyRepository) Resolve(ctx context.Context, id, resolvedBy, note string) (*model.PaymentDiscrepancy, error) {
+	d, err := scanPaymentDiscrepancy(r.db.QueryRow(ctx, `
+		UPDATE payment_discrepancies
+		SET status = 'resolved',
+			resolved_by = $2,
+			resolution_note = $3,
+			resolved_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'open'
+		RETURNING `+paymentDiscrepancyColumns, id, resolvedBy, note))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return d, err
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+// PaymentEventRepository records gateway webhook deliveries so each event is applied once
+type PaymentEventRepository interface {
+	Claim(ctx context.Context, event *model.PaymentEvent) (processed bool, err error)
+	MarkProcessed(ctx context.Context, id string, paymentID *string) error
+}
+
+type paymentEventRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPaymentEventRepository(db *pgxpool.Pool) PaymentEventRepository {
+	return &paymentEventRepository{db: db}
+}
+
+// Claim stores an event unless it is known. processed is true when an earlier delivery of the
+// event was applied already, a delivery that failed halfway is handed out again.
+func (r *paymentEventRepository) Claim(ctx context.Context, event *model.PaymentEvent) (bool, error) {
+	query := `
+		INSERT INTO payment_events (id, event_type, payload)
+		VALUES (@id, @event_type, @payload)
+		ON CONFLICT (id) DO UPDATE SET id = EXCLUDED.id
+		RETURNING processed_at IS NOT NULL, received_at
+	`
+
+	var processed bool
+	err := r.db.QueryRow(ctx, query, pgx.NamedArgs{
+		"id":         event.ID,
+		"event_type": event.EventType,
+		"payload":    event.Payload,
+	}).Scan(&processed, &event.ReceivedAt)
+	return processed, err
+}
+
+func (r *paymentEventRepository) MarkProcessed(ctx context.Context, id string, paymentID *string) error {
+	query := `
+		UPDATE payment_events
+		SET processed_at = NOW(), payment_id = @payment_id
+		WHERE id = @id
+	`
+	_, err := r.db.Exec(ctx, query, pgx.NamedArgs{"id": id, "payment_id": paymentID})
+	return err
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+type PayoutRepository interface {
+	OpenBatch(ctx context.Context) (*model.PayoutBatch, error)
+	CloseBatch(ctx context.Context, batchID string) (*model.PayoutBatch, error)
+	GetBatch(ctx context.Context, batchID string) (*model.PayoutBatch, error)
+	ListBatches(ctx context.Context, limit int) ([]*model.PayoutBatch, error)
+	CreatePayout(ctx context.Context, payout *model.Payout) (bool, error)
+	ListPayouts(ctx context.Context, batchID string) ([]*model.Payout, error)
+}
+
+type payoutRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPayoutRepository(db *pgxpool.Pool) PayoutRepository {
+	return &payoutRepository{db: db}
+}
+
+const payoutBatchColumns = `id, status, payout_count, total_amount, currency, created_at, completed_at`
+
+func scanPayoutBatch(row pgx.Row) (*model.PayoutBatch, error) {
+	var batch model.PayoutBatch
+	err := row.Scan(
+		&batch.ID,
+		&batch.Status,
+		&batch.PayoutCount,
+		&batch.TotalAmount,
+		&batch.Currency,
+		&batch.CreatedAt,
+		&batch.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// OpenBatch returns the open payout batch, creating one when there is none. At most one batch is
+// open at a time, so an interrupted settlement continues in its batch.
+func (r *payoutRepository) OpenBatch(ctx context.Context) (*model.PayoutBatch, error) {
+	batch, err := scanPayoutBatch(r.db.QueryRow(ctx, `
+		INSERT INTO payout_batches (status) VALUES ('open')
+		ON CONFLICT (status) WHERE status = 'open' DO NOTHING
+		RETURNING `+payoutBatchColumns))
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return batch, err
+	}
+
+	return scanPayoutBatch(r.db.QueryRow(ctx, `
+		SELECT `+payoutBatchColumns+`
+		FROM payout_batches
+		WHERE status = 'open'
+	`))
+}
+
+// CloseBatch marks a batch ready with the count and total of its payouts
+func (r *payoutRepository) CloseBatch(ctx context.Context, batchID string) (*model.PayoutBatch, error) {
+	return scanPayoutBatch(r.db.QueryRow(ctx, `
+		UPDATE payout_batches b
+		SET status = 'ready',
+			payout_count = p.count,
+			total_amount = p.total,
+			completed_at = CURRENT_TIMESTAMP
+		FROM (
+			SELECT COUNT(*) AS count, COALESCE(SUM(amount), 0) AS total
+			FROM payouts WHERE batch_id = $1
+		) p
+		WHERE b.id = $1
+		RETURNING b.id, b.status, b.payout_count, b.total_amount, b.currency, b.created_at, b.completed_at
+	`, batchID))
+}
+
+func (r *payoutRepository) GetBatch(ctx context.Context, batchID string) (*model.PayoutBatch, error) {
+	return scanPayoutBatch(r.db.QueryRow(ctx, `
+		SELECT `+payoutBatchColumns+`
+		FROM payout_batches
+		WHERE id = $1
+	`, batchID))
+}
+
+// ListBatches returns the latest payout batches, newest first
+func (r *payoutRepository) ListBatches(ctx context.Context, limit int) ([]*model.PayoutBatch, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+payoutBatchColumns+`
+		FROM payout_batches
+		ORDER BY created_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []*model.PayoutBatch
+	for rows.Next() {
+		batch, err := scanPayoutBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+	return batches, rows.Err()
+}
+
+// CreatePayout adds a driver's payout to a batch. It reports false, without changes, when the
+// driver already has a payout in the batch.
+func (r *payoutRepository) CreatePayout(ctx context.Context, payout *model.Payout) (bool, error) {
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO payouts (batch_id, driver_id, amount, currency)
+		VALUES (@batch_id, @driver_id, @amount, @currency)
+		ON CONFLICT (batch_id, driver_id) DO NOTHING
+		RETURNING id, created_at
+	`, pgx.NamedArgs{
+		"batch_id":  payout.BatchID,
+		"driver_id": payout.DriverID,
+		"amount":    payout.Amount,
+		"currency":  payout.Currency,
+	}).Scan(&payout.ID, &payout.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListPayouts returns the payouts of a batch with the drivers they pay
+func (r *payoutRepository) ListPayouts(ctx context.Context, batchID string) ([]*model.Payout, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT p.id, p.batch_id, p.driver_id, p.amount, p.currency, p.created_at,
+			u.name, u.phone, d.vechile_number
+		FROM payouts p
+		JOIN drivers d ON d.id = p.driver_id
+		JOIN users u ON u.id = d.user_id
+		WHERE p.batch_id = $1
+		ORDER BY p.created_at, p.id
+	`, batchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payouts []*model.Payout
+	for rows.Next() {
+		var p model.Payout
+		err := rows.Scan(
+			&p.ID,
+			&p.BatchID,
+			&p.DriverID,
+			&p.Amount,
+			&p.Currency,
+			&p.CreatedAt,
+			&p.DriverName,
+			&p.Phone,
+			&p.VehicleNumber,
+		)
+		if err != nil {
+			return nil, err
+		}
+		payouts = append(payouts, &p)
+	}
+	return payouts, rows.Err()
+}
@@ -0,0 +1,308 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+var (
+	// ErrPromoCodeTaken is returned when another promotion already has the code
+	ErrPromoCodeTaken = errors.New("promo code is already taken")
+	// ErrPromoUsageLimit is returned when redeeming a promotion that reached its usage limit
+	ErrPromoUsageLimit = errors.New("promo code has reached its usage limit")
+	// ErrPromoUserLimit is returned when the rider already redeemed the promotion as often as allowed
+	ErrPromoUserLimit = errors.New("promo code was already used the maximum number of times")
+)
+
+type PromotionRepository interface {
+	Create(ctx context.Context, promotion *model.Promotion) error
+	Update(ctx context.Context, promotion *model.Promotion) error
+	GetByID(ctx context.Context, id string) (*model.Promotion, error)
+	GetByCode(ctx context.Context, code string) (*model.Promotion, error)
+	List(ctx context.Context, active *bool, limit int) ([]*model.Promotion, error)
+	Deactivate(ctx context.Context, id string) (bool, error)
+	CountUserRedemptions(ctx context.Context, promotionID, userID string) (int, error)
+	Release(ctx context.Context, rideID string) (bool, error)
+}
+
+type promotionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPromotionRepository(db *pgxpool.Pool) PromotionRepository {
+	return &promotionRepository{db: db}
+}
+
+const promotionColumns = `
+	id, code, description, discount_type, discount_value, max_discount, min_fare,
+	starts_at, ends_at, usage_limit, per_user_limit, usage_count, vehicle_types,
+	zone_name, zone_lat, zone_lng, zone_radius_km, active, granted_to, created_by, created_at, updated_at`
+
+func scanPromotion(row pgx.Row) (*model.Promotion, error) {
+	var p model.Promotion
+	var zoneName *string
+	var zoneLat, zoneLng, zoneRadius *float64
+	err := row.Scan(
+		&p.ID,
+		&p.Code,
+		&p.Description,
+		&p.DiscountType,
+		&p.DiscountValue,
+		&p.MaxDiscount,
+		&p.MinFare,
+		&p.StartsAt,
+		&p.EndsAt,
+		&p.UsageLimit,
+		&p.PerUserLimit,
+		&p.UsageCount,
+		&p.VehicleTypes,
+		&zoneName,
+		&zoneLat,
+		&zoneLng,
+		&zoneRadius,
+		&p.Active,
+		&p.GrantedTo,
+		&p.CreatedBy,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if zoneLat != nil && zoneLng != nil && zoneRadius != nil {
+		p.Zone = &model.PromoZone{
+			Center:   model.Location{Latitude: *zoneLat, Longitude: *zoneLng},
+			RadiusKm: *zoneRadius,
+		}
+		if zoneName != nil {
+			p.Zone.Name = *zoneName
+		}
+	}
+	return &p, nil
+}
+
+func promotionArgs(p *model.Promotion) pgx.NamedArgs {
+	args := pgx.NamedArgs{
+		"id":             p.ID,
+		"code":           p.Code,
+		"description":    p.Description,
+		"discount_type":  p.DiscountType,
+		"discount_value": p.DiscountValue,
+		"max_discount":   p.MaxDiscount,
+		"min_fare":       p.MinFare,
+		"starts_at":      p.StartsAt,
+		"ends_at":        p.EndsAt,
+		"usage_limit":    p.UsageLimit,
+		"per_user_limit": p.PerUserLimit,
+		"vehicle_types":  p.VehicleTypes,
+		"zone_name":      nil,
+		"zone_lat":       nil,
+		"zone_lng":       nil,
+		"zone_radius_km": nil,
+		"active":         p.Active,
+		"granted_to":     p.GrantedTo,
+		"created_by":     p.CreatedBy,
+	}
+	if p.VehicleTypes == nil {
+		args["vehicle_types"] = []string{}
+	}
+	if p.Zone != nil {
+		args["zone_name"] = p.Zone.Name
+		args["zone_lat"] = p.Zone.Center.Latitude
+		args["zone_lng"] = p.Zone.Center.Longitude
+		args["zone_radius_km"] = p.Zone.RadiusKm
+	}
+	return args
+}
+
+func promotionErr(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == "unique_promotion_code" {
+		return ErrPromoCodeTaken
+	}
+	return err
+}
+
+func (r *promotionRepository) Create(ctx context.Context, p *model.Promotion) error {
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO promotions (
+			code, description, discount_type, discount_value, max_discount, min_fare,
+			starts_at, ends_at, usage_limit, per_user_limit, vehicle_types,
+			zone_name, zone_lat, zone_lng, zone_radius_km, active, granted_to, created_by
+		) VALUES (
+			@code, @description, @discount_type, @discount_value, @max_discount, @min_fare,
+			@starts_at, @ends_at, @usage_limit, @per_user_limit, @vehicle_types,
+			@zone_name, @zone_lat, @zone_lng, @zone_radius_km, @active, @granted_to, @created_by
+		) RETURNING id, usage_count, created_at, updated_at
+	`, promotionArgs(p)).Scan(&p.ID, &p.UsageCount, &p.CreatedAt, &p.UpdatedAt)
+	return promotionErr(err)
+}
+
+// Update replaces a promotion's terms, its usage count and grantee are kept. pgx.ErrNoRows when there is no
+// promotion with the id.
+func (r *promotionRepository) Update(ctx context.Context, p *model.Promotion) error {
+	err := r.db.QueryRow(ctx, `
+		UPDATE promotions
+		SET code = @code,
+			description = @description,
+			discount_type = @discount_type,
+			discount_value = @discount_value,
+			max_discount = @max_discount,
+			min_fare = @min_fare,
+			starts_at = @starts_at,
+			ends_at = @ends_at,
+			usage_limit = @usage_limit,
+			per_user_limit = @per_user_limit,
+			vehicle_types = @vehicle_types,
+			zone_name = @zone_name,
+			zone_lat = @zone_lat,
+			zone_lng = @zone_lng,
+			zone_radius_km = @zone_radius_km,
+			active = @active
+		WHERE id = @id
+		RETURNING usage_count, granted_to, created_by, created_at, updated_at
+	`, promotionArgs(p)).Scan(&p.UsageCount, &p.GrantedTo, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt)
+	return promotionErr(err)
+}
+
+// GetByID returns a promotion, nil when there is none with the id
+func (r *promotionRepository) GetByID(ctx context.Context, id string) (*model.Promotion, error) {
+	p, err := scanPromotion(r.db.QueryRow(ctx, `SELECT `+promotionColumns+` FROM promotions WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return p, err
+}
+
+// GetByCode returns the promotion with a code in any case, nil when there is none
+func (r *promotionRepository) GetByCode(ctx context.Context, code string) (*model.Promotion, error) {
+	p, err := scanPromotion(r.db.QueryRow(ctx, `SELECT `+promotionColumns+` FROM promotions WHERE UPPER(code) = UPPER($1)`, code))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return p, err
+}
+
+// List returns the latest promotions, only active or only inactive ones when active is set
+func (r *promotionRepository) List(ctx context.Context, active *bool, limit int) ([]*model.Promotion, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+promotionColumns+`
+		FROM promotions
+		WHERE $1::boolean IS NULL OR active = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, active, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promotions []*model.Promotion
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, p)
+	}
+	return promotions, rows.Err()
+}
+
+// Deactivate stops a promotion from being redeemed, its redemptions are kept. It reports whether
+// there was a promotion with the id.
+func (r *promotionRepository) Deactivate(ctx context.Context, id string) (bool, error) {
+	result, err := r.db.Exec(ctx, `UPDATE promotions SET active = FALSE WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// CountUserRedemptions counts a rider's redemptions of a promotion that were not released
+func (r *promotionRepository) CountUserRedemptions(ctx context.Context, promotionID, userID string) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM promo_redemptions
+		WHERE promotion_id = $1 AND user_id = $2 AND status = 'redeemed'
+	`, promotionID, userID).Scan(&count)
+	return count, err
+}
+
+// Release gives back the promo redeemed for a ride so it no longer counts towards the limits. It
+// reports whether the ride had one.
+func (r *promotionRepository) Release(ctx context.Context, rideID string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var promotionID string
+	err = tx.QueryRow(ctx, `
+		UPDATE promo_redemptions
+		SET status = 'released', released_at = CURRENT_TIMESTAMP
+		WHERE ride_id = $1 AND status = 'redeemed'
+		RETURNING promotion_id
+	`, rideID).Scan(&promotionID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE promotions SET usage_count = usage_count - 1 WHERE id = $1`, promotionID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// redeemPromotion records a redemption in a transaction that also writes the ride. The promotion
+// row is locked so concurrent redemptions cannot exceed its limits.
+func redeemPromotion(ctx context.Context, tx pgx.Tx, redemption *model.PromoRedemption) error {
+	var usageLimit *int
+	var usageCount, perUserLimit int
+	err := tx.QueryRow(ctx, `
+		SELECT usage_limit, usage_count, per_user_limit
+		FROM promotions
+		WHERE id = $1
+		FOR UPDATE
+	`, redemption.PromotionID).Scan(&usageLimit, &usageCount, &perUserLimit)
+	if err != nil {
+		return err
+	}
+	if usageLimit != nil && usageCount >= *usageLimit {
+		return ErrPromoUsageLimit
+	}
+
+	var redeemed int
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM promo_redemptions
+		WHERE promotion_id = $1 AND user_id = $2 AND status = 'redeemed'
+	`, redemption.PromotionID, redemption.UserID).Scan(&redeemed)
+	if err != nil {
+		return err
+	}
+	if redeemed >= perUserLimit {
+		return ErrPromoUserLimit
+	}
+
+	redemption.Status = model.PromoRedemptionRedeemed
+	err = tx.QueryRow(ctx, `
+		INSERT INTO promo_redemptions (promotion_id, user_id, ride_id, discount, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, redemption.PromotionID, redemption.UserID, redemption.RideID, redemption.Discount, redemption.Status).
+		Scan(&redemption.ID, &redemption.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE promotions SET usage_count = usage_count + 1 WHERE id = $1`, redemption.PromotionID)
+	return err
+}
@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+// ErrReferralCodeTaken is returned when creating a user with a referral code another user has
+var ErrReferralCodeTaken = errors.New("referral code is already taken")
+
+// PaidRide is a rider's first paid ride, with the user id of the driver who drove it
+type PaidRide struct {
+	RideID       string
+	DriverUserID *string
+}
+
+type ReferralRepository interface {
+	GetReferrer(ctx context.Context, code string) (*model.User, error)
+	Create(ctx context.Context, referral *model.Referral) error
+	GetByReferee(ctx context.Context, refereeID string) (*model.Referral, error)
+	ListByReferrer(ctx context.Context, referrerID string) ([]*model.Referral, error)
+	ListPendingPaid(ctx context.Context, limit int) ([]string, error)
+	FirstPaidRide(ctx context.Context, userID string) (*PaidRide, error)
+	Reward(ctx context.Context, referral *model.Referral) (bool, error)
+	Reject(ctx context.Context, referral *model.Referral) (bool, error)
+}
+
+type referralRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewReferralRepository(db *pgxpool.Pool) ReferralRepository {
+	return &referralRepository{db: db}
+}
+
+const referralColumns = `
+	f.id, f.referrer_id, f.referee_id, f.code, f.status, f.reject_reason, f.ride_id, f.reward_type,
+	f.referrer_reward, f.referee_reward, f.referrer_promo_code, f.referee_promo_code, f.rewarded_at,
+	f.created_at, f.updated_at`
+
+// scanReferral scans the referral columns followed by the first name of the other party
+func scanReferral(row pgx.Row) (*model.Referral, error) {
+	var f model.Referral
+	err := row.Scan(
+		&f.ID,
+		&f.ReferrerID,
+		&f.RefereeID,
+		&f.Code,
+		&f.Status,
+		&f.RejectReason,
+		&f.RideID,
+		&f.RewardType,
+		&f.ReferrerReward,
+		&f.RefereeReward,
+		&f.ReferrerPromoCode,
+		&f.RefereePromoCode,
+		&f.RewardedAt,
+		&f.CreatedAt,
+		&f.UpdatedAt,
+		&f.Name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// GetReferrer returns the user with a referral code in any case, nil when there is none
+func (r *referralRepository) GetReferrer(ctx context.Context, code string) (*model.User, error) {
+	var user model.User
+	err := r.db.QueryRow(ctx, `
+		SELECT id, name, email, phone, role, referral_code, signup_device_id, created_at, updated_at
+		FROM users
+		WHERE referral_code = UPPER($1)
+	`, code).Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.Phone,
+		&user.Role,
+		&user.ReferralCode,
+		&user.SignupDeviceID,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *referralRepository) Create(ctx context.Context, f *model.Referral) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO referrals (referrer_id, referee_id, code, status, reject_reason)
+		VALUES (@referrer_id, @referee_id, @code, @status, @reject_reason)
+		RETURNING id, created_at, updated_at
+	`, pgx.NamedArgs{
+		"referrer_id":   f.ReferrerID,
+		"referee_id":    f.RefereeID,
+		"code":          f.Code,
+		"status":        f.Status,
+		"reject_reason": f.RejectReason,
+	}).Scan(&f.ID, &f.CreatedAt, &f.UpdatedAt)
+}
+
+// GetByReferee returns the referral a rider signed up with, named after the referrer. Nil when
+// the rider was not referred.
+func (r *referralRepository) GetByReferee(ctx context.Context, refereeID string) (*model.Referral, error) {
+	f, err := scanReferral(r.db.QueryRow(ctx, `
+		SELECT `+referralColumns+`, SPLIT_PART(u.name, ' ', 1)
+		FROM referrals f
+		JOIN users u ON u.id = f.referrer_id
+		WHERE f.referee_id = $1
+	`, refereeID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return f, err
+}
+
+// ListByReferrer returns the referrals a user made, latest first, named after the riders they referred
+func (r *referralRepository) ListByReferrer(ctx context.Context, referrerID string) ([]*model.Referral, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+referralColumns+`, SPLIT_PART(u.name, ' ', 1)
+		FROM referrals f
+		JOIN users u ON u.id = f.referee_id
+		WHERE f.referrer_id = $1
+		ORDER BY f.created_at DESC
+	`, referrerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var referrals []*model.Referral
+	for rows.Next() {
+		f, err := scanReferral(rows)
+		if err != nil {
+			return nil, err
+		}
+		referrals = append(referrals, f)
+	}
+	return referrals, rows.Err()
+}
+
+// ListPendingPaid returns the riders with a pending referral who already have a paid ride, the
+// oldest referrals first
+func (r *referralRepository) ListPendingPaid(ctx context.Context, limit int) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT f.referee_id::text
+		FROM referrals f
+		WHERE f.status = 'pending'
+		AND EXISTS (SELECT 1 FROM payments p WHERE p.user_id = f.referee_id AND p.status = 'captured')
+		ORDER BY f.created_at
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// FirstPaidRide returns the rider's earliest ride with a captured payment, nil when there is none
+func (r *referralRepository) FirstPaidRide(ctx context.Context, userID string) (*PaidRide, error) {
+	var ride PaidRide
+	err := r.db.QueryRow(ctx, `
+		SELECT p.ride_id::text, d.user_id::text
+		FROM payments p
+		JOIN rides r ON r.id = p.ride_id
+		LEFT JOIN drivers d ON d.id = r.driver_id
+		WHERE p.user_id = $1 AND p.status = 'captured'
+		ORDER BY p.updated_at
+		LIMIT 1
+	`, userID).Scan(&ride.RideID, &ride.DriverUserID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ride, nil
+}
+
+// Reward marks a pending referral rewarded with its ride and rewards. It reports false when the
+// referral is no longer pending.
+func (r *referralRepository) Reward(ctx context.Context, f *model.Referral) (bool, error) {
+	err := r.db.QueryRow(ctx, `
+		UPDATE referrals
+		SET status = 'rewarded',
+			ride_id = @ride_id,
+			reward_type = @reward_type,
+			referrer_reward = @referrer_reward,
+			referee_reward = @referee_reward,
+			referrer_promo_code = @referrer_promo_code,
+			referee_promo_code = @referee_promo_code,
+			rewarded_at = CURRENT_TIMESTAMP
+		WHERE id = @id AND status = 'pending'
+		RETURNING status, rewarded_at, updated_at
+	`, pgx.NamedArgs{
+		"id":                  f.ID,
+		"ride_id":             f.RideID,
+		"reward_type":         f.RewardType,
+		"referrer_reward":     f.ReferrerReward,
+		"referee_reward":      f.RefereeReward,
+		"referrer_promo_code": f.ReferrerPromoCode,
+		"referee_promo_code":  f.RefereePromoCode,
+	}).Scan(&f.Status, &f.RewardedAt, &f.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Reject marks a pending referral rejected with its reason. It reports false when the referral is
+// no longer pending.
+func (r *referralRepository) Reject(ctx context.Context, f *model.Referral) (bool, error) {
+	err := r.db.QueryRow(ctx, `
+		UPDATE referrals
+		SET status = 'rejected', reject_reason = $2, ride_id = $3
+		WHERE id = $1 AND status = 'pending'
+		RETURNING status, updated_at
+	`, f.ID, f.RejectReason, f.RideID).Scan(&f.Status, &f.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
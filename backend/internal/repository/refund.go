@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+// ErrRefundExceedsPayment is returned by Create when the refund is larger than what is left of the
+// payment after its pending and processed refunds
+var ErrRefundExceedsPayment = errors.New("refund exceeds the refundable amount of the payment")
+
+type RefundRepository interface {
+	Create(ctx context.Context, refund *model.Refund) error
+	GetByID(ctx context.Context, id string) (*model.Refund, error)
+	GetByGatewayRefundID(ctx context.Context, gatewayRefundID string) (*model.Refund, error)
+	ListByPayment(ctx context.Context, paymentID string) ([]*model.Refund, error)
+	ListPending(ctx context.Context, createdBefore time.Time, limit int) ([]*model.Refund, error)
+	SetGatewayRefundID(ctx context.Context, id, gatewayRefundID string) error
+	TransitionStatus(ctx context.Context, refund *model.Refund, from model.RefundStatus) (bool, error)
+	ProcessedTotal(ctx context.Context, paymentID string) (float64, error)
+}
+
+type refundRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRefundRepository(db *pgxpool.Pool) RefundRepository {
+	return &refundRepository{db: db}
+}
+
+const refundColumns = `
+	id, payment_id, user_id, amount, currency, destination, source, reason, initiated_by,
+	status, gateway_refund_id, failure_reason, created_at, updated_at, processed_at`
+
+func scanRefund(row pgx.Row) (*model.Refund, error) {
+	var refund model.Refund
+	err := row.Scan(
+		&refund.ID,
+		&refund.PaymentID,
+		&refund.UserID,
+		&refund.Amount,
+		&refund.Currency,
+		&refund.Destination,
+		&refund.Source,
+		&refund.Reason,
+		&refund.InitiatedBy,
+		&refund.Status,
+		&refund.GatewayRefundID,
+		&refund.FailureReason,
+		&refund.CreatedAt,
+		&refund.UpdatedAt,
+		&refund.ProcessedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// Create stores a refund after checking it fits in the payment. The payment row is locked so
+// concurrent refunds cannot together exceed it. A refund without an amount takes everything left.
+func (r *refundRepository) Create(ctx context.Context, refund *model.Refund) error {
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var remaining float64
+	err = tx.QueryRow(ctx, `
+		SELECT p.amount - COALESCE((
+			SELECT SUM(amount) FROM refunds
+			WHERE payment_id = p.id AND status IN ('pending', 'processed')
+		), 0)
+		FROM payments p
+		WHERE p.id = $1
+		FOR UPDATE
+	`, refund.PaymentID).Scan(&remaining)
+	if err != nil {
+		return err
+	}
+
+	if refund.Amount == 0 {
+		refund.Amount = remaining
+	}
+	if refund.Amount <= 0 || refund.Amount > remaining {
+		return ErrRefundExceedsPayment
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO refunds (
+			payment_id, user_id, amount, currency, destination, source, reason,
+			initiated_by, status, gateway_refund_id
+		) VALUES (
+			@payment_id, @user_id, @amount, @currency, @destination, @source, @reason,
+			@initiated_by, @status, @gateway_refund_id
+		) RETURNING id, created_at, updated_at
+	`, pgx.NamedArgs{
+		"payment_id":        refund.PaymentID,
+		"user_id":           refund.UserID,
+		"amount":            refund.Amount,
+		"currency":          refund.Currency,
+		"destination":       refund.Destination,
+		"source":            refund.Source,
+		"reason":            refund.Reason,
+		"initiated_by":      refund.InitiatedBy,
+		"status":            refund.Status,
+		"gateway_refund_id": refund.GatewayRefundID,
+	}).Scan(&refund.ID, &refund.CreatedAt, &refund.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *refundRepository) GetByID(ctx context.Context, id string) (*model.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE id = $1`
+	return scanRefund(r.db.QueryRow(ctx, query, id))
+}
+
+// GetByGatewayRefundID finds the refund the gateway knows under an id, nil when there is none
+func (r *refundRepository) GetByGatewayRefundID(ctx context.Context, gatewayRefundID string) (*model.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE gateway_refund_id = $1`
+	refund, err := scanRefund(r.db.QueryRow(ctx, query, gatewayRefundID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return refund, err
+}
+
+func (r *refundRepository) ListByPayment(ctx context.Context, paymentID string) ([]*model.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE payment_id = $1 ORDER BY created_at DESC`
+	return r.list(ctx, query, paymentID)
+}
+
+// ListPending returns the oldest refunds still waiting for the gateway
+func (r *refundRepository) ListPending(ctx context.Context, createdBefore time.Time, limit int) ([]*model.Refund, error) {
+	query := `
+		SELECT ` + refundColumns + `
+		FROM refunds
+		WHERE status = 'pending' AND created_at < $1
+		ORDER BY created_at
+		LIMIT $2
+	`
+	return r.list(ctx, query, createdBefore, limit)
+}
+
+func (r *refundRepository) list(ctx context.Context, query string, args ...any) ([]*model.Refund, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []*model.Refund
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+	return refunds, rows.Err()
+}
+
+// SetGatewayRefundID links a refund to the gateway refund created for it
+func (r *refundRepository) SetGatewayRefundID(ctx context.Context, id, gatewayRefundID string) error {
+	query := `
+		UPDATE refunds
+		SET gateway_refund_id = $1
+		WHERE id = $2
+	`
+
+	_, err := r.db.Exec(ctx, query, gatewayRefundID, id)
+	return err
+}
+
+// TransitionStatus writes the refund's status only if the stored status is still from and reports
+// whether the row was updated
+func (r *refundRepository) TransitionStatus(ctx context.Context, refund *model.Refund, from model.RefundStatus) (bool, error) {
+	query := `
+		UPDATE refunds
+		SET status = @status,
+			gateway_refund_id = COALESCE(@gateway_refund_id, gateway_refund_id),
+			failure_reason = @failure_reason,
+			processed_at = CASE WHEN @status = 'processed' THEN NOW() ELSE processed_at END
+		WHERE id = @id AND status = @from
+		RETURNING updated_at, processed_at
+	`
+
+	err := r.db.QueryRow(ctx, query, pgx.NamedArgs{
+		"id":                refund.ID,
+		"status":            refund.Status,
+		"gateway_refund_id": refund.GatewayRefundID,
+		"failure_reason":    refund.FailureReason,
+		"from":              from,
+	}).Scan(&refund.UpdatedAt, &refund.ProcessedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ProcessedTotal sums the processed refunds of a payment
+func (r *refundRepository) ProcessedTotal(ctx context.Context, paymentID string) (float64, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM refunds
+		WHERE payment_id = $1 AND status = 'processed'
+	`
+
+	var total float64
+	err := r.db.QueryRow(ctx, query, paymentID).Scan(&total)
+	return total, err
+}
@@ -3,17 +3,45 @@ package repository
 import "github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
 
 type Repositories struct {
-	User    *UserRepository
-	Driver  *DriverRepository
-	Ride    *RideRepository
-	Payment PaymentRepository
+	User           *UserRepository
+	Driver         DriverrRepository
+	Ride           RiddeRepository
+	Payment        PaymentRepository
+	PaymentEvent   PaymentEventRepository
+	Discrepancy    PaymentDiscrepancyRepository
+	Refund         RefundRepository
+	Wallet         WalletRepository
+	Ledger         LedgerRepository
+	Earnings       EarningsRepository
+	Payout         PayoutRepository
+	Promotion      PromotionRepository
+	Referral       ReferralRepository
+	Chat           RideMessageRepository
+	Device         DeviceTokenRepository
+	DriverLocation DriverLocationRepository
+	OTP            OTPRepository
+	LocationFlag   LocationFlagRepository
 }
 
 func NewRepositories(s *server.Server) *Repositories {
 	return &Repositories{
-		User:    NewUserRepository(s),
-		Driver:  NewDriverRepository(s),
-		Ride:    NewRideRepository(s),
-		Payment: NewPaymentRepository(s.DB.Pool),
+		User:           NewUserRepository(s),
+		Driver:         NewDriverRepository(s),
+		Ride:           NewRideRepository(s),
+		Payment:        NewPaymentRepository(s.DB.Pool),
+		PaymentEvent:   NewPaymentEventRepository(s.DB.Pool),
+		Discrepancy:    NewPaymentDiscrepancyRepository(s.DB.Pool),
+		Refund:         NewRefundRepository(s.DB.Pool),
+		Wallet:         NewWalletRepository(s.DB.Pool),
+		Ledger:         NewLedgerRepository(s.DB.Pool),
+		Earnings:       NewEarningsRepository(s.DB.Pool),
+		Payout:         NewPayoutRepository(s.DB.Pool),
+		Promotion:      NewPromotionRepository(s.DB.Pool),
+		Referral:       NewReferralRepository(s.DB.Pool),
+		Chat:           NewRideMessageRepository(s.DB.Pool),
+		Device:         NewDeviceTokenRepository(s.DB.Pool),
+		DriverLocation: NewDriverLocationRepository(s.DB.Pool),
+		OTP:            NewOTPRepository(s.DB.Pool),
+		LocationFlag:   NewLocationFlagRepository(s.DB.Pool),
 	}
 }
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/redis/go-redis/v9"
@@ -56,11 +57,40 @@ func NewRideRepository(s *server.Server) *RideRepository {
 
 // New Logic
 func (r *RideRepository) Create(ctx context.Context, ride *model.Ride) error {
+	return insertRide(ctx, r.server.DB.Pool, ride)
+}
+
+// CreateWithRedemption creates a ride with the promo redeemed for it in one transaction. A promo
+// that reached its usage limits in the meantime is reported as ErrPromoUsageLimit or
+// ErrPromoUserLimit and no ride is created.
+func (r *RideRepository) CreateWithRedemption(ctx context.Context, ride *model.Ride, redemption *model.PromoRedemption) error {
+	tx, err := r.server.DB.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertRide(ctx, tx, ride); err != nil {
+		return err
+	}
+	redemption.RideID = ride.ID
+	redemption.UserID = ride.UserID
+	if err := redeemPromotion(ctx, tx, redemption); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func insertRide(ctx context.Context, q rowQuerier, ride *model.Ride) error {
 	query := `
 	 INSERT INTO rides(
 	 id,user_id,pickup_location,pickup_address,
-	 dropoff_location,dropoff_address,status,fare,distance_km,
-	 duration_minutes,payment_status
+	 dropoff_location,dropoff_address,status,vehicle_type,payment_method,
+	 fare,distance_km,duration_minutes,promo_discount,payment_status
 	 ) VALUES(
 	  gen_random_uuid(), @user_id,
 	  ST_SetSRID(ST_MakePoint(@pickup_lng,@pickup_lat),4326),
@@ -68,15 +98,18 @@ func (r *RideRepository) Create(ctx context.Context, ride *model.Ride) error {
 	  ST_SetSRID(ST_MakePoint(@dropoff_lng,@dropoff_lat),4326),
 	  @dropoff_address,
 	  @status,
+	  COALESCE(@vehicle_type, 'sedan'),
+	  COALESCE(@payment_method, 'cash'),
 	  @fare,
 	  @distance_km,
 	  @duration_minute,
+	  @promo_discount,
 	  @payment_status
 
 	  ) RETURNING id,requested_at,created_at,updated_at
 	   `
 
-	return r.server.DB.Pool.QueryRow(ctx, query, pgx.NamedArgs{
+	return q.QueryRow(ctx, query, pgx.NamedArgs{
 		"user_id":         ride.UserID,
 		"pickup_lng":      ride.PickupLocation.Longitude,
 		"pickup_lat":      ride.PickupLocation.Latitude,
@@ -85,9 +118,12 @@ func (r *RideRepository) Create(ctx context.Context, ride *model.Ride) error {
 		"dropoff_lat":     ride.DropoffLocation.Latitude,
 		"dropoff_address": ride.DropoffAddress,
 		"status":          ride.Status,
+		"vehicle_type":    ride.VehicleType,
+		"payment_method":  ride.PaymentMethod,
 		"fare":            ride.Fare,
 		"distance_km":     ride.DistanceKm,
 		"duration_minute": ride.DurationMinutes,
+		"promo_discount":  ride.PromoDiscount,
 		"payment_status":  ride.PaymentStatus,
 	}).Scan(&ride.ID, &ride.RequestedAt, &ride.CreatedAt, &ride.UpdatedAt)
 
@@ -106,7 +142,7 @@ func (r *RideRepository) GetByID(ctx context.Context, rideID string) (*model.Rid
 	dropoff_address,
 	status,vehicle_type,payment_method,otp,fare,distance_km,duration_minutes,
 	requested_at,accepted_at,started_at,completed_at,
-	payment_status,payment_id,rating,feedback,
+	payment_status,payment_id,rating,feedback,promo_discount,
 	created_at,updated_at
 	FROM rides
 	WHERE id = @ride_id`
@@ -121,7 +157,7 @@ func (r *RideRepository) GetByID(ctx context.Context, rideID string) (*model.Rid
 		&dropoffLat, &dropoffLng, &ride.DropoffAddress,
 		&ride.Status, &ride.VehicleType, &ride.PaymentMethod, &ride.OTP, &ride.Fare, &ride.DistanceKm, &ride.DurationMinutes,
 		&ride.RequestedAt, &ride.AcceptedAt, &ride.StartedAt, &ride.CompletedAt,
-		&ride.PaymentStatus, &ride.PaymentID, &ride.Rating, &ride.Feedback,
+		&ride.PaymentStatus, &ride.PaymentID, &ride.Rating, &ride.Feedback, &ride.PromoDiscount,
 		&ride.CreatedAt, &ride.UpdatedAt,
 	)
 
@@ -212,7 +248,7 @@ func (r *RideRepository) GetActiveRideForUser(ctx context.Context, userID string
 			dropoff_address,
 			status, vehicle_type, payment_method, otp, fare, distance_km, duration_minutes,
 			requested_at, accepted_at, started_at, completed_at,
-			payment_status, payment_id, rating, feedback,
+			payment_status, payment_id, rating, feedback, promo_discount,
 			created_at, updated_at
 		FROM rides
 		WHERE user_id = $1 
@@ -236,7 +272,7 @@ func (r *RideRepository) GetActiveRideForUser(ctx context.Context, userID string
 		&dropoffLat, &dropoffLng, &ride.DropoffAddress,
 		&ride.Status, &ride.VehicleType, &ride.PaymentMethod, &ride.OTP, &ride.Fare, &ride.DistanceKm, &ride.DurationMinutes,
 		&ride.RequestedAt, &ride.AcceptedAt, &ride.StartedAt, &ride.CompletedAt,
-		&ride.PaymentStatus, &ride.PaymentID, &ride.Rating, &ride.Feedback,
+		&ride.PaymentStatus, &ride.PaymentID, &ride.Rating, &ride.Feedback, &ride.PromoDiscount,
 		&ride.CreatedAt, &ride.UpdatedAt,
 	)
 
@@ -263,7 +299,7 @@ func (r *RideRepository) GetActiveRideForDriver(ctx context.Context, driverID st
 			dropoff_address,
 			status, vehicle_type, payment_method, otp, fare, distance_km, duration_minutes,
 			requested_at, accepted_at, started_at, completed_at,
-			payment_status, payment_id, rating, feedback,
+			payment_status, payment_id, rating, feedback, promo_discount,
 			created_at, updated_at
 		FROM rides
 		WHERE driver_id = $1 
@@ -286,7 +322,7 @@ func (r *RideRepository) GetActiveRideForDriver(ctx context.Context, driverID st
 		&dropoffLat, &dropoffLng, &ride.DropoffAddress,
 		&ride.Status, &ride.VehicleType, &ride.PaymentMethod, &ride.OTP, &ride.Fare, &ride.DistanceKm, &ride.DurationMinutes,
 		&ride.RequestedAt, &ride.AcceptedAt, &ride.StartedAt, &ride.CompletedAt,
-		&ride.PaymentStatus, &ride.PaymentID, &ride.Rating, &ride.Feedback,
+		&ride.PaymentStatus, &ride.PaymentID, &ride.Rating, &ride.Feedback, &ride.PromoDiscount,
 		&ride.CreatedAt, &ride.UpdatedAt,
 	)
 
@@ -302,76 +338,78 @@ func (r *RideRepository) GetActiveRideForDriver(ctx context.Context, driverID st
 
 // FindNearbyRides finds requested rides near a location
 func (r *RideRepository) FindNearbyRides(ctx context.Context, lat, lng, radiusKm float64) ([]model.Ride, error) {
-	// 1. Try Redis GEOSEARCH first
-	// We want to find rides within radiusKm
-	redisCmd := r.server.Redis.GeoSearch(ctx, "rides:requested", &redis.GeoSearchQuery{
-		Longitude:  lng,
-		Latitude:   lat,
-		Radius:     radiusKm,
-		RadiusUnit: "km",
-		Sort:       "ASC", // Closest first
-		Count:      20,    // Limit to 20
-	})
-
-	locations, err := redisCmd.Result()
-	if err == nil && len(locations) > 0 {
-		// Found in Redis, fetch details from DB by ID
-		var rideIDs []string
-		for _, loc := range locations {
-			rideIDs = append(rideIDs, loc)
-		}
+	// 1. Try Redis GEOSEARCH first, skipped entirely in degraded mode
+	if r.server.RedisAvailable() {
+		// We want to find rides within radiusKm
+		redisCmd := r.server.Redis.GeoSearch(ctx, "rides:requested", &redis.GeoSearchQuery{
+			Longitude:  lng,
+			Latitude:   lat,
+			Radius:     radiusKm,
+			RadiusUnit: "km",
+			Sort:       "ASC", // Closest first
+			Count:      20,    // Limit to 20
+		})
+
+		locations, err := redisCmd.Result()
+		if err == nil && len(locations) > 0 {
+			// Found in Redis, fetch details from DB by ID
+			var rideIDs []string
+			for _, loc := range locations {
+				rideIDs = append(rideIDs, loc)
+			}
 
-		// Fetch rides by IDs
-		// We use ANY to get valid UUIDs only
-		query := `
-			SELECT 
-				id, user_id, driver_id,
-				ST_Y(pickup_location::geometry) as pickup_lat,
-				ST_X(pickup_location::geometry) as pickup_lng,
-				pickup_address,
-				ST_Y(dropoff_location::geometry) as dropoff_lat,
-				ST_X(dropoff_location::geometry) as dropoff_lng,
-				dropoff_address,
-				status, vehicle_type, payment_method, otp, fare, distance_km, duration_minutes,
-				requested_at, accepted_at, started_at, completed_at,
-				payment_status, payment_id, rating, feedback,
-				created_at, updated_at
-			FROM rides
-			WHERE id = ANY($1) AND status = $2
-		`
-
-		rows, err := r.server.DB.Pool.Query(ctx, query, rideIDs, model.RideStatusRequested)
-		if err != nil {
-			r.server.Logger.Error().Err(err).Msg("Failed to fetch rides by IDs from Redis result, falling back to PostGIS")
-			goto Fallback
-		}
-		defer rows.Close()
-
-		var rides []model.Ride
-		for rows.Next() {
-			var ride model.Ride
-			var pickupLat, pickupLng, dropoffLat, dropoffLng float64
-
-			err := rows.Scan(
-				&ride.ID, &ride.UserID, &ride.DriverID,
-				&pickupLat, &pickupLng, &ride.PickupAddress,
-				&dropoffLat, &dropoffLng, &ride.DropoffAddress,
-				&ride.Status, &ride.VehicleType, &ride.PaymentMethod, &ride.OTP, &ride.Fare, &ride.DistanceKm, &ride.DurationMinutes,
-				&ride.RequestedAt, &ride.AcceptedAt, &ride.StartedAt, &ride.CompletedAt,
-				&ride.PaymentStatus, &ride.PaymentID, &ride.Rating, &ride.Feedback,
-				&ride.CreatedAt, &ride.UpdatedAt,
-			)
+			// Fetch rides by IDs
+			// We use ANY to get valid UUIDs only
+			query := `
+				SELECT 
+					id, user_id, driver_id,
+					ST_Y(pickup_location::geometry) as pickup_lat,
+					ST_X(pickup_location::geometry) as pickup_lng,
+					pickup_address,
+					ST_Y(dropoff_location::geometry) as dropoff_lat,
+					ST_X(dropoff_location::geometry) as dropoff_lng,
+					dropoff_address,
+					status, vehicle_type, payment_method, otp, fare, distance_km, duration_minutes,
+					requested_at, accepted_at, started_at, completed_at,
+					payment_status, payment_id, rating, feedback, promo_discount,
+					created_at, updated_at
+				FROM rides
+				WHERE id = ANY($1) AND status = $2
+			`
+
+			rows, err := r.server.DB.Pool.Query(ctx, query, rideIDs, model.RideStatusRequested)
 			if err != nil {
-				continue // Skip invalid rows
+				r.server.Logger.Error().Err(err).Msg("Failed to fetch rides by IDs from Redis result, falling back to PostGIS")
+				goto Fallback
+			}
+			defer rows.Close()
+
+			var rides []model.Ride
+			for rows.Next() {
+				var ride model.Ride
+				var pickupLat, pickupLng, dropoffLat, dropoffLng float64
+
+				err := rows.Scan(
+					&ride.ID, &ride.UserID, &ride.DriverID,
+					&pickupLat, &pickupLng, &ride.PickupAddress,
+					&dropoffLat, &dropoffLng, &ride.DropoffAddress,
+					&ride.Status, &ride.VehicleType, &ride.PaymentMethod, &ride.OTP, &ride.Fare, &ride.DistanceKm, &ride.DurationMinutes,
+					&ride.RequestedAt, &ride.AcceptedAt, &ride.StartedAt, &ride.CompletedAt,
+					&ride.PaymentStatus, &ride.PaymentID, &ride.Rating, &ride.Feedback, &ride.PromoDiscount,
+					&ride.CreatedAt, &ride.UpdatedAt,
+				)
+				if err != nil {
+					continue // Skip invalid rows
+				}
+				ride.PickupLocation = model.Location{Latitude: pickupLat, Longitude: pickupLng}
+				ride.DropoffLocation = model.Location{Latitude: dropoffLat, Longitude: dropoffLng}
+				rides = append(rides, ride)
+			}
+			// Return if we found rides (even if fewer than Redis reported due to filters)
+			// Only return if not empty to prefer accuracy
+			if len(rides) > 0 {
+				return rides, nil
 			}
-			ride.PickupLocation = model.Location{Latitude: pickupLat, Longitude: pickupLng}
-			ride.DropoffLocation = model.Location{Latitude: dropoffLat, Longitude: dropoffLng}
-			rides = append(rides, ride)
-		}
-		// Return if we found rides (even if fewer than Redis reported due to filters)
-		// Only return if not empty to prefer accuracy
-		if len(rides) > 0 {
-			return rides, nil
 		}
 	}
 
@@ -388,7 +426,7 @@ Fallback:
 			dropoff_address,
 			status, vehicle_type, payment_method, otp, fare, distance_km, duration_minutes,
 			requested_at, accepted_at, started_at, completed_at,
-			payment_status, payment_id, rating, feedback,
+			payment_status, payment_id, rating, feedback, promo_discount,
 			created_at, updated_at
 		FROM rides
 		WHERE status = $1
@@ -422,7 +460,7 @@ Fallback:
 			&dropoffLat, &dropoffLng, &ride.DropoffAddress,
 			&ride.Status, &ride.VehicleType, &ride.PaymentMethod, &ride.OTP, &ride.Fare, &ride.DistanceKm, &ride.DurationMinutes,
 			&ride.RequestedAt, &ride.AcceptedAt, &ride.StartedAt, &ride.CompletedAt,
-			&ride.PaymentStatus, &ride.PaymentID, &ride.Rating, &ride.Feedback,
+			&ride.PaymentStatus, &ride.PaymentID, &ride.Rating, &ride.Feedback, &ride.PromoDiscount,
 			&ride.CreatedAt, &ride.UpdatedAt,
 		)
 		if err != nil {
@@ -440,3 +478,90 @@ Fallback:
 
 	return rides, nil
 }
+
+// ListRequestedPickups returns the pickup location of every ride still waiting for a driver, keyed by ride id
+func (r *RideRepository) ListRequestedPickups(ctx context.Context) (map[string]model.Location, error) {
+	query := `
+		SELECT id, ST_Y(pickup_location::geometry), ST_X(pickup_location::geometry)
+		FROM rides
+		WHERE status = $1
+	`
+
+	rows, err := r.server.DB.Pool.Query(ctx, query, model.RideStatusRequested)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query requested rides: %w", err)
+	}
+	defer rows.Close()
+
+	pickups := make(map[string]model.Location)
+	for rows.Next() {
+		var id string
+		var pickup model.Location
+		if err := rows.Scan(&id, &pickup.Latitude, &pickup.Longitude); err != nil {
+			return nil, fmt.Errorf("failed to scan requested ride: %w", err)
+		}
+		pickups[id] = pickup
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating requested rides: %w", err)
+	}
+
+	return pickups, nil
+}
+
+// CountRequestsByCell counts the rides requested since the given time per square grid cell of
+// cellDeg degrees, keyed by the cell holding the pickup. Rides still waiting for a driver are left
+// out, the heatmap counts them as open requests.
+func (r *RideRepository) CountRequestsByCell(ctx context.Context, since time.Time, cellDeg float64) (map[model.GridCell]int, error) {
+	query := `
+		SELECT
+			floor(ST_Y(pickup_location::geometry) / $2)::int AS row,
+			floor(ST_X(pickup_location::geometry) / $2)::int AS col,
+			count(*)
+		FROM rides
+		WHERE requested_at >= $1 AND status <> $3
+		GROUP BY row, col
+	`
+
+	rows, err := r.server.DB.Pool.Query(ctx, query, since, cellDeg, model.RideStatusRequested)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count requested rides by cell: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[model.GridCell]int)
+	for rows.Next() {
+		var cell model.GridCell
+		var count int
+		if err := rows.Scan(&cell.Row, &cell.Col, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan ride count: %w", err)
+		}
+		counts[cell] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ride counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// GetCharges returns the fare of a ride with its tip, wallet and promo adjustments
+func (r *RideRepository) GetCharges(ctx context.Context, rideID string) (*model.RideCharges, error) {
+	query := `
+		SELECT COALESCE(fare, 0), tip_amount, wallet_applied, promo_discount
+		FROM rides
+		WHERE id = $1
+	`
+
+	var charges model.RideCharges
+	err := r.server.DB.Pool.QueryRow(ctx, query, rideID).Scan(
+		&charges.Fare, &charges.Tip, &charges.WalletApplied, &charges.PromoDiscount,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ride charges: %w", err)
+	}
+
+	return &charges, nil
+}
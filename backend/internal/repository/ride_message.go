@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+type RideMessageRepository interface {
+	Create(ctx context.Context, message *model.RideMessage) error
+	ListByRide(ctx context.Context, rideID string, before *time.Time, limit int) ([]*model.RideMessage, error)
+	MarkReceipt(ctx context.Context, rideID, recipientID string, messageIDs []string, status model.ReceiptStatus, at time.Time) ([]string, error)
+}
+
+type rideMessageRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRideMessageRepository(db *pgxpool.Pool) RideMessageRepository {
+	return &rideMessageRepository{db: db}
+}
+
+func (r *rideMessageRepository) Create(ctx context.Context, message *model.RideMessage) error {
+	query := `
+		INSERT INTO ride_messages (
+			ride_id, sender_id, recipient_id, body, quick_reply_id
+		) VALUES (
+			$1, $2, $3, $4, $5
+		) RETURNING id, created_at
+	`
+
+	return r.db.QueryRow(ctx, query,
+		message.RideID,
+		message.SenderID,
+		message.RecipientID,
+		message.Body,
+		message.QuickReplyID,
+	).Scan(&message.ID, &message.CreatedAt)
+}
+
+// ListByRide returns up to limit messages of a ride created before the given time, newest first
+func (r *rideMessageRepository) ListByRide(ctx context.Context, rideID string, before *time.Time, limit int) ([]*model.RideMessage, error) {
+	query := `
+		SELECT id, ride_id, sender_id, recipient_id, body, quick_reply_id,
+			delivered_at, read_at, created_at
+		FROM ride_messages
+		WHERE ride_id = @ride_id
+			AND (@before::timestamptz IS NULL OR created_at < @before::timestamptz)
+		ORDER BY created_at DESC
+		LIMIT @limit
+	`
+
+	rows, err := r.db.Query(ctx, query, pgx.NamedArgs{
+		"ride_id": rideID,
+		"before":  before,
+		"limit":   limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]*model.RideMessage, 0, limit)
+	for rows.Next() {
+		var message model.RideMessage
+		if err := rows.Scan(
+			&message.ID,
+			&message.RideID,
+			&message.SenderID,
+			&message.RecipientID,
+			&message.Body,
+			&message.QuickReplyID,
+			&message.DeliveredAt,
+			&message.ReadAt,
+			&message.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		messages = append(messages, &message)
+	}
+
+	return messages, rows.Err()
+}
+
+// MarkReceipt records delivery or read of messages addressed to the recipient and returns the IDs
+// whose state changed. Reading a message also marks it delivered.
+func (r *rideMessageRepository) MarkReceipt(ctx context.Context, rideID, recipientID string, messageIDs []string, status model.ReceiptStatus, at time.Time) ([]string, error) {
+	query := `
+		UPDATE ride_messages
+		SET delivered_at = COALESCE(delivered_at, @at)
+		WHERE ride_id = @ride_id
+			AND recipient_id = @recipient_id
+			AND id = ANY(@ids::uuid[])
+			AND delivered_at IS NULL
+		RETURNING id
+	`
+	if status == model.ReceiptStatusRead {
+		query = `
+			UPDATE ride_messages
+			SET delivered_at = COALESCE(delivered_at, @at), read_at = @at
+			WHERE ride_id = @ride_id
+				AND recipient_id = @recipient_id
+				AND id = ANY(@ids::uuid[])
+				AND read_at IS NULL
+			RETURNING id
+		`
+	}
+
+	rows, err := r.db.Query(ctx, query, pgx.NamedArgs{
+		"ride_id":      rideID,
+		"recipient_id": recipientID,
+		"ids":          messageIDs,
+		"at":           at,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
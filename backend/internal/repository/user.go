@@ -2,10 +2,12 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
 )
@@ -18,11 +20,13 @@ func NewUserRepository(s *server.Server) *UserRepository {
 	return &UserRepository{server: s}
 }
 
+// Create inserts a user with a referral code drawn by generate_referral_code. A drawn code another
+// user has is reported as ErrReferralCodeTaken.
 func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 	query := `
-		INSERT INTO users (id, name, email, password_hash, phone, role, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, created_at, updated_at
+		INSERT INTO users (id, name, email, password_hash, phone, role, referral_code, signup_device_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, generate_referral_code(), $7, $8, $9)
+		RETURNING id, referral_code, created_at, updated_at
 	`
 
 	err := r.server.DB.Pool.QueryRow(
@@ -34,11 +38,16 @@ func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 		user.PasswordHash,
 		user.Phone,
 		user.Role,
+		user.SignupDeviceID,
 		user.CreatedAt,
 		user.UpdatedAt,
-	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.ReferralCode, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "unique_users_referral_code" {
+			return ErrReferralCodeTaken
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -47,7 +56,7 @@ func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
 	query := `
-		SELECT id, name, email, password_hash, phone, role, created_at, updated_at
+		SELECT id, name, email, password_hash, phone, role, referral_code, signup_device_id, created_at, updated_at
 		FROM users
 		WHERE email = $1
 	`
@@ -60,6 +69,8 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.U
 		&user.PasswordHash,
 		&user.Phone,
 		&user.Role,
+		&user.ReferralCode,
+		&user.SignupDeviceID,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -76,7 +87,7 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.U
 
 func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
 	query := `
-		SELECT id, name, email, password_hash, phone, role, created_at, updated_at
+		SELECT id, name, email, password_hash, phone, role, referral_code, signup_device_id, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
@@ -89,6 +100,8 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.User
 		&user.PasswordHash,
 		&user.Phone,
 		&user.Role,
+		&user.ReferralCode,
+		&user.SignupDeviceID,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -114,3 +127,12 @@ func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, e
 
 	return exists, nil
 }
+
+// ListIDsByRole returns the ids of all users with the given role
+func (r *UserRepository) ListIDsByRole(ctx context.Context, role model.UserRole) ([]string, error) {
+	rows, err := r.server.DB.Pool.Query(ctx, `SELECT id::text FROM users WHERE role = $1`, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users by role: %w", err)
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+// WalletRepository stores wallet top-ups, balances are kept in the ledger
+type WalletRepository interface {
+	CreateTopup(ctx context.Context, topup *model.WalletTopup) error
+	GetTopup(ctx context.Context, id string) (*model.WalletTopup, error)
+	GetTopupByGatewayOrderID(ctx context.Context, orderID string) (*model.WalletTopup, error)
+	SetTopupOrder(ctx context.Context, id, provider, orderID string) error
+	TransitionTopup(ctx context.Context, topup *model.WalletTopup, from model.WalletTopupStatus) (bool, error)
+	ListUnsettledTopups(ctx context.Context, createdBefore, failedAfter time.Time, limit int) ([]*model.WalletTopup, error)
+	MarkTopupReconciled(ctx context.Context, id string) error
+}
+
+type walletRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewWalletRepository(db *pgxpool.Pool) WalletRepository {
+	return &walletRepository{db: db}
+}
+
+const topupColumns = `
+	id, user_id, amount, currency, status, provider, gateway_order_id, gateway_payment_id, created_at, updated_at`
+
+func scanTopup(row pgx.Row) (*model.WalletTopup, error) {
+	var topup model.WalletTopup
+	err := row.Scan(
+		&topup.ID,
+		&topup.UserID,
+		&topup.Amount,
+		&topup.Currency,
+		&topup.Status,
+		&topup.Provider,
+		&topup.GatewayOrderID,
+		&topup.GatewayPaymentID,
+		&topup.CreatedAt,
+		&topup.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &topup, nil
+}
+
+func (r *walletRepository) CreateTopup(ctx context.Context, topup *model.WalletTopup) error {
+	query := `
+		INSERT INTO wallet_topups (user_id, amount, currency, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`
+
+	return r.db.QueryRow(ctx, query, topup.UserID, topup.Amount, topup.Currency, topup.Status).
+		Scan(&topup.ID, &topup.CreatedAt, &topup.UpdatedAt)
+}
+
+func (r *walletRepository) GetTopup(ctx context.Context, id string) (*model.WalletTopup, error) {
+	query := `SELECT ` + topupColumns + ` FROM wallet_topups WHERE id = $1`
+	return scanTopup(r.db.QueryRow(ctx, query, id))
+}
+
+// GetTopupByGatewayOrderID finds the top-up paid through a gateway order, nil when there is none
+func (r *walletRepository) GetTopupByGatewayOrderID(ctx context.Context, orderID string) (*model.WalletTopup, error) {
+	query := `SELECT ` + topupColumns + ` FROM wallet_topups WHERE gateway_order_id = $1`
+	topup, err := scanTopup(r.db.QueryRow(ctx, query, orderID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return topup, err
+}
+
+// SetTopupOrder links a top-up to the order created for it at a gateway
+func (r *walletRepository) SetTopupOrder(ctx context.Context, id, provider, orderID string) error {
+	query := `
+		UPDATE wallet_topups
+		SET provider = $1, gateway_order_id = $2
+		WHERE id = $3
+	`
+
+	_, err := r.db.Exec(ctx, query, provider, orderID, id)
+	return err
+}
+
+// TransitionTopup writes the top-up's status and gateway payment only if the stored status is still
+// from and reports whether the row was updated
+func (r *walletRepository) TransitionTopup(ctx context.Context, topup *model.WalletTopup, from model.WalletTopupStatus) (bool, error) {
+	query := `
+		UPDATE wallet_topups
+		SET status = $1, gateway_payment_id = COALESCE($2, gateway_payment_id)
+		WHERE id = $3 AND status = $4
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRow(ctx, query, topup.Status, topup.GatewayPaymentID, topup.ID, from).Scan(&topup.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ListUnsettledTopups returns the top-ups still created with a gateway order created before
+// createdBefore, and the failed ones whose order was also created after failedAfter, the ones
+// reconciled least recently first
+func (r *walletRepository) ListUnsettledTopups(ctx context.Context, createdBefore, failedAfter time.Time, limit int) ([]*model.WalletTopup, error) {
+	query := `SELECT ` + topupColumns + `
+		FROM wallet_topups
+		WHERE status IN ('created', 'failed')
+			AND (status <> 'failed' OR created_at > $2)
+			AND gateway_order_id IS NOT NULL
+			AND created_at < $1
+		ORDER BY reconciled_at NULLS FIRST, created_at
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, createdBefore, failedAfter, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*model.WalletTopup, error) {
+		return scanTopup(row)
+	})
+}
+
+// MarkTopupReconciled records that a top-up was compared with the gateway
+func (r *walletRepository) MarkTopupReconciled(ctx context.Context, id string) error {
+	_, err := r.db.Exec(ctx, `UPDATE wallet_topups SET reconciled_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+	return err
+}
@@ -61,9 +61,13 @@ func NewRouter(s *server.Server, h *handler.Handlers, services *service.Services
 		auth.POST("/otp/send", h.Auth.SendOTP)
 		auth.POST("/otp/verify", h.Auth.VerifyOTP)
 		auth.GET("/me", h.Auth.Me, middlewares.Auth.RequireAuth)
-		auth.GET("/ws", realtime.Handler(s.Hub), middlewares.Auth.RequireAuth)
+		auth.POST("/ws/ticket", h.Auth.WSTicket, middlewares.Auth.RequireAuth)
+		auth.GET("/ws", realtime.Handler(s.Hub), middlewares.Auth.RequireWSTicket)
 	}
 
+	// Server-sent events fallback for networks that block websockets
+	v1.GET("/events", realtime.SSEHandler(s.Hub), middlewares.Auth.RequireAuth)
+
 	riders := v1.Group("/riders", middlewares.Auth.RequireAuth, middlewares.Auth.RequireRole(model.RoleRider, model.RoleAdmin))
 	{
 
@@ -77,6 +81,9 @@ func NewRouter(s *server.Server, h *handler.Handlers, services *service.Services
 		drivers.PUT("/profile", h.Driver.UpdateProfile)
 		drivers.GET("/profile", h.Driver.GetProfile)
 		drivers.GET("/rides/nearby", h.Ride.GetNearbyRides)
+		drivers.GET("/heatmap", h.Heatmap.GetHeatmap)
+		drivers.GET("/earnings/daily", h.Earnings.GetDailyEarnings)
+		drivers.GET("/earnings/weekly", h.Earnings.GetWeeklyEarnings)
 		drivers.POST("/logout", h.Auth.SignOut)
 	}
 
@@ -84,12 +91,26 @@ func NewRouter(s *server.Server, h *handler.Handlers, services *service.Services
 	{
 
 		admin.POST("/logout", h.Auth.SignOut)
+		admin.GET("/drivers/fraud-scores", h.Fraud.ListScores)
+		admin.GET("/drivers/:id/location-flags", h.Fraud.ListFlags)
+		admin.POST("/payments/:id/refunds", h.Payment.CreateRefund)
+		admin.GET("/payments/:id/refunds", h.Payment.ListRefunds)
+		admin.GET("/payments/discrepancies", h.Payment.ListDiscrepancies)
+		admin.POST("/payments/discrepancies/:id/resolve", h.Payment.ResolveDiscrepancy)
+		admin.GET("/payouts/batches", h.Earnings.ListPayoutBatches)
+		admin.GET("/payouts/batches/:id/file", h.Earnings.GetPayoutFile)
+		admin.GET("/promotions", h.Promotion.ListPromotions)
+		admin.POST("/promotions", h.Promotion.CreatePromotion)
+		admin.GET("/promotions/:id", h.Promotion.GetPromotion)
+		admin.PUT("/promotions/:id", h.Promotion.UpdatePromotion)
+		admin.DELETE("/promotions/:id", h.Promotion.DeletePromotion)
 	}
 
 	// Location routes (drivers only)
 	location := v1.Group("/location", middlewares.Auth.RequireAuth, middlewares.Auth.RequireRole(model.RoleDriver))
 	{
 		location.POST("/update", h.Location.UpdateLocation)
+		location.POST("/batch", h.Location.UploadLocationBatch)
 		location.POST("/availability", h.Location.SetAvailability)
 	}
 
@@ -108,16 +129,34 @@ func NewRouter(s *server.Server, h *handler.Handlers, services *service.Services
 	rides := v1.Group("/rides", middlewares.Auth.RequireAuth)
 	{
 		rides.POST("", h.Ride.CreateRide, middlewares.Auth.RequireRole(model.RoleRider))
+		rides.POST("/quote", h.Promotion.QuoteFare, middlewares.Auth.RequireRole(model.RoleRider))
 		rides.GET("/active", h.Ride.GetActiveRide)
 		rides.POST("/:id/accept", h.Ride.AcceptRide, middlewares.Auth.RequireRole(model.RoleDriver))
 		rides.POST("/:id/start", h.Ride.StartRide, middlewares.Auth.RequireRole(model.RoleDriver))
 		rides.POST("/:id/complete", h.Ride.CompleteRide, middlewares.Auth.RequireRole(model.RoleDriver))
 		rides.POST("/:id/cancel", h.Ride.CancelRide)
 		rides.POST("/:id/rate", h.Ride.RateRide, middlewares.Auth.RequireRole(model.RoleRider))
+
+		// Rider-driver chat
+		rides.GET("/quick-replies", h.Chat.QuickReplies)
+		rides.GET("/:id/messages", h.Chat.ListMessages)
+		rides.POST("/:id/messages", h.Chat.SendMessage)
+		rides.POST("/:id/messages/receipts", h.Chat.MarkMessages)
+	}
+
+	// Push notification device tokens
+	devices := v1.Group("/devices", middlewares.Auth.RequireAuth)
+	{
+		devices.GET("", h.Device.ListDevices)
+		devices.POST("", h.Device.RegisterDevice)
+		devices.POST("/unregister", h.Device.UnregisterDevice)
 	}
 
 	// Payment routes
 
+	// Razorpay authenticates webhooks with a signature instead of a user token
+	v1.POST("/payments/webhook", h.Payment.Webhook)
+
 	payments := v1.Group("/payments", middlewares.Auth.RequireAuth)
 	{
 		payments.POST("/create", h.Payment.CreatePaymentOrder, middlewares.Auth.RequireRole(model.RoleRider))
@@ -125,7 +164,14 @@ func NewRouter(s *server.Server, h *handler.Handlers, services *service.Services
 		payments.POST("/cash", h.Payment.ProcessCashPayment, middlewares.Auth.RequireRole(model.RoleRider))
 		payments.POST("/upi", h.Payment.ProcessUPIPayment, middlewares.Auth.RequireRole(model.RoleRider))
 		payments.GET("/ride/:ride_id", h.Payment.GetPaymentByRideID)
+		payments.GET("/wallet", h.Payment.GetWallet)
+		payments.GET("/wallet/statement", h.Payment.GetWalletStatement)
+		payments.POST("/wallet/topup", h.Payment.CreateWalletTopup, middlewares.Auth.RequireRole(model.RoleRider))
+		payments.POST("/wallet/topup/verify", h.Payment.VerifyWalletTopup, middlewares.Auth.RequireRole(model.RoleRider))
 	}
 
+	// Referral program
+	v1.GET("/referrals", h.Referral.GetReferralStatus, middlewares.Auth.RequireAuth)
+
 	return router
 }
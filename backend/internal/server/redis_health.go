@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// How often redis availability is re-checked
+	redisCheckInterval = 5 * time.Second
+	redisCheckTimeout  = 2 * time.Second
+)
+
+// RedisAvailable reports whether redis answered the last health check. While it is unavailable
+// the server runs in degraded mode and redis backed features fall back to PostgreSQL.
+func (s *Server) RedisAvailable() bool {
+	return s.Redis != nil && !s.redisDown.Load()
+}
+
+// Degraded reports whether the server is running without redis
+func (s *Server) Degraded() bool {
+	return !s.RedisAvailable()
+}
+
+// checkRedis pings redis and records the result, logging when the state changes
+func (s *Server) checkRedis(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, redisCheckTimeout)
+	defer cancel()
+
+	err := s.Redis.Ping(ctx).Err()
+	wasDown := s.redisDown.Swap(err != nil)
+	switch {
+	case err != nil && !wasDown:
+		s.Logger.Error().Err(err).Msg("Redis unavailable, running in degraded mode")
+	case err == nil && wasDown:
+		s.Logger.Info().Msg("Redis available again, leaving degraded mode")
+	}
+}
+
+// watchRedis keeps the redis availability up to date until stop is closed
+func (s *Server) watchRedis(stop <-chan struct{}) {
+	ticker := time.NewTicker(redisCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.checkRedis(context.Background())
+		case <-stop:
+			return
+		}
+	}
+}
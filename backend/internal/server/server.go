@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/newrelic/go-agent/v3/integrations/nrredis-v9"
@@ -27,6 +28,10 @@ type Server struct {
 	httpServer    *http.Server
 	Job           *job.JobService
 	Hub           *realtime.Hub
+	shutdownHooks []func(context.Context) error
+	// redisDown is inverted so a zero Server treats its redis client as available
+	redisDown      atomic.Bool
+	stopRedisWatch chan struct{}
 }
 
 func New(cfg *config.Config, logger *zerolog.Logger, loggerservice *loggerpkg.LoggerService) (*Server, error) {
@@ -45,14 +50,6 @@ func New(cfg *config.Config, logger *zerolog.Logger, loggerservice *loggerpkg.Lo
 		redisclient.AddHook(nrredis.NewHook(redisclient.Options()))
 	}
 
-	// Test Redis Connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	if err := redisclient.Ping(ctx).Err(); err != nil {
-		logger.Error().Err(err).Msg("Failed to Connect to Redis , Continuing without redis")
-	}
-
-	defer cancel()
-
 	// Job Service
 	jobservice := job.NewJobService(logger, cfg)
 	jobservice.InitHandlers(cfg, logger)
@@ -61,20 +58,26 @@ func New(cfg *config.Config, logger *zerolog.Logger, loggerservice *loggerpkg.Lo
 	if err := jobservice.Start(); err != nil {
 		return nil, err
 	}
-	// WebSocket Hub
-	hub := realtime.NewHub(logger)
+	// WebSocket Hub, relays messages between instances through redis pub/sub
+	hub := realtime.NewHub(logger, redisclient)
+	realtime.SetAllowedOrigins(cfg.Server.CORSAllowOrigins)
 	go hub.Run()
 
 	server := &Server{
-		Config:        cfg,
-		Logger:        logger,
-		LoggerService: loggerservice,
-		DB:            db,
-		Redis:         redisclient,
-		Job:           jobservice,
-		Hub:           hub,
+		Config:         cfg,
+		Logger:         logger,
+		LoggerService:  loggerservice,
+		DB:             db,
+		Redis:          redisclient,
+		Job:            jobservice,
+		Hub:            hub,
+		stopRedisWatch: make(chan struct{}),
 	}
 
+	// Test Redis Connection, without it the server starts in degraded mode and keeps checking
+	server.checkRedis(context.Background())
+	go server.watchRedis(server.stopRedisWatch)
+
 	// Start metrics collection
 	// Runtime metrics are automatically collected by New Relic Go agent
 
@@ -103,12 +106,24 @@ func (s *Server) Start() error {
 	return s.httpServer.ListenAndServe()
 }
 
+// RegisterOnShutdown adds a hook that runs during Shutdown once no more requests are served,
+// while the database and redis are still available
+func (s *Server) RegisterOnShutdown(hook func(context.Context) error) {
+	s.shutdownHooks = append(s.shutdownHooks, hook)
+}
+
 // Gracefull Shutdown
 func (s *Server) Shutdown(ctx context.Context) error {
 	if err := s.httpServer.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown HTTP server: %w", err)
 	}
 
+	for _, hook := range s.shutdownHooks {
+		if err := hook(ctx); err != nil {
+			s.Logger.Error().Err(err).Msg("Shutdown hook failed")
+		}
+	}
+
 	if err := s.DB.Close(); err != nil {
 		return fmt.Errorf("failed to close database connection: %w", err)
 	}
@@ -117,5 +132,15 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		s.Job.Stop()
 	}
 
+	if s.stopRedisWatch != nil {
+		close(s.stopRedisWatch)
+	}
+
+	if s.Hub != nil {
+		if err := s.Hub.Close(); err != nil {
+			return fmt.Errorf("failed to close websocket hub: %w", err)
+		}
+	}
+
 	return nil
 }
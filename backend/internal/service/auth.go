@@ -2,6 +2,12 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	mrand "math/rand"
 	"strconv"
@@ -9,6 +15,8 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/job"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
@@ -16,9 +24,21 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	// Redis key prefix of login OTPs
+	otpPrefix = "otp:"
+	// Login OTPs expire after this long in redis and in the database fallback
+	otpTTL = 10 * time.Minute
+	// Redis key prefix of single-use websocket tickets
+	wsTicketPrefix = "ws:ticket:"
+	// Websocket tickets must be redeemed within this window
+	wsTicketTTL = 30 * time.Second
+)
+
 type AuthService struct {
-	server *server.Server
-	repo   *repository.Repositories
+	server          *server.Server
+	repo            *repository.Repositories
+	referralService *ReferralService
 }
 
 type JWTClaims struct {
@@ -28,10 +48,11 @@ type JWTClaims struct {
 	jwt.RegisteredClaims
 }
 
-func NewAuthService(s *server.Server, repo *repository.Repositories) *AuthService {
+func NewAuthService(s *server.Server, repo *repository.Repositories, referralService *ReferralService) *AuthService {
 	return &AuthService{
-		server: s,
-		repo:   repo,
+		server:          s,
+		repo:            repo,
+		referralService: referralService,
 	}
 }
 
@@ -45,6 +66,17 @@ func (s *AuthService) Signup(ctx context.Context, req *model.SignupRequest) (*mo
 		return nil, fmt.Errorf("email already registered")
 	}
 
+	var referrer *model.User
+	if req.ReferralCode != "" {
+		if req.Role != model.RoleRider {
+			return nil, errs.NewBadRequest("referral codes are for riders")
+		}
+		referrer, err = s.referralService.Referrer(ctx, req.ReferralCode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -67,11 +99,28 @@ func (s *AuthService) Signup(ctx context.Context, req *model.SignupRequest) (*mo
 	if req.Phone != "" {
 		user.Phone = &req.Phone
 	}
+	if req.DeviceID != "" {
+		user.SignupDeviceID = &req.DeviceID
+	}
 
-	if err := s.repo.User.Create(ctx, user); err != nil {
+	// The database draws the referral code, it draws again in the unlikely case the first is taken
+	for attempt := 1; ; attempt++ {
+		err = s.repo.User.Create(ctx, user)
+		if !errors.Is(err, repository.ErrReferralCodeTaken) || attempt == 3 {
+			break
+		}
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
+	if referrer != nil {
+		if _, err := s.referralService.Attach(ctx, referrer, user); err != nil {
+			// The account is created either way, only the referral is lost
+			s.server.Logger.Error().Err(err).Str("user_id", user.ID.String()).Msg("Failed to record referral")
+		}
+	}
+
 	// Generate JWT token
 	token, err := s.generateToken(user)
 	if err != nil {
@@ -125,9 +174,7 @@ func (s *AuthService) SendOTP(ctx context.Context, req *model.SendOTPRequest) er
 	// Generate OTP
 	otp := s.generateOTP()
 
-	// Store in Redis
-	key := fmt.Sprintf("otp:%s", req.Email)
-	if err := s.server.Redis.Set(ctx, key, otp, 10*time.Minute).Err(); err != nil {
+	if err := s.storeOTP(ctx, req.Email, otp); err != nil {
 		return fmt.Errorf("failed to store otp: %w", err)
 	}
 
@@ -145,14 +192,11 @@ func (s *AuthService) SendOTP(ctx context.Context, req *model.SendOTPRequest) er
 }
 
 func (s *AuthService) VerifyOTP(ctx context.Context, req *model.VerifyOTPRequest) (*model.AuthResponse, error) {
-	// Get OTP from Redis
-	key := fmt.Sprintf("otp:%s", req.Email)
-	storedOTP, err := s.server.Redis.Get(ctx, key).Result()
+	valid, err := s.checkOTP(ctx, req.Email, req.OTP)
 	if err != nil {
 		return nil, fmt.Errorf("invalid or expired otp")
 	}
-
-	if storedOTP != req.OTP {
+	if !valid {
 		return nil, fmt.Errorf("invalid otp")
 	}
 
@@ -166,8 +210,7 @@ func (s *AuthService) VerifyOTP(ctx context.Context, req *model.VerifyOTPRequest
 		return nil, fmt.Errorf("role mismatch")
 	}
 
-	// Delete OTP
-	s.server.Redis.Del(ctx, key)
+	s.deleteOTP(ctx, req.Email)
 
 	// Generate Token
 	token, err := s.generateToken(user)
@@ -181,6 +224,102 @@ func (s *AuthService) VerifyOTP(ctx context.Context, req *model.VerifyOTPRequest
 	}, nil
 }
 
+// storeOTP keeps the OTP in redis, or in PostgreSQL when redis is unavailable
+func (s *AuthService) storeOTP(ctx context.Context, email, otp string) error {
+	if s.server.RedisAvailable() {
+		err := s.server.Redis.Set(ctx, otpPrefix+email, otp, otpTTL).Err()
+		if err == nil {
+			return nil
+		}
+		s.server.Logger.Error().Err(err).Msg("Failed to store otp in redis, falling back to database")
+	}
+	return s.repo.OTP.Save(ctx, email, hashOTP(otp), time.Now().Add(otpTTL))
+}
+
+// checkOTP compares the OTP with the one stored in redis, then with the one stored in PostgreSQL
+// so codes sent during an outage stay valid once redis is back
+func (s *AuthService) checkOTP(ctx context.Context, email, otp string) (bool, error) {
+	if s.server.RedisAvailable() {
+		stored, err := s.server.Redis.Get(ctx, otpPrefix+email).Result()
+		if err == nil {
+			return stored == otp, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			s.server.Logger.Error().Err(err).Msg("Failed to read otp from redis, falling back to database")
+		}
+	}
+
+	storedHash, err := s.repo.OTP.Get(ctx, email)
+	if err != nil {
+		return false, err
+	}
+	if storedHash == "" {
+		return false, errors.New("otp not found")
+	}
+	return subtle.ConstantTimeCompare([]byte(storedHash), []byte(hashOTP(otp))) == 1, nil
+}
+
+// deleteOTP makes a verified OTP unusable in both stores
+func (s *AuthService) deleteOTP(ctx context.Context, email string) {
+	if s.server.RedisAvailable() {
+		s.server.Redis.Del(ctx, otpPrefix+email)
+	}
+	if err := s.repo.OTP.Delete(ctx, email); err != nil {
+		s.server.Logger.Error().Err(err).Msg("Failed to delete otp from database")
+	}
+}
+
+func hashOTP(otp string) string {
+	sum := sha256.Sum256([]byte(otp))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueWSTicket exchanges an authenticated session for a short-lived ticket that opens one
+// websocket, so the JWT never ends up in a URL.
+func (s *AuthService) IssueWSTicket(ctx context.Context, claims *JWTClaims) (*model.WSTicketResponse, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate ws ticket: %w", err)
+	}
+	ticket := hex.EncodeToString(raw)
+
+	data, err := json.Marshal(JWTClaims{
+		UserID: claims.UserID,
+		Email:  claims.Email,
+		Role:   claims.Role,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ws ticket: %w", err)
+	}
+
+	if err := s.server.Redis.Set(ctx, wsTicketPrefix+ticket, data, wsTicketTTL).Err(); err != nil {
+		return nil, fmt.Errorf("failed to store ws ticket: %w", err)
+	}
+
+	return &model.WSTicketResponse{
+		Ticket:    ticket,
+		ExpiresIn: int(wsTicketTTL.Seconds()),
+	}, nil
+}
+
+// RedeemWSTicket consumes a websocket ticket and returns the claims it was issued for.
+// A ticket can be redeemed only once.
+func (s *AuthService) RedeemWSTicket(ctx context.Context, ticket string) (*JWTClaims, error) {
+	data, err := s.server.Redis.GetDel(ctx, wsTicketPrefix+ticket).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errs.NewUnauthorized("Invalid or expired ticket")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem ws ticket: %w", err)
+	}
+
+	var claims JWTClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ws ticket: %w", err)
+	}
+	return &claims, nil
+}
+
 func (s *AuthService) generateOTP() string {
 	rng := mrand.New(mrand.NewSource(time.Now().UnixNano()))
 	return strconv.Itoa(100000 + rng.Intn(900000))
@@ -0,0 +1,61 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWSTicket(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	logger := zerolog.Nop()
+	srv := &server.Server{
+		Logger: &logger,
+		Redis:  redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+	}
+	authService := service.NewAuthService(srv, &repository.Repositories{}, nil)
+
+	claims := &service.JWTClaims{
+		UserID: uuid.New(),
+		Email:  "rider@example.com",
+		Role:   model.RoleRider,
+	}
+
+	t.Run("Single Use", func(t *testing.T) {
+		resp, err := authService.IssueWSTicket(context.Background(), claims)
+		require.NoError(t, err)
+		assert.Equal(t, 30, resp.ExpiresIn)
+
+		redeemed, err := authService.RedeemWSTicket(context.Background(), resp.Ticket)
+		require.NoError(t, err)
+		assert.Equal(t, claims.UserID, redeemed.UserID)
+		assert.Equal(t, claims.Role, redeemed.Role)
+
+		_, err = authService.RedeemWSTicket(context.Background(), resp.Ticket)
+		assert.Error(t, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		resp, err := authService.IssueWSTicket(context.Background(), claims)
+		require.NoError(t, err)
+
+		mr.FastForward(31 * time.Second)
+
+		_, err = authService.RedeemWSTicket(context.Background(), resp.Ticket)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+)
+
+const (
+	eventChatMessage = "chat_message"
+	eventChatReceipt = "chat_receipt"
+
+	defaultChatHistoryLimit = 50
+	maxChatHistoryLimit     = 100
+)
+
+var (
+	codeChatClosed        = "RIDE_CHAT_CLOSED"
+	codeUnknownQuickReply = "UNKNOWN_QUICK_REPLY"
+)
+
+// ChatService handles rider-driver chat scoped to an active ride
+type ChatService struct {
+	server *server.Server
+	repo   *repository.Repositories
+}
+
+func NewChatService(s *server.Server, repo *repository.Repositories) *ChatService {
+	return &ChatService{
+		server: s,
+		repo:   repo,
+	}
+}
+
+// chatParticipant describes the caller's side of a ride conversation
+type chatParticipant struct {
+	ride        *model.Ride
+	role        model.UserRole
+	otherUserID string
+}
+
+// SendMessage stores a message from the caller and pushes it to the other party of the ride.
+// Chat is only open while a driver is assigned and the ride has not ended.
+func (s *ChatService) SendMessage(ctx context.Context, userID, rideID string, req *model.SendRideMessageRequest) (*model.RideMessage, error) {
+	p, err := s.participant(ctx, userID, rideID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.ride.Status {
+	case model.RideStatusAccepted, model.RideStatusDriverArrived, model.RideStatusInProgress:
+	default:
+		return nil, errs.NewBadRequestError("chat is closed for this ride", false, &codeChatClosed, nil, nil)
+	}
+
+	message := &model.RideMessage{
+		RideID:      rideID,
+		SenderID:    userID,
+		RecipientID: p.otherUserID,
+		Body:        req.Body,
+	}
+	if req.QuickReplyID != "" {
+		reply, ok := model.FindQuickReply(p.role, req.QuickReplyID)
+		if !ok {
+			return nil, errs.NewBadRequestError("unknown quick reply", false, &codeUnknownQuickReply, nil, nil)
+		}
+		message.Body = reply.Text
+		message.QuickReplyID = &reply.ID
+	}
+
+	if err := s.repo.Chat.Create(ctx, message); err != nil {
+		return nil, errs.Wrap(err, "failed to create ride message")
+	}
+
+	s.server.Hub.BroadcastToUser(p.otherUserID, eventChatMessage, message)
+	return message, nil
+}
+
+// ListMessages returns the ride's chat history, newest first. History stays readable after the ride ends.
+func (s *ChatService) ListMessages(ctx context.Context, userID, rideID string, before *time.Time, limit int) ([]*model.RideMessage, error) {
+	if _, err := s.participant(ctx, userID, rideID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultChatHistoryLimit
+	}
+	if limit > maxChatHistoryLimit {
+		limit = maxChatHistoryLimit
+	}
+
+	messages, err := s.repo.Chat.ListByRide(ctx, rideID, before, limit)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list ride messages")
+	}
+	return messages, nil
+}
+
+// MarkMessages records delivery or read receipts for messages addressed to the caller and
+// notifies the sender about the messages whose state changed
+func (s *ChatService) MarkMessages(ctx context.Context, userID, rideID string, req *model.RideMessageReceiptRequest) error {
+	p, err := s.participant(ctx, userID, rideID)
+	if err != nil {
+		return err
+	}
+
+	at := time.Now().UTC()
+	updated, err := s.repo.Chat.MarkReceipt(ctx, rideID, userID, req.MessageIDs, req.Status, at)
+	if err != nil {
+		return errs.Wrap(err, "failed to update ride message receipts")
+	}
+	if len(updated) == 0 {
+		return nil
+	}
+
+	s.server.Hub.BroadcastToUser(p.otherUserID, eventChatReceipt, model.RideMessageReceipt{
+		RideID:     rideID,
+		MessageIDs: updated,
+		Status:     req.Status,
+		At:         at,
+	})
+	return nil
+}
+
+// QuickReplies returns the canned messages offered to the role
+func (s *ChatService) QuickReplies(role model.UserRole) []model.QuickReply {
+	replies := model.QuickReplies[role]
+	if replies == nil {
+		return []model.QuickReply{}
+	}
+	return replies
+}
+
+// participant loads the ride and checks that the caller is its rider or its assigned driver
+func (s *ChatService) participant(ctx context.Context, userID, rideID string) (*chatParticipant, error) {
+	ride, err := s.repo.Ride.GetByID(ctx, rideID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errs.NewNotFoundError("ride not found", false, nil)
+		}
+		return nil, errs.Wrap(err, "failed to get ride")
+	}
+
+	driverUserID := resolveDriverUserID(ctx, s.repo, ride)
+	switch {
+	case userID == ride.UserID && driverUserID != "":
+		return &chatParticipant{ride: ride, role: model.RoleRider, otherUserID: driverUserID}, nil
+	case userID == ride.UserID:
+		// No driver yet, so there is nobody to talk to
+		return nil, errs.NewBadRequestError("chat is closed for this ride", false, &codeChatClosed, nil, nil)
+	case driverUserID != "" && userID == driverUserID:
+		return &chatParticipant{ride: ride, role: model.RoleDriver, otherUserID: ride.UserID}, nil
+	default:
+		return nil, errs.NewForbiddenError("you are not part of this ride", false)
+	}
+}
@@ -0,0 +1,226 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model/driver"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/realtime"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatService(t *testing.T) {
+	ctx := context.Background()
+	const rideID = "9b2f6c1e-4d3a-4e5b-8c7d-1a2b3c4d5e6f"
+	riderID := uuid.New().String()
+	driverID := uuid.New()
+	driverUserID := uuid.New()
+	driverIDStr := driverID.String()
+
+	type fixture struct {
+		service  *service.ChatService
+		rides    *testutil.MockRideRepository
+		drivers  *testutil.MockDriverRepository
+		messages *testutil.MockRideMessageRepository
+		redis    *redis.Client
+	}
+	setup := func(t *testing.T, status model.RideStatus, assigned bool) *fixture {
+		mr, err := miniredis.Run()
+		require.NoError(t, err)
+		t.Cleanup(mr.Close)
+
+		redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		logger := zerolog.Nop()
+		srv := &server.Server{Logger: &logger, Redis: redisClient, Hub: realtime.NewHub(&logger, redisClient)}
+
+		f := &fixture{
+			rides:    new(testutil.MockRideRepository),
+			drivers:  new(testutil.MockDriverRepository),
+			messages: new(testutil.MockRideMessageRepository),
+			redis:    redisClient,
+		}
+		f.service = service.NewChatService(srv, &repository.Repositories{Ride: f.rides, Driver: f.drivers, Chat: f.messages})
+
+		ride := &model.Ride{ID: rideID, UserID: riderID, Status: status}
+		if assigned {
+			ride.DriverID = &driverIDStr
+			d := &driver.Driver{UserID: driverUserID}
+			d.ID = driverID
+			f.drivers.On("GetByID", mock.Anything, driverID).Return(d, nil)
+		}
+		f.rides.On("GetByID", mock.Anything, rideID).Return(ride, nil)
+		return f
+	}
+	// events returns the logged messages pushed to a user, oldest first
+	events := func(t *testing.T, f *fixture, userID string) []realtime.Message {
+		entries, err := f.redis.XRange(ctx, "ws:events:"+userID, "-", "+").Result()
+		require.NoError(t, err)
+		var messages []realtime.Message
+		for _, entry := range entries {
+			var message realtime.Message
+			require.NoError(t, json.Unmarshal([]byte(entry.Values["data"].(string)), &message))
+			messages = append(messages, message)
+		}
+		return messages
+	}
+	httpStatus := func(t *testing.T, err error) int {
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		return httpErr.Status
+	}
+
+	t.Run("Rider message is pushed to the driver", func(t *testing.T) {
+		f := setup(t, model.RideStatusAccepted, true)
+		f.messages.On("Create", mock.Anything, mock.MatchedBy(func(m *model.RideMessage) bool {
+			return m.SenderID == riderID && m.RecipientID == driverUserID.String() && m.Body == "At the gate" && m.QuickReplyID == nil
+		})).Return(nil).Once()
+
+		message, err := f.service.SendMessage(ctx, riderID, rideID, &model.SendRideMessageRequest{Body: "At the gate"})
+		require.NoError(t, err)
+		assert.Equal(t, "At the gate", message.Body)
+
+		pushed := events(t, f, driverUserID.String())
+		require.Len(t, pushed, 1)
+		assert.Equal(t, "chat_message", pushed[0].Type)
+		assert.Empty(t, events(t, f, riderID))
+		f.messages.AssertExpectations(t)
+	})
+
+	t.Run("Driver message is pushed to the rider", func(t *testing.T) {
+		f := setup(t, model.RideStatusInProgress, true)
+		f.messages.On("Create", mock.Anything, mock.MatchedBy(func(m *model.RideMessage) bool {
+			return m.SenderID == driverUserID.String() && m.RecipientID == riderID
+		})).Return(nil).Once()
+
+		_, err := f.service.SendMessage(ctx, driverUserID.String(), rideID, &model.SendRideMessageRequest{Body: "Turning in"})
+		require.NoError(t, err)
+		assert.Len(t, events(t, f, riderID), 1)
+	})
+
+	t.Run("Other users are not part of the ride", func(t *testing.T) {
+		f := setup(t, model.RideStatusAccepted, true)
+
+		_, err := f.service.SendMessage(ctx, uuid.New().String(), rideID, &model.SendRideMessageRequest{Body: "Hi"})
+		assert.Equal(t, http.StatusForbidden, httpStatus(t, err))
+
+		_, err = f.service.ListMessages(ctx, uuid.New().String(), rideID, nil, 0)
+		assert.Equal(t, http.StatusForbidden, httpStatus(t, err))
+		f.messages.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		f.messages.AssertNotCalled(t, "ListByRide", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unknown ride is not found", func(t *testing.T) {
+		f := setup(t, model.RideStatusAccepted, true)
+		f.rides.On("GetByID", mock.Anything, "missing").Return(nil, pgx.ErrNoRows)
+
+		_, err := f.service.SendMessage(ctx, riderID, "missing", &model.SendRideMessageRequest{Body: "Hi"})
+		assert.Equal(t, http.StatusNotFound, httpStatus(t, err))
+	})
+
+	t.Run("Chat is closed until a driver is assigned", func(t *testing.T) {
+		f := setup(t, model.RideStatusRequested, false)
+
+		_, err := f.service.SendMessage(ctx, riderID, rideID, &model.SendRideMessageRequest{Body: "Hi"})
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusBadRequest, httpErr.Status)
+		assert.Equal(t, "RIDE_CHAT_CLOSED", httpErr.Code)
+	})
+
+	t.Run("Chat is closed after the ride ended but history stays readable", func(t *testing.T) {
+		f := setup(t, model.RideStatusCompleted, true)
+
+		_, err := f.service.SendMessage(ctx, riderID, rideID, &model.SendRideMessageRequest{Body: "Thanks"})
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, "RIDE_CHAT_CLOSED", httpErr.Code)
+
+		history := []*model.RideMessage{{ID: "m1", RideID: rideID, Body: "At the gate"}}
+		// The limit is capped
+		f.messages.On("ListByRide", mock.Anything, rideID, (*time.Time)(nil), 100).Return(history, nil).Once()
+		messages, err := f.service.ListMessages(ctx, driverUserID.String(), rideID, nil, 500)
+		require.NoError(t, err)
+		assert.Equal(t, history, messages)
+		f.messages.AssertExpectations(t)
+	})
+
+	t.Run("Quick reply is sent with its text", func(t *testing.T) {
+		f := setup(t, model.RideStatusDriverArrived, true)
+		f.messages.On("Create", mock.Anything, mock.MatchedBy(func(m *model.RideMessage) bool {
+			return m.Body == "I have arrived at the pickup point" && m.QuickReplyID != nil && *m.QuickReplyID == "arrived"
+		})).Return(nil).Once()
+
+		message, err := f.service.SendMessage(ctx, driverUserID.String(), rideID, &model.SendRideMessageRequest{QuickReplyID: "arrived"})
+		require.NoError(t, err)
+		assert.Equal(t, "I have arrived at the pickup point", message.Body)
+		f.messages.AssertExpectations(t)
+	})
+
+	t.Run("Quick replies of the other role are refused", func(t *testing.T) {
+		f := setup(t, model.RideStatusAccepted, true)
+
+		_, err := f.service.SendMessage(ctx, riderID, rideID, &model.SendRideMessageRequest{QuickReplyID: "arrived"})
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, "UNKNOWN_QUICK_REPLY", httpErr.Code)
+		f.messages.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Quick replies are listed per role", func(t *testing.T) {
+		f := setup(t, model.RideStatusAccepted, true)
+
+		assert.Equal(t, model.QuickReplies[model.RoleRider], f.service.QuickReplies(model.RoleRider))
+		assert.Equal(t, model.QuickReplies[model.RoleDriver], f.service.QuickReplies(model.RoleDriver))
+		replies := f.service.QuickReplies(model.RoleAdmin)
+		assert.NotNil(t, replies)
+		assert.Empty(t, replies)
+	})
+
+	t.Run("Read receipt is pushed to the sender", func(t *testing.T) {
+		f := setup(t, model.RideStatusInProgress, true)
+		ids := []string{"m1", "m2"}
+		f.messages.On("MarkReceipt", mock.Anything, rideID, driverUserID.String(), ids, model.ReceiptStatusRead, mock.Anything).
+			Return([]string{"m2"}, nil).Once()
+
+		err := f.service.MarkMessages(ctx, driverUserID.String(), rideID, &model.RideMessageReceiptRequest{MessageIDs: ids, Status: model.ReceiptStatusRead})
+		require.NoError(t, err)
+
+		pushed := events(t, f, riderID)
+		require.Len(t, pushed, 1)
+		assert.Equal(t, "chat_receipt", pushed[0].Type)
+		data, err := json.Marshal(pushed[0].Payload)
+		require.NoError(t, err)
+		var receipt model.RideMessageReceipt
+		require.NoError(t, json.Unmarshal(data, &receipt))
+		// Only the messages whose state changed are reported
+		assert.Equal(t, []string{"m2"}, receipt.MessageIDs)
+		assert.Equal(t, model.ReceiptStatusRead, receipt.Status)
+		f.messages.AssertExpectations(t)
+	})
+
+	t.Run("Receipt without changes is not pushed", func(t *testing.T) {
+		f := setup(t, model.RideStatusInProgress, true)
+		f.messages.On("MarkReceipt", mock.Anything, rideID, riderID, []string{"m3"}, model.ReceiptStatusDelivered, mock.Anything).
+			Return([]string{}, nil).Once()
+
+		err := f.service.MarkMessages(ctx, riderID, rideID, &model.RideMessageReceiptRequest{MessageIDs: []string{"m3"}, Status: model.ReceiptStatusDelivered})
+		require.NoError(t, err)
+		assert.Empty(t, events(t, f, driverUserID.String()))
+	})
+}
@@ -83,6 +83,13 @@ func (s *DriverService) UpdateProfile(ctx context.Context, userID uuid.UUID, req
 		return nil, err
 	}
 
+	// Nearby driver results show the vehicle, drop the cached copy
+	if s.server.RedisAvailable() {
+		if err := s.server.Redis.Del(ctx, driverProfileCachePrefix+userID.String()).Err(); err != nil {
+			s.server.Logger.Error().Err(err).Str("driver_id", userID.String()).Msg("Failed to invalidate driver profile cache")
+		}
+	}
+
 	return existing, nil
 }
 
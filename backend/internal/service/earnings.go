@@ -0,0 +1,291 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/job"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+)
+
+const defaultPayoutBatchLimit = 20
+
+// Columns of a payout file, one row per payout
+var payoutFileHeader = []string{"payout_id", "driver_id", "driver_name", "phone", "vehicle_number", "amount", "currency"}
+
+// EarningsService reports what drivers earned and settles their balances. Paid rides are split
+// between the driver and the platform by the payment service; a periodic job pays the driver
+// balances out in batches that admins export as payout files.
+type EarningsService struct {
+	server *server.Server
+	repo   *repository.Repositories
+	cfg    *config.EarningsConfig
+}
+
+func NewEarningsService(s *server.Server, repo *repository.Repositories, cfg *config.EarningsConfig) *EarningsService {
+	return &EarningsService{
+		server: s,
+		repo:   repo,
+		cfg:    cfg,
+	}
+}
+
+// Register schedules the payout settlement job
+func (e *EarningsService) Register() error {
+	e.server.Job.HandleFunc(job.TaskPayoutSettlement, e.handleSettlementTask)
+	if err := e.server.Job.Schedule(e.cfg.PayoutSchedule, job.NewPayoutSettlementTask()); err != nil {
+		return fmt.Errorf("failed to schedule payout settlement: %w", err)
+	}
+	return nil
+}
+
+func (e *EarningsService) handleSettlementTask(ctx context.Context, t *asynq.Task) error {
+	batch, err := e.SettlePayouts(ctx)
+	if err != nil {
+		e.server.Logger.Error().Err(err).Msg("Payout settlement failed")
+		return err
+	}
+	e.server.Logger.Info().
+		Str("batch_id", batch.ID).
+		Int("payouts", batch.PayoutCount).
+		Float64("total", batch.TotalAmount).
+		Msg("Settled driver payouts")
+	return nil
+}
+
+// GetDailyEarnings returns the driver's earnings of one UTC day
+func (e *EarningsService) GetDailyEarnings(ctx context.Context, userID string, req *model.EarningsRequest) (*model.EarningsSummary, error) {
+	day := earningsDay(req)
+	return e.summary(ctx, userID, day, day.AddDate(0, 0, 1), false)
+}
+
+// GetWeeklyEarnings returns the driver's earnings of the Monday to Sunday week, broken down by day
+func (e *EarningsService) GetWeeklyEarnings(ctx context.Context, userID string, req *model.EarningsRequest) (*model.EarningsSummary, error) {
+	day := earningsDay(req)
+	monday := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	return e.summary(ctx, userID, monday, monday.AddDate(0, 0, 7), true)
+}
+
+func earningsDay(req *model.EarningsRequest) time.Time {
+	if req.Date != "" {
+		day, _ := time.Parse(time.DateOnly, req.Date)
+		return day
+	}
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
+
+// summary adds up the earnings of the days in [from, to)
+func (e *EarningsService) summary(ctx context.Context, userID string, from, to time.Time, byDay bool) (*model.EarningsSummary, error) {
+	driverID, err := e.driverID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := e.repo.Earnings.DailyTotals(ctx, driverID, from, to)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to add up earnings")
+	}
+	account, err := e.repo.Ledger.GetAccount(ctx, model.LedgerAccountDriverEarnings, driverID)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to get driver balance")
+	}
+
+	summary := &model.EarningsSummary{
+		DriverID: driverID,
+		Currency: account.Currency,
+		From:     from.Format(time.DateOnly),
+		To:       to.AddDate(0, 0, -1).Format(time.DateOnly),
+		Balance:  gateway.FromPaise(account.Balance),
+	}
+	var period model.EarningsTotals
+	days := make(map[time.Time]*model.EarningsTotals, len(totals))
+	for _, t := range totals {
+		addEarnings(&period, t)
+		days[t.Day] = t
+	}
+	summary.EarningsBreakdown = earningsBreakdown(&period)
+
+	if byDay {
+		for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+			d := &model.EarningsDay{Date: day.Format(time.DateOnly)}
+			if t, ok := days[day]; ok {
+				d.EarningsBreakdown = earningsBreakdown(t)
+			}
+			summary.Days = append(summary.Days, d)
+		}
+	}
+	return summary, nil
+}
+
+// driverID returns the driver profile id of a driver's user id
+func (e *EarningsService) driverID(ctx context.Context, userID string) (string, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return "", errs.NewBadRequest("invalid driver user id")
+	}
+	driver, err := e.repo.Driver.GetByUserID(ctx, uid)
+	if err != nil {
+		return "", errs.Wrap(err, "failed to get driver profile")
+	}
+	if driver == nil {
+		return "", errs.NewNotFoundError("driver profile not found", false, nil)
+	}
+	return driver.ID.String(), nil
+}
+
+func addEarnings(sum, t *model.EarningsTotals) {
+	sum.Rides += t.Rides
+	sum.Fares += t.Fares
+	sum.Tips += t.Tips
+	sum.Commission += t.Commission
+	sum.Refunds += t.Refunds
+	sum.Net += t.Net
+	sum.CashCollected += t.CashCollected
+	sum.PlatformDues += t.PlatformDues
+}
+
+func earningsBreakdown(t *model.EarningsTotals) model.EarningsBreakdown {
+	return model.EarningsBreakdown{
+		Rides:         t.Rides,
+		Fares:         gateway.FromPaise(t.Fares),
+		Tips:          gateway.FromPaise(t.Tips),
+		Commission:    gateway.FromPaise(t.Commission),
+		Refunds:       gateway.FromPaise(t.Refunds),
+		NetEarnings:   gateway.FromPaise(t.Net),
+		CashCollected: gateway.FromPaise(t.CashCollected),
+		PlatformDues:  gateway.FromPaise(t.PlatformDues),
+	}
+}
+
+// SettlePayouts pays out every driver balance of at least the minimum payout in one batch, each
+// payout is posted from the driver's earnings to driver_payouts. A settlement interrupted part way
+// is resumed in its batch: the payouts already in it are posted again, which changes nothing, and
+// drivers with a payout are not paid twice.
+func (e *EarningsService) SettlePayouts(ctx context.Context) (*model.PayoutBatch, error) {
+	batch, err := e.repo.Payout.OpenBatch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open payout batch: %w", err)
+	}
+
+	resumed, err := e.repo.Payout.ListPayouts(ctx, batch.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payouts of batch %s: %w", batch.ID, err)
+	}
+	for _, payout := range resumed {
+		if err := e.postPayout(ctx, payout); err != nil {
+			return nil, err
+		}
+	}
+
+	// Drivers owing dues have a negative balance and are left out until their earnings cover them
+	accounts, err := e.repo.Ledger.ListAccountsWithBalance(ctx, model.LedgerAccountDriverEarnings, gateway.ToPaise(e.cfg.MinimumPayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list driver balances: %w", err)
+	}
+	for _, account := range accounts {
+		payout := &model.Payout{
+			BatchID:  batch.ID,
+			DriverID: *account.OwnerID,
+			Amount:   gateway.FromPaise(account.Balance),
+			Currency: account.Currency,
+		}
+		created, err := e.repo.Payout.CreatePayout(ctx, payout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create payout for driver %s: %w", payout.DriverID, err)
+		}
+		if !created {
+			// Paid in this batch already, what was earned since waits for the next one
+			continue
+		}
+		if err := e.postPayout(ctx, payout); err != nil {
+			return nil, err
+		}
+	}
+
+	batch, err = e.repo.Payout.CloseBatch(ctx, batch.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close payout batch: %w", err)
+	}
+	return batch, nil
+}
+
+func (e *EarningsService) postPayout(ctx context.Context, payout *model.Payout) error {
+	entry := transfer(model.JournalEntryPayout, payout.ID, "Payout to bank account", payout.Currency,
+		gateway.ToPaise(payout.Amount), driverEarnings(payout.DriverID), driverPayouts())
+	if _, err := e.repo.Ledger.Post(ctx, entry); err != nil {
+		return fmt.Errorf("failed to post payout %s: %w", payout.ID, err)
+	}
+	return nil
+}
+
+// ListPayoutBatches returns the latest payout batches, newest first
+func (e *EarningsService) ListPayoutBatches(ctx context.Context, req *model.ListPayoutBatchesRequest) ([]*model.PayoutBatch, error) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultPayoutBatchLimit
+	}
+	batches, err := e.repo.Payout.ListBatches(ctx, limit)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list payout batches")
+	}
+	if batches == nil {
+		batches = []*model.PayoutBatch{}
+	}
+	return batches, nil
+}
+
+// PayoutFile exports the payouts of a settled batch as CSV for the bank transfer
+func (e *EarningsService) PayoutFile(ctx context.Context, batchID string) ([]byte, error) {
+	batch, err := e.repo.Payout.GetBatch(ctx, batchID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errs.NewNotFoundError("payout batch not found", false, nil)
+		}
+		return nil, errs.Wrap(err, "failed to get payout batch")
+	}
+	if batch.Status != model.PayoutBatchStatusReady {
+		return nil, errs.NewBadRequest("payout batch is still being settled")
+	}
+
+	payouts, err := e.repo.Payout.ListPayouts(ctx, batch.ID)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list payouts")
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(payoutFileHeader)
+	for _, p := range payouts {
+		var phone string
+		if p.Phone != nil {
+			phone = *p.Phone
+		}
+		_ = w.Write([]string{
+			p.ID,
+			p.DriverID,
+			p.DriverName,
+			phone,
+			p.VehicleNumber,
+			strconv.FormatFloat(p.Amount, 'f', 2, 64),
+			p.Currency,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, errs.Wrap(err, "failed to write payout file")
+	}
+	return buf.Bytes(), nil
+}
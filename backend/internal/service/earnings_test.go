@@ -0,0 +1,236 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model/driver"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRideEarnings(t *testing.T) {
+	ctx := context.Background()
+
+	const userID = "user-1"
+	const rideID = "ride-1"
+	const paymentID = "8e1f2a3b-4c5d-4e6f-8a9b-0c1d2e3f4a5b"
+	driverID := "driver-1"
+	bike := model.VehicleTypeBike
+
+	// A ₹200 bike ride with a ₹20 tip, paid in cash
+	setup := func() (service.PaymentService, *testutil.MockPaymentRepository, *testutil.MockRideRepository, *testutil.MockLedgerRepository, *testutil.MockEarningsRepository) {
+		payments := new(testutil.MockPaymentRepository)
+		rides := new(testutil.MockRideRepository)
+		ledger := new(testutil.MockLedgerRepository)
+		earnings := new(testutil.MockEarningsRepository)
+
+		payments.On("GetByID", mock.Anything, paymentID).Return(&model.Payment{
+			ID: paymentID, RideID: rideID, UserID: userID, Amount: 220, Currency: "INR",
+			Status: model.PaymentStatusTypePending, PaymentMethod: "cash",
+		}, nil)
+		rides.On("GetByID", mock.Anything, rideID).Return(&model.Ride{ID: rideID, UserID: userID, DriverID: &driverID, VehicleType: &bike}, nil)
+		rides.On("GetCharges", mock.Anything, rideID).Return(&model.RideCharges{Fare: 200, Tip: 20}, nil)
+
+		paymentService := service.NewPaymentService(payments, rides, new(testutil.MockPaymentEventRepository), nil, nil, ledger,
+			earnings, nil, config.DefaultEarningsConfig(), &config.DefaultPaymentConfig().Reconciliation, nil, nil, nil)
+		return paymentService, payments, rides, ledger, earnings
+	}
+
+	t.Run("Cash ride charges the driver the commission", func(t *testing.T) {
+		paymentService, payments, rides, ledger, earnings := setup()
+		// 15% of the ₹200 fare for bikes, the tip is not commissioned
+		ledger.On("Post", mock.Anything, mock.MatchedBy(func(e *model.JournalEntry) bool {
+			return e.Type == model.JournalEntryCashCommission && e.ReferenceID == paymentID && len(e.Postings) == 2 &&
+				e.Postings[0].AccountType == model.LedgerAccountDriverEarnings && *e.Postings[0].OwnerID == driverID && e.Postings[0].Amount == -3000 &&
+				e.Postings[1].AccountType == model.LedgerAccountPlatformCommission && e.Postings[1].Amount == 3000
+		})).Return(true, nil).Once()
+		earnings.On("Record", mock.Anything, &model.RideEarning{
+			Kind: model.EarningKindRide, ReferenceID: paymentID, DriverID: driverID, RideID: rideID, PaymentID: paymentID,
+			PaymentMethod: "cash", Fare: 20000, Tip: 2000, Commission: 3000, Net: 19000,
+		}).Return(nil).Once()
+		payments.On("TransitionStatus", mock.Anything, mock.Anything, model.PaymentStatusTypePending).Return(true, nil).Once()
+		rides.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusCompleted, paymentID).Return(nil).Once()
+
+		err := paymentService.ProcessCashPayment(ctx, userID, &model.CashPaymentRequest{PaymentID: paymentID})
+		require.NoError(t, err)
+		ledger.AssertExpectations(t)
+		earnings.AssertExpectations(t)
+	})
+
+	t.Run("Earnings are recorded after the entry is posted", func(t *testing.T) {
+		paymentService, _, _, ledger, earnings := setup()
+		ledger.On("Post", mock.Anything, mock.Anything).Return(false, assert.AnError).Once()
+
+		err := paymentService.ProcessCashPayment(ctx, userID, &model.CashPaymentRequest{PaymentID: paymentID})
+		require.Error(t, err)
+		earnings.AssertNotCalled(t, "Record", mock.Anything, mock.Anything)
+	})
+}
+
+func TestEarningsService(t *testing.T) {
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	srv := &server.Server{Logger: &logger}
+
+	driverUserID := uuid.New()
+	driverUUID := uuid.New()
+	driverID := driverUUID.String()
+	const batchID = "b7c6d5e4-f3a2-4b1c-9d8e-7f6a5b4c3d2e"
+
+	type fixture struct {
+		service  *service.EarningsService
+		drivers  *testutil.MockDriverRepository
+		earnings *testutil.MockEarningsRepository
+		ledger   *testutil.MockLedgerRepository
+		payouts  *testutil.MockPayoutRepository
+	}
+
+	setup := func() *fixture {
+		f := &fixture{
+			drivers:  new(testutil.MockDriverRepository),
+			earnings: new(testutil.MockEarningsRepository),
+			ledger:   new(testutil.MockLedgerRepository),
+			payouts:  new(testutil.MockPayoutRepository),
+		}
+		d := &driver.Driver{UserID: driverUserID}
+		d.ID = driverUUID
+		f.drivers.On("GetByUserID", mock.Anything, driverUserID).Return(d, nil).Maybe()
+		f.ledger.On("GetAccount", mock.Anything, model.LedgerAccountDriverEarnings, driverID).
+			Return(&model.LedgerAccount{Type: model.LedgerAccountDriverEarnings, Currency: "INR", Balance: 125050}, nil).Maybe()
+
+		repos := &repository.Repositories{Driver: f.drivers, Earnings: f.earnings, Ledger: f.ledger, Payout: f.payouts}
+		f.service = service.NewEarningsService(srv, repos, config.DefaultEarningsConfig())
+		return f
+	}
+
+	t.Run("Weekly earnings are broken down by day", func(t *testing.T) {
+		f := setup()
+		// 2026-03-11 is a Wednesday, its week starts on Monday the 9th
+		monday := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+		f.earnings.On("DailyTotals", mock.Anything, driverID, monday, monday.AddDate(0, 0, 7)).Return([]*model.EarningsTotals{
+			{Day: monday, Rides: 2, Fares: 40000, Tips: 2000, Commission: 8000, Net: 34000},
+			{Day: monday.AddDate(0, 0, 3), Rides: 1, Fares: 20000, Commission: 4000, Refunds: 5000, Net: 12000,
+				CashCollected: 20000, PlatformDues: 4000},
+		}, nil).Once()
+
+		summary, err := f.service.GetWeeklyEarnings(ctx, driverUserID.String(), &model.EarningsRequest{Date: "2026-03-11"})
+		require.NoError(t, err)
+		assert.Equal(t, "2026-03-09", summary.From)
+		assert.Equal(t, "2026-03-15", summary.To)
+		assert.Equal(t, 3, summary.Rides)
+		assert.Equal(t, 600.0, summary.Fares)
+		assert.Equal(t, 120.0, summary.Commission)
+		assert.Equal(t, 50.0, summary.Refunds)
+		assert.Equal(t, 460.0, summary.NetEarnings)
+		assert.Equal(t, 40.0, summary.PlatformDues)
+		assert.Equal(t, 1250.5, summary.Balance)
+
+		require.Len(t, summary.Days, 7)
+		assert.Equal(t, "2026-03-09", summary.Days[0].Date)
+		assert.Equal(t, 340.0, summary.Days[0].NetEarnings)
+		assert.Equal(t, 0, summary.Days[1].Rides)
+		assert.Equal(t, 200.0, summary.Days[3].CashCollected)
+	})
+
+	t.Run("Daily earnings cover one day", func(t *testing.T) {
+		f := setup()
+		day := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+		f.earnings.On("DailyTotals", mock.Anything, driverID, day, day.AddDate(0, 0, 1)).Return(nil, nil).Once()
+
+		summary, err := f.service.GetDailyEarnings(ctx, driverUserID.String(), &model.EarningsRequest{Date: "2026-03-15"})
+		require.NoError(t, err)
+		assert.Equal(t, summary.From, summary.To)
+		assert.Equal(t, 0, summary.Rides)
+		assert.Empty(t, summary.Days)
+	})
+
+	t.Run("Users without a driver profile have no earnings", func(t *testing.T) {
+		f := setup()
+		other := uuid.New()
+		f.drivers.On("GetByUserID", mock.Anything, other).Return(nil, nil).Once()
+
+		_, err := f.service.GetDailyEarnings(ctx, other.String(), &model.EarningsRequest{})
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusNotFound, httpErr.Status)
+	})
+
+	t.Run("Settlement pays out balances above the minimum", func(t *testing.T) {
+		f := setup()
+		const otherDriver = "c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f"
+		f.payouts.On("OpenBatch", mock.Anything).Return(&model.PayoutBatch{ID: batchID, Status: model.PayoutBatchStatusOpen}, nil).Once()
+		// An earlier run was interrupted after adding the payout of the other driver
+		resumed := &model.Payout{ID: "payout-1", BatchID: batchID, DriverID: otherDriver, Amount: 300, Currency: "INR"}
+		f.payouts.On("ListPayouts", mock.Anything, batchID).Return([]*model.Payout{resumed}, nil).Once()
+		f.ledger.On("ListAccountsWithBalance", mock.Anything, model.LedgerAccountDriverEarnings, int64(10000)).Return([]*model.LedgerAccount{
+			{Type: model.LedgerAccountDriverEarnings, OwnerID: &driverID, Currency: "INR", Balance: 125050},
+		}, nil).Once()
+		f.payouts.On("CreatePayout", mock.Anything, mock.MatchedBy(func(p *model.Payout) bool {
+			return p.BatchID == batchID && p.DriverID == driverID && p.Amount == 1250.5
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*model.Payout).ID = "payout-2"
+		}).Return(true, nil).Once()
+
+		isPayout := func(payoutID, owner string, amount int64) interface{} {
+			return mock.MatchedBy(func(e *model.JournalEntry) bool {
+				return e.Type == model.JournalEntryPayout && e.ReferenceID == payoutID &&
+					e.Postings[0].AccountType == model.LedgerAccountDriverEarnings && *e.Postings[0].OwnerID == owner && e.Postings[0].Amount == -amount &&
+					e.Postings[1].AccountType == model.LedgerAccountDriverPayouts && e.Postings[1].Amount == amount
+			})
+		}
+		// Posting the resumed payout again changes nothing
+		f.ledger.On("Post", mock.Anything, isPayout("payout-1", otherDriver, 30000)).Return(false, nil).Once()
+		f.ledger.On("Post", mock.Anything, isPayout("payout-2", driverID, 125050)).Return(true, nil).Once()
+		f.payouts.On("CloseBatch", mock.Anything, batchID).Return(&model.PayoutBatch{
+			ID: batchID, Status: model.PayoutBatchStatusReady, PayoutCount: 2, TotalAmount: 1550.5,
+		}, nil).Once()
+
+		batch, err := f.service.SettlePayouts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.PayoutBatchStatusReady, batch.Status)
+		assert.Equal(t, 2, batch.PayoutCount)
+		f.ledger.AssertExpectations(t)
+		f.payouts.AssertExpectations(t)
+	})
+
+	t.Run("Payout file lists the payouts of a ready batch", func(t *testing.T) {
+		f := setup()
+		phone := "+919876543210"
+		f.payouts.On("GetBatch", mock.Anything, batchID).Return(&model.PayoutBatch{ID: batchID, Status: model.PayoutBatchStatusReady}, nil).Once()
+		f.payouts.On("ListPayouts", mock.Anything, batchID).Return([]*model.Payout{
+			{ID: "payout-2", BatchID: batchID, DriverID: driverID, Amount: 1250.5, Currency: "INR",
+				DriverName: "Ravi Kumar", Phone: &phone, VehicleNumber: "KA01AB1234"},
+		}, nil).Once()
+
+		file, err := f.service.PayoutFile(ctx, batchID)
+		require.NoError(t, err)
+		assert.Equal(t,
+			"payout_id,driver_id,driver_name,phone,vehicle_number,amount,currency\n"+
+				"payout-2,"+driverID+",Ravi Kumar,+919876543210,KA01AB1234,1250.50,INR\n",
+			string(file))
+	})
+
+	t.Run("Open batch cannot be exported", func(t *testing.T) {
+		f := setup()
+		f.payouts.On("GetBatch", mock.Anything, batchID).Return(&model.PayoutBatch{ID: batchID, Status: model.PayoutBatchStatusOpen}, nil).Once()
+
+		_, err := f.service.PayoutFile(ctx, batchID)
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusBadRequest, httpErr.Status)
+		f.payouts.AssertNotCalled(t, "ListPayouts", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,8 @@
+package service
+
+import "time"
+
+// SetClock replaces the clock nearby driver positions are jittered by
+func (s *LocationService) SetClock(now func() time.Time) {
+	s.now = now
+}
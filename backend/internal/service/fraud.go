@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/push"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+)
+
+const (
+	// Redis key prefix of the last live fix of a driver, the reference for plausibility checks
+	driverLastFixPrefix = "driver:last_fix:"
+	// A driver offline for longer than this starts over without a reference fix
+	driverLastFixTTL = time.Hour
+	// Moving further than suddenJumpKm within suddenJumpWindow without any update in between is
+	// suspicious even at a possible speed, live updates arrive every few seconds
+	suddenJumpKm     = 5.0
+	suddenJumpWindow = 5 * time.Minute
+	// A reference fix that rejected this many updates in a row, or kept rejecting them for this
+	// long, is replaced by the newest fix. A bad reference, e.g. from a GPS glitch or a device
+	// swap, would otherwise reject every update of the driver until it expires.
+	reanchorAfterRejections = 3
+	reanchorAfter           = 2 * time.Minute
+	// Websocket event type alerting admins about a driver crossing the fraud threshold
+	eventDriverFraudAlert = "driver_fraud_alert"
+	defaultFraudListLimit = 20
+)
+
+// Fraud score added per flag
+var locationFlagScores = map[model.LocationFlagReason]int{
+	model.FlagImpossibleSpeed: 10,
+	model.FlagSuddenJump:      5,
+	model.FlagMockLocation:    25,
+}
+
+var codeLocationRejected = "LOCATION_REJECTED"
+
+// FraudService detects spoofed driver locations, keeps a per-driver fraud score and alerts
+// admins when a driver's score reaches the configured threshold
+type FraudService struct {
+	server              *server.Server
+	repo                *repository.Repositories
+	cfg                 *config.LocationConfig
+	notificationService *NotificationService
+}
+
+func NewFraudService(s *server.Server, repo *repository.Repositories, cfg *config.LocationConfig, notificationService *NotificationService) *FraudService {
+	return &FraudService{
+		server:              s,
+		repo:                repo,
+		cfg:                 cfg,
+		notificationService: notificationService,
+	}
+}
+
+// referenceFix is the driver's last accepted live fix with the impossible moves rejected against it since
+type referenceFix struct {
+	fix           model.LocationFix
+	rejections    int
+	rejectedSince time.Time
+}
+
+// InspectLiveFix checks a live fix against the driver's previous position and the client's mock
+// flag. Suspicious fixes are recorded; the returned flag says whether the fix must be rejected.
+// Only the first impossible move against a reference counts towards the fraud score, the moves
+// rejected after it are recorded without a score.
+func (s *FraudService) InspectLiveFix(ctx context.Context, driverID string, fix model.LocationFix) *model.LocationFlag {
+	var flag *model.LocationFlag
+	if fix.IsMock {
+		flag = newLocationFlag(model.FlagMockLocation, fix)
+	} else if ref := s.reference(ctx, driverID); ref != nil {
+		flag = assessMove(ref.fix, fix)
+		if flag != nil && flag.Reason == model.FlagImpossibleSpeed {
+			if ref.rejections > 0 {
+				flag.Score = 0
+			}
+			s.noteRejection(ctx, driverID, ref, fix)
+		}
+	}
+	if flag == nil {
+		return nil
+	}
+
+	flag.DriverUserID = driverID
+	s.Record(ctx, flag)
+	return flag
+}
+
+// assessMove flags a move from prev to fix that no driver could make, or that skipped all the
+// updates a moving driver would have sent
+func assessMove(prev, fix model.LocationFix) *model.LocationFlag {
+	elapsed := fix.RecordedAt.Sub(prev.RecordedAt)
+	if elapsed < 0 {
+		return nil
+	}
+	distance := calculateDistance(prev.Location, fix.Location)
+
+	var flag *model.LocationFlag
+	switch {
+	case !plausibleMove(distance, elapsed):
+		flag = newLocationFlag(model.FlagImpossibleSpeed, fix)
+	case distance > suddenJumpKm && elapsed < suddenJumpWindow:
+		flag = newLocationFlag(model.FlagSuddenJump, fix)
+	default:
+		return nil
+	}
+
+	seconds := elapsed.Seconds()
+	flag.PreviousLocation = &prev.Location
+	flag.DistanceKm = &distance
+	flag.ElapsedSeconds = &seconds
+	if elapsed > 0 {
+		speed := distance / elapsed.Hours()
+		flag.ImpliedSpeedKmh = &speed
+	}
+	return flag
+}
+
+func newLocationFlag(reason model.LocationFlagReason, fix model.LocationFix) *model.LocationFlag {
+	return &model.LocationFlag{
+		Reason:     reason,
+		Rejected:   reason != model.FlagSuddenJump,
+		Score:      locationFlagScores[reason],
+		Location:   fix.Location,
+		RecordedAt: fix.RecordedAt,
+	}
+}
+
+// Record stores a flag and alerts admins when it takes the driver's score to the threshold.
+// Failures are logged only, fraud bookkeeping never fails a location update.
+func (s *FraudService) Record(ctx context.Context, flag *model.LocationFlag) {
+	s.server.Logger.Warn().
+		Str("driver_id", flag.DriverUserID).
+		Str("reason", string(flag.Reason)).
+		Bool("rejected", flag.Rejected).
+		Msg("Suspicious driver location")
+
+	// The score comes back with the flag, the driver's flags are stored one at a time so concurrent
+	// flags never both see the threshold crossing
+	score, err := s.repo.LocationFlag.Create(ctx, flag, time.Now().Add(-s.cfg.FraudScoreWindow))
+	if err != nil {
+		s.server.Logger.Error().Err(err).Str("driver_id", flag.DriverUserID).Msg("Failed to store location flag")
+		return
+	}
+
+	// Alert once when the threshold is crossed, not on every flag above it
+	if score >= s.cfg.FraudAlertThreshold && score-flag.Score < s.cfg.FraudAlertThreshold {
+		s.alertAdmins(ctx, model.DriverFraudAlert{
+			DriverUserID: flag.DriverUserID,
+			Score:        score,
+			Threshold:    s.cfg.FraudAlertThreshold,
+			Reason:       flag.Reason,
+			Timestamp:    time.Now().UTC(),
+		})
+	}
+}
+
+func (s *FraudService) alertAdmins(ctx context.Context, alert model.DriverFraudAlert) {
+	s.server.Logger.Warn().
+		Str("driver_id", alert.DriverUserID).
+		Int("score", alert.Score).
+		Msg("Driver fraud score crossed alert threshold")
+
+	admins, err := s.repo.User.ListIDsByRole(ctx, model.RoleAdmin)
+	if err != nil {
+		s.server.Logger.Error().Err(err).Msg("Failed to list admins for fraud alert")
+		return
+	}
+
+	for _, adminID := range admins {
+		s.server.Hub.BroadcastToUser(adminID, eventDriverFraudAlert, alert)
+		if s.notificationService != nil {
+			s.notificationService.NotifyIfOffline(ctx, adminID, push.Notification{
+				Title: "Possible GPS spoofing",
+				Body:  fmt.Sprintf("Driver %s reached fraud score %d", alert.DriverUserID, alert.Score),
+				Data:  map[string]string{"type": eventDriverFraudAlert, "driver_user_id": alert.DriverUserID},
+			})
+		}
+	}
+}
+
+// LastFix returns the driver's last live fix, nil when unknown or redis is unavailable
+func (s *FraudService) LastFix(ctx context.Context, driverID string) *model.LocationFix {
+	ref := s.reference(ctx, driverID)
+	if ref == nil {
+		return nil
+	}
+	return &ref.fix
+}
+
+func (s *FraudService) reference(ctx context.Context, driverID string) *referenceFix {
+	if !s.server.RedisAvailable() {
+		return nil
+	}
+	values, err := s.server.Redis.HGetAll(ctx, driverLastFixPrefix+driverID).Result()
+	if err != nil || len(values) == 0 {
+		return nil
+	}
+
+	lat, latErr := strconv.ParseFloat(values["lat"], 64)
+	lng, lngErr := strconv.ParseFloat(values["lng"], 64)
+	at, atErr := strconv.ParseInt(values["t"], 10, 64)
+	if latErr != nil || lngErr != nil || atErr != nil {
+		return nil
+	}
+	ref := &referenceFix{
+		fix: model.LocationFix{
+			Location:   model.Location{Latitude: lat, Longitude: lng},
+			RecordedAt: time.UnixMilli(at).UTC(),
+		},
+	}
+	// Missing counters mean no rejection since the fix was stored
+	ref.rejections, _ = strconv.Atoi(values["rejections"])
+	if since, err := strconv.ParseInt(values["rejected_since"], 10, 64); err == nil {
+		ref.rejectedSince = time.UnixMilli(since).UTC()
+	}
+	return ref
+}
+
+// noteRejection counts an impossible move against the reference, or makes the rejected fix the
+// new reference once the old one has rejected too many updates or for too long
+func (s *FraudService) noteRejection(ctx context.Context, driverID string, ref *referenceFix, fix model.LocationFix) {
+	rejections := ref.rejections + 1
+	since := ref.rejectedSince
+	if ref.rejections == 0 {
+		since = fix.RecordedAt
+	}
+	if rejections >= reanchorAfterRejections || fix.RecordedAt.Sub(since) >= reanchorAfter {
+		s.server.Logger.Warn().
+			Str("driver_id", driverID).
+			Int("rejections", rejections).
+			Msg("Replacing driver reference fix after repeated rejections")
+		s.RememberFix(ctx, driverID, fix)
+		return
+	}
+
+	err := s.server.Redis.HSet(ctx, driverLastFixPrefix+driverID,
+		"rejections", strconv.Itoa(rejections),
+		"rejected_since", strconv.FormatInt(since.UnixMilli(), 10),
+	).Err()
+	if err != nil {
+		s.server.Logger.Error().Err(err).Str("driver_id", driverID).Msg("Failed to count rejected driver fix")
+	}
+}
+
+// RememberFix stores the driver's newest live fix as the reference for the next check and clears
+// the rejections counted against the previous one
+func (s *FraudService) RememberFix(ctx context.Context, driverID string, fix model.LocationFix) {
+	if !s.server.RedisAvailable() {
+		return
+	}
+	key := driverLastFixPrefix + driverID
+	pipe := s.server.Redis.TxPipeline()
+	pipe.HSet(ctx, key,
+		"lat", strconv.FormatFloat(fix.Location.Latitude, 'f', -1, 64),
+		"lng", strconv.FormatFloat(fix.Location.Longitude, 'f', -1, 64),
+		"t", strconv.FormatInt(fix.RecordedAt.UnixMilli(), 10),
+	)
+	pipe.HDel(ctx, key, "rejections", "rejected_since")
+	pipe.Expire(ctx, key, driverLastFixTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.server.Logger.Error().Err(err).Str("driver_id", driverID).Msg("Failed to store last driver fix")
+	}
+}
+
+// TopScores lists the drivers with the highest fraud score in the scoring window
+func (s *FraudService) TopScores(ctx context.Context, req *model.ListFraudScoresRequest) ([]model.DriverFraudScore, error) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultFraudListLimit
+	}
+	scores, err := s.repo.LocationFlag.TopScores(ctx, time.Now().Add(-s.cfg.FraudScoreWindow), limit)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list fraud scores")
+	}
+	if scores == nil {
+		scores = []model.DriverFraudScore{}
+	}
+	return scores, nil
+}
+
+// ListFlags returns the most recent location flags of a driver
+func (s *FraudService) ListFlags(ctx context.Context, req *model.ListLocationFlagsRequest) ([]*model.LocationFlag, error) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultFraudListLimit
+	}
+	flags, err := s.repo.LocationFlag.ListByDriver(ctx, req.DriverID, limit)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list location flags")
+	}
+	if flags == nil {
+		flags = []*model.LocationFlag{}
+	}
+	return flags, nil
+}
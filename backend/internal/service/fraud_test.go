@@ -0,0 +1,111 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocationSpoofingDetection(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	logger := zerolog.Nop()
+	srv := &server.Server{Logger: &logger, Redis: redisClient}
+
+	mockFlagRepo := new(testutil.MockLocationFlagRepository)
+	repos := &repository.Repositories{LocationFlag: mockFlagRepo}
+	cfg := config.DefaultLocationConfig()
+	fraudService := service.NewFraudService(srv, repos, cfg, nil)
+	locationService := service.NewLocationService(srv, repos, nil, fraudService)
+
+	ctx := context.Background()
+	driverID := "5a0c7d8e-2f7b-4a1e-8e55-6c2f1b9d3a47"
+	update := func(lat, lng float64, mocked bool) error {
+		return locationService.UpdateDriverLocation(ctx, &model.LocationUpdate{
+			DriverID: driverID,
+			Location: model.Location{Latitude: lat, Longitude: lng},
+			IsMock:   mocked,
+		})
+	}
+	expectScoredFlag := func(reason model.LocationFlagReason, rejected bool, score int) {
+		mockFlagRepo.On("Create", mock.Anything, mock.MatchedBy(func(f *model.LocationFlag) bool {
+			return f.Reason == reason && f.Rejected == rejected && f.DriverUserID == driverID && f.Score == score
+		}), mock.Anything).Return(10, nil).Once()
+	}
+	expectFlag := func(reason model.LocationFlagReason, rejected bool) {
+		mockFlagRepo.On("Create", mock.Anything, mock.MatchedBy(func(f *model.LocationFlag) bool {
+			return f.Reason == reason && f.Rejected == rejected && f.DriverUserID == driverID
+		}), mock.Anything).Return(10, nil).Once()
+	}
+	assertRejected := func(err error) {
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, "LOCATION_REJECTED", httpErr.Code)
+	}
+
+	// The first update has nothing to compare against
+	require.NoError(t, update(12.9716, 77.5946, false))
+
+	t.Run("Mock location is rejected", func(t *testing.T) {
+		expectFlag(model.FlagMockLocation, true)
+		assertRejected(update(12.9720, 77.5950, true))
+	})
+
+	t.Run("Teleport is rejected and keeps the previous reference", func(t *testing.T) {
+		// Bengaluru to Chennai within a second
+		expectFlag(model.FlagImpossibleSpeed, true)
+		assertRejected(update(13.0827, 80.2707, false))
+
+		pos, err := redisClient.GeoPos(ctx, "drivers:geo", driverID).Result()
+		require.NoError(t, err)
+		assert.InDelta(t, 12.9716, pos[0].Latitude, 0.0001)
+	})
+
+	t.Run("Plausible move is accepted", func(t *testing.T) {
+		require.NoError(t, update(12.9716, 77.5946, false))
+	})
+
+	t.Run("Reference rejecting every update is replaced", func(t *testing.T) {
+		// The stored reference is wrong and the driver really is in Chennai. Only the first
+		// rejection is scored, the third one makes Chennai the reference.
+		expectScoredFlag(model.FlagImpossibleSpeed, true, 10)
+		assertRejected(update(13.0827, 80.2707, false))
+		expectScoredFlag(model.FlagImpossibleSpeed, true, 0)
+		assertRejected(update(13.0828, 80.2708, false))
+		expectScoredFlag(model.FlagImpossibleSpeed, true, 0)
+		assertRejected(update(13.0829, 80.2709, false))
+
+		require.NoError(t, update(13.0829, 80.2709, false))
+	})
+
+	t.Run("Reference rejecting updates for long is replaced", func(t *testing.T) {
+		expectScoredFlag(model.FlagImpossibleSpeed, true, 10)
+		assertRejected(update(12.9716, 77.5946, false))
+		// The first rejection happened a while ago
+		key := "driver:last_fix:" + driverID
+		require.NoError(t, redisClient.HSet(ctx, key, "rejected_since", time.Now().Add(-3*time.Minute).UnixMilli()).Err())
+		expectScoredFlag(model.FlagImpossibleSpeed, true, 0)
+		assertRejected(update(12.9717, 77.5947, false))
+
+		require.NoError(t, update(12.9717, 77.5947, false))
+	})
+
+	mockFlagRepo.AssertExpectations(t)
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/job"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+)
+
+// New Relic metric names, one data point per correction type and run
+const (
+	metricGeoRidesAdded     = "Custom/GeoIndex/RidesAdded"
+	metricGeoRidesRemoved   = "Custom/GeoIndex/RidesRemoved"
+	metricGeoDriversEvicted = "Custom/GeoIndex/DriversEvicted"
+)
+
+// GeoReconcileResult counts the corrections made by one reconciliation run
+type GeoReconcileResult struct {
+	RidesAdded     int `json:"rides_added"`
+	RidesRemoved   int `json:"rides_removed"`
+	DriversEvicted int `json:"drivers_evicted"`
+}
+
+// GeoIndexReconciler repairs the redis geo indexes that are updated fire-and-forget:
+// rides:requested is rebuilt from the requested rides in the database and drivers whose
+// online key expired are evicted from drivers:geo.
+type GeoIndexReconciler struct {
+	server *server.Server
+	repo   *repository.Repositories
+}
+
+func NewGeoIndexReconciler(s *server.Server, repo *repository.Repositories) *GeoIndexReconciler {
+	return &GeoIndexReconciler{
+		server: s,
+		repo:   repo,
+	}
+}
+
+// Register schedules the reconciliation job
+func (g *GeoIndexReconciler) Register() error {
+	g.server.Job.HandleFunc(job.TaskGeoIndexReconcile, g.handleReconcileTask)
+	if err := g.server.Job.Schedule(g.server.Config.Location.GeoReconcileSchedule, job.NewGeoIndexReconcileTask()); err != nil {
+		return fmt.Errorf("failed to schedule geo index reconciliation: %w", err)
+	}
+	return nil
+}
+
+// Reconcile runs both repairs and records a metric for every kind of correction
+func (g *GeoIndexReconciler) Reconcile(ctx context.Context) (*GeoReconcileResult, error) {
+	result := &GeoReconcileResult{}
+	if !g.server.RedisAvailable() {
+		// Nothing reads the indexes in degraded mode, the first run after recovery repairs them
+		return result, nil
+	}
+
+	if err := g.reconcileRequestedRides(ctx, result); err != nil {
+		return result, err
+	}
+	if err := g.evictOfflineDrivers(ctx, result); err != nil {
+		return result, err
+	}
+
+	g.recordMetrics(result)
+	if result.RidesAdded+result.RidesRemoved+result.DriversEvicted > 0 {
+		g.server.Logger.Info().
+			Int("rides_added", result.RidesAdded).
+			Int("rides_removed", result.RidesRemoved).
+			Int("drivers_evicted", result.DriversEvicted).
+			Msg("Corrected drifted geo indexes")
+	}
+	return result, nil
+}
+
+// reconcileRequestedRides makes rides:requested hold exactly the requested rides of the database.
+// The index is read before the database, so a ride created in between is re-added rather than removed.
+func (g *GeoIndexReconciler) reconcileRequestedRides(ctx context.Context, result *GeoReconcileResult) error {
+	indexed, err := g.server.Redis.ZRange(ctx, rideRequestedGeoKey, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", rideRequestedGeoKey, err)
+	}
+
+	pickups, err := g.repo.Ride.ListRequestedPickups(ctx)
+	if err != nil {
+		return err
+	}
+
+	var stale []interface{}
+	for _, rideID := range indexed {
+		if _, ok := pickups[rideID]; !ok {
+			stale = append(stale, rideID)
+		}
+	}
+	if len(stale) > 0 {
+		removed, err := g.server.Redis.ZRem(ctx, rideRequestedGeoKey, stale...).Result()
+		if err != nil {
+			return fmt.Errorf("failed to remove stale rides: %w", err)
+		}
+		result.RidesRemoved = int(removed)
+	}
+
+	if len(pickups) > 0 {
+		locations := make([]*redis.GeoLocation, 0, len(pickups))
+		for rideID, pickup := range pickups {
+			locations = append(locations, &redis.GeoLocation{
+				Name:      rideID,
+				Longitude: pickup.Longitude,
+				Latitude:  pickup.Latitude,
+			})
+		}
+		// GEOADD only counts new members, rides already indexed just get their position refreshed
+		added, err := g.server.Redis.GeoAdd(ctx, rideRequestedGeoKey, locations...).Result()
+		if err != nil {
+			return fmt.Errorf("failed to add missing rides: %w", err)
+		}
+		result.RidesAdded = int(added)
+	}
+
+	return nil
+}
+
+// evictOfflineDrivers removes drivers from drivers:geo once their online key has expired
+func (g *GeoIndexReconciler) evictOfflineDrivers(ctx context.Context, result *GeoReconcileResult) error {
+	drivers, err := g.server.Redis.ZRange(ctx, driverGeoKey, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", driverGeoKey, err)
+	}
+	if len(drivers) == 0 {
+		return nil
+	}
+
+	pipe := g.server.Redis.Pipeline()
+	online := make([]*redis.IntCmd, len(drivers))
+	for i, driverID := range drivers {
+		online[i] = pipe.Exists(ctx, driverOnlinePrefix+driverID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to check driver online keys: %w", err)
+	}
+
+	var offline []interface{}
+	for i, driverID := range drivers {
+		if online[i].Val() == 0 {
+			offline = append(offline, driverID)
+		}
+	}
+	if len(offline) == 0 {
+		return nil
+	}
+
+	evicted, err := g.server.Redis.ZRem(ctx, driverGeoKey, offline...).Result()
+	if err != nil {
+		return fmt.Errorf("failed to evict offline drivers: %w", err)
+	}
+	result.DriversEvicted = int(evicted)
+	return nil
+}
+
+func (g *GeoIndexReconciler) recordMetrics(result *GeoReconcileResult) {
+	if g.server.LoggerService == nil || g.server.LoggerService.GetApplication() == nil {
+		return
+	}
+	app := g.server.LoggerService.GetApplication()
+	app.RecordCustomMetric(metricGeoRidesAdded, float64(result.RidesAdded))
+	app.RecordCustomMetric(metricGeoRidesRemoved, float64(result.RidesRemoved))
+	app.RecordCustomMetric(metricGeoDriversEvicted, float64(result.DriversEvicted))
+}
+
+func (g *GeoIndexReconciler) handleReconcileTask(ctx context.Context, t *asynq.Task) error {
+	if _, err := g.Reconcile(ctx); err != nil {
+		g.server.Logger.Error().Err(err).Msg("Geo index reconciliation failed")
+		return err
+	}
+	return nil
+}
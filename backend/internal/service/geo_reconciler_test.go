@@ -0,0 +1,64 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGeoIndexReconcile(t *testing.T) {
+	mr, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer mr.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	logger := zerolog.Nop()
+	srv := &server.Server{Logger: &logger, Redis: redisClient}
+
+	mockRideRepo := new(testutil.MockRideRepository)
+	reconciler := service.NewGeoIndexReconciler(srv, &repository.Repositories{Ride: mockRideRepo})
+	ctx := context.Background()
+
+	// ride-1 is indexed and still requested, ride-2 was accepted but never removed,
+	// ride-3 is requested but its GEOADD was lost
+	redisClient.GeoAdd(ctx, "rides:requested",
+		&redis.GeoLocation{Name: "ride-1", Latitude: 12.97, Longitude: 77.59},
+		&redis.GeoLocation{Name: "ride-2", Latitude: 12.98, Longitude: 77.60},
+	)
+	mockRideRepo.On("ListRequestedPickups", mock.Anything).Return(map[string]model.Location{
+		"ride-1": {Latitude: 12.97, Longitude: 77.59},
+		"ride-3": {Latitude: 12.93, Longitude: 77.62},
+	}, nil)
+
+	// driver-1 is online, driver-2's online key expired
+	redisClient.GeoAdd(ctx, "drivers:geo",
+		&redis.GeoLocation{Name: "driver-1", Latitude: 12.97, Longitude: 77.59},
+		&redis.GeoLocation{Name: "driver-2", Latitude: 12.96, Longitude: 77.58},
+	)
+	redisClient.Set(ctx, "driver:online:driver-1", "1", 30*time.Second)
+
+	result, err := reconciler.Reconcile(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &service.GeoReconcileResult{RidesAdded: 1, RidesRemoved: 1, DriversEvicted: 1}, result)
+
+	rides, _ := redisClient.ZRange(ctx, "rides:requested", 0, -1).Result()
+	assert.ElementsMatch(t, []string{"ride-1", "ride-3"}, rides)
+	drivers, _ := redisClient.ZRange(ctx, "drivers:geo", 0, -1).Result()
+	assert.Equal(t, []string{"driver-1"}, drivers)
+
+	// A second run finds nothing to correct
+	result, err = reconciler.Reconcile(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &service.GeoReconcileResult{}, result)
+}
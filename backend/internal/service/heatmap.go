@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/job"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+)
+
+const (
+	// Edge of a square heatmap cell in degrees, about 1.1km north-south
+	heatmapCellDeg = 0.01
+	// Redis key prefix of the cached stats of one cell, and the set of cells written by the last refresh
+	heatmapCellPrefix   = "heatmap:cell:"
+	heatmapCellsKey     = "heatmap:cells"
+	heatmapGeneratedKey = "heatmap:generated_at"
+	// Cells outlive a few missed refreshes, then disappear instead of showing stale demand
+	heatmapCellTTL = 10 * time.Minute
+	// Radius of a heatmap request without radius_km
+	defaultHeatmapRadiusKm = 3.0
+	// Recent requests count half as much as requests still waiting for a driver
+	recentDemandWeight = 0.5
+	// Each unit of demand per available driver above one adds this much surge, up to maxSurgeMultiplier
+	surgeStep          = 0.25
+	maxSurgeMultiplier = 2.5
+)
+
+// HeatmapService aggregates ride demand into a grid of square cells so drivers can see where rides
+// are requested. A periodic job rebuilds the cells into redis, requests only read the cells around
+// the driver. Surge multipliers are advisory and do not change fares.
+type HeatmapService struct {
+	server *server.Server
+	repo   *repository.Repositories
+	cfg    *config.LocationConfig
+}
+
+func NewHeatmapService(s *server.Server, repo *repository.Repositories, cfg *config.LocationConfig) *HeatmapService {
+	return &HeatmapService{
+		server: s,
+		repo:   repo,
+		cfg:    cfg,
+	}
+}
+
+// Register schedules the heatmap refresh job
+func (h *HeatmapService) Register() error {
+	h.server.Job.HandleFunc(job.TaskHeatmapRefresh, h.handleRefreshTask)
+	if err := h.server.Job.Schedule(h.cfg.HeatmapRefreshSchedule, job.NewHeatmapRefreshTask()); err != nil {
+		return fmt.Errorf("failed to schedule heatmap refresh: %w", err)
+	}
+	return nil
+}
+
+// Refresh rebuilds the cached cells from the open requests in rides:requested, the rides requested
+// within the history window and the available drivers in drivers:geo. It returns the number of
+// cells with demand.
+func (h *HeatmapService) Refresh(ctx context.Context) (int, error) {
+	if !h.server.RedisAvailable() {
+		// The cache cannot be written, the first run after recovery rebuilds it
+		return 0, nil
+	}
+
+	stats := make(map[model.GridCell]*model.HeatmapCellStats)
+	cellStats := func(cell model.GridCell) *model.HeatmapCellStats {
+		if stats[cell] == nil {
+			stats[cell] = &model.HeatmapCellStats{}
+		}
+		return stats[cell]
+	}
+
+	open, err := h.indexedCells(ctx, rideRequestedGeoKey)
+	if err != nil {
+		return 0, err
+	}
+	for cell, count := range open {
+		cellStats(cell).OpenRequests = count
+	}
+
+	recent, err := h.repo.Ride.CountRequestsByCell(ctx, time.Now().Add(-h.cfg.HeatmapHistoryWindow), heatmapCellDeg)
+	if err != nil {
+		return 0, err
+	}
+	for cell, count := range recent {
+		cellStats(cell).RecentRequests = count
+	}
+
+	drivers, err := h.indexedCells(ctx, driverGeoKey)
+	if err != nil {
+		return 0, err
+	}
+	// Supply only matters where there is demand
+	for cell, count := range drivers {
+		if s, ok := stats[cell]; ok {
+			s.AvailableDrivers = count
+		}
+	}
+
+	if err := h.storeCells(ctx, stats); err != nil {
+		return 0, err
+	}
+	return len(stats), nil
+}
+
+// indexedCells counts the members of a geo index per cell
+func (h *HeatmapService) indexedCells(ctx context.Context, key string) (map[model.GridCell]int, error) {
+	members, err := h.server.Redis.ZRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	counts := make(map[model.GridCell]int)
+	if len(members) == 0 {
+		return counts, nil
+	}
+
+	positions, err := h.server.Redis.GeoPos(ctx, key, members...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read positions from %s: %w", key, err)
+	}
+	for _, pos := range positions {
+		if pos == nil {
+			continue
+		}
+		counts[cellOf(model.Location{Latitude: pos.Latitude, Longitude: pos.Longitude})]++
+	}
+	return counts, nil
+}
+
+// storeCells scores the cells, writes them and deletes the cells of the previous run that have no
+// demand any more
+func (h *HeatmapService) storeCells(ctx context.Context, stats map[model.GridCell]*model.HeatmapCellStats) error {
+	previous, err := h.server.Redis.SMembers(ctx, heatmapCellsKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read heatmap cells: %w", err)
+	}
+
+	pipe := h.server.Redis.TxPipeline()
+	current := make(map[string]bool, len(stats))
+	ids := make([]interface{}, 0, len(stats))
+	for cell, s := range stats {
+		scoreCell(s)
+		data, err := json.Marshal(s)
+		if err != nil {
+			continue
+		}
+		id := cellID(cell)
+		current[id] = true
+		ids = append(ids, id)
+		pipe.Set(ctx, heatmapCellPrefix+id, data, heatmapCellTTL)
+	}
+	for _, id := range previous {
+		if !current[id] {
+			pipe.Del(ctx, heatmapCellPrefix+id)
+		}
+	}
+	pipe.Del(ctx, heatmapCellsKey)
+	if len(ids) > 0 {
+		pipe.SAdd(ctx, heatmapCellsKey, ids...)
+		pipe.Expire(ctx, heatmapCellsKey, heatmapCellTTL)
+	}
+	pipe.Set(ctx, heatmapGeneratedKey, time.Now().UTC().Format(time.RFC3339), heatmapCellTTL)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store heatmap cells: %w", err)
+	}
+	return nil
+}
+
+// GetHeatmap returns the cells with demand around a point as GeoJSON. The cache is built on the
+// first request when the refresh job has not run yet.
+func (h *HeatmapService) GetHeatmap(ctx context.Context, req *model.HeatmapRequest) (*model.HeatmapResponse, error) {
+	if !h.server.RedisAvailable() {
+		return nil, errs.NewServiceUnavailableError("demand heatmap is unavailable while the service is degraded", true)
+	}
+
+	generatedAt, err := h.generatedAt(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	radiusKm := req.RadiusKm
+	if radiusKm == 0 {
+		radiusKm = defaultHeatmapRadiusKm
+	}
+	center := model.Location{Latitude: req.Latitude, Longitude: req.Longitude}
+	latSpan := radiusKm * 1000 / metersPerDegreeLat
+	lngSpan := radiusKm * 1000 / (metersPerDegreeLat * math.Cos(center.Latitude*math.Pi/180))
+	minCell := cellOf(model.Location{Latitude: center.Latitude - latSpan, Longitude: center.Longitude - lngSpan})
+	maxCell := cellOf(model.Location{Latitude: center.Latitude + latSpan, Longitude: center.Longitude + lngSpan})
+
+	var cells []model.GridCell
+	var keys []string
+	for row := minCell.Row; row <= maxCell.Row; row++ {
+		for col := minCell.Col; col <= maxCell.Col; col++ {
+			cell := model.GridCell{Row: row, Col: col}
+			cells = append(cells, cell)
+			keys = append(keys, heatmapCellPrefix+cellID(cell))
+		}
+	}
+
+	values, err := h.server.Redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to read heatmap cells")
+	}
+
+	resp := &model.HeatmapResponse{
+		Type:        "FeatureCollection",
+		Features:    []model.HeatmapFeature{},
+		GeneratedAt: generatedAt,
+	}
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var stats model.HeatmapCellStats
+		if err := json.Unmarshal([]byte(raw), &stats); err != nil {
+			continue
+		}
+		resp.Features = append(resp.Features, model.HeatmapFeature{
+			Type:       "Feature",
+			ID:         cellID(cells[i]),
+			Geometry:   cellPolygon(cells[i]),
+			Properties: stats,
+		})
+	}
+	return resp, nil
+}
+
+// generatedAt returns when the cells were last built, building them now if they never were
+func (h *HeatmapService) generatedAt(ctx context.Context) (time.Time, error) {
+	value, err := h.server.Redis.Get(ctx, heatmapGeneratedKey).Result()
+	if err == nil {
+		if at, err := time.Parse(time.RFC3339, value); err == nil {
+			return at, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		return time.Time{}, errs.Wrap(err, "failed to read heatmap generation time")
+	}
+
+	if _, err := h.Refresh(ctx); err != nil {
+		return time.Time{}, errs.Wrap(err, "failed to build heatmap")
+	}
+	return time.Now().UTC().Truncate(time.Second), nil
+}
+
+func (h *HeatmapService) handleRefreshTask(ctx context.Context, t *asynq.Task) error {
+	cells, err := h.Refresh(ctx)
+	if err != nil {
+		h.server.Logger.Error().Err(err).Msg("Heatmap refresh failed")
+		return err
+	}
+	h.server.Logger.Debug().Int("cells", cells).Msg("Refreshed demand heatmap")
+	return nil
+}
+
+// scoreCell sets the demand score and the surge multiplier of a cell from its counts
+func scoreCell(s *model.HeatmapCellStats) {
+	demand := float64(s.OpenRequests) + recentDemandWeight*float64(s.RecentRequests)
+	s.DemandScore = math.Round(demand*10) / 10
+
+	surge := 1.0
+	if perDriver := demand / math.Max(float64(s.AvailableDrivers), 1); perDriver > 1 {
+		surge = math.Min(1+(perDriver-1)*surgeStep, maxSurgeMultiplier)
+	}
+	s.SurgeMultiplier = math.Round(surge*10) / 10
+}
+
+func cellOf(loc model.Location) model.GridCell {
+	return model.GridCell{
+		Row: int(math.Floor(loc.Latitude / heatmapCellDeg)),
+		Col: int(math.Floor(loc.Longitude / heatmapCellDeg)),
+	}
+}
+
+func cellID(cell model.GridCell) string {
+	return strconv.Itoa(cell.Row) + ":" + strconv.Itoa(cell.Col)
+}
+
+// cellPolygon returns the cell outline as a closed, counter-clockwise ring
+func cellPolygon(cell model.GridCell) model.GeoJSONPolygon {
+	// Rounded to drop the float noise of multiplying by the cell size
+	edge := func(i int) float64 { return math.Round(float64(i)*heatmapCellDeg*1e6) / 1e6 }
+	minLat, maxLat := edge(cell.Row), edge(cell.Row+1)
+	minLng, maxLng := edge(cell.Col), edge(cell.Col+1)
+	return model.GeoJSONPolygon{
+		Type: "Polygon",
+		Coordinates: [][][2]float64{{
+			{minLng, minLat},
+			{maxLng, minLat},
+			{maxLng, maxLat},
+			{minLng, maxLat},
+			{minLng, minLat},
+		}},
+	}
+}
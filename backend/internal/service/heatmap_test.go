@@ -0,0 +1,109 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeatmapService(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	logger := zerolog.Nop()
+	srv := &server.Server{Logger: &logger, Redis: redisClient}
+
+	mockRideRepo := new(testutil.MockRideRepository)
+	repos := &repository.Repositories{Ride: mockRideRepo}
+	heatmapService := service.NewHeatmapService(srv, repos, config.DefaultLocationConfig())
+	ctx := context.Background()
+
+	// Cell 1297:7759 has three open requests and one driver, cell 1298:7760 only past demand
+	require.NoError(t, redisClient.GeoAdd(ctx, "rides:requested",
+		&redis.GeoLocation{Name: "ride-1", Latitude: 12.9716, Longitude: 77.5946},
+		&redis.GeoLocation{Name: "ride-2", Latitude: 12.9720, Longitude: 77.5950},
+		&redis.GeoLocation{Name: "ride-3", Latitude: 12.9730, Longitude: 77.5960},
+	).Err())
+	require.NoError(t, redisClient.GeoAdd(ctx, "drivers:geo",
+		&redis.GeoLocation{Name: "driver-1", Latitude: 12.9750, Longitude: 77.5970},
+	).Err())
+	mockRideRepo.On("CountRequestsByCell", mock.Anything, mock.Anything, 0.01).Return(map[model.GridCell]int{
+		{Row: 1297, Col: 7759}: 3,
+		{Row: 1298, Col: 7760}: 2,
+	}, nil)
+
+	req := &model.HeatmapRequest{Latitude: 12.9716, Longitude: 77.5946}
+
+	t.Run("First request builds the cells", func(t *testing.T) {
+		resp, err := heatmapService.GetHeatmap(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, "FeatureCollection", resp.Type)
+		require.Len(t, resp.Features, 2)
+
+		busy := resp.Features[0]
+		assert.Equal(t, "1297:7759", busy.ID)
+		assert.Equal(t, model.HeatmapCellStats{
+			OpenRequests:     3,
+			RecentRequests:   3,
+			AvailableDrivers: 1,
+			DemandScore:      4.5,
+			SurgeMultiplier:  1.9,
+		}, busy.Properties)
+
+		ring := busy.Geometry.Coordinates[0]
+		assert.Equal(t, "Polygon", busy.Geometry.Type)
+		assert.Len(t, ring, 5)
+		assert.Equal(t, ring[0], ring[4])
+		assert.Equal(t, [2]float64{77.59, 12.97}, ring[0])
+
+		quiet := resp.Features[1]
+		assert.Equal(t, "1298:7760", quiet.ID)
+		assert.Equal(t, 1.0, quiet.Properties.DemandScore)
+		assert.Equal(t, 1.0, quiet.Properties.SurgeMultiplier)
+	})
+
+	t.Run("Cells without demand are dropped on refresh", func(t *testing.T) {
+		mockRideRepo.ExpectedCalls = nil
+		mockRideRepo.On("CountRequestsByCell", mock.Anything, mock.Anything, 0.01).Return(map[model.GridCell]int{
+			{Row: 1297, Col: 7759}: 3,
+		}, nil)
+
+		cells, err := heatmapService.Refresh(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, cells)
+		assert.False(t, mr.Exists("heatmap:cell:1298:7760"))
+
+		resp, err := heatmapService.GetHeatmap(ctx, req)
+		require.NoError(t, err)
+		assert.Len(t, resp.Features, 1)
+	})
+
+	t.Run("Cells outside the radius are not returned", func(t *testing.T) {
+		resp, err := heatmapService.GetHeatmap(ctx, &model.HeatmapRequest{Latitude: 13.5, Longitude: 77.5946})
+		require.NoError(t, err)
+		assert.Empty(t, resp.Features)
+	})
+
+	t.Run("Unavailable without redis", func(t *testing.T) {
+		degraded := service.NewHeatmapService(&server.Server{Logger: &logger}, repos, config.DefaultLocationConfig())
+		_, err := degraded.GetHeatmap(ctx, req)
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, 503, httpErr.Status)
+	})
+}
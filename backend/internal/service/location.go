@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
@@ -23,50 +24,208 @@ const (
 	defaultSearchRadiusKm = 5.0
 	// Default limit for nearby drivers
 	defaultNearbyDriverLimit = 20
+	// Redis key prefix mapping a driver (user id) to the ride they are currently serving
+	driverActiveRidePrefix = "driver:active_ride:"
+	// Safety TTL so a ride that never reaches a terminal state stops streaming eventually
+	driverActiveRideTTL = 12 * time.Hour
+	// Redis key prefix used to throttle location events forwarded to riders
+	driverLocationThrottlePrefix = "driver:location_fwd:"
+	// Minimum interval between two driver_location events sent to the same rider
+	driverLocationForwardInterval = 2 * time.Second
+	// Websocket event type carrying live driver positions to riders
+	eventDriverLocation = "driver_location"
+	// In degraded mode drivers whose persisted position is older than this are treated as offline
+	degradedLocationMaxAge = time.Minute
 )
 
-
 type LocationService struct {
 	server *server.Server
 	repo   *repository.Repositories
+	writer *LocationWriter
+	fraud  *FraudService
+	// now is the clock the jitter of nearby driver positions is bucketed by
+	now func() time.Time
 }
 
-func NewLocationService(s *server.Server, repo *repository.Repositories) *LocationService {
+func NewLocationService(s *server.Server, repo *repository.Repositories, writer *LocationWriter, fraud *FraudService) *LocationService {
 	return &LocationService{
 		server: s,
 		repo:   repo,
+		writer: writer,
+		fraud:  fraud,
+		now:    time.Now,
 	}
 }
 
-// UpdateDriverLocation updates a driver's location in Redis and periodically in PostgreSQL
+// UpdateDriverLocation updates a driver's location in Redis and queues it for the batched PostgreSQL writer.
+// In degraded mode only the writer is fed, nearby searches then read drivers.location.
+// Mocked fixes and moves no vehicle could make are rejected and count towards the driver's fraud score.
 func (s *LocationService) UpdateDriverLocation(ctx context.Context, update *model.LocationUpdate) error {
-	// Store in Redis for fast access
-	// _ ,err := ctx , err := context.WithTimeout(ctx,5*time.Second)
-	_, err := s.server.Redis.GeoAdd(ctx, driverGeoKey, &redis.GeoLocation{
-		Name:      update.DriverID,
-		Longitude: update.Location.Longitude,
-		Latitude:  update.Location.Latitude,
-	}).Result()
-	if err != nil {
-		return errs.Wrap(err, "failed to update location in redis")
+	fix := model.LocationFix{
+		Location:   update.Location,
+		Heading:    update.Heading,
+		Speed:      update.Speed,
+		RecordedAt: time.Now().UTC(),
+		IsMock:     update.IsMock,
+	}
+	if s.fraud != nil {
+		if flag := s.fraud.InspectLiveFix(ctx, update.DriverID, fix); flag != nil && flag.Rejected {
+			return errs.NewBadRequestError("location update rejected: "+string(flag.Reason), false, &codeLocationRejected, nil, nil)
+		}
 	}
+	return s.ingestFixes(ctx, update.DriverID, []model.LocationFix{fix}, true)
+}
+
+// ingestFixes persists fixes ordered oldest first and appends them to the active trip trace.
+// With updateLive the newest fix also becomes the driver's live position and is forwarded to the rider.
+func (s *LocationService) ingestFixes(ctx context.Context, driverID string, fixes []model.LocationFix, updateLive bool) error {
+	newest := fixes[len(fixes)-1]
+
+	var active map[string]string
+	if s.server.RedisAvailable() {
+		if updateLive {
+			// Store in Redis for fast access
+			_, err := s.server.Redis.GeoAdd(ctx, driverGeoKey, &redis.GeoLocation{
+				Name:      driverID,
+				Longitude: newest.Location.Longitude,
+				Latitude:  newest.Location.Latitude,
+			}).Result()
+			if err != nil {
+				return errs.Wrap(err, "failed to update location in redis")
+			}
+
+			// Set online TTL Key
+			onlineKey := driverOnlinePrefix + driverID
+			if err := s.server.Redis.Set(ctx, onlineKey, "1", driverOnlineTTL).Err(); err != nil {
+				return errs.Wrap(err, "failed to set driver online status in redis")
+			}
 
-	// Set online TTL Key
-	onlineKey := driverOnlinePrefix + update.DriverID
-	if err := s.server.Redis.Set(ctx, onlineKey, "1", driverOnlineTTL).Err(); err != nil {
-		return errs.Wrap(err, "failed to set driver online status in redis")
+			if s.fraud != nil {
+				s.fraud.RememberFix(ctx, driverID, newest)
+			}
+		}
+
+		// The active ride is looked up once, it tags the history samples, feeds the trace and routes the rider event
+		var err error
+		active, err = s.server.Redis.HGetAll(ctx, driverActiveRidePrefix+driverID).Result()
+		if err != nil {
+			s.server.Logger.Error().Err(err).Str("driver_id", driverID).Msg("Failed to look up driver active ride")
+		}
 	}
 
 	s.server.Logger.Debug().
-		Str("driver_id", update.DriverID).
-		Float64("lat", update.Location.Latitude).
-		Float64("lng", update.Location.Longitude).
+		Str("driver_id", driverID).
+		Int("fixes", len(fixes)).
+		Float64("lat", newest.Location.Latitude).
+		Float64("lng", newest.Location.Longitude).
+		Bool("degraded", s.server.Degraded()).
 		Msg("Driver location updated")
 
+	// HGetAll returns an empty map for a driver without an active ride
+	rideID := active["ride_id"]
+
+	if s.writer != nil {
+		for _, fix := range fixes {
+			sample := model.DriverLocationSample{
+				DriverUserID: driverID,
+				Location:     fix.Location,
+				Heading:      fix.Heading,
+				Speed:        fix.Speed,
+				RecordedAt:   fix.RecordedAt,
+			}
+			if rideID != "" {
+				sample.RideID = &rideID
+			}
+			s.writer.Record(sample)
+		}
+	}
+
+	if rideID != "" {
+		s.appendTrace(ctx, active, fixes)
+	}
+
+	// Live positions are not forwarded to riders in degraded mode, the throttle lives in redis
+	if updateLive && rideID != "" {
+		s.forwardLocationToRider(ctx, &model.LocationUpdate{
+			DriverID: driverID,
+			Location: newest.Location,
+			Heading:  newest.Heading,
+			Speed:    newest.Speed,
+		}, active)
+	}
+
 	return nil
 }
 
+// TrackActiveRide starts streaming the driver's location updates to the rider of the given ride
+func (s *LocationService) TrackActiveRide(ctx context.Context, driverUserID, rideID, riderID string) error {
+	if !s.server.RedisAvailable() {
+		return nil
+	}
+	key := driverActiveRidePrefix + driverUserID
+	pipe := s.server.Redis.TxPipeline()
+	pipe.HSet(ctx, key, "ride_id", rideID, "rider_id", riderID)
+	pipe.Expire(ctx, key, driverActiveRideTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errs.Wrap(err, "failed to track active ride in redis")
+	}
+	return nil
+}
+
+// UntrackActiveRide stops streaming the driver's location once their ride has ended
+func (s *LocationService) UntrackActiveRide(ctx context.Context, driverUserID string) error {
+	if !s.server.RedisAvailable() {
+		return nil
+	}
+	if err := s.server.Redis.Del(ctx,
+		driverActiveRidePrefix+driverUserID,
+		driverLocationThrottlePrefix+driverUserID,
+	).Err(); err != nil {
+		return errs.Wrap(err, "failed to untrack active ride in redis")
+	}
+	return nil
+}
+
+// forwardLocationToRider pushes a throttled driver_location event to the rider of the driver's active ride.
+// Failures are logged only, a missed position must never fail the location update itself.
+func (s *LocationService) forwardLocationToRider(ctx context.Context, update *model.LocationUpdate, active map[string]string) {
+	rideID, riderID := active["ride_id"], active["rider_id"]
+	if rideID == "" || riderID == "" {
+		return
+	}
+
+	// Only the first update in every interval is forwarded
+	allowed, err := s.server.Redis.SetNX(ctx, driverLocationThrottlePrefix+update.DriverID, "1", driverLocationForwardInterval).Result()
+	if err != nil {
+		s.server.Logger.Error().Err(err).Str("driver_id", update.DriverID).Msg("Failed to throttle driver location event")
+		return
+	}
+	if !allowed {
+		return
+	}
+
+	s.server.Hub.BroadcastToUser(riderID, eventDriverLocation, model.DriverLocationEvent{
+		RideID:    rideID,
+		DriverID:  update.DriverID,
+		Location:  update.Location,
+		Heading:   update.Heading,
+		Speed:     update.Speed,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
 func (s *LocationService) GetDriverlocation(ctx context.Context, driverId string) (*model.Location, error) {
+	if !s.server.RedisAvailable() {
+		location, err := s.repo.DriverLocation.GetLatest(ctx, driverId, time.Now().Add(-degradedLocationMaxAge))
+		if err != nil {
+			return nil, errs.Wrap(err, "failed to get driver location from database")
+		}
+		if location == nil {
+			return nil, fmt.Errorf("driver is offline")
+		}
+		return location, nil
+	}
+
 	// Check if the the driver is online or not
 	onlineKey := driverOnlinePrefix + driverId
 	exists, err := s.server.Redis.Get(ctx, onlineKey).Result()
@@ -93,56 +252,27 @@ func (s *LocationService) GetDriverlocation(ctx context.Context, driverId string
 	return location, nil
 }
 
-func (s *LocationService) FindNearbyDrivers(ctx context.Context, req *model.NearbyDriversRequest) (*[]model.NearByDriversResponseFromRedis, error) {
-	rediusKm := req.RadiusKm
-	if rediusKm == 0 {
-		rediusKm = defaultSearchRadiusKm
-	}
-
-	limit := req.Limit
-	if limit == 0 {
-		limit = defaultNearbyDriverLimit
-	}
-
-	result, err := s.server.Redis.GeoRadius(ctx,
-		driverGeoKey,
-		req.Location.Longitude,
-		req.Location.Latitude,
-		&redis.GeoRadiusQuery{
-			Radius: rediusKm,
-			Unit:   "km",
-			Count:  limit,
-			Sort:   "ASC",
-			WithDist:  true,
-			WithCoord: true,
-
-		},
-	).Result()
+// SetDriverAvailability sets the availability status of a driver. drivers.is_available is always
+// updated so availability survives a redis outage, redis only when it is reachable.
+func (s *LocationService) SetDriverAvailability(ctx context.Context, driverID string, available bool) error {
+	userID, err := uuid.Parse(driverID)
 	if err != nil {
-		return nil, errs.Wrap(err, "failed to find nearby drivers in redis")
+		return errs.Wrap(err, "invalid driver id")
 	}
-
-	response := make([]model.NearByDriversResponseFromRedis, 0, len(result))
-
-	for  _,loc := range result{
-		driver := model.NearByDriversResponseFromRedis{
-			ID: loc.Name,
-			Distance: loc.Dist,
-			Latitude: loc.Latitude,
-			Longitude: loc.Longitude,
-
-
-		}
-		response = append(response, driver)
+	if err := s.repo.Driver.SetAvailability(ctx, userID, available); err != nil {
+		return err
 	}
 
-	return &response, nil
-}
+	if !s.server.RedisAvailable() {
+		s.server.Logger.Debug().
+			Str("driver_id", driverID).
+			Bool("available", available).
+			Msg("Driver availability updated in database only, redis unavailable")
+		return nil
+	}
 
-// SetDriverAvailability sets the availability status of a driver
-func (s *LocationService) SetDriverAvailability(ctx context.Context, driverID string, available bool) error {
 	onlineKey := driverOnlinePrefix + driverID
-	
+
 	if available {
 		// Set driver as online
 		if err := s.server.Redis.Set(ctx, onlineKey, "1", driverOnlineTTL).Err(); err != nil {
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+const (
+	// Fixes may be stamped slightly ahead of the server clock
+	maxFixClockSkew = 30 * time.Second
+	// Buffered fixes older than this are not accepted any more
+	maxFixAge = 24 * time.Hour
+	// The newest fix of a batch only becomes the live position when it is this recent
+	liveFixMaxAge = driverOnlineTTL
+)
+
+// Reasons reported for dropped fixes
+const (
+	fixRejectedFuture      = "recorded_in_future"
+	fixRejectedTooOld      = "too_old"
+	fixRejectedDuplicate   = "duplicate_timestamp"
+	fixRejectedImplausible = "implausible_speed"
+	fixRejectedMock        = "mock_location"
+)
+
+var codeFixesOutOfOrder = "FIXES_OUT_OF_ORDER"
+
+// UploadLocationBatch ingests fixes buffered by the driver app. The batch must be ordered by
+// recorded_at; individual fixes that are stale, from the future, mocked or imply an impossible speed
+// are dropped and reported, mocked and impossible ones also count towards the fraud score. All
+// remaining fixes are persisted and added to the trip trace, the newest one also updates the live
+// position when it is recent enough.
+func (s *LocationService) UploadLocationBatch(ctx context.Context, driverID string, req *model.LocationBatchRequest) (*model.LocationBatchResponse, error) {
+	for i := 1; i < len(req.Fixes); i++ {
+		if req.Fixes[i].RecordedAt.Before(req.Fixes[i-1].RecordedAt) {
+			return nil, errs.NewBadRequestError(
+				fmt.Sprintf("fix %d is recorded before fix %d, fixes must be ordered oldest first", i, i-1),
+				false, &codeFixesOutOfOrder, nil, nil)
+		}
+	}
+
+	now := time.Now().UTC()
+	resp := &model.LocationBatchResponse{Rejected: []model.RejectedFix{}}
+	accepted := make([]model.LocationFix, 0, len(req.Fixes))
+
+	// The first fix is checked against the last live position when the batch continues from it
+	var prev *model.LocationFix
+	if s.fraud != nil {
+		if last := s.fraud.LastFix(ctx, driverID); last != nil && last.RecordedAt.Before(req.Fixes[0].RecordedAt) {
+			prev = last
+		}
+	}
+
+	for i, fix := range req.Fixes {
+		fix.RecordedAt = fix.RecordedAt.UTC()
+		reason := ""
+		var flag *model.LocationFlag
+		switch {
+		case fix.RecordedAt.After(now.Add(maxFixClockSkew)):
+			reason = fixRejectedFuture
+		case fix.RecordedAt.Before(now.Add(-maxFixAge)):
+			reason = fixRejectedTooOld
+		case fix.IsMock:
+			reason = fixRejectedMock
+			flag = newLocationFlag(model.FlagMockLocation, fix)
+		case prev != nil:
+			elapsed := fix.RecordedAt.Sub(prev.RecordedAt)
+			distance := calculateDistance(prev.Location, fix.Location)
+			if elapsed == 0 {
+				reason = fixRejectedDuplicate
+			} else if !plausibleMove(distance, elapsed) {
+				reason = fixRejectedImplausible
+				flag = assessMove(*prev, fix)
+			}
+		}
+
+		if flag != nil && s.fraud != nil {
+			flag.DriverUserID = driverID
+			s.fraud.Record(ctx, flag)
+		}
+		if reason != "" {
+			resp.Rejected = append(resp.Rejected, model.RejectedFix{Index: i, Reason: reason})
+			continue
+		}
+		accepted = append(accepted, fix)
+		prev = &fix
+	}
+
+	resp.Accepted = len(accepted)
+	if len(accepted) == 0 {
+		return resp, nil
+	}
+
+	resp.LiveUpdated = now.Sub(accepted[len(accepted)-1].RecordedAt) <= liveFixMaxAge
+	if err := s.ingestFixes(ctx, driverID, accepted, resp.LiveUpdated); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,114 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadLocationBatch(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	logger := zerolog.Nop()
+	srv := &server.Server{Logger: &logger, Redis: redisClient}
+	locationService := service.NewLocationService(srv, &repository.Repositories{}, nil, nil)
+
+	ctx := context.Background()
+	driverID := "5a0c7d8e-2f7b-4a1e-8e55-6c2f1b9d3a47"
+	rideID := "0b8f2f8e-7a43-4a4e-9d0c-2a6c1d5b2f10"
+	now := time.Now().UTC()
+	fix := func(ago time.Duration, lat, lng float64) model.LocationFix {
+		return model.LocationFix{
+			Location:   model.Location{Latitude: lat, Longitude: lng},
+			RecordedAt: now.Add(-ago),
+		}
+	}
+
+	// The trip started ten minutes ago, the rider is not connected so nothing is forwarded
+	require.NoError(t, redisClient.HSet(ctx, "driver:active_ride:"+driverID, "ride_id", rideID).Err())
+	require.NoError(t, locationService.MarkRideStarted(ctx, driverID, now.Add(-10*time.Minute)))
+
+	t.Run("Rejects unordered batches", func(t *testing.T) {
+		_, err := locationService.UploadLocationBatch(ctx, driverID, &model.LocationBatchRequest{
+			Fixes: []model.LocationFix{fix(time.Minute, 12.97, 77.59), fix(2*time.Minute, 12.97, 77.59)},
+		})
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, "FIXES_OUT_OF_ORDER", httpErr.Code)
+	})
+
+	t.Run("Drops implausible fixes and reconstructs distance across a gap", func(t *testing.T) {
+		resp, err := locationService.UploadLocationBatch(ctx, driverID, &model.LocationBatchRequest{
+			Fixes: []model.LocationFix{
+				// Before the trip started, persisted but not traced
+				fix(15*time.Minute, 12.90, 77.50),
+				fix(8*time.Minute, 12.9700, 77.5900),
+				// GPS jump of ~100km within a minute
+				fix(7*time.Minute, 13.8700, 77.5900),
+				// Connectivity gap, ~1.1km further north four minutes later
+				fix(4*time.Minute, 12.9800, 77.5900),
+				fix(10*time.Second, 12.9900, 77.5900),
+				fix(-time.Hour, 12.9900, 77.5900),
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 4, resp.Accepted)
+		assert.Equal(t, []model.RejectedFix{
+			{Index: 2, Reason: "implausible_speed"},
+			{Index: 5, Reason: "recorded_in_future"},
+		}, resp.Rejected)
+		assert.True(t, resp.LiveUpdated)
+
+		distance, ok, err := locationService.TripDistanceKm(ctx, rideID)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.InDelta(t, 2.22, distance, 0.05)
+
+		// The newest fix is the live position
+		pos, err := redisClient.GeoPos(ctx, "drivers:geo", driverID).Result()
+		require.NoError(t, err)
+		assert.InDelta(t, 12.99, pos[0].Latitude, 0.0001)
+	})
+
+	t.Run("Trace of GPS jumps only keeps the estimate", func(t *testing.T) {
+		jumpyRideID := "4d1e6a2b-9c3f-4e8a-b7d5-1f2a3c4b5d6e"
+		at := now.Add(-5 * time.Minute).UnixMilli()
+		require.NoError(t, redisClient.ZAdd(ctx, "ride:trace:"+jumpyRideID,
+			redis.Z{Score: float64(at), Member: fmt.Sprintf(`{"lat":12.97,"lng":77.59,"t":%d}`, at)},
+			redis.Z{Score: float64(at + 1000), Member: fmt.Sprintf(`{"lat":13.87,"lng":77.59,"t":%d}`, at+1000)},
+		).Err())
+
+		distance, ok, err := locationService.TripDistanceKm(ctx, jumpyRideID)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.Zero(t, distance)
+	})
+
+	t.Run("Old backlog does not move the live position", func(t *testing.T) {
+		resp, err := locationService.UploadLocationBatch(ctx, driverID, &model.LocationBatchRequest{
+			Fixes: []model.LocationFix{fix(5*time.Minute, 12.9850, 77.5900)},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, resp.Accepted)
+		assert.False(t, resp.LiveUpdated)
+
+		pos, err := redisClient.GeoPos(ctx, "drivers:geo", driverID).Result()
+		require.NoError(t, err)
+		assert.InDelta(t, 12.99, pos[0].Latitude, 0.0001)
+	})
+}
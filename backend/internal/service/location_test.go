@@ -0,0 +1,68 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLocationServiceDegradedMode(t *testing.T) {
+	// A server without redis runs in degraded mode
+	logger := zerolog.Nop()
+	srv := &server.Server{Logger: &logger}
+	assert.True(t, srv.Degraded())
+
+	mockLocationRepo := new(testutil.MockDriverLocationRepository)
+	mockDriverRepo := new(testutil.MockDriverRepository)
+	repos := &repository.Repositories{DriverLocation: mockLocationRepo, Driver: mockDriverRepo}
+	locationService := service.NewLocationService(srv, repos, nil, nil)
+	pickup := model.Location{Latitude: 12.9716, Longitude: 77.5946}
+
+	t.Run("Nearby drivers come from the database", func(t *testing.T) {
+		drivers := []model.NearByDriversResponseFromRedis{
+			{ID: "driver-1", Distance: 0.8, Latitude: 12.97, Longitude: 77.59},
+		}
+		mockLocationRepo.On("FindNearby", mock.Anything, pickup, 5.0, 20, mock.Anything).Return(drivers, nil).Once()
+		mockDriverRepo.On("GetNearbyProfiles", mock.Anything, []string{"driver-1"}).Return(map[string]model.NearbyDriver{
+			"driver-1": {DriverID: "driver-1", Name: "Ravi", VehicleType: "car", Rating: 4.8},
+		}, nil).Once()
+
+		result, err := locationService.FindNearbyDrivers(context.Background(), &model.NearbyDriversRequest{Location: pickup}, true)
+		assert.NoError(t, err)
+		assert.Equal(t, []model.NearbyDriver{{
+			DriverID:    "driver-1",
+			Name:        "Ravi",
+			VehicleType: "car",
+			Rating:      4.8,
+			Location:    model.Location{Latitude: 12.97, Longitude: 77.59},
+			DistanceKm:  0.8,
+			IsAvailable: true,
+		}}, *result)
+	})
+
+	t.Run("Driver without a recent position is offline", func(t *testing.T) {
+		mockLocationRepo.On("GetLatest", mock.Anything, "driver-2", mock.Anything).Return(nil, nil).Once()
+
+		_, err := locationService.GetDriverlocation(context.Background(), "driver-2")
+		assert.Error(t, err)
+	})
+
+	t.Run("Location updates do not touch redis", func(t *testing.T) {
+		err := locationService.UpdateDriverLocation(context.Background(), &model.LocationUpdate{
+			DriverID: "driver-1",
+			Location: pickup,
+		})
+		assert.NoError(t, err)
+	})
+
+	mockLocationRepo.AssertExpectations(t)
+	mockDriverRepo.AssertExpectations(t)
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/job"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+)
+
+const (
+	// Upper bound of history samples buffered between flushes, the oldest are dropped beyond it
+	maxPendingLocationHistory = 50000
+	// Daily history partitions created ahead of the current day
+	locationHistoryPartitionsAhead = 3
+	// Timeout of a single flush to PostgreSQL
+	locationFlushTimeout = 10 * time.Second
+)
+
+// LocationWriter buffers driver location samples in memory and persists them in batches.
+// Only the latest sample per driver is written to drivers.location, every sample is appended
+// to driver_location_history.
+type LocationWriter struct {
+	repo   repository.DriverLocationRepository
+	cfg    *config.LocationConfig
+	logger *zerolog.Logger
+
+	mu      sync.Mutex
+	latest  map[string]model.DriverLocationSample
+	history []model.DriverLocationSample
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewLocationWriter(logger *zerolog.Logger, cfg *config.LocationConfig, repo repository.DriverLocationRepository) *LocationWriter {
+	return &LocationWriter{
+		repo:   repo,
+		cfg:    cfg,
+		logger: logger,
+		latest: make(map[string]model.DriverLocationSample),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Register hooks the writer into the server: it prepares the history partitions, schedules their
+// maintenance, starts the flush loop and flushes the remaining samples on shutdown
+func (w *LocationWriter) Register(s *server.Server) error {
+	s.Job.HandleFunc(job.TaskLocationHistoryMaintenance, w.handleHistoryMaintenanceTask)
+	if err := s.Job.Schedule(w.cfg.HistoryMaintenanceSchedule, job.NewLocationHistoryMaintenanceTask()); err != nil {
+		return fmt.Errorf("failed to schedule location history maintenance: %w", err)
+	}
+
+	// Samples recorded before the first scheduled run still need a partition
+	ctx, cancel := context.WithTimeout(context.Background(), locationFlushTimeout)
+	defer cancel()
+	if err := w.repo.EnsureHistoryPartitions(ctx, time.Now(), locationHistoryPartitionsAhead); err != nil {
+		w.logger.Error().Err(err).Msg("Failed to create driver location history partitions")
+	}
+
+	w.Start()
+	s.RegisterOnShutdown(w.Stop)
+	return nil
+}
+
+// Record queues a sample for the next flush
+func (w *LocationWriter) Record(sample model.DriverLocationSample) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if prev, ok := w.latest[sample.DriverUserID]; !ok || !sample.RecordedAt.Before(prev.RecordedAt) {
+		w.latest[sample.DriverUserID] = sample
+	}
+
+	if len(w.history) >= maxPendingLocationHistory {
+		w.history = w.history[1:]
+	}
+	w.history = append(w.history, sample)
+}
+
+// Start runs the flush loop until Stop is called
+func (w *LocationWriter) Start() {
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(w.cfg.FlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), locationFlushTimeout)
+				if err := w.Flush(ctx); err != nil {
+					w.logger.Error().Err(err).Msg("Failed to flush driver locations")
+				}
+				cancel()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the flush loop and writes whatever is still buffered
+func (w *LocationWriter) Stop(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.stop) })
+
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return w.Flush(ctx)
+}
+
+// Flush writes the buffered samples. Latest positions that fail to persist are put back unless
+// a newer sample arrived meanwhile, history samples are dropped with the error.
+func (w *LocationWriter) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	latest, history := w.latest, w.history
+	w.latest = make(map[string]model.DriverLocationSample, len(latest))
+	w.history = nil
+	w.mu.Unlock()
+
+	var errList []error
+	if len(latest) > 0 {
+		samples := make([]model.DriverLocationSample, 0, len(latest))
+		for _, sample := range latest {
+			samples = append(samples, sample)
+		}
+		if err := w.repo.UpdateLatest(ctx, samples); err != nil {
+			w.requeueLatest(latest)
+			errList = append(errList, fmt.Errorf("failed to update driver locations: %w", err))
+		}
+	}
+
+	if len(history) > 0 {
+		if err := w.repo.InsertHistory(ctx, history); err != nil {
+			errList = append(errList, fmt.Errorf("failed to insert %d driver location samples: %w", len(history), err))
+		}
+	}
+	return errors.Join(errList...)
+}
+
+func (w *LocationWriter) requeueLatest(latest map[string]model.DriverLocationSample) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for driverID, sample := range latest {
+		if _, ok := w.latest[driverID]; !ok {
+			w.latest[driverID] = sample
+		}
+	}
+}
+
+// MaintainHistory creates the upcoming history partitions and drops the ones past retention
+func (w *LocationWriter) MaintainHistory(ctx context.Context) error {
+	now := time.Now().UTC()
+	if err := w.repo.EnsureHistoryPartitions(ctx, now, locationHistoryPartitionsAhead); err != nil {
+		return err
+	}
+
+	cutoff := now.Truncate(24*time.Hour).AddDate(0, 0, -w.cfg.HistoryRetentionDays)
+	dropped, err := w.repo.DropHistoryPartitionsBefore(ctx, cutoff)
+	if len(dropped) > 0 {
+		w.logger.Info().Strs("partitions", dropped).Msg("Dropped expired driver location history partitions")
+	}
+	return err
+}
+
+func (w *LocationWriter) handleHistoryMaintenanceTask(ctx context.Context, t *asynq.Task) error {
+	if err := w.MaintainHistory(ctx); err != nil {
+		w.logger.Error().Err(err).Msg("Driver location history maintenance failed")
+		return err
+	}
+	return nil
+}
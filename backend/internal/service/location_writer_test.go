@@ -0,0 +1,75 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLocationWriterFlush(t *testing.T) {
+	logger := zerolog.Nop()
+	base := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	sample := func(driverID string, offset time.Duration, lat float64) model.DriverLocationSample {
+		return model.DriverLocationSample{
+			DriverUserID: driverID,
+			Location:     model.Location{Latitude: lat, Longitude: 77.59},
+			RecordedAt:   base.Add(offset),
+		}
+	}
+
+	t.Run("Coalesces latest position per driver", func(t *testing.T) {
+		repo := new(testutil.MockDriverLocationRepository)
+		writer := service.NewLocationWriter(&logger, config.DefaultLocationConfig(), repo)
+
+		writer.Record(sample("driver-1", 0, 12.1))
+		writer.Record(sample("driver-1", 2*time.Second, 12.2))
+		// Out of order samples never overwrite a newer position
+		writer.Record(sample("driver-1", time.Second, 12.15))
+		writer.Record(sample("driver-2", 0, 13.0))
+
+		repo.On("UpdateLatest", mock.Anything, mock.MatchedBy(func(samples []model.DriverLocationSample) bool {
+			if len(samples) != 2 {
+				return false
+			}
+			for _, s := range samples {
+				if s.DriverUserID == "driver-1" && s.Location.Latitude != 12.2 {
+					return false
+				}
+			}
+			return true
+		})).Return(nil).Once()
+		repo.On("InsertHistory", mock.Anything, mock.MatchedBy(func(samples []model.DriverLocationSample) bool {
+			return len(samples) == 4
+		})).Return(nil).Once()
+
+		assert.NoError(t, writer.Flush(context.Background()))
+		// Nothing is left to write
+		assert.NoError(t, writer.Flush(context.Background()))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Keeps latest positions when the update fails", func(t *testing.T) {
+		repo := new(testutil.MockDriverLocationRepository)
+		writer := service.NewLocationWriter(&logger, config.DefaultLocationConfig(), repo)
+
+		writer.Record(sample("driver-1", 0, 12.1))
+		repo.On("UpdateLatest", mock.Anything, mock.Anything).Return(errors.New("connection refused")).Once()
+		repo.On("InsertHistory", mock.Anything, mock.Anything).Return(nil).Once()
+		assert.Error(t, writer.Flush(context.Background()))
+
+		repo.On("UpdateLatest", mock.Anything, mock.MatchedBy(func(samples []model.DriverLocationSample) bool {
+			return len(samples) == 1 && samples[0].DriverUserID == "driver-1"
+		})).Return(nil).Once()
+		assert.NoError(t, writer.Flush(context.Background()))
+		repo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+const (
+	// Redis key prefix caching the public profile shown in nearby driver results
+	driverProfileCachePrefix = "driver:profile:"
+	driverProfileCacheTTL    = time.Minute
+	// Filters drop candidates after hydration, so the geo search fetches more of them
+	nearbyFilterOverfetch = 4
+	maxNearbyCandidates   = 200
+	// Positions shown to riders are moved by up to this distance
+	nearbyJitterMeters = 150.0
+	// The jitter of a driver stays the same within a bucket, so polling does not average it out
+	nearbyJitterBucket = 5 * time.Minute
+	metersPerDegreeLat = 111_320.0
+)
+
+// FindNearbyDrivers returns the available drivers around a location, closest first, with their public
+// profile. Unless exact is set, positions are jittered and distances rounded so riders cannot pinpoint
+// a driver who is not assigned to them.
+func (s *LocationService) FindNearbyDrivers(ctx context.Context, req *model.NearbyDriversRequest, exact bool) (*[]model.NearbyDriver, error) {
+	radiusKm := req.RadiusKm
+	if radiusKm == 0 {
+		radiusKm = defaultSearchRadiusKm
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultNearbyDriverLimit
+	}
+
+	fetch := limit
+	if req.VehicleType != "" || req.MinRating > 0 {
+		fetch = min(limit*nearbyFilterOverfetch, maxNearbyCandidates)
+	}
+
+	candidates, err := s.findNearbyCandidates(ctx, req.Location, radiusKm, fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		ids = append(ids, c.ID)
+	}
+	profiles, err := s.driverProfiles(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	drivers := make([]model.NearbyDriver, 0, min(limit, len(candidates)))
+	for _, c := range candidates {
+		driver, ok := profiles[c.ID]
+		if !ok {
+			continue
+		}
+		if req.VehicleType != "" && driver.VehicleType != req.VehicleType {
+			continue
+		}
+		if driver.Rating < req.MinRating {
+			continue
+		}
+
+		driver.Location = model.Location{Latitude: c.Latitude, Longitude: c.Longitude}
+		driver.DistanceKm = c.Distance
+		// Only available drivers are indexed, the cached profile may lag behind
+		driver.IsAvailable = true
+		if !exact {
+			driver.Location = jitterLocation(driver.Location, c.ID, now)
+			driver.DistanceKm = math.Round(driver.DistanceKm*10) / 10
+		}
+
+		drivers = append(drivers, driver)
+		if len(drivers) == limit {
+			break
+		}
+	}
+
+	return &drivers, nil
+}
+
+// findNearbyCandidates searches the geo index, or the persisted positions in degraded mode
+func (s *LocationService) findNearbyCandidates(ctx context.Context, location model.Location, radiusKm float64, limit int) ([]model.NearByDriversResponseFromRedis, error) {
+	if !s.server.RedisAvailable() {
+		drivers, err := s.repo.DriverLocation.FindNearby(ctx, location, radiusKm, limit, time.Now().Add(-degradedLocationMaxAge))
+		if err != nil {
+			return nil, errs.Wrap(err, "failed to find nearby drivers in database")
+		}
+		return drivers, nil
+	}
+
+	result, err := s.server.Redis.GeoRadius(ctx,
+		driverGeoKey,
+		location.Longitude,
+		location.Latitude,
+		&redis.GeoRadiusQuery{
+			Radius:    radiusKm,
+			Unit:      "km",
+			Count:     limit,
+			Sort:      "ASC",
+			WithDist:  true,
+			WithCoord: true,
+		},
+	).Result()
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to find nearby drivers in redis")
+	}
+
+	drivers := make([]model.NearByDriversResponseFromRedis, 0, len(result))
+	for _, loc := range result {
+		drivers = append(drivers, model.NearByDriversResponseFromRedis{
+			ID:        loc.Name,
+			Distance:  loc.Dist,
+			Latitude:  loc.Latitude,
+			Longitude: loc.Longitude,
+		})
+	}
+	return drivers, nil
+}
+
+// driverProfiles returns the public profiles of the given drivers, from the redis cache where
+// possible and from one batched query for the rest
+func (s *LocationService) driverProfiles(ctx context.Context, driverIDs []string) (map[string]model.NearbyDriver, error) {
+	profiles := make(map[string]model.NearbyDriver, len(driverIDs))
+	if len(driverIDs) == 0 {
+		return profiles, nil
+	}
+
+	missing := driverIDs
+	if s.server.RedisAvailable() {
+		missing = s.cachedDriverProfiles(ctx, driverIDs, profiles)
+	}
+	if len(missing) == 0 {
+		return profiles, nil
+	}
+
+	loaded, err := s.repo.Driver.GetNearbyProfiles(ctx, missing)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to load nearby driver profiles")
+	}
+	for id, profile := range loaded {
+		profiles[id] = profile
+	}
+
+	if s.server.RedisAvailable() {
+		s.cacheDriverProfiles(ctx, loaded)
+	}
+	return profiles, nil
+}
+
+// cachedDriverProfiles fills profiles from the cache and returns the ids that were not cached.
+// A failing cache is treated as empty.
+func (s *LocationService) cachedDriverProfiles(ctx context.Context, driverIDs []string, profiles map[string]model.NearbyDriver) []string {
+	keys := make([]string, len(driverIDs))
+	for i, id := range driverIDs {
+		keys[i] = driverProfileCachePrefix + id
+	}
+
+	values, err := s.server.Redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		s.server.Logger.Error().Err(err).Msg("Failed to read driver profile cache")
+		return driverIDs
+	}
+
+	var missing []string
+	for i, value := range values {
+		raw, ok := value.(string)
+		var profile model.NearbyDriver
+		if !ok || json.Unmarshal([]byte(raw), &profile) != nil {
+			missing = append(missing, driverIDs[i])
+			continue
+		}
+		profiles[driverIDs[i]] = profile
+	}
+	return missing
+}
+
+func (s *LocationService) cacheDriverProfiles(ctx context.Context, profiles map[string]model.NearbyDriver) {
+	if len(profiles) == 0 {
+		return
+	}
+
+	pipe := s.server.Redis.Pipeline()
+	for id, profile := range profiles {
+		data, err := json.Marshal(profile)
+		if err != nil {
+			continue
+		}
+		pipe.Set(ctx, driverProfileCachePrefix+id, data, driverProfileCacheTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.server.Logger.Error().Err(err).Msg("Failed to cache driver profiles")
+	}
+}
+
+// jitterLocation moves a position by up to nearbyJitterMeters in a direction derived from the
+// driver and the current time bucket
+func jitterLocation(loc model.Location, driverID string, now time.Time) model.Location {
+	h := fnv.New64a()
+	h.Write([]byte(driverID))
+	h.Write([]byte(strconv.FormatInt(now.Unix()/int64(nearbyJitterBucket.Seconds()), 10)))
+	sum := h.Sum64()
+
+	bearing := float64(sum&0xffffffff) / float64(1<<32) * 2 * math.Pi
+	// sqrt spreads the offsets evenly over the disc instead of bunching them at the centre
+	distance := nearbyJitterMeters * math.Sqrt(float64(sum>>32)/float64(1<<32))
+
+	return model.Location{
+		Latitude:  loc.Latitude + distance*math.Cos(bearing)/metersPerDegreeLat,
+		Longitude: loc.Longitude + distance*math.Sin(bearing)/(metersPerDegreeLat*math.Cos(loc.Latitude*math.Pi/180)),
+	}
+}
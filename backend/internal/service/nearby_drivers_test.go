@@ -0,0 +1,109 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindNearbyDrivers(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	logger := zerolog.Nop()
+	srv := &server.Server{Logger: &logger, Redis: redisClient}
+
+	mockDriverRepo := new(testutil.MockDriverRepository)
+	repos := &repository.Repositories{Driver: mockDriverRepo}
+	locationService := service.NewLocationService(srv, repos, nil, nil)
+
+	ctx := context.Background()
+	pickup := model.Location{Latitude: 12.9716, Longitude: 77.5946}
+	car := model.Location{Latitude: 12.9750, Longitude: 77.5950}
+	bike := model.Location{Latitude: 12.9800, Longitude: 77.6000}
+	require.NoError(t, redisClient.GeoAdd(ctx, "drivers:geo",
+		&redis.GeoLocation{Name: "driver-car", Latitude: car.Latitude, Longitude: car.Longitude},
+		&redis.GeoLocation{Name: "driver-bike", Latitude: bike.Latitude, Longitude: bike.Longitude},
+		&redis.GeoLocation{Name: "no-profile", Latitude: 12.9720, Longitude: 77.5947},
+	).Err())
+
+	// Profiles are loaded in one query and cached afterwards
+	mockDriverRepo.On("GetNearbyProfiles", mock.Anything, mock.MatchedBy(func(ids []string) bool {
+		return len(ids) == 3
+	})).Return(map[string]model.NearbyDriver{
+		"driver-car":  {DriverID: "driver-car", Name: "Asha", VehicleType: "car", VehicleNumber: "KA01AB1234", Rating: 4.9},
+		"driver-bike": {DriverID: "driver-bike", Name: "Ravi", VehicleType: "bike", VehicleNumber: "KA02CD5678", Rating: 4.2},
+	}, nil).Once()
+	mockDriverRepo.On("GetNearbyProfiles", mock.Anything, []string{"no-profile"}).Return(map[string]model.NearbyDriver{}, nil)
+
+	t.Run("Drivers are hydrated with their profile", func(t *testing.T) {
+		result, err := locationService.FindNearbyDrivers(ctx, &model.NearbyDriversRequest{Location: pickup}, true)
+		require.NoError(t, err)
+		require.Len(t, *result, 2)
+
+		first := (*result)[0]
+		assert.Equal(t, "driver-car", first.DriverID)
+		assert.Equal(t, "Asha", first.Name)
+		assert.Equal(t, "KA01AB1234", first.VehicleNumber)
+		assert.True(t, first.IsAvailable)
+		assert.InDelta(t, car.Latitude, first.Location.Latitude, 0.0001)
+		assert.Equal(t, "driver-bike", (*result)[1].DriverID)
+	})
+
+	t.Run("Filters by vehicle type and rating", func(t *testing.T) {
+		result, err := locationService.FindNearbyDrivers(ctx, &model.NearbyDriversRequest{Location: pickup, VehicleType: "bike"}, true)
+		require.NoError(t, err)
+		require.Len(t, *result, 1)
+		assert.Equal(t, "driver-bike", (*result)[0].DriverID)
+
+		result, err = locationService.FindNearbyDrivers(ctx, &model.NearbyDriversRequest{Location: pickup, MinRating: 4.5}, true)
+		require.NoError(t, err)
+		require.Len(t, *result, 1)
+		assert.Equal(t, "driver-car", (*result)[0].DriverID)
+	})
+
+	t.Run("Riders get jittered positions", func(t *testing.T) {
+		now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+		locationService.SetClock(func() time.Time { return now })
+		defer locationService.SetClock(time.Now)
+
+		first, err := locationService.FindNearbyDrivers(ctx, &model.NearbyDriversRequest{Location: pickup, VehicleType: "car"}, false)
+		require.NoError(t, err)
+		require.Len(t, *first, 1)
+		driver := (*first)[0]
+
+		assert.NotEqual(t, car, driver.Location)
+		// Jitter stays within 150m, about 0.0014 degrees
+		assert.InDelta(t, car.Latitude, driver.Location.Latitude, 0.0014)
+		assert.InDelta(t, car.Longitude, driver.Location.Longitude, 0.0014)
+		assert.Equal(t, driver.DistanceKm, float64(int(driver.DistanceKm*10+0.5))/10)
+
+		// Polling again within the bucket does not reveal a different offset
+		now = now.Add(4*time.Minute + 59*time.Second)
+		second, err := locationService.FindNearbyDrivers(ctx, &model.NearbyDriversRequest{Location: pickup, VehicleType: "car"}, false)
+		require.NoError(t, err)
+		assert.Equal(t, driver.Location, (*second)[0].Location)
+
+		// The next bucket moves the offset
+		now = now.Add(time.Second)
+		third, err := locationService.FindNearbyDrivers(ctx, &model.NearbyDriversRequest{Location: pickup, VehicleType: "car"}, false)
+		require.NoError(t, err)
+		assert.NotEqual(t, driver.Location, (*third)[0].Location)
+	})
+
+	mockDriverRepo.AssertExpectations(t)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/job"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/push"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+)
+
+// NotificationService manages device tokens and delivers push notifications through the job queue
+type NotificationService struct {
+	server   *server.Server
+	repo     *repository.Repositories
+	notifier push.Notifier
+}
+
+func NewNotificationService(s *server.Server, repo *repository.Repositories) (*NotificationService, error) {
+	notifier, err := push.NewNotifier(s.Config.Notification, s.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := &NotificationService{
+		server:   s,
+		repo:     repo,
+		notifier: notifier,
+	}
+	s.Job.HandleFunc(job.TaskPushNotification, svc.handlePushTask)
+	return svc, nil
+}
+
+// RegisterDevice stores a push token for the user
+func (s *NotificationService) RegisterDevice(ctx context.Context, userID string, req *model.RegisterDeviceRequest) (*model.DeviceToken, error) {
+	device := &model.DeviceToken{
+		UserID:   userID,
+		Token:    req.Token,
+		Platform: req.Platform,
+	}
+	if err := s.repo.Device.Upsert(ctx, device); err != nil {
+		return nil, errs.Wrap(err, "failed to register device")
+	}
+	return device, nil
+}
+
+// UnregisterDevice removes a push token of the user
+func (s *NotificationService) UnregisterDevice(ctx context.Context, userID string, req *model.UnregisterDeviceRequest) error {
+	if err := s.repo.Device.Delete(ctx, userID, req.Token); err != nil {
+		return errs.Wrap(err, "failed to unregister device")
+	}
+	return nil
+}
+
+// ListDevices returns the push tokens registered by the user
+func (s *NotificationService) ListDevices(ctx context.Context, userID string) ([]*model.DeviceToken, error) {
+	devices, err := s.repo.Device.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list devices")
+	}
+	if devices == nil {
+		devices = []*model.DeviceToken{}
+	}
+	return devices, nil
+}
+
+// Notify enqueues one push delivery per registered device of the user
+func (s *NotificationService) Notify(ctx context.Context, userID string, n push.Notification) error {
+	devices, err := s.repo.Device.ListByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	for _, device := range devices {
+		task, err := job.NewPushNotificationTask(job.PushNotificationPayload{
+			UserID:   userID,
+			Token:    device.Token,
+			Platform: device.Platform,
+			Title:    n.Title,
+			Body:     n.Body,
+			Data:     n.Data,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create push task: %w", err)
+		}
+		if _, err := s.server.Job.Client.EnqueueContext(ctx, task); err != nil {
+			return fmt.Errorf("failed to enqueue push task: %w", err)
+		}
+	}
+	return nil
+}
+
+// NotifyIfOffline pushes the notification only when the user has no live websocket or SSE stream
+func (s *NotificationService) NotifyIfOffline(ctx context.Context, userID string, n push.Notification) {
+	if s.server.Hub != nil && s.server.Hub.IsOnline(ctx, userID) {
+		return
+	}
+	if err := s.Notify(ctx, userID, n); err != nil {
+		s.server.Logger.Error().Err(err).Str("user_id", userID).Msg("Failed to queue push notification")
+	}
+}
+
+// handlePushTask delivers one notification. Dead tokens are removed instead of retried.
+func (s *NotificationService) handlePushTask(ctx context.Context, t *asynq.Task) error {
+	var p job.PushNotificationPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("failed to unmarshal push payload: %w: %w", err, asynq.SkipRetry)
+	}
+
+	err := s.notifier.Send(ctx, push.Target{Token: p.Token, Platform: push.Platform(p.Platform)}, push.Notification{
+		Title: p.Title,
+		Body:  p.Body,
+		Data:  p.Data,
+	})
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, push.ErrInvalidToken):
+		s.server.Logger.Info().Str("user_id", p.UserID).Str("platform", p.Platform).Msg("Removing invalid device token")
+		if err := s.repo.Device.DeleteByToken(ctx, p.Token); err != nil {
+			return fmt.Errorf("failed to remove invalid device token: %w", err)
+		}
+		return nil
+	case errors.Is(err, push.ErrRejected):
+		s.server.Logger.Error().Err(err).Str("user_id", p.UserID).Msg("Push notification rejected")
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	default:
+		return err
+	}
+}
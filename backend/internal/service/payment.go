@@ -2,54 +2,116 @@ package service
 
 import (
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"fmt"
-	"os"
+	"net/http"
+	"slices"
 
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/push"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
 )
 
 type PaymentService interface {
 	CreatePaymentOrder(ctx context.Context, userID string, req *model.CreatePaymentOrderRequest) (*model.CreatePaymentOrderResponse, error)
-	VerifyPayment(ctx context.Context, req *model.VerifyPaymentRequest) error
+	VerifyPayment(ctx context.Context, userID string, req *model.VerifyPaymentRequest) error
 	ProcessCashPayment(ctx context.Context, userID string, req *model.CashPaymentRequest) error
 	ProcessUPIPayment(ctx context.Context, userID string, req *model.UPIPaymentRequest) error
 	GetPaymentByID(ctx context.Context, paymentID string) (*model.Payment, error)
 	GetPaymentByRideID(ctx context.Context, rideID string) (*model.Payment, error)
+	HandleWebhook(ctx context.Context, body []byte, header http.Header) error
+	CreateRefund(ctx context.Context, adminID, paymentID string, req *model.CreateRefundRequest) (*model.Refund, error)
+	ListRefunds(ctx context.Context, paymentID string) ([]*model.Refund, error)
+	SyncRefunds(ctx context.Context) (int, error)
+	ReconcilePayments(ctx context.Context) (*model.ReconciliationResult, error)
+	ListDiscrepancies(ctx context.Context, req *model.ListPaymentDiscrepanciesRequest) ([]*model.PaymentDiscrepancy, error)
+	ResolveDiscrepancy(ctx context.Context, adminID string, req *model.ResolvePaymentDiscrepancyRequest) (*model.PaymentDiscrepancy, error)
+	GetWallet(ctx context.Context, userID string) (*model.Wallet, error)
+	GetWalletStatement(ctx context.Context, userID string, req *model.WalletStatementRequest) (*model.WalletStatement, error)
+	CreateWalletTopup(ctx context.Context, userID string, req *model.CreateWalletTopupRequest) (*model.CreateWalletTopupResponse, error)
+	VerifyWalletTopup(ctx context.Context, userID string, req *model.VerifyWalletTopupRequest) (*model.Wallet, error)
+	Register(s *server.Server) error
+}
+
+// paymentNotifier delivers push notifications about payments to riders
+type paymentNotifier interface {
+	Notify(ctx context.Context, userID string, n push.Notification) error
+}
+
+// ridePaidListener is told about captured ride payments, the referral program rewards first rides
+type ridePaidListener interface {
+	RidePaid(ctx context.Context, payment *model.Payment)
 }
 
 type paymentService struct {
-	paymentRepo    repository.PaymentRepository
-	rideRepo       repository.RideRepository
-	razorpayKey    string
-	razorpaySecret string
+	paymentRepo repository.PaymentRepository
+	rideRepo    repository.RiddeRepository
+	eventRepo   repository.PaymentEventRepository
+	refundRepo  repository.RefundRepository
+	walletRepo  repository.WalletRepository
+	ledgerRepo  repository.LedgerRepository
+	// earningsRepo records how each paid ride splits between the driver and the platform
+	earningsRepo repository.EarningsRepository
+	// discrepancyRepo is the report of the reconciliation job
+	discrepancyRepo repository.PaymentDiscrepancyRepository
+	plan            *config.EarningsConfig
+	reconcile       *config.ReconciliationConfig
+	// gateway is nil when no provider is configured, online payments are then refused
+	gateway gateway.PaymentGateway
+	// notifier is optional, riders are not notified without it
+	notifier paymentNotifier
+	// ridePaid is optional, it is told about every captured ride payment
+	ridePaid ridePaidListener
 }
 
 func NewPaymentService(
 	paymentRepo repository.PaymentRepository,
-	rideRepo repository.RideRepository,
+	rideRepo repository.RiddeRepository,
+	eventRepo repository.PaymentEventRepository,
+	refundRepo repository.RefundRepository,
+	walletRepo repository.WalletRepository,
+	ledgerRepo repository.LedgerRepository,
+	earningsRepo repository.EarningsRepository,
+	discrepancyRepo repository.PaymentDiscrepancyRepository,
+	plan *config.EarningsConfig,
+	reconcile *config.ReconciliationConfig,
+	gw gateway.PaymentGateway,
+	notifier paymentNotifier,
+	ridePaid ridePaidListener,
 ) PaymentService {
 	return &paymentService{
-		paymentRepo:    paymentRepo,
-		rideRepo:       rideRepo,
-		razorpayKey:    getEnv("RAZORPAY_KEY_ID", ""),
-		razorpaySecret: getEnv("RAZORPAY_KEY_SECRET", ""),
+		paymentRepo:     paymentRepo,
+		rideRepo:        rideRepo,
+		eventRepo:       eventRepo,
+		refundRepo:      refundRepo,
+		walletRepo:      walletRepo,
+		ledgerRepo:      ledgerRepo,
+		earningsRepo:    earningsRepo,
+		discrepancyRepo: discrepancyRepo,
+		plan:            plan,
+		reconcile:       reconcile,
+		gateway:         gw,
+		notifier:        notifier,
+		ridePaid:        ridePaid,
 	}
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// gatewayOf returns the configured gateway if an order of it was created at it, nil for orders
+// made through another provider before it was switched
+func (s *paymentService) gatewayOf(provider *string) gateway.PaymentGateway {
+	if s.gateway == nil || provider == nil || *provider != s.gateway.Name() {
+		return nil
 	}
-	return defaultValue
+	return s.gateway
 }
 
-
-
+// CreatePaymentOrder opens the payment of a completed ride. The amount is computed from the ride's
+// charges. A ride has at most one outstanding payment, asking again for the same amount returns it,
+// a changed tip replaces it as long as the rider has not started paying it.
 func (s *paymentService) CreatePaymentOrder(ctx context.Context, userID string, req *model.CreatePaymentOrderRequest) (*model.CreatePaymentOrderResponse, error) {
 	// Verify ride exists and belongs to user
 	ride, err := s.rideRepo.GetByID(ctx, req.RideID)
@@ -61,107 +123,356 @@ func (s *paymentService) CreatePaymentOrder(ctx context.Context, userID string,
 		return nil, errs.NewUnauthorized("unauthorized access to ride")
 	}
 
+	if ride.Status != model.RideStatusCompleted {
+		return nil, errs.NewBadRequest("ride is not completed yet")
+	}
+	if ride.PaymentMethod == nil || string(*ride.PaymentMethod) != req.PaymentMethod {
+		return nil, errs.NewBadRequest("payment method does not match the ride")
+	}
+	if ride.PaymentStatus == model.PaymentStatusCompleted {
+		return nil, paymentConflict("ride is already paid")
+	}
+
+	charges, err := s.rideRepo.GetCharges(ctx, ride.ID)
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+	// A changed tip is saved with the payment that collects it, a refused request leaves it as it was
+	tipChanged := req.Tip != charges.Tip
+	charges.Tip = req.Tip
+	amount := charges.Payable()
+
+	if req.PaymentMethod == string(model.PaymentMethodWallet) {
+		wallet, err := s.ledgerRepo.GetAccount(ctx, model.LedgerAccountRiderWallet, userID)
+		if err != nil {
+			return nil, errs.NewInternalServerError()
+		}
+		if wallet.Balance < gateway.ToPaise(amount) {
+			return nil, insufficientWalletBalance()
+		}
+	}
+
+	// Online payments are collected through the gateway, without one they cannot be paid
+	online := req.PaymentMethod != string(model.PaymentMethodCash) && req.PaymentMethod != string(model.PaymentMethodWallet)
+	if online && amount > 0 && s.gateway == nil {
+		return nil, errs.NewServiceUnavailableError("payment gateway is not configured", true)
+	}
+
+	outstanding, err := s.paymentRepo.GetOutstandingByRideID(ctx, ride.ID)
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+	if outstanding != nil {
+		if gateway.ToPaise(outstanding.Amount) == gateway.ToPaise(amount) {
+			if outstanding.PaymentMethod == string(model.PaymentMethodWallet) {
+				// An earlier request was interrupted before the wallet was charged
+				return s.payFromWallet(ctx, outstanding, charges)
+			}
+			return s.orderResponse(outstanding, charges), nil
+		}
+		if err := s.supersedePayment(ctx, outstanding); err != nil {
+			return nil, err
+		}
+	}
 
-	
 	// Create payment record
 	payment := &model.Payment{
-		RideID:        req.RideID,
+		RideID:        ride.ID,
 		UserID:        userID,
-		Amount:        req.Amount,
+		Amount:        amount,
 		Currency:      "INR",
 		Status:        model.PaymentStatusTypeCreated,
 		PaymentMethod: req.PaymentMethod,
 	}
 
-	// For cash payments, no Razorpay order needed
-	if req.PaymentMethod == "cash" {
+	// For cash payments, no gateway order needed
+	if req.PaymentMethod == string(model.PaymentMethodCash) {
 		payment.Status = model.PaymentStatusTypePending
-		if err := s.paymentRepo.Create(ctx, payment); err != nil {
-			return nil, errs.NewInternalServerError()
+	}
+
+	// The payment row comes first so the order can carry its id as receipt
+	if tipChanged {
+		err = s.paymentRepo.CreateWithTip(ctx, payment, req.Tip)
+	} else {
+		err = s.paymentRepo.Create(ctx, payment)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrOutstandingPayment) {
+			return nil, paymentConflict("a payment for this ride is already in progress")
 		}
+		return nil, errs.NewInternalServerError()
+	}
+
+	// Fully covered by wallet and promo, there is nothing to collect
+	if amount == 0 {
+		if err := s.transitionPayment(ctx, payment, model.PaymentStatusTypeCaptured, nil, nil); err != nil {
+			return nil, err
+		}
+		return s.orderResponse(payment, charges), nil
+	}
+
+	if payment.PaymentMethod == string(model.PaymentMethodWallet) {
+		return s.payFromWallet(ctx, payment, charges)
+	}
 
-		return &model.CreatePaymentOrderResponse{
-			PaymentID:     payment.ID,
-			Amount:        payment.Amount,
-			Currency:      payment.Currency,
-			PaymentMethod: payment.PaymentMethod,
-		}, nil
+	if payment.PaymentMethod == string(model.PaymentMethodCash) {
+		return s.orderResponse(payment, charges), nil
 	}
 
-	// For online payments (UPI, card, wallet), create Razorpay order
-	// Note: In production, you would integrate with Razorpay SDK here
-	// For now, we'll simulate the order creation
-	if s.razorpayKey != "" && s.razorpaySecret != "" {
-		// TODO: Integrate actual Razorpay SDK
-		// orderID, err := createRazorpayOrder(req.Amount)
-		// For now, generate a mock order ID
-		orderID := fmt.Sprintf("order_%s", payment.ID)
-		payment.RazorpayOrderID = &orderID
+	// For online payments (UPI, card), create the gateway order
+	order, err := s.gateway.CreateOrder(ctx, gateway.OrderRequest{
+		Amount:   gateway.ToPaise(payment.Amount),
+		Currency: payment.Currency,
+		Receipt:  payment.ID,
+		Notes:    map[string]string{"payment_id": payment.ID, "ride_id": payment.RideID},
+	})
+	if err != nil {
+		payment.Status = model.PaymentStatusTypeFailed
+		_ = s.paymentRepo.Update(ctx, payment)
+		return nil, errs.NewServiceUnavailableError("payment gateway is unavailable, please try again", true)
 	}
 
-	if err := s.paymentRepo.Create(ctx, payment); err != nil {
+	provider := s.gateway.Name()
+	if err := s.paymentRepo.SetGatewayOrder(ctx, payment.ID, provider, order.ID); err != nil {
 		return nil, errs.NewInternalServerError()
 	}
+	payment.Provider = &provider
+	payment.GatewayOrderID = &order.ID
 
-	response := &model.CreatePaymentOrderResponse{
-		PaymentID:       payment.ID,
-		RazorpayOrderID: payment.RazorpayOrderID,
-		Amount:          payment.Amount,
-		Currency:        payment.Currency,
-		PaymentMethod:   payment.PaymentMethod,
+	return s.orderResponse(payment, charges), nil
+}
+
+// supersedePayment fails an outstanding payment so a new one for a different amount can be opened.
+// Payments the rider may already have paid stay, the new payment is refused instead.
+func (s *paymentService) supersedePayment(ctx context.Context, payment *model.Payment) error {
+	if payment.Status == model.PaymentStatusTypeAuthorized {
+		return paymentConflict("a payment for this ride is already in progress")
+	}
+	if gw := s.gatewayOf(payment.Provider); gw != nil && payment.GatewayOrderID != nil {
+		order, err := gw.FetchOrder(ctx, *payment.GatewayOrderID)
+		if err != nil {
+			return errs.NewServiceUnavailableError("payment gateway is unavailable, please try again", true)
+		}
+		if order.Attempts > 0 {
+			return paymentConflict("a payment for this ride is already in progress")
+		}
 	}
 
-	if s.razorpayKey != "" {
-		response.RazorpayKeyID = &s.razorpayKey
+	// Not through transitionPayment, the ride is still waiting for the payment that replaces it
+	from := payment.Status
+	payment.Status = model.PaymentStatusTypeFailed
+	updated, err := s.paymentRepo.TransitionStatus(ctx, payment, from)
+	if err != nil {
+		return errs.NewInternalServerError()
 	}
+	if !updated {
+		return paymentConflict("a payment for this ride is already in progress")
+	}
+	return nil
+}
 
-	return response, nil
+func (s *paymentService) orderResponse(payment *model.Payment, charges *model.RideCharges) *model.CreatePaymentOrderResponse {
+	response := &model.CreatePaymentOrderResponse{
+		PaymentID:      payment.ID,
+		Provider:       payment.Provider,
+		GatewayOrderID: payment.GatewayOrderID,
+		Amount:         payment.Amount,
+		Currency:       payment.Currency,
+		PaymentMethod:  payment.PaymentMethod,
+		Status:         string(payment.Status),
+		Breakdown:      *charges,
+	}
+	if gw := s.gatewayOf(payment.Provider); gw != nil && payment.GatewayOrderID != nil {
+		keyID := gw.KeyID()
+		response.GatewayKeyID = &keyID
+	}
+	return response
 }
 
-func (s *paymentService) VerifyPayment(ctx context.Context, req *model.VerifyPaymentRequest) error {
+// VerifyPayment confirms the checkout of the caller's payment. The signature proves the ids came
+// from the gateway, the payment is then fetched to check it belongs to the order, has the order's
+// amount and succeeded. Authorized payments are captured.
+func (s *paymentService) VerifyPayment(ctx context.Context, userID string, req *model.VerifyPaymentRequest) error {
 	// Get payment
 	payment, err := s.paymentRepo.GetByID(ctx, req.PaymentID)
 	if err != nil {
 		return errs.NewBadRequest("payment not found")
 	}
 
-	// Verify Razorpay signature
-	if s.razorpaySecret != "" {
-		if !s.verifyRazorpaySignature(req.RazorpayOrderID, req.RazorpayPaymentID, req.RazorpaySignature) {
-			payment.Status = model.PaymentStatusTypeFailed
-			s.paymentRepo.Update(ctx, payment)
-			return errs.NewBadRequest("invalid payment signature")
-		}
+	// Verify payment belongs to user
+	if payment.UserID != userID {
+		return errs.NewUnauthorized("unauthorized access to payment")
 	}
 
-	// Update payment status
-	payment.Status = model.PaymentStatusTypeCaptured
-	payment.RazorpayPaymentID = &req.RazorpayPaymentID
-	payment.RazorpaySignature = &req.RazorpaySignature
+	// A retried verify, or one racing the webhook, of an already captured payment succeeds
+	if payment.Status == model.PaymentStatusTypeCaptured &&
+		payment.GatewayPaymentID != nil && *payment.GatewayPaymentID == req.GatewayPaymentID {
+		return nil
+	}
 
-	if err := s.paymentRepo.Update(ctx, payment); err != nil {
-		return errs.NewInternalServerError()
+	// Nothing can vouch for the checkout without a gateway
+	if s.gateway == nil {
+		return errs.NewServiceUnavailableError("payment gateway is not configured", true)
+	}
+	if payment.GatewayOrderID == nil || *payment.GatewayOrderID != req.GatewayOrderID {
+		return errs.NewBadRequest("order does not belong to this payment")
+	}
+	gw := s.gatewayOf(payment.Provider)
+	if gw == nil {
+		return errs.NewBadRequest("payment was ordered through another payment provider")
 	}
 
-	// Update ride payment status
-	ride, err := s.rideRepo.GetByID(ctx, payment.RideID)
+	// Verify the checkout signature
+	if !gw.VerifyPaymentSignature(req.GatewayOrderID, req.GatewayPaymentID, req.GatewaySignature) {
+		s.transitionPayment(ctx, payment, model.PaymentStatusTypeFailed, nil, nil)
+		return errs.NewBadRequest("invalid payment signature")
+	}
+
+	err = s.captureGatewayPayment(ctx, gw, *payment.GatewayOrderID, payment.Amount, req.GatewayPaymentID)
+	if errors.Is(err, errGatewayPaymentFailed) {
+		s.transitionPayment(ctx, payment, model.PaymentStatusTypeFailed, &req.GatewayPaymentID, nil)
+		return errs.NewBadRequest("payment was not successful")
+	}
 	if err != nil {
 		return err
 	}
 
-	if err := s.rideRepo.UpdatePaymentStatus(ctx, ride.ID, model.PaymentStatusCompleted, payment.ID); err != nil {
-		return errs.NewInternalServerError()
+	err = s.transitionPayment(ctx, payment, model.PaymentStatusTypeCaptured, &req.GatewayPaymentID, &req.GatewaySignature)
+	if errors.Is(err, errPaymentTransition) {
+		return paymentConflict(fmt.Sprintf("payment is already %s", payment.Status))
 	}
+	return err
+}
 
-	return nil
+// errGatewayPaymentFailed is returned by captureGatewayPayment when the payment did not succeed
+var errGatewayPaymentFailed = errors.New("gateway payment was not successful")
+
+// captureGatewayPayment checks the gateway's view of a payment of an order and captures it when
+// only authorized
+func (s *paymentService) captureGatewayPayment(ctx context.Context, gw gateway.PaymentGateway, orderID string, amount float64, gatewayPaymentID string) error {
+	gp, err := gw.FetchPayment(ctx, gatewayPaymentID)
+	if err != nil {
+		if errors.Is(err, gateway.ErrNotFound) {
+			return errs.NewBadRequest("payment not found at gateway")
+		}
+		return errs.NewServiceUnavailableError("payment gateway is unavailable, please try again", true)
+	}
+
+	if gp.OrderID != orderID || gp.Amount != gateway.ToPaise(amount) {
+		return errs.NewBadRequest("gateway payment does not match the order")
+	}
+
+	switch gp.Status {
+	case gateway.PaymentStatusCaptured:
+		return nil
+	case gateway.PaymentStatusAuthorized:
+		if _, err := gw.CapturePayment(ctx, gp.ID, gp.Amount, gp.Currency); err != nil {
+			return errs.NewServiceUnavailableError("failed to capture payment, please try again", true)
+		}
+		return nil
+	default:
+		return errGatewayPaymentFailed
+	}
+}
+
+// Allowed payment status changes. A failed payment can still be captured, gateways accept further
+// attempts on the same order.
+var paymentTransitions = map[model.PaymentStatusType][]model.PaymentStatusType{
+	model.PaymentStatusTypeCreated:    {model.PaymentStatusTypePending, model.PaymentStatusTypeCaptured, model.PaymentStatusTypeFailed},
+	model.PaymentStatusTypePending:    {model.PaymentStatusTypeCaptured, model.PaymentStatusTypeFailed},
+	model.PaymentStatusTypeAuthorized: {model.PaymentStatusTypeCaptured, model.PaymentStatusTypeFailed},
+	model.PaymentStatusTypeFailed:     {model.PaymentStatusTypeCaptured},
+	model.PaymentStatusTypeCaptured:   {model.PaymentStatusTypePartiallyRefunded, model.PaymentStatusTypeRefunded},
+	// Further partial refunds keep the status, the last one completes the refund
+	model.PaymentStatusTypePartiallyRefunded: {model.PaymentStatusTypeRefunded},
 }
 
-func (s *paymentService) verifyRazorpaySignature(orderID, paymentID, signature string) bool {
-	message := orderID + "|" + paymentID
-	mac := hmac.New(sha256.New, []byte(s.razorpaySecret))
-	mac.Write([]byte(message))
-	expectedSignature := hex.EncodeToString(mac.Sum(nil))
-	return hmac.Equal([]byte(signature), []byte(expectedSignature))
+// Ride payment status that follows a payment status
+var ridePaymentStatuses = map[model.PaymentStatusType]model.PaymentStatus{
+	model.PaymentStatusTypeCaptured:          model.PaymentStatusCompleted,
+	model.PaymentStatusTypeFailed:            model.PaymentStatusFailed,
+	model.PaymentStatusTypeRefunded:          model.PaymentStatusRefunded,
+	model.PaymentStatusTypePartiallyRefunded: model.PaymentStatusPartiallyRefunded,
+}
+
+var codePaymentStateConflict = "PAYMENT_STATE_CONFLICT"
+
+// paymentConflict reports a request that does not fit the current state of the payment
+func paymentConflict(message string) error {
+	return errs.NewBadRequestError(message, false, &codePaymentStateConflict, nil, nil)
+}
+
+// errPaymentTransition is returned when a payment is not in a state the requested change applies to
+var errPaymentTransition = errors.New("payment status transition not allowed")
+
+// transitionPayment is the single path for payment status changes made by the checkout verification,
+// cash confirmation and gateway webhooks. It updates the payment only if nobody changed it in the
+// meantime and mirrors the outcome on the ride. A capture is posted to the ledger first, so an
+// interrupted capture is posted once when retried. Moving to the current status is a no-op.
+// A payment captured for a ride another payment already paid is refunded in full instead.
+func (s *paymentService) transitionPayment(ctx context.Context, payment *model.Payment, to model.PaymentStatusType, gatewayPaymentID, signature *string) error {
+	from := payment.Status
+	if from == to {
+		return nil
+	}
+	if !slices.Contains(paymentTransitions[from], to) {
+		return errPaymentTransition
+	}
+
+	// The ride follows only the payment that paid it
+	var duplicate bool
+	if _, ok := ridePaymentStatuses[to]; ok {
+		var err error
+		if duplicate, err = s.paidByAnother(ctx, payment); err != nil {
+			return err
+		}
+	}
+	if to == model.PaymentStatusTypeCaptured {
+		if err := s.recordRidePayment(ctx, payment); err != nil {
+			return err
+		}
+	}
+
+	payment.Status = to
+	if gatewayPaymentID != nil {
+		payment.GatewayPaymentID = gatewayPaymentID
+	}
+	if signature != nil {
+		payment.GatewaySignature = signature
+	}
+
+	updated, err := s.paymentRepo.TransitionStatus(ctx, payment, from)
+	if err != nil {
+		return errs.NewInternalServerError()
+	}
+	if !updated {
+		// Another request moved the payment first, fine if it got where we wanted
+		current, err := s.paymentRepo.GetByID(ctx, payment.ID)
+		if err != nil {
+			return errs.NewInternalServerError()
+		}
+		*payment = *current
+		if current.Status == to {
+			return nil
+		}
+		return errPaymentTransition
+	}
+
+	if duplicate {
+		if to == model.PaymentStatusTypeCaptured {
+			return s.refundDuplicatePayment(ctx, payment)
+		}
+		return nil
+	}
+	if rideStatus, ok := ridePaymentStatuses[to]; ok {
+		if err := s.rideRepo.UpdatePaymentStatus(ctx, payment.RideID, rideStatus, payment.ID); err != nil {
+			return errs.NewInternalServerError()
+		}
+	}
+	if to == model.PaymentStatusTypeCaptured && s.ridePaid != nil {
+		s.ridePaid.RidePaid(ctx, payment)
+	}
+	return nil
 }
 
 func (s *paymentService) ProcessCashPayment(ctx context.Context, userID string, req *model.CashPaymentRequest) error {
@@ -177,27 +488,16 @@ func (s *paymentService) ProcessCashPayment(ctx context.Context, userID string,
 	}
 
 	// Verify it's a cash payment
-	if payment.PaymentMethod != "cash" {
+	if payment.PaymentMethod != string(model.PaymentMethodCash) {
 		return errs.NewBadRequest("not a cash payment")
 	}
 
 	// Update payment status to captured (completed)
-	payment.Status = model.PaymentStatusTypeCaptured
-	if err := s.paymentRepo.Update(ctx, payment); err != nil {
-		return errs.NewInternalServerError()
+	err = s.transitionPayment(ctx, payment, model.PaymentStatusTypeCaptured, nil, nil)
+	if errors.Is(err, errPaymentTransition) {
+		return paymentConflict(fmt.Sprintf("payment is already %s", payment.Status))
 	}
-
-	// Update ride payment status
-	ride, err := s.rideRepo.GetByID(ctx, payment.RideID)
-	if err != nil {
-		return err
-	}
-
-	if err := s.rideRepo.UpdatePaymentStatus(ctx, ride.ID, model.PaymentStatusCompleted, payment.ID); err != nil {
-		return errs.NewInternalServerError()
-	}
-
-	return nil
+	return err
 }
 
 func (s *paymentService) ProcessUPIPayment(ctx context.Context, userID string, req *model.UPIPaymentRequest) error {
@@ -212,14 +512,21 @@ func (s *paymentService) ProcessUPIPayment(ctx context.Context, userID string, r
 		return errs.NewUnauthorized("unauthorized access to payment")
 	}
 
-	// In production, you would initiate UPI payment request here
-	// For now, we'll just mark it as pending and expect verification later
-	payment.Status = model.PaymentStatusTypePending
-	if err := s.paymentRepo.Update(ctx, payment); err != nil {
-		return errs.NewInternalServerError()
+	if payment.PaymentMethod != string(model.PaymentMethodUPI) {
+		return errs.NewBadRequest("not a UPI payment")
 	}
 
-	return nil
+	// In production, you would initiate UPI payment request here
+	// For now, we'll just mark it as pending and expect verification later. Only a payment nobody
+	// started paying can be marked, a captured or refunded one must not go back to pending.
+	if payment.Status != model.PaymentStatusTypeCreated {
+		return paymentConflict(fmt.Sprintf("payment is already %s", payment.Status))
+	}
+	err = s.transitionPayment(ctx, payment, model.PaymentStatusTypePending, nil, nil)
+	if errors.Is(err, errPaymentTransition) {
+		return paymentConflict(fmt.Sprintf("payment is already %s", payment.Status))
+	}
+	return err
 }
 
 func (s *paymentService) GetPaymentByID(ctx context.Context, paymentID string) (*model.Payment, error) {
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+)
+
+// Ledger accounts money moves between
+func riderWallet(userID string) *model.LedgerPosting {
+	return &model.LedgerPosting{AccountType: model.LedgerAccountRiderWallet, OwnerID: &userID}
+}
+
+func driverEarnings(driverID string) *model.LedgerPosting {
+	return &model.LedgerPosting{AccountType: model.LedgerAccountDriverEarnings, OwnerID: &driverID}
+}
+
+func platformCommission() *model.LedgerPosting {
+	return &model.LedgerPosting{AccountType: model.LedgerAccountPlatformCommission}
+}
+
+func gatewayClearing() *model.LedgerPosting {
+	return &model.LedgerPosting{AccountType: model.LedgerAccountGatewayClearing}
+}
+
+func driverPayouts() *model.LedgerPosting {
+	return &model.LedgerPosting{AccountType: model.LedgerAccountDriverPayouts}
+}
+
+func platformPromotions() *model.LedgerPosting {
+	return &model.LedgerPosting{AccountType: model.LedgerAccountPlatformPromotions}
+}
+
+// transfer builds a journal entry moving an amount in paise from one account to another
+func transfer(entryType model.JournalEntryType, referenceID, description, currency string, amount int64, from, to *model.LedgerPosting) *model.JournalEntry {
+	from.Amount = -amount
+	to.Amount = amount
+	return journalEntry(entryType, referenceID, description, currency, from, to)
+}
+
+// journalEntry builds a journal entry from postings with their amounts set. Postings of nothing,
+// like the commission of a commission-free ride, are left out.
+func journalEntry(entryType model.JournalEntryType, referenceID, description, currency string, postings ...*model.LedgerPosting) *model.JournalEntry {
+	entry := &model.JournalEntry{
+		Type:        entryType,
+		ReferenceID: referenceID,
+		Description: description,
+		Currency:    currency,
+	}
+	for _, p := range postings {
+		if p.Amount != 0 {
+			entry.Postings = append(entry.Postings, p)
+		}
+	}
+	return entry
+}
+
+func credit(p *model.LedgerPosting, amount int64) *model.LedgerPosting {
+	p.Amount = amount
+	return p
+}
+
+func debit(p *model.LedgerPosting, amount int64) *model.LedgerPosting {
+	p.Amount = -amount
+	return p
+}
+
+// postEntry posts a journal entry, posting it again changes nothing. A wallet that cannot cover
+// the entry is reported as repository.ErrInsufficientBalance.
+func (s *paymentService) postEntry(ctx context.Context, entry *model.JournalEntry) error {
+	_, err := s.ledgerRepo.Post(ctx, entry)
+	if errors.Is(err, repository.ErrInsufficientBalance) {
+		return err
+	}
+	if err != nil {
+		return errs.NewInternalServerError()
+	}
+	return nil
+}
+
+// rideEarning splits a ride payment between the driver and the platform. The commission plan rate
+// of the ride's vehicle type applies to the fare, the tip goes to the driver in full. A promo
+// discount is paid by the platform, the driver earns on the fare before it.
+func (s *paymentService) rideEarning(ctx context.Context, payment *model.Payment) (*model.RideEarning, error) {
+	ride, err := s.rideRepo.GetByID(ctx, payment.RideID)
+	if err != nil || ride.DriverID == nil {
+		// Paid rides always have a driver
+		return nil, errs.NewInternalServerError()
+	}
+	charges, err := s.rideRepo.GetCharges(ctx, ride.ID)
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+
+	amount := gateway.ToPaise(payment.Amount)
+	tip := gateway.ToPaise(charges.Tip)
+	if tip > amount {
+		tip = amount
+	}
+	var vehicleType string
+	if ride.VehicleType != nil {
+		vehicleType = string(*ride.VehicleType)
+	}
+	promo := gateway.ToPaise(charges.PromoDiscount)
+	fare := amount - tip + promo
+	commission := int64(math.Round(float64(fare) * s.plan.CommissionRateFor(vehicleType)))
+
+	return &model.RideEarning{
+		Kind:          model.EarningKindRide,
+		ReferenceID:   payment.ID,
+		DriverID:      *ride.DriverID,
+		RideID:        ride.ID,
+		PaymentID:     payment.ID,
+		PaymentMethod: payment.PaymentMethod,
+		Fare:          fare,
+		Tip:           tip,
+		Commission:    commission,
+		Net:           fare + tip - commission,
+		Promo:         promo,
+	}, nil
+}
+
+// recordRidePayment posts a captured ride payment, from the rider's wallet or the gateway and the
+// platform's promotions to the driver's share of it and the platform's commission. Cash goes to
+// the driver directly, the driver is charged the commission and credited the promo instead.
+func (s *paymentService) recordRidePayment(ctx context.Context, payment *model.Payment) error {
+	amount := gateway.ToPaise(payment.Amount)
+	if amount == 0 {
+		// Nothing was collected, only a promo covering the whole fare leaves something to post
+		charges, err := s.rideRepo.GetCharges(ctx, payment.RideID)
+		if err != nil {
+			return errs.NewInternalServerError()
+		}
+		if charges.PromoDiscount == 0 {
+			return nil
+		}
+	}
+	earning, err := s.rideEarning(ctx, payment)
+	if err != nil {
+		return err
+	}
+
+	var entry *model.JournalEntry
+	switch payment.PaymentMethod {
+	case string(model.PaymentMethodCash):
+		entry = journalEntry(model.JournalEntryCashCommission, payment.ID, "Commission on cash ride", payment.Currency,
+			debit(platformPromotions(), earning.Promo),
+			credit(driverEarnings(earning.DriverID), earning.Promo-earning.Commission),
+			credit(platformCommission(), earning.Commission))
+	case string(model.PaymentMethodWallet):
+		entry = journalEntry(model.JournalEntryRidePayment, payment.ID, "Ride payment", payment.Currency,
+			debit(riderWallet(payment.UserID), amount),
+			debit(platformPromotions(), earning.Promo),
+			credit(driverEarnings(earning.DriverID), earning.Net),
+			credit(platformCommission(), earning.Commission))
+	default:
+		entry = journalEntry(model.JournalEntryRidePayment, payment.ID, "Ride payment", payment.Currency,
+			debit(gatewayClearing(), amount),
+			debit(platformPromotions(), earning.Promo),
+			credit(driverEarnings(earning.DriverID), earning.Net),
+			credit(platformCommission(), earning.Commission))
+	}
+	if len(entry.Postings) > 0 {
+		if err := s.postEntry(ctx, entry); err != nil {
+			return err
+		}
+	}
+
+	if err := s.earningsRepo.Record(ctx, earning); err != nil {
+		return errs.NewInternalServerError()
+	}
+	return nil
+}
+
+// recordRefund posts a processed refund. It reverses the refunded share of the ride payment: the
+// commission and promo in proportion, the rest from the driver's earnings, which go negative for
+// cash rides, the driver kept the cash.
+func (s *paymentService) recordRefund(ctx context.Context, refund *model.Refund) error {
+	payment, err := s.paymentRepo.GetByID(ctx, refund.PaymentID)
+	if err != nil {
+		return errs.NewInternalServerError()
+	}
+	paid, err := s.earningsRepo.GetRideEarning(ctx, payment.ID)
+	if err != nil {
+		return errs.NewInternalServerError()
+	}
+	if paid == nil {
+		// Captured before commissions were taken, the driver got the whole payment
+		driverID, err := s.rideDriver(ctx, payment.RideID)
+		if err != nil {
+			return err
+		}
+		paid = &model.RideEarning{
+			DriverID:      driverID,
+			RideID:        payment.RideID,
+			PaymentMethod: payment.PaymentMethod,
+			Fare:          gateway.ToPaise(payment.Amount),
+		}
+	}
+
+	amount := gateway.ToPaise(refund.Amount)
+	var commission, promo int64
+	if collected := paid.Fare + paid.Tip - paid.Promo; collected > 0 {
+		share := float64(amount) / float64(collected)
+		commission = int64(math.Round(float64(paid.Commission) * share))
+		promo = int64(math.Round(float64(paid.Promo) * share))
+	}
+
+	to, description := gatewayClearing(), "Refund to original payment method"
+	if refund.Destination == model.RefundDestinationWallet {
+		to, description = riderWallet(refund.UserID), "Refund to wallet"
+	}
+	err = s.postEntry(ctx, journalEntry(model.JournalEntryRefund, refund.ID, description, refund.Currency,
+		debit(driverEarnings(paid.DriverID), amount-commission+promo),
+		debit(platformCommission(), commission),
+		credit(platformPromotions(), promo),
+		credit(to, amount)))
+	if err != nil {
+		return err
+	}
+
+	err = s.earningsRepo.Record(ctx, &model.RideEarning{
+		Kind:          model.EarningKindRefund,
+		ReferenceID:   refund.ID,
+		DriverID:      paid.DriverID,
+		RideID:        payment.RideID,
+		PaymentID:     payment.ID,
+		PaymentMethod: payment.PaymentMethod,
+		Fare:          -(amount + promo),
+		Commission:    -commission,
+		Net:           commission - amount - promo,
+		Promo:         -promo,
+	})
+	if err != nil {
+		return errs.NewInternalServerError()
+	}
+	return nil
+}
+
+// rideDriver returns the driver of a ride, paid rides always have one
+func (s *paymentService) rideDriver(ctx context.Context, rideID string) (string, error) {
+	ride, err := s.rideRepo.GetByID(ctx, rideID)
+	if err != nil || ride.DriverID == nil {
+		return "", errs.NewInternalServerError()
+	}
+	return *ride.DriverID, nil
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/job"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+)
+
+const defaultDiscrepancyLimit = 50
+
+// registerReconciliation schedules the job that reconciles open payments with the gateway
+func (s *paymentService) registerReconciliation(srv *server.Server) error {
+	srv.Job.HandleFunc(job.TaskPaymentReconcile, func(ctx context.Context, t *asynq.Task) error {
+		result, err := s.ReconcilePayments(ctx)
+		if err != nil {
+			srv.Logger.Error().Err(err).Msg("Payment reconciliation failed")
+			return err
+		}
+		if result.Repaired > 0 || result.Flagged > 0 {
+			srv.Logger.Warn().
+				Int("checked", result.Checked).
+				Int("repaired", result.Repaired).
+				Int("flagged", result.Flagged).
+				Msg("Payment reconciliation found discrepancies")
+		}
+		return nil
+	})
+	if err := srv.Job.Schedule(s.reconcile.Schedule, job.NewPaymentReconcileTask()); err != nil {
+		return fmt.Errorf("failed to schedule payment reconciliation: %w", err)
+	}
+	return nil
+}
+
+// ReconcilePayments compares the payments still open a while after their order was created, and
+// the recently failed ones, with the gateway's records. Payments the gateway captured or failed are
+// moved there through the usual transitions, authorized ones are captured. Every difference is
+// recorded as a discrepancy, repaired or open for an admin. Wallet top-ups are reconciled the same
+// way afterwards. A payment or top-up that fails to reconcile does not stop the others.
+func (s *paymentService) ReconcilePayments(ctx context.Context) (*model.ReconciliationResult, error) {
+	now := time.Now()
+	createdBefore := now.Add(-s.reconcile.After)
+	failedAfter := now.Add(-s.reconcile.FailedWindow)
+
+	payments, err := s.paymentRepo.ListUnsettled(ctx, createdBefore, failedAfter, s.reconcile.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unsettled payments: %w", err)
+	}
+
+	result := &model.ReconciliationResult{}
+	var firstErr error
+	for _, payment := range payments {
+		if err := s.reconcileAndRecord(ctx, payment, result); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to reconcile payment %s: %w", payment.ID, err)
+		}
+	}
+
+	topups, err := s.walletRepo.ListUnsettledTopups(ctx, createdBefore, failedAfter, s.reconcile.BatchSize)
+	if err != nil {
+		return result, fmt.Errorf("failed to list unsettled top-ups: %w", err)
+	}
+	for _, topup := range topups {
+		if err := s.reconcileTopupAndMark(ctx, topup, result); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to reconcile top-up %s: %w", topup.ID, err)
+		}
+	}
+	return result, firstErr
+}
+
+func (s *paymentService) reconcileAndRecord(ctx context.Context, payment *model.Payment, result *model.ReconciliationResult) error {
+	found, err := s.reconcilePayment(ctx, payment)
+	if err != nil {
+		return err
+	}
+	result.Checked++
+
+	for _, discrepancy := range found {
+		inserted, err := s.discrepancyRepo.Record(ctx, discrepancy)
+		if err != nil {
+			return fmt.Errorf("failed to record discrepancy: %w", err)
+		}
+		switch {
+		case discrepancy.Status == model.DiscrepancyStatusRepaired:
+			result.Repaired++
+		case inserted:
+			result.Flagged++
+		}
+	}
+	return s.paymentRepo.MarkReconciled(ctx, payment.ID)
+}
+
+// reconcilePayment repairs an open payment from the attempts on its order and returns the
+// differences it found
+func (s *paymentService) reconcilePayment(ctx context.Context, payment *model.Payment) ([]*model.PaymentDiscrepancy, error) {
+	gw := s.gatewayOf(payment.Provider)
+	if gw == nil {
+		provider := "no provider"
+		if payment.Provider != nil {
+			provider = *payment.Provider
+		}
+		return []*model.PaymentDiscrepancy{newDiscrepancy(payment, model.DiscrepancyProviderUnavailable, model.DiscrepancyStatusOpen, nil,
+			fmt.Sprintf("order was created at %s, which is not the configured payment provider", provider))}, nil
+	}
+
+	attempts, err := gw.FetchOrderPayments(ctx, *payment.GatewayOrderID)
+	if errors.Is(err, gateway.ErrNotFound) {
+		return []*model.PaymentDiscrepancy{newDiscrepancy(payment, model.DiscrepancyOrderNotFound, model.DiscrepancyStatusOpen, nil,
+			"the gateway does not know the payment's order")}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*model.PaymentDiscrepancy
+	var captured, authorized []*gateway.Payment
+	failed := 0
+	for i := range attempts {
+		gp := &attempts[i]
+		if gp.Amount != gateway.ToPaise(payment.Amount) && gp.Status != gateway.PaymentStatusFailed {
+			found = append(found, newDiscrepancy(payment, model.DiscrepancyAmountMismatch, model.DiscrepancyStatusOpen, gp,
+				fmt.Sprintf("gateway payment is %s for ₹%.2f, the payment is for ₹%.2f", gp.Status, gateway.FromPaise(gp.Amount), payment.Amount)))
+			continue
+		}
+		switch gp.Status {
+		case gateway.PaymentStatusCaptured:
+			captured = append(captured, gp)
+		case gateway.PaymentStatusAuthorized:
+			authorized = append(authorized, gp)
+		case gateway.PaymentStatusRefunded:
+			// Refunded outside the app before we saw the capture, the ledger needs a person
+			found = append(found, newDiscrepancy(payment, model.DiscrepancyMissedCapture, model.DiscrepancyStatusOpen, gp,
+				"gateway payment was captured and refunded while the payment stayed open"))
+		case gateway.PaymentStatusFailed:
+			failed++
+		}
+	}
+
+	switch {
+	case len(captured) > 0:
+		gp := captured[0]
+		repaired := newDiscrepancy(payment, model.DiscrepancyMissedCapture, model.DiscrepancyStatusRepaired, gp,
+			"gateway captured the payment, marked captured")
+		if ok, err := s.repairPayment(ctx, payment, model.PaymentStatusTypeCaptured, gp); err != nil {
+			return nil, err
+		} else if ok {
+			found = append(found, repaired)
+		}
+		for _, extra := range captured[1:] {
+			found = append(found, newDiscrepancy(payment, model.DiscrepancyDuplicateCapture, model.DiscrepancyStatusOpen, extra,
+				fmt.Sprintf("order was paid again after gateway payment %s, refund one of them", gp.ID)))
+		}
+
+	case len(authorized) > 0:
+		gp := authorized[0]
+		repaired := newDiscrepancy(payment, model.DiscrepancyUncapturedAuthorization, model.DiscrepancyStatusRepaired, gp,
+			"gateway payment was only authorized, captured")
+		if err := s.captureGatewayPayment(ctx, gw, *payment.GatewayOrderID, payment.Amount, gp.ID); err != nil {
+			return nil, err
+		}
+		if ok, err := s.repairPayment(ctx, payment, model.PaymentStatusTypeCaptured, gp); err != nil {
+			return nil, err
+		} else if ok {
+			found = append(found, repaired)
+		}
+
+	case failed > 0 && failed == len(attempts) && payment.Status != model.PaymentStatusTypeFailed:
+		gp := &attempts[len(attempts)-1]
+		repaired := newDiscrepancy(payment, model.DiscrepancyMissedFailure, model.DiscrepancyStatusRepaired, gp,
+			"every attempt on the order failed at the gateway, marked failed")
+		if ok, err := s.repairPayment(ctx, payment, model.PaymentStatusTypeFailed, gp); err != nil {
+			return nil, err
+		} else if ok {
+			found = append(found, repaired)
+		}
+	}
+	return found, nil
+}
+
+func (s *paymentService) reconcileTopupAndMark(ctx context.Context, topup *model.WalletTopup, result *model.ReconciliationResult) error {
+	repaired, err := s.reconcileTopup(ctx, topup)
+	if err != nil {
+		return err
+	}
+	result.Checked++
+	if repaired {
+		result.Repaired++
+	}
+	return s.walletRepo.MarkTopupReconciled(ctx, topup.ID)
+}
+
+// reconcileTopup credits a top-up the gateway captured, capturing an authorized attempt first, and
+// fails an open one whose attempts all failed. It reports whether it changed the top-up. Top-ups
+// have no discrepancy report, attempts for another amount and unknown orders are left alone.
+func (s *paymentService) reconcileTopup(ctx context.Context, topup *model.WalletTopup) (bool, error) {
+	gw := s.gatewayOf(topup.Provider)
+	if gw == nil {
+		return false, nil
+	}
+	attempts, err := gw.FetchOrderPayments(ctx, *topup.GatewayOrderID)
+	if errors.Is(err, gateway.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var authorized *gateway.Payment
+	failed := 0
+	for i := range attempts {
+		gp := &attempts[i]
+		if gp.Amount != gateway.ToPaise(topup.Amount) && gp.Status != gateway.PaymentStatusFailed {
+			continue
+		}
+		switch gp.Status {
+		case gateway.PaymentStatusCaptured:
+			return true, s.creditTopup(ctx, topup, gp.ID)
+		case gateway.PaymentStatusAuthorized:
+			if authorized == nil {
+				authorized = gp
+			}
+		case gateway.PaymentStatusFailed:
+			failed++
+		}
+	}
+
+	switch {
+	case authorized != nil:
+		if err := s.captureGatewayPayment(ctx, gw, *topup.GatewayOrderID, topup.Amount, authorized.ID); err != nil {
+			return false, err
+		}
+		return true, s.creditTopup(ctx, topup, authorized.ID)
+	case failed > 0 && failed == len(attempts) && topup.Status == model.WalletTopupStatusCreated:
+		s.failTopup(ctx, topup)
+		return true, nil
+	}
+	return false, nil
+}
+
+// repairPayment moves a payment to the gateway's status and reports whether it did. A payment
+// someone else moved elsewhere in the meantime is left alone.
+func (s *paymentService) repairPayment(ctx context.Context, payment *model.Payment, to model.PaymentStatusType, gp *gateway.Payment) (bool, error) {
+	err := s.transitionPayment(ctx, payment, to, &gp.ID, nil)
+	if errors.Is(err, errPaymentTransition) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func newDiscrepancy(payment *model.Payment, kind model.DiscrepancyKind, status model.DiscrepancyStatus, gp *gateway.Payment, details string) *model.PaymentDiscrepancy {
+	discrepancy := &model.PaymentDiscrepancy{
+		PaymentID:      payment.ID,
+		Kind:           kind,
+		Status:         status,
+		PaymentStatus:  payment.Status,
+		Amount:         payment.Amount,
+		Provider:       payment.Provider,
+		GatewayOrderID: payment.GatewayOrderID,
+		Details:        details,
+	}
+	if gp != nil {
+		gatewayStatus := string(gp.Status)
+		gatewayAmount := gateway.FromPaise(gp.Amount)
+		discrepancy.GatewayPaymentID = &gp.ID
+		discrepancy.GatewayStatus = &gatewayStatus
+		discrepancy.GatewayAmount = &gatewayAmount
+	}
+	return discrepancy
+}
+
+// ListDiscrepancies returns the reconciliation discrepancies in a status, open ones by default
+func (s *paymentService) ListDiscrepancies(ctx context.Context, req *model.ListPaymentDiscrepanciesRequest) ([]*model.PaymentDiscrepancy, error) {
+	status := model.DiscrepancyStatusOpen
+	if req.Status != "" {
+		status = model.DiscrepancyStatus(req.Status)
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultDiscrepancyLimit
+	}
+
+	discrepancies, err := s.discrepancyRepo.List(ctx, status, limit)
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+	if discrepancies == nil {
+		discrepancies = []*model.PaymentDiscrepancy{}
+	}
+	return discrepancies, nil
+}
+
+// ResolveDiscrepancy closes an open discrepancy on behalf of an admin
+func (s *paymentService) ResolveDiscrepancy(ctx context.Context, adminID string, req *model.ResolvePaymentDiscrepancyRequest) (*model.PaymentDiscrepancy, error) {
+	discrepancy, err := s.discrepancyRepo.Resolve(ctx, req.ID, adminID, req.Note)
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+	if discrepancy == nil {
+		return nil, errs.NewNotFoundError("open discrepancy not found", false, nil)
+	}
+	return discrepancy, nil
+}
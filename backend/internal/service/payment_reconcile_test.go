@@ -0,0 +1,342 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/razorpay"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/razorpay/razorpaytest"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaymentReconciliation(t *testing.T) {
+	gw := razorpaytest.NewServer("rzp_test_key", "secret")
+	defer gw.Close()
+	client := razorpay.NewClient(gw.Config())
+	ctx := context.Background()
+
+	const rideID = "ride-1"
+	driverID := "driver-1"
+	provider := "razorpay"
+
+	type fixture struct {
+		service       service.PaymentService
+		payments      *testutil.MockPaymentRepository
+		rides         *testutil.MockRideRepository
+		discrepancies *testutil.MockPaymentDiscrepancyRepository
+		wallet        *testutil.MockWalletRepository
+		ledger        *testutil.MockLedgerRepository
+		noTopups      *mock.Call
+		recorded      []*model.PaymentDiscrepancy
+	}
+
+	setup := func(t *testing.T, unsettled ...*model.Payment) *fixture {
+		f := &fixture{
+			payments:      new(testutil.MockPaymentRepository),
+			rides:         new(testutil.MockRideRepository),
+			discrepancies: new(testutil.MockPaymentDiscrepancyRepository),
+			wallet:        new(testutil.MockWalletRepository),
+			ledger:        new(testutil.MockLedgerRepository),
+		}
+		ledger := f.ledger
+		earnings := new(testutil.MockEarningsRepository)
+
+		// Failed payments are checked for longer than open ones are left alone
+		f.payments.On("ListUnsettled", mock.Anything, mock.Anything, mock.Anything, 200).Run(func(args mock.Arguments) {
+			require.True(t, args.Get(2).(time.Time).Before(args.Get(1).(time.Time)))
+		}).Return(unsettled, nil).Once()
+		f.noTopups = f.wallet.On("ListUnsettledTopups", mock.Anything, mock.Anything, mock.Anything, 200).Return(nil, nil).Maybe()
+		f.payments.On("MarkReconciled", mock.Anything, mock.Anything).Return(nil)
+		f.payments.On("TransitionStatus", mock.Anything, mock.Anything, mock.Anything).Return(true, nil).Maybe()
+		f.rides.On("GetByID", mock.Anything, rideID).Return(&model.Ride{ID: rideID, DriverID: &driverID}, nil).Maybe()
+		f.rides.On("GetCharges", mock.Anything, rideID).Return(&model.RideCharges{Fare: 250}, nil).Maybe()
+		ledger.On("Post", mock.Anything, mock.Anything).Return(true, nil).Maybe()
+		earnings.On("Record", mock.Anything, mock.Anything).Return(nil).Maybe()
+		f.discrepancies.On("Record", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			f.recorded = append(f.recorded, args.Get(1).(*model.PaymentDiscrepancy))
+		}).Return(true, nil).Maybe()
+
+		f.service = service.NewPaymentService(f.payments, f.rides, new(testutil.MockPaymentEventRepository), nil, f.wallet, ledger,
+			earnings, f.discrepancies, config.DefaultEarningsConfig(), &config.DefaultPaymentConfig().Reconciliation, gateway.NewRazorpay(client), nil, nil)
+		return f
+	}
+
+	// openPayment returns a payment stuck in created with a fresh order of the given amount in paise
+	openPayment := func(t *testing.T, id string, amount int64) *model.Payment {
+		order, err := client.CreateOrder(ctx, razorpay.CreateOrderRequest{Amount: amount, Currency: "INR", Receipt: id})
+		require.NoError(t, err)
+		return &model.Payment{
+			ID: id, RideID: rideID, Amount: 250, Currency: "INR", Provider: &provider,
+			GatewayOrderID: &order.ID, Status: model.PaymentStatusTypeCreated, PaymentMethod: "upi",
+		}
+	}
+
+	t.Run("Missed capture is repaired", func(t *testing.T) {
+		payment := openPayment(t, "payment-1", 25000)
+		gp, _, err := gw.Pay(*payment.GatewayOrderID, "upi")
+		require.NoError(t, err)
+		f := setup(t, payment)
+		f.rides.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusCompleted, payment.ID).Return(nil).Once()
+
+		result, err := f.service.ReconcilePayments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.ReconciliationResult{Checked: 1, Repaired: 1}, *result)
+		assert.Equal(t, model.PaymentStatusTypeCaptured, payment.Status)
+		assert.Equal(t, gp.ID, *payment.GatewayPaymentID)
+
+		require.Len(t, f.recorded, 1)
+		d := f.recorded[0]
+		assert.Equal(t, model.DiscrepancyMissedCapture, d.Kind)
+		assert.Equal(t, model.DiscrepancyStatusRepaired, d.Status)
+		assert.Equal(t, model.PaymentStatusTypeCreated, d.PaymentStatus)
+		assert.Equal(t, "captured", *d.GatewayStatus)
+		f.rides.AssertExpectations(t)
+		f.payments.AssertCalled(t, "MarkReconciled", mock.Anything, payment.ID)
+	})
+
+	t.Run("Authorized payment is captured", func(t *testing.T) {
+		payment := openPayment(t, "payment-2", 25000)
+		gp, _, err := gw.Authorize(*payment.GatewayOrderID, "upi")
+		require.NoError(t, err)
+		f := setup(t, payment)
+		f.rides.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusCompleted, payment.ID).Return(nil).Once()
+
+		result, err := f.service.ReconcilePayments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Repaired)
+		assert.Equal(t, model.PaymentStatusTypeCaptured, payment.Status)
+
+		captured, err := client.FetchPayment(ctx, gp.ID)
+		require.NoError(t, err)
+		assert.Equal(t, razorpay.PaymentStatusCaptured, captured.Status)
+		require.Len(t, f.recorded, 1)
+		assert.Equal(t, model.DiscrepancyUncapturedAuthorization, f.recorded[0].Kind)
+	})
+
+	t.Run("Declined order is failed", func(t *testing.T) {
+		payment := openPayment(t, "payment-3", 25000)
+		_, err := gw.Decline(*payment.GatewayOrderID, "upi")
+		require.NoError(t, err)
+		f := setup(t, payment)
+		f.rides.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusFailed, payment.ID).Return(nil).Once()
+
+		result, err := f.service.ReconcilePayments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Repaired)
+		assert.Equal(t, model.PaymentStatusTypeFailed, payment.Status)
+		require.Len(t, f.recorded, 1)
+		assert.Equal(t, model.DiscrepancyMissedFailure, f.recorded[0].Kind)
+	})
+
+	t.Run("Unattempted order is left open", func(t *testing.T) {
+		payment := openPayment(t, "payment-4", 25000)
+		f := setup(t, payment)
+
+		result, err := f.service.ReconcilePayments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.ReconciliationResult{Checked: 1}, *result)
+		assert.Equal(t, model.PaymentStatusTypeCreated, payment.Status)
+		assert.Empty(t, f.recorded)
+		f.payments.AssertNotCalled(t, "TransitionStatus", mock.Anything, mock.Anything, mock.Anything)
+		f.payments.AssertCalled(t, "MarkReconciled", mock.Anything, payment.ID)
+	})
+
+	t.Run("Payment of another amount is flagged, not captured", func(t *testing.T) {
+		payment := openPayment(t, "payment-5", 30000)
+		_, _, err := gw.Pay(*payment.GatewayOrderID, "upi")
+		require.NoError(t, err)
+		f := setup(t, payment)
+
+		result, err := f.service.ReconcilePayments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.ReconciliationResult{Checked: 1, Flagged: 1}, *result)
+		assert.Equal(t, model.PaymentStatusTypeCreated, payment.Status)
+		require.Len(t, f.recorded, 1)
+		d := f.recorded[0]
+		assert.Equal(t, model.DiscrepancyAmountMismatch, d.Kind)
+		assert.Equal(t, model.DiscrepancyStatusOpen, d.Status)
+		assert.Equal(t, 300.0, *d.GatewayAmount)
+	})
+
+	t.Run("Order paid twice is repaired and flagged", func(t *testing.T) {
+		payment := openPayment(t, "payment-6", 25000)
+		first, _, err := gw.Authorize(*payment.GatewayOrderID, "upi")
+		require.NoError(t, err)
+		_, _, err = gw.Pay(*payment.GatewayOrderID, "card")
+		require.NoError(t, err)
+		_, err = client.CapturePayment(ctx, first.ID, 25000, "INR")
+		require.NoError(t, err)
+		f := setup(t, payment)
+		f.rides.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusCompleted, payment.ID).Return(nil).Once()
+
+		result, err := f.service.ReconcilePayments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.ReconciliationResult{Checked: 1, Repaired: 1, Flagged: 1}, *result)
+		require.Len(t, f.recorded, 2)
+		assert.Equal(t, model.DiscrepancyMissedCapture, f.recorded[0].Kind)
+		assert.Equal(t, model.DiscrepancyDuplicateCapture, f.recorded[1].Kind)
+		assert.Equal(t, model.DiscrepancyStatusOpen, f.recorded[1].Status)
+	})
+
+	t.Run("Unknown orders and providers are flagged", func(t *testing.T) {
+		missing := "order_missing"
+		unknownOrder := &model.Payment{ID: "payment-7", RideID: rideID, Amount: 250, Provider: &provider,
+			GatewayOrderID: &missing, Status: model.PaymentStatusTypePending}
+		other := "fake"
+		otherProvider := &model.Payment{ID: "payment-8", RideID: rideID, Amount: 250, Provider: &other,
+			GatewayOrderID: &missing, Status: model.PaymentStatusTypeCreated}
+		f := setup(t, unknownOrder, otherProvider)
+
+		result, err := f.service.ReconcilePayments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.ReconciliationResult{Checked: 2, Flagged: 2}, *result)
+		require.Len(t, f.recorded, 2)
+		assert.Equal(t, model.DiscrepancyOrderNotFound, f.recorded[0].Kind)
+		assert.Equal(t, model.DiscrepancyProviderUnavailable, f.recorded[1].Kind)
+	})
+
+	t.Run("Capture after the payment failed is repaired", func(t *testing.T) {
+		payment := openPayment(t, "payment-10", 25000)
+		payment.Status = model.PaymentStatusTypeFailed
+		_, err := gw.Decline(*payment.GatewayOrderID, "upi")
+		require.NoError(t, err)
+		gp, _, err := gw.Pay(*payment.GatewayOrderID, "card")
+		require.NoError(t, err)
+		f := setup(t, payment)
+		f.rides.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusCompleted, payment.ID).Return(nil).Once()
+
+		result, err := f.service.ReconcilePayments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.ReconciliationResult{Checked: 1, Repaired: 1}, *result)
+		assert.Equal(t, model.PaymentStatusTypeCaptured, payment.Status)
+		assert.Equal(t, gp.ID, *payment.GatewayPaymentID)
+		require.Len(t, f.recorded, 1)
+		assert.Equal(t, model.DiscrepancyMissedCapture, f.recorded[0].Kind)
+		assert.Equal(t, model.PaymentStatusTypeFailed, f.recorded[0].PaymentStatus)
+		f.rides.AssertExpectations(t)
+	})
+
+	t.Run("Failed payment without a capture stays failed", func(t *testing.T) {
+		payment := openPayment(t, "payment-11", 25000)
+		payment.Status = model.PaymentStatusTypeFailed
+		_, err := gw.Decline(*payment.GatewayOrderID, "upi")
+		require.NoError(t, err)
+		f := setup(t, payment)
+
+		result, err := f.service.ReconcilePayments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.ReconciliationResult{Checked: 1}, *result)
+		assert.Empty(t, f.recorded)
+		f.payments.AssertNotCalled(t, "TransitionStatus", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	// openTopup returns a top-up of ₹500 stuck in created with a fresh order
+	openTopup := func(t *testing.T, id string) *model.WalletTopup {
+		order, err := client.CreateOrder(ctx, razorpay.CreateOrderRequest{Amount: 50000, Currency: "INR", Receipt: id})
+		require.NoError(t, err)
+		return &model.WalletTopup{
+			ID: id, UserID: "user-1", Amount: 500, Currency: "INR", Provider: &provider,
+			GatewayOrderID: &order.ID, Status: model.WalletTopupStatusCreated,
+		}
+	}
+	withTopups := func(f *fixture, topups ...*model.WalletTopup) {
+		f.noTopups.Unset()
+		f.wallet.On("ListUnsettledTopups", mock.Anything, mock.Anything, mock.Anything, 200).Return(topups, nil).Once()
+		f.wallet.On("MarkTopupReconciled", mock.Anything, mock.Anything).Return(nil)
+	}
+
+	t.Run("Top-up the gateway captured is credited", func(t *testing.T) {
+		topup := openTopup(t, "topup-1")
+		gp, _, err := gw.Authorize(*topup.GatewayOrderID, "upi")
+		require.NoError(t, err)
+		f := setup(t)
+		withTopups(f, topup)
+		f.wallet.On("TransitionTopup", mock.Anything, mock.MatchedBy(func(tp *model.WalletTopup) bool {
+			return tp.Status == model.WalletTopupStatusCaptured && *tp.GatewayPaymentID == gp.ID
+		}), model.WalletTopupStatusCreated).Return(true, nil).Once()
+
+		result, err := f.service.ReconcilePayments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.ReconciliationResult{Checked: 1, Repaired: 1}, *result)
+		captured, err := client.FetchPayment(ctx, gp.ID)
+		require.NoError(t, err)
+		assert.Equal(t, razorpay.PaymentStatusCaptured, captured.Status)
+		f.ledger.AssertCalled(t, "Post", mock.Anything, mock.MatchedBy(func(e *model.JournalEntry) bool {
+			return e.Type == model.JournalEntryWalletTopup && e.ReferenceID == topup.ID
+		}))
+		f.wallet.AssertExpectations(t)
+		f.wallet.AssertCalled(t, "MarkTopupReconciled", mock.Anything, topup.ID)
+	})
+
+	t.Run("Top-up whose attempts all failed is failed", func(t *testing.T) {
+		topup := openTopup(t, "topup-2")
+		_, err := gw.Decline(*topup.GatewayOrderID, "upi")
+		require.NoError(t, err)
+		f := setup(t)
+		withTopups(f, topup)
+		f.wallet.On("TransitionTopup", mock.Anything, mock.MatchedBy(func(tp *model.WalletTopup) bool {
+			return tp.Status == model.WalletTopupStatusFailed
+		}), model.WalletTopupStatusCreated).Return(true, nil).Once()
+
+		result, err := f.service.ReconcilePayments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.ReconciliationResult{Checked: 1, Repaired: 1}, *result)
+		f.wallet.AssertExpectations(t)
+		f.ledger.AssertNotCalled(t, "Post", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Gateway outage skips the payment for the next run", func(t *testing.T) {
+		payment := openPayment(t, "payment-9", 25000)
+		f := setup(t, payment)
+		gw.FailNext(http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+
+		result, err := f.service.ReconcilePayments(ctx)
+		require.Error(t, err)
+		assert.Equal(t, 0, result.Checked)
+		f.payments.AssertNotCalled(t, "MarkReconciled", mock.Anything, payment.ID)
+	})
+}
+
+func TestPaymentDiscrepancies(t *testing.T) {
+	ctx := context.Background()
+	discrepancies := new(testutil.MockPaymentDiscrepancyRepository)
+	paymentService := service.NewPaymentService(new(testutil.MockPaymentRepository), new(testutil.MockRideRepository),
+		new(testutil.MockPaymentEventRepository), nil, nil, new(testutil.MockLedgerRepository), new(testutil.MockEarningsRepository),
+		discrepancies, config.DefaultEarningsConfig(), &config.DefaultPaymentConfig().Reconciliation, nil, nil, nil)
+
+	t.Run("Open discrepancies are listed by default", func(t *testing.T) {
+		discrepancies.On("List", mock.Anything, model.DiscrepancyStatusOpen, 50).Return(nil, nil).Once()
+
+		list, err := paymentService.ListDiscrepancies(ctx, &model.ListPaymentDiscrepanciesRequest{})
+		require.NoError(t, err)
+		assert.Empty(t, list)
+		assert.NotNil(t, list)
+	})
+
+	t.Run("Resolving needs an open discrepancy", func(t *testing.T) {
+		const id = "0b7e3c1d-8f6a-4e2b-9c5d-1a2b3c4d5e6f"
+		discrepancies.On("Resolve", mock.Anything, id, "admin-1", "refunded the second payment").
+			Return(&model.PaymentDiscrepancy{ID: id, Status: model.DiscrepancyStatusResolved}, nil).Once()
+		discrepancies.On("Resolve", mock.Anything, id, "admin-1", "again").Return(nil, nil).Once()
+
+		d, err := paymentService.ResolveDiscrepancy(ctx, "admin-1", &model.ResolvePaymentDiscrepancyRequest{ID: id, Note: "refunded the second payment"})
+		require.NoError(t, err)
+		assert.Equal(t, model.DiscrepancyStatusResolved, d.Status)
+
+		_, err = paymentService.ResolveDiscrepancy(ctx, "admin-1", &model.ResolvePaymentDiscrepancyRequest{ID: id, Note: "again"})
+		var httpErr *errs.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, http.StatusNotFound, httpErr.Status)
+	})
+}
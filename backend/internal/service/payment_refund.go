@@ -0,0 +1,376 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/job"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/push"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+)
+
+const (
+	refundSyncSchedule = "@every 5m"
+	refundSyncBatch    = 100
+	// Refunds younger than this are left to the request that created them and to the webhook
+	refundSyncDelay = 2 * time.Minute
+)
+
+// Register schedules the jobs that settle refunds still pending at the gateway and reconcile open
+// payments
+func (s *paymentService) Register(srv *server.Server) error {
+	srv.Job.HandleFunc(job.TaskRefundSync, func(ctx context.Context, t *asynq.Task) error {
+		settled, err := s.SyncRefunds(ctx)
+		if err != nil {
+			srv.Logger.Error().Err(err).Int("settled", settled).Msg("Refund sync failed")
+			return err
+		}
+		if settled > 0 {
+			srv.Logger.Info().Int("settled", settled).Msg("Settled pending refunds")
+		}
+		return nil
+	})
+	if err := srv.Job.Schedule(refundSyncSchedule, job.NewRefundSyncTask()); err != nil {
+		return fmt.Errorf("failed to schedule refund sync: %w", err)
+	}
+	return s.registerReconciliation(srv)
+}
+
+// CreateRefund refunds all or part of a payment on behalf of an admin
+func (s *paymentService) CreateRefund(ctx context.Context, adminID, paymentID string, req *model.CreateRefundRequest) (*model.Refund, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		return nil, errs.NewNotFoundError("payment not found", false, nil)
+	}
+	return s.refund(ctx, payment, req.Amount, model.RefundSourceAdmin, req.Reason, &adminID)
+}
+
+// paidByAnother reports whether the ride of a payment was already paid by a different payment
+func (s *paymentService) paidByAnother(ctx context.Context, payment *model.Payment) (bool, error) {
+	ride, err := s.rideRepo.GetByID(ctx, payment.RideID)
+	if err != nil {
+		return false, errs.NewInternalServerError()
+	}
+	if ride.PaymentID == nil || *ride.PaymentID == payment.ID {
+		return false, nil
+	}
+	switch ride.PaymentStatus {
+	case model.PaymentStatusCompleted, model.PaymentStatusPartiallyRefunded, model.PaymentStatusRefunded:
+		return true, nil
+	}
+	return false, nil
+}
+
+// refundDuplicatePayment refunds, by policy, a payment captured for a ride another payment already
+// paid, such as the checkout of an order that was replaced after the tip changed completing late
+func (s *paymentService) refundDuplicatePayment(ctx context.Context, payment *model.Payment) error {
+	if gateway.ToPaise(payment.Amount) == 0 {
+		return nil
+	}
+	_, err := s.refund(ctx, payment, 0, model.RefundSourcePolicy, "ride was already paid by another payment", nil)
+	return err
+}
+
+func (s *paymentService) ListRefunds(ctx context.Context, paymentID string) ([]*model.Refund, error) {
+	refunds, err := s.refundRepo.ListByPayment(ctx, paymentID)
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+	if refunds == nil {
+		refunds = []*model.Refund{}
+	}
+	return refunds, nil
+}
+
+// refund records a refund and sends it on its way. Cash payments, and payments that never went
+// through the gateway, are refunded to the rider's wallet at once. Gateway refunds complete now or
+// later through the webhook or SyncRefunds, depending on the gateway.
+func (s *paymentService) refund(ctx context.Context, payment *model.Payment, amount float64, source model.RefundSource, reason string, initiatedBy *string) (*model.Refund, error) {
+	if payment.Status != model.PaymentStatusTypeCaptured && payment.Status != model.PaymentStatusTypePartiallyRefunded {
+		return nil, paymentConflict(fmt.Sprintf("a %s payment cannot be refunded", payment.Status))
+	}
+
+	// Zero refunds the whole remaining amount, so an amount that rounds to zero is refused rather
+	// than passed on
+	rounded := math.Round(amount*100) / 100
+	if amount != 0 && rounded <= 0 {
+		return nil, errs.NewBadRequestError("Validation Failed", false, nil,
+			[]errs.FieldError{{Field: "amount", Error: "Must be at least 0.01"}}, nil)
+	}
+
+	refund := &model.Refund{
+		PaymentID:   payment.ID,
+		UserID:      payment.UserID,
+		Amount:      rounded,
+		Currency:    payment.Currency,
+		Destination: refundDestination(payment),
+		Source:      source,
+		Reason:      reason,
+		InitiatedBy: initiatedBy,
+		Status:      model.RefundStatusPending,
+	}
+	if refund.Destination == model.RefundDestinationGateway && s.gatewayOf(payment.Provider) == nil {
+		return nil, errs.NewServiceUnavailableError("payment gateway is not configured", true)
+	}
+
+	if err := s.refundRepo.Create(ctx, refund); err != nil {
+		if errors.Is(err, repository.ErrRefundExceedsPayment) {
+			return nil, errs.NewBadRequest("refund exceeds the refundable amount of the payment")
+		}
+		return nil, errs.NewInternalServerError()
+	}
+
+	if refund.Destination == model.RefundDestinationWallet {
+		if err := s.creditWallet(ctx, refund); err != nil {
+			return nil, err
+		}
+		return refund, nil
+	}
+
+	if err := s.submitRefund(ctx, refund, payment); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+func refundDestination(payment *model.Payment) model.RefundDestination {
+	if payment.PaymentMethod == string(model.PaymentMethodCash) || payment.GatewayPaymentID == nil {
+		return model.RefundDestinationWallet
+	}
+	return model.RefundDestinationGateway
+}
+
+// creditWallet settles a pending wallet refund, the ledger entry credits the wallet
+func (s *paymentService) creditWallet(ctx context.Context, refund *model.Refund) error {
+	return s.settleRefund(ctx, refund, model.RefundStatusProcessed, nil, nil)
+}
+
+// submitRefund creates the gateway refund. A rejection fails the refund, an ambiguous error leaves
+// it pending for SyncRefunds, which looks it up by receipt before trying again.
+func (s *paymentService) submitRefund(ctx context.Context, refund *model.Refund, payment *model.Payment) error {
+	gr, err := s.gatewayOf(payment.Provider).CreateRefund(ctx, *payment.GatewayPaymentID, gateway.RefundRequest{
+		Amount:  gateway.ToPaise(refund.Amount),
+		Receipt: refund.ID,
+		Notes:   map[string]string{"refund_id": refund.ID, "payment_id": payment.ID},
+	})
+	if err != nil {
+		var rejected *gateway.RejectedError
+		if errors.As(err, &rejected) {
+			if err := s.settleRefund(ctx, refund, model.RefundStatusFailed, nil, &rejected.Reason); err != nil {
+				return err
+			}
+			return errs.NewBadRequest("refund rejected by the payment gateway: " + rejected.Reason)
+		}
+		return nil
+	}
+
+	if err := s.refundRepo.SetGatewayRefundID(ctx, refund.ID, gr.ID); err != nil {
+		return errs.NewInternalServerError()
+	}
+	refund.GatewayRefundID = &gr.ID
+	return s.applyGatewayRefund(ctx, refund, gr)
+}
+
+// applyGatewayRefund moves a refund to the status the gateway reports
+func (s *paymentService) applyGatewayRefund(ctx context.Context, refund *model.Refund, gr *gateway.Refund) error {
+	switch gr.Status {
+	case gateway.RefundStatusProcessed:
+		return s.settleRefund(ctx, refund, model.RefundStatusProcessed, &gr.ID, nil)
+	case gateway.RefundStatusFailed:
+		reason := "refund failed at the payment gateway"
+		return s.settleRefund(ctx, refund, model.RefundStatusFailed, &gr.ID, &reason)
+	}
+	return nil
+}
+
+// settleRefund ends a pending refund. A processed refund is posted to the ledger before its status
+// changes. Settling an already settled refund does nothing, so the webhook, the sync job and the
+// request that created the refund can race.
+func (s *paymentService) settleRefund(ctx context.Context, refund *model.Refund, to model.RefundStatus, gatewayRefundID, failureReason *string) error {
+	if refund.Status != model.RefundStatusPending {
+		return nil
+	}
+
+	if to == model.RefundStatusProcessed {
+		if err := s.recordRefund(ctx, refund); err != nil {
+			return err
+		}
+	}
+
+	refund.Status = to
+	if gatewayRefundID != nil {
+		refund.GatewayRefundID = gatewayRefundID
+	}
+	refund.FailureReason = failureReason
+
+	updated, err := s.refundRepo.TransitionStatus(ctx, refund, model.RefundStatusPending)
+	if err != nil {
+		return errs.NewInternalServerError()
+	}
+	if !updated || to != model.RefundStatusProcessed {
+		return nil
+	}
+	return s.completeRefund(ctx, refund)
+}
+
+// completeRefund updates the payment and its ride after a refund was processed and tells the rider
+func (s *paymentService) completeRefund(ctx context.Context, refund *model.Refund) error {
+	payment, err := s.paymentRepo.GetByID(ctx, refund.PaymentID)
+	if err != nil {
+		return errs.NewInternalServerError()
+	}
+	total, err := s.refundRepo.ProcessedTotal(ctx, payment.ID)
+	if err != nil {
+		return errs.NewInternalServerError()
+	}
+
+	to := model.PaymentStatusTypePartiallyRefunded
+	if gateway.ToPaise(total) >= gateway.ToPaise(payment.Amount) {
+		to = model.PaymentStatusTypeRefunded
+	}
+	err = s.transitionPayment(ctx, payment, to, nil, nil)
+	if err != nil && !errors.Is(err, errPaymentTransition) {
+		return err
+	}
+
+	s.notifyRefund(ctx, refund)
+	return nil
+}
+
+func (s *paymentService) notifyRefund(ctx context.Context, refund *model.Refund) {
+	if s.notifier == nil {
+		return
+	}
+	destination := "original payment method"
+	if refund.Destination == model.RefundDestinationWallet {
+		destination = "wallet"
+	}
+	// A missed notification does not undo the refund, the rider still sees it in the app
+	_ = s.notifier.Notify(ctx, refund.UserID, push.Notification{
+		Title: "Refund processed",
+		Body:  fmt.Sprintf("₹%.2f has been refunded to your %s", refund.Amount, destination),
+		Data: map[string]string{
+			"type":       "refund_processed",
+			"refund_id":  refund.ID,
+			"payment_id": refund.PaymentID,
+		},
+	})
+}
+
+// SyncRefunds settles the refunds that are still pending a while after they were created. Wallet
+// refunds interrupted before the credit are credited, gateway refunds are fetched from the gateway
+// and refunds the gateway never received are submitted again. It returns the number of refunds that
+// are no longer pending.
+func (s *paymentService) SyncRefunds(ctx context.Context) (int, error) {
+	pending, err := s.refundRepo.ListPending(ctx, time.Now().Add(-refundSyncDelay), refundSyncBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pending refunds: %w", err)
+	}
+
+	settled := 0
+	var firstErr error
+	for _, refund := range pending {
+		if err := s.syncRefund(ctx, refund); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to sync refund %s: %w", refund.ID, err)
+			}
+			continue
+		}
+		if refund.Status != model.RefundStatusPending {
+			settled++
+		}
+	}
+	return settled, firstErr
+}
+
+func (s *paymentService) syncRefund(ctx context.Context, refund *model.Refund) error {
+	if refund.Destination == model.RefundDestinationWallet {
+		return s.creditWallet(ctx, refund)
+	}
+
+	payment, err := s.paymentRepo.GetByID(ctx, refund.PaymentID)
+	if err != nil {
+		return err
+	}
+	gw := s.gatewayOf(payment.Provider)
+	if gw == nil || payment.GatewayPaymentID == nil {
+		return nil
+	}
+
+	if refund.GatewayRefundID != nil {
+		gr, err := gw.FetchRefund(ctx, *refund.GatewayRefundID)
+		if err != nil {
+			return err
+		}
+		return s.applyGatewayRefund(ctx, refund, gr)
+	}
+
+	existing, err := gw.FetchPaymentRefunds(ctx, *payment.GatewayPaymentID)
+	if err != nil {
+		return err
+	}
+	for i := range existing {
+		if gr := &existing[i]; gr.Receipt == refund.ID {
+			if err := s.refundRepo.SetGatewayRefundID(ctx, refund.ID, gr.ID); err != nil {
+				return err
+			}
+			refund.GatewayRefundID = &gr.ID
+			return s.applyGatewayRefund(ctx, refund, gr)
+		}
+	}
+	return s.submitRefund(ctx, refund, payment)
+}
+
+// applyWebhookRefund settles the refund a refund event is about. Refunds made outside the app,
+// e.g. in the gateway's dashboard, are recorded when they are processed.
+func (s *paymentService) applyWebhookRefund(ctx context.Context, payment *model.Payment, event string, gr *gateway.Refund) error {
+	refund, err := s.refundRepo.GetByGatewayRefundID(ctx, gr.ID)
+	if err != nil {
+		return errs.Wrap(err, "failed to find refund for webhook")
+	}
+	if refund == nil && gr.Receipt != "" {
+		// Our refunds carry their id as receipt, the gateway id may not be stored yet
+		if refund, err = s.refundRepo.GetByID(ctx, gr.Receipt); err != nil {
+			refund = nil
+		}
+	}
+
+	if refund == nil {
+		if event != gateway.EventRefundProcessed {
+			return nil
+		}
+		refund = &model.Refund{
+			PaymentID:       payment.ID,
+			UserID:          payment.UserID,
+			Amount:          gateway.FromPaise(gr.Amount),
+			Currency:        payment.Currency,
+			Destination:     model.RefundDestinationGateway,
+			Source:          model.RefundSourceGateway,
+			Reason:          "refunded at the payment gateway",
+			Status:          model.RefundStatusPending,
+			GatewayRefundID: &gr.ID,
+		}
+		if err := s.refundRepo.Create(ctx, refund); err != nil {
+			if errors.Is(err, repository.ErrRefundExceedsPayment) {
+				// Already covered by the refunds we know about
+				return nil
+			}
+			return errs.Wrap(err, "failed to record gateway refund")
+		}
+	}
+
+	if event == gateway.EventRefundFailed {
+		gr.Status = gateway.RefundStatusFailed
+	} else {
+		gr.Status = gateway.RefundStatusProcessed
+	}
+	return s.applyGatewayRefund(ctx, refund, gr)
+}
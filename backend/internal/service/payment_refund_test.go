@@ -0,0 +1,289 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/push"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/razorpay"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/razorpay/razorpaytest"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingNotifier struct {
+	sent map[string][]push.Notification
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, userID string, notification push.Notification) error {
+	n.sent[userID] = append(n.sent[userID], notification)
+	return nil
+}
+
+func TestPaymentRefunds(t *testing.T) {
+	gw := razorpaytest.NewServer("rzp_test_key", "secret")
+	defer gw.Close()
+	client := razorpay.NewClient(gw.Config())
+	ctx := context.Background()
+
+	const paymentID = "5d1c7a2b-3e4f-4a5b-9c6d-7e8f9a0b1c2d"
+	const rideID = "ride-1"
+	const userID = "user-1"
+	const adminID = "admin-1"
+	driverID := "driver-1"
+
+	type fixture struct {
+		service    service.PaymentService
+		payments   *testutil.MockPaymentRepository
+		rides      *testutil.MockRideRepository
+		refunds    *testutil.MockRefundRepository
+		ledger     *testutil.MockLedgerRepository
+		earnings   *testutil.MockEarningsRepository
+		notifier   *recordingNotifier
+		payment    *model.Payment
+		refundRows int
+		refunded   float64
+	}
+
+	// setup returns a captured payment of ₹250, paid through the gateway unless cash. The platform
+	// took ₹50 commission.
+	setup := func(t *testing.T, cash bool) *fixture {
+		f := &fixture{
+			payments: new(testutil.MockPaymentRepository),
+			rides:    new(testutil.MockRideRepository),
+			refunds:  new(testutil.MockRefundRepository),
+			ledger:   new(testutil.MockLedgerRepository),
+			earnings: new(testutil.MockEarningsRepository),
+			notifier: &recordingNotifier{sent: map[string][]push.Notification{}},
+			payment: &model.Payment{
+				ID:            paymentID,
+				RideID:        rideID,
+				UserID:        userID,
+				Amount:        250,
+				Currency:      "INR",
+				Status:        model.PaymentStatusTypeCaptured,
+				PaymentMethod: "cash",
+			},
+		}
+		if !cash {
+			order, err := client.CreateOrder(ctx, razorpay.CreateOrderRequest{Amount: 25000, Currency: "INR"})
+			require.NoError(t, err)
+			gp, _, err := gw.Pay(order.ID, "upi")
+			require.NoError(t, err)
+			provider := "razorpay"
+			f.payment.PaymentMethod = "upi"
+			f.payment.Provider = &provider
+			f.payment.GatewayOrderID = &order.ID
+			f.payment.GatewayPaymentID = &gp.ID
+		}
+
+		f.payments.On("GetByID", mock.Anything, paymentID).Return(f.payment, nil).Maybe()
+		f.rides.On("GetByID", mock.Anything, rideID).Return(&model.Ride{ID: rideID, UserID: userID, DriverID: &driverID, PaymentID: &f.payment.ID}, nil).Maybe()
+		f.ledger.On("Post", mock.Anything, mock.Anything).Return(true, nil).Maybe()
+		f.earnings.On("GetRideEarning", mock.Anything, paymentID).Return(&model.RideEarning{
+			Kind: model.EarningKindRide, ReferenceID: paymentID, DriverID: driverID, RideID: rideID, PaymentID: paymentID,
+			PaymentMethod: f.payment.PaymentMethod, Fare: 25000, Commission: 5000, Net: 20000,
+		}, nil).Maybe()
+		f.earnings.On("Record", mock.Anything, mock.Anything).Return(nil).Maybe()
+		f.payments.On("TransitionStatus", mock.Anything, mock.Anything, mock.Anything).Return(true, nil).Maybe()
+		f.refunds.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			r := args.Get(1).(*model.Refund)
+			if r.Amount == 0 {
+				r.Amount = f.payment.Amount - f.refunded
+			}
+			f.refunded += r.Amount
+			f.refundRows++
+			r.ID = fmt.Sprintf("0a9b8c7d-0000-4000-8000-%012d", f.refundRows)
+		}).Return(nil).Maybe()
+		f.refunds.On("SetGatewayRefundID", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+		f.refunds.On("TransitionStatus", mock.Anything, mock.Anything, model.RefundStatusPending).Return(true, nil).Maybe()
+
+		f.service = service.NewPaymentService(f.payments, f.rides, new(testutil.MockPaymentEventRepository), f.refunds, new(testutil.MockWalletRepository), f.ledger,
+			f.earnings, nil, config.DefaultEarningsConfig(), &config.DefaultPaymentConfig().Reconciliation, gateway.NewRazorpay(client), f.notifier, nil)
+		return f
+	}
+
+	t.Run("Partial then full gateway refund", func(t *testing.T) {
+		f := setup(t, false)
+		f.refunds.On("ProcessedTotal", mock.Anything, paymentID).Return(100.0, nil).Once()
+		f.rides.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusPartiallyRefunded, paymentID).Return(nil).Once()
+
+		refund, err := f.service.CreateRefund(ctx, adminID, paymentID, &model.CreateRefundRequest{Amount: 100, Reason: "driver took a longer route"})
+		require.NoError(t, err)
+		assert.Equal(t, model.RefundStatusProcessed, refund.Status)
+		assert.Equal(t, model.RefundDestinationGateway, refund.Destination)
+		assert.Equal(t, adminID, *refund.InitiatedBy)
+		require.NotNil(t, refund.GatewayRefundID)
+		assert.Equal(t, model.PaymentStatusTypePartiallyRefunded, f.payment.Status)
+
+		gr, err := client.FetchRefund(ctx, *refund.GatewayRefundID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10000), gr.Amount)
+		assert.Equal(t, refund.ID, *gr.Receipt)
+
+		// Two fifths of the payment come back, two fifths of the commission with them
+		f.ledger.AssertCalled(t, "Post", mock.Anything, mock.MatchedBy(func(e *model.JournalEntry) bool {
+			return e.Type == model.JournalEntryRefund && e.ReferenceID == refund.ID && len(e.Postings) == 3 &&
+				e.Postings[0].AccountType == model.LedgerAccountDriverEarnings && e.Postings[0].Amount == -8000 &&
+				e.Postings[1].AccountType == model.LedgerAccountPlatformCommission && e.Postings[1].Amount == -2000 &&
+				e.Postings[2].AccountType == model.LedgerAccountGatewayClearing && e.Postings[2].Amount == 10000
+		}))
+		f.earnings.AssertCalled(t, "Record", mock.Anything, &model.RideEarning{
+			Kind: model.EarningKindRefund, ReferenceID: refund.ID, DriverID: driverID, RideID: rideID, PaymentID: paymentID,
+			PaymentMethod: "upi", Fare: -10000, Commission: -2000, Net: -8000,
+		})
+
+		// The rest of the payment
+		f.refunds.On("ProcessedTotal", mock.Anything, paymentID).Return(250.0, nil).Once()
+		f.rides.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusRefunded, paymentID).Return(nil).Once()
+		_, err = f.service.CreateRefund(ctx, adminID, paymentID, &model.CreateRefundRequest{Reason: "ride cancelled by support"})
+		require.NoError(t, err)
+		assert.Equal(t, model.PaymentStatusTypeRefunded, f.payment.Status)
+
+		require.Len(t, f.notifier.sent[userID], 2)
+		assert.Equal(t, "refund_processed", f.notifier.sent[userID][0].Data["type"])
+		f.rides.AssertExpectations(t)
+	})
+
+	t.Run("Cash payment is refunded to the wallet", func(t *testing.T) {
+		f := setup(t, true)
+		f.refunds.On("ProcessedTotal", mock.Anything, paymentID).Return(250.0, nil).Once()
+		f.rides.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusRefunded, paymentID).Return(nil).Once()
+
+		refund, err := f.service.CreateRefund(ctx, adminID, paymentID, &model.CreateRefundRequest{Reason: "driver overcharged"})
+		require.NoError(t, err)
+		assert.Equal(t, model.RefundDestinationWallet, refund.Destination)
+		assert.Equal(t, model.RefundSourceAdmin, refund.Source)
+		assert.Equal(t, model.RefundStatusProcessed, refund.Status)
+		assert.Equal(t, model.PaymentStatusTypeRefunded, f.payment.Status)
+		assert.Contains(t, f.notifier.sent[userID][0].Body, "wallet")
+
+		// The driver kept the cash, the refund is taken from their earnings and the commission
+		// charged on it is given back
+		f.ledger.AssertCalled(t, "Post", mock.Anything, mock.MatchedBy(func(e *model.JournalEntry) bool {
+			return e.Type == model.JournalEntryRefund && e.ReferenceID == refund.ID &&
+				e.Postings[0].AccountType == model.LedgerAccountDriverEarnings && e.Postings[0].Amount == -20000 &&
+				e.Postings[1].AccountType == model.LedgerAccountPlatformCommission && e.Postings[1].Amount == -5000 &&
+				e.Postings[2].AccountType == model.LedgerAccountRiderWallet && *e.Postings[2].OwnerID == userID
+		}))
+	})
+
+	t.Run("Payment of a ride paid by another payment is refunded", func(t *testing.T) {
+		f := setup(t, false)
+		// The rider paid a replaced order after the ride was paid through the new one
+		f.payment.Status = model.PaymentStatusTypeFailed
+		otherPaymentID := "7e6d5c4b-3a29-4180-9f7e-6d5c4b3a2918"
+		f.rides.ExpectedCalls = nil
+		f.rides.On("GetByID", mock.Anything, rideID).Return(&model.Ride{
+			ID: rideID, UserID: userID, DriverID: &driverID, PaymentID: &otherPaymentID, PaymentStatus: model.PaymentStatusCompleted,
+		}, nil)
+		f.rides.On("GetCharges", mock.Anything, rideID).Return(&model.RideCharges{Fare: 250}, nil)
+		f.refunds.On("ProcessedTotal", mock.Anything, paymentID).Return(250.0, nil).Once()
+
+		err := f.service.VerifyPayment(ctx, userID, &model.VerifyPaymentRequest{
+			PaymentID:        paymentID,
+			GatewayOrderID:   *f.payment.GatewayOrderID,
+			GatewayPaymentID: *f.payment.GatewayPaymentID,
+			GatewaySignature: razorpay.PaymentSignature(*f.payment.GatewayOrderID, *f.payment.GatewayPaymentID, gw.KeySecret),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, model.PaymentStatusTypeRefunded, f.payment.Status)
+		f.refunds.AssertCalled(t, "Create", mock.Anything, mock.MatchedBy(func(r *model.Refund) bool {
+			return r.Source == model.RefundSourcePolicy && r.Amount == 250 && r.Destination == model.RefundDestinationGateway
+		}))
+		require.Len(t, f.notifier.sent[userID], 1)
+		// The ride stays with the payment that paid it
+		f.rides.AssertNotCalled(t, "UpdatePaymentStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Refund larger than the payment is rejected", func(t *testing.T) {
+		f := setup(t, false)
+		f.refunds.ExpectedCalls = nil
+		f.refunds.On("Create", mock.Anything, mock.Anything).Return(repository.ErrRefundExceedsPayment).Once()
+
+		_, err := f.service.CreateRefund(ctx, adminID, paymentID, &model.CreateRefundRequest{Amount: 300, Reason: "too much"})
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusBadRequest, httpErr.Status)
+	})
+
+	t.Run("Refund rounding to nothing is rejected", func(t *testing.T) {
+		f := setup(t, false)
+
+		// Passed on as zero it would refund the whole payment
+		_, err := f.service.CreateRefund(ctx, adminID, paymentID, &model.CreateRefundRequest{Amount: 0.004, Reason: "rounding"})
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusBadRequest, httpErr.Status)
+		require.Len(t, httpErr.Errors, 1)
+		assert.Equal(t, "amount", httpErr.Errors[0].Field)
+		f.refunds.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Uncaptured payment cannot be refunded", func(t *testing.T) {
+		f := setup(t, false)
+		f.payment.Status = model.PaymentStatusTypeCreated
+
+		_, err := f.service.CreateRefund(ctx, adminID, paymentID, &model.CreateRefundRequest{Reason: "not paid"})
+		require.Error(t, err)
+		f.refunds.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Pending gateway refund is settled by the sync", func(t *testing.T) {
+		f := setup(t, false)
+		gw.RefundStatus = razorpay.RefundStatusPending
+		defer func() { gw.RefundStatus = razorpay.RefundStatusProcessed }()
+
+		refund, err := f.service.CreateRefund(ctx, adminID, paymentID, &model.CreateRefundRequest{Reason: "duplicate charge"})
+		require.NoError(t, err)
+		assert.Equal(t, model.RefundStatusPending, refund.Status)
+		assert.Equal(t, model.PaymentStatusTypeCaptured, f.payment.Status)
+		assert.Empty(t, f.notifier.sent)
+
+		gw.SetRefundStatus(*refund.GatewayRefundID, razorpay.RefundStatusProcessed)
+		f.refunds.On("ListPending", mock.Anything, mock.AnythingOfType("time.Time"), mock.Anything).Return([]*model.Refund{refund}, nil).Once()
+		f.refunds.On("ProcessedTotal", mock.Anything, paymentID).Return(250.0, nil).Once()
+		f.rides.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusRefunded, paymentID).Return(nil).Once()
+
+		settled, err := f.service.SyncRefunds(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, settled)
+		assert.Equal(t, model.RefundStatusProcessed, refund.Status)
+		assert.Equal(t, model.PaymentStatusTypeRefunded, f.payment.Status)
+	})
+
+	t.Run("Refund lost in a gateway outage is submitted again", func(t *testing.T) {
+		f := setup(t, false)
+		gw.FailNext(http.StatusBadGateway)
+
+		refund, err := f.service.CreateRefund(ctx, adminID, paymentID, &model.CreateRefundRequest{Amount: 50, Reason: "wait time"})
+		require.NoError(t, err)
+		assert.Equal(t, model.RefundStatusPending, refund.Status)
+		assert.Nil(t, refund.GatewayRefundID)
+
+		refund.CreatedAt = time.Now().Add(-time.Hour)
+		f.refunds.On("ListPending", mock.Anything, mock.Anything, mock.Anything).Return([]*model.Refund{refund}, nil).Once()
+		f.refunds.On("ProcessedTotal", mock.Anything, paymentID).Return(50.0, nil).Once()
+		f.rides.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusPartiallyRefunded, paymentID).Return(nil).Once()
+
+		settled, err := f.service.SyncRefunds(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, settled)
+		require.NotNil(t, refund.GatewayRefundID)
+
+		refunds, err := client.FetchPaymentRefunds(ctx, *f.payment.GatewayPaymentID)
+		require.NoError(t, err)
+		assert.Len(t, refunds, 1)
+	})
+}
@@ -0,0 +1,632 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/razorpay"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/razorpay/razorpaytest"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaymentGatewayFlow(t *testing.T) {
+	gw := razorpaytest.NewServer("rzp_test_key", "secret")
+	defer gw.Close()
+	ctx := context.Background()
+
+	const userID = "user-1"
+	const driverID = "driver-1"
+	upi := model.PaymentMethodUPI
+	driver := driverID
+	ride := &model.Ride{ID: "ride-1", UserID: userID, DriverID: &driver, Status: model.RideStatusCompleted, PaymentMethod: &upi}
+
+	setup := func() (service.PaymentService, *testutil.MockPaymentRepository, *testutil.MockRideRepository, *model.Payment, *testutil.MockLedgerRepository) {
+		mockPaymentRepo := new(testutil.MockPaymentRepository)
+		mockRideRepo := new(testutil.MockRideRepository)
+		mockLedgerRepo := new(testutil.MockLedgerRepository)
+		mockEarningsRepo := new(testutil.MockEarningsRepository)
+		stored := &model.Payment{}
+
+		mockRideRepo.On("GetByID", mock.Anything, ride.ID).Return(ride, nil)
+		mockRideRepo.On("GetCharges", mock.Anything, ride.ID).Return(&model.RideCharges{Fare: 349.75}, nil).Maybe()
+		mockPaymentRepo.On("GetOutstandingByRideID", mock.Anything, ride.ID).Return(nil, nil).Maybe()
+		mockPaymentRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.Payment")).Run(func(args mock.Arguments) {
+			p := args.Get(1).(*model.Payment)
+			p.ID = "3f7c1b9e-1d2a-4c5b-8e6f-7a8b9c0d1e2f"
+			*stored = *p
+		}).Return(nil).Once()
+		mockPaymentRepo.On("SetGatewayOrder", mock.Anything, "3f7c1b9e-1d2a-4c5b-8e6f-7a8b9c0d1e2f", "razorpay", mock.Anything).Run(func(args mock.Arguments) {
+			provider, orderID := args.String(2), args.String(3)
+			stored.Provider, stored.GatewayOrderID = &provider, &orderID
+		}).Return(nil).Maybe()
+		mockPaymentRepo.On("GetByID", mock.Anything, "3f7c1b9e-1d2a-4c5b-8e6f-7a8b9c0d1e2f").Return(stored, nil).Maybe()
+		mockLedgerRepo.On("Post", mock.Anything, mock.Anything).Return(true, nil).Maybe()
+		mockEarningsRepo.On("Record", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+		paymentService := service.NewPaymentService(mockPaymentRepo, mockRideRepo, new(testutil.MockPaymentEventRepository), nil, nil, mockLedgerRepo,
+			mockEarningsRepo, nil, config.DefaultEarningsConfig(), &config.DefaultPaymentConfig().Reconciliation, gateway.NewRazorpay(razorpay.NewClient(gw.Config())), nil, nil)
+		return paymentService, mockPaymentRepo, mockRideRepo, stored, mockLedgerRepo
+	}
+
+	createOrder := func(t *testing.T, paymentService service.PaymentService) *model.CreatePaymentOrderResponse {
+		resp, err := paymentService.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{
+			RideID:        ride.ID,
+			PaymentMethod: "upi",
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp.GatewayOrderID)
+		return resp
+	}
+
+	t.Run("Create, checkout and verify", func(t *testing.T) {
+		paymentService, mockPaymentRepo, mockRideRepo, stored, mockLedgerRepo := setup()
+		resp := createOrder(t, paymentService)
+		assert.Equal(t, "rzp_test_key", *resp.GatewayKeyID)
+
+		order, ok := gw.Order(*resp.GatewayOrderID)
+		require.True(t, ok)
+		assert.Equal(t, int64(34975), order.Amount)
+		assert.Equal(t, resp.PaymentID, order.Receipt)
+
+		gp, signature, err := gw.Pay(order.ID, "upi")
+		require.NoError(t, err)
+
+		mockPaymentRepo.On("TransitionStatus", mock.Anything, mock.MatchedBy(func(p *model.Payment) bool {
+			return p.Status == model.PaymentStatusTypeCaptured && *p.GatewayPaymentID == gp.ID
+		}), model.PaymentStatusTypeCreated).Return(true, nil).Once()
+		mockRideRepo.On("UpdatePaymentStatus", mock.Anything, ride.ID, model.PaymentStatusCompleted, resp.PaymentID).Return(nil).Once()
+
+		err = paymentService.VerifyPayment(ctx, userID, &model.VerifyPaymentRequest{
+			PaymentID:        resp.PaymentID,
+			GatewayOrderID:   order.ID,
+			GatewayPaymentID: gp.ID,
+			GatewaySignature: signature,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, model.PaymentStatusTypeCaptured, stored.Status)
+
+		// The fare moves from the gateway to the driver's earnings, less the 20% commission
+		mockLedgerRepo.AssertCalled(t, "Post", mock.Anything, mock.MatchedBy(func(e *model.JournalEntry) bool {
+			return e.Type == model.JournalEntryRidePayment && e.ReferenceID == resp.PaymentID && len(e.Postings) == 3 &&
+				e.Postings[0].AccountType == model.LedgerAccountGatewayClearing && e.Postings[0].Amount == -34975 &&
+				e.Postings[1].AccountType == model.LedgerAccountDriverEarnings && *e.Postings[1].OwnerID == driverID && e.Postings[1].Amount == 27980 &&
+				e.Postings[2].AccountType == model.LedgerAccountPlatformCommission && e.Postings[2].Amount == 6995
+		}))
+		mockPaymentRepo.AssertExpectations(t)
+		mockRideRepo.AssertExpectations(t)
+	})
+
+	t.Run("Authorized payments are captured on verify", func(t *testing.T) {
+		paymentService, mockPaymentRepo, mockRideRepo, _, _ := setup()
+		resp := createOrder(t, paymentService)
+
+		gp, signature, err := gw.Authorize(*resp.GatewayOrderID, "upi")
+		require.NoError(t, err)
+		mockPaymentRepo.On("TransitionStatus", mock.Anything, mock.Anything, model.PaymentStatusTypeCreated).Return(true, nil).Once()
+		mockRideRepo.On("UpdatePaymentStatus", mock.Anything, ride.ID, model.PaymentStatusCompleted, resp.PaymentID).Return(nil).Once()
+
+		err = paymentService.VerifyPayment(ctx, userID, &model.VerifyPaymentRequest{
+			PaymentID:        resp.PaymentID,
+			GatewayOrderID:   *resp.GatewayOrderID,
+			GatewayPaymentID: gp.ID,
+			GatewaySignature: signature,
+		})
+		require.NoError(t, err)
+
+		order, _ := gw.Order(*resp.GatewayOrderID)
+		assert.Equal(t, razorpay.OrderStatusPaid, order.Status)
+	})
+
+	t.Run("Forged signature fails the payment", func(t *testing.T) {
+		paymentService, mockPaymentRepo, mockRideRepo, stored, _ := setup()
+		resp := createOrder(t, paymentService)
+
+		gp, _, err := gw.Pay(*resp.GatewayOrderID, "upi")
+		require.NoError(t, err)
+		mockPaymentRepo.On("TransitionStatus", mock.Anything, mock.Anything, model.PaymentStatusTypeCreated).Return(true, nil).Once()
+		mockRideRepo.On("UpdatePaymentStatus", mock.Anything, ride.ID, model.PaymentStatusFailed, resp.PaymentID).Return(nil).Once()
+
+		err = paymentService.VerifyPayment(ctx, userID, &model.VerifyPaymentRequest{
+			PaymentID:        resp.PaymentID,
+			GatewayOrderID:   *resp.GatewayOrderID,
+			GatewayPaymentID: gp.ID,
+			GatewaySignature: razorpay.PaymentSignature(*resp.GatewayOrderID, gp.ID, "not-the-secret"),
+		})
+		require.Error(t, err)
+		assert.Equal(t, model.PaymentStatusTypeFailed, stored.Status)
+		mockRideRepo.AssertExpectations(t)
+	})
+
+	t.Run("Verify racing a captured webhook succeeds", func(t *testing.T) {
+		paymentService, mockPaymentRepo, _, stored, _ := setup()
+		resp := createOrder(t, paymentService)
+
+		gp, signature, err := gw.Pay(*resp.GatewayOrderID, "upi")
+		require.NoError(t, err)
+		// The webhook captures the payment between our read and our conditional update
+		mockPaymentRepo.On("TransitionStatus", mock.Anything, mock.Anything, model.PaymentStatusTypeCreated).Run(func(args mock.Arguments) {
+			*stored = *args.Get(1).(*model.Payment)
+		}).Return(false, nil).Once()
+
+		err = paymentService.VerifyPayment(ctx, userID, &model.VerifyPaymentRequest{
+			PaymentID:        resp.PaymentID,
+			GatewayOrderID:   *resp.GatewayOrderID,
+			GatewayPaymentID: gp.ID,
+			GatewaySignature: signature,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, model.PaymentStatusTypeCaptured, stored.Status)
+	})
+
+	t.Run("Payment of another order is rejected", func(t *testing.T) {
+		paymentService, _, _, _, _ := setup()
+		resp := createOrder(t, paymentService)
+
+		err := paymentService.VerifyPayment(ctx, userID, &model.VerifyPaymentRequest{
+			PaymentID:        resp.PaymentID,
+			GatewayOrderID:   "order_someone_else",
+			GatewayPaymentID: "pay_x",
+			GatewaySignature: razorpay.PaymentSignature("order_someone_else", "pay_x", gw.KeySecret),
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("Payment of another rider is refused", func(t *testing.T) {
+		paymentService, mockPaymentRepo, _, _, _ := setup()
+		resp := createOrder(t, paymentService)
+
+		gp, signature, err := gw.Pay(*resp.GatewayOrderID, "upi")
+		require.NoError(t, err)
+		err = paymentService.VerifyPayment(ctx, "user-2", &model.VerifyPaymentRequest{
+			PaymentID:        resp.PaymentID,
+			GatewayOrderID:   *resp.GatewayOrderID,
+			GatewayPaymentID: gp.ID,
+			GatewaySignature: signature,
+		})
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusUnauthorized, httpErr.Status)
+		mockPaymentRepo.AssertNotCalled(t, "TransitionStatus", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Without a gateway nothing is captured", func(t *testing.T) {
+		mockPaymentRepo := new(testutil.MockPaymentRepository)
+		mockRideRepo := new(testutil.MockRideRepository)
+		mockRideRepo.On("GetByID", mock.Anything, ride.ID).Return(ride, nil)
+		mockRideRepo.On("GetCharges", mock.Anything, ride.ID).Return(&model.RideCharges{Fare: 349.75}, nil)
+		mockPaymentRepo.On("GetByID", mock.Anything, "payment-1").Return(&model.Payment{
+			ID: "payment-1", RideID: ride.ID, UserID: userID, Amount: 349.75, Status: model.PaymentStatusTypeCreated, PaymentMethod: "upi",
+		}, nil)
+		paymentService := service.NewPaymentService(mockPaymentRepo, mockRideRepo, new(testutil.MockPaymentEventRepository), nil, nil, nil,
+			nil, nil, config.DefaultEarningsConfig(), &config.DefaultPaymentConfig().Reconciliation, nil, nil, nil)
+
+		_, err := paymentService.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{RideID: ride.ID, PaymentMethod: "upi"})
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusServiceUnavailable, httpErr.Status)
+
+		err = paymentService.VerifyPayment(ctx, userID, &model.VerifyPaymentRequest{
+			PaymentID:        "payment-1",
+			GatewayOrderID:   "order_x",
+			GatewayPaymentID: "pay_x",
+			GatewaySignature: "anything",
+		})
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusServiceUnavailable, httpErr.Status)
+		mockPaymentRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		mockPaymentRepo.AssertNotCalled(t, "TransitionStatus", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Gateway outage marks the payment failed", func(t *testing.T) {
+		paymentService, mockPaymentRepo, _, _, _ := setup()
+		gw.FailNext(http.StatusInternalServerError)
+		mockPaymentRepo.On("Update", mock.Anything, mock.MatchedBy(func(p *model.Payment) bool {
+			return p.Status == model.PaymentStatusTypeFailed
+		})).Return(nil).Once()
+
+		_, err := paymentService.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{
+			RideID:        ride.ID,
+			PaymentMethod: "upi",
+		})
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusServiceUnavailable, httpErr.Status)
+		mockPaymentRepo.AssertExpectations(t)
+	})
+}
+
+func TestPaymentWebhook(t *testing.T) {
+	gw := razorpaytest.NewServer("rzp_test_key", "secret")
+	defer gw.Close()
+	client := razorpay.NewClient(gw.Config())
+	ctx := context.Background()
+
+	const paymentID = "9b2e4f60-5a1c-4d3e-8f7a-6b5c4d3e2f1a"
+	const rideID = "ride-1"
+	driverID := "driver-1"
+	provider := "razorpay"
+	// paid records the captures the payment service of the last setup reported
+	var paid *ridePaidRecorder
+
+	// setup returns a payment for a fresh order, stored as created, and a checkout of that order
+	setup := func(t *testing.T) (service.PaymentService, *testutil.MockPaymentRepository, *testutil.MockRideRepository, *testutil.MockPaymentEventRepository, *testutil.MockRefundRepository, *model.Payment, *razorpay.Payment) {
+		order, err := client.CreateOrder(ctx, razorpay.CreateOrderRequest{Amount: 25000, Currency: "INR", Receipt: paymentID})
+		require.NoError(t, err)
+		gp, _, err := gw.Pay(order.ID, "upi")
+		require.NoError(t, err)
+
+		stored := &model.Payment{
+			ID:             paymentID,
+			RideID:         rideID,
+			Amount:         250,
+			Currency:       "INR",
+			Provider:       &provider,
+			GatewayOrderID: &order.ID,
+			Status:         model.PaymentStatusTypeCreated,
+		}
+
+		mockPaymentRepo := new(testutil.MockPaymentRepository)
+		mockRideRepo := new(testutil.MockRideRepository)
+		mockEventRepo := new(testutil.MockPaymentEventRepository)
+		mockRefundRepo := new(testutil.MockRefundRepository)
+		mockLedgerRepo := new(testutil.MockLedgerRepository)
+		mockPaymentRepo.On("GetByGatewayOrderID", mock.Anything, order.ID).Return(stored, nil).Maybe()
+		mockPaymentRepo.On("GetByID", mock.Anything, paymentID).Return(stored, nil).Maybe()
+		mockPaymentRepo.On("TransitionStatus", mock.Anything, mock.Anything, mock.Anything).Return(true, nil).Maybe()
+		mockRideRepo.On("GetByID", mock.Anything, rideID).Return(&model.Ride{ID: rideID, DriverID: &driverID}, nil).Maybe()
+		mockRideRepo.On("GetCharges", mock.Anything, rideID).Return(&model.RideCharges{Fare: 250}, nil).Maybe()
+		mockLedgerRepo.On("Post", mock.Anything, mock.Anything).Return(true, nil).Maybe()
+		mockEarningsRepo := new(testutil.MockEarningsRepository)
+		mockEarningsRepo.On("Record", mock.Anything, mock.Anything).Return(nil).Maybe()
+		mockEarningsRepo.On("GetRideEarning", mock.Anything, paymentID).Return(nil, nil).Maybe()
+
+		paid = &ridePaidRecorder{}
+		paymentService := service.NewPaymentService(mockPaymentRepo, mockRideRepo, mockEventRepo, mockRefundRepo, nil, mockLedgerRepo,
+			mockEarningsRepo, nil, config.DefaultEarningsConfig(), &config.DefaultPaymentConfig().Reconciliation, gateway.NewRazorpay(client), nil, paid)
+		return paymentService, mockPaymentRepo, mockRideRepo, mockEventRepo, mockRefundRepo, stored, gp
+	}
+
+	t.Run("Captured event captures the payment once", func(t *testing.T) {
+		paymentService, _, mockRideRepo, mockEventRepo, _, stored, gp := setup(t)
+		body, signature, eventID, err := gw.Webhook(razorpay.EventPaymentCaptured, gp.ID, "")
+		require.NoError(t, err)
+
+		mockEventRepo.On("Claim", mock.Anything, mock.MatchedBy(func(e *model.PaymentEvent) bool {
+			return e.ID == eventID && e.EventType == razorpay.EventPaymentCaptured
+		})).Return(false, nil).Once()
+		mockEventRepo.On("MarkProcessed", mock.Anything, eventID, mock.MatchedBy(func(id *string) bool {
+			return id != nil && *id == paymentID
+		})).Return(nil).Once()
+		mockRideRepo.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusCompleted, paymentID).Return(nil).Once()
+
+		require.NoError(t, paymentService.HandleWebhook(ctx, body, razorpayHeader(signature, eventID)))
+		assert.Equal(t, model.PaymentStatusTypeCaptured, stored.Status)
+		assert.Equal(t, gp.ID, *stored.GatewayPaymentID)
+
+		// Razorpay redelivers until it sees a 2xx, the redelivery changes nothing
+		mockEventRepo.On("Claim", mock.Anything, mock.Anything).Return(true, nil).Once()
+		require.NoError(t, paymentService.HandleWebhook(ctx, body, razorpayHeader(signature, eventID)))
+		assert.Equal(t, []string{paymentID}, paid.payments)
+
+		mockEventRepo.AssertExpectations(t)
+		mockRideRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid signature is rejected", func(t *testing.T) {
+		paymentService, _, _, mockEventRepo, _, stored, gp := setup(t)
+		body, _, eventID, err := gw.Webhook(razorpay.EventPaymentCaptured, gp.ID, "")
+		require.NoError(t, err)
+
+		err = paymentService.HandleWebhook(ctx, body, razorpayHeader(razorpay.WebhookSignature(body, "guessed"), eventID))
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusUnauthorized, httpErr.Status)
+		assert.Equal(t, model.PaymentStatusTypeCreated, stored.Status)
+		mockEventRepo.AssertNotCalled(t, "Claim", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Failed event after the capture is ignored", func(t *testing.T) {
+		paymentService, mockPaymentRepo, _, mockEventRepo, _, stored, gp := setup(t)
+		stored.Status = model.PaymentStatusTypeCaptured
+		body, signature, eventID, err := gw.Webhook(razorpay.EventPaymentFailed, gp.ID, "")
+		require.NoError(t, err)
+
+		mockEventRepo.On("Claim", mock.Anything, mock.Anything).Return(false, nil).Once()
+		mockEventRepo.On("MarkProcessed", mock.Anything, eventID, mock.Anything).Return(nil).Once()
+
+		require.NoError(t, paymentService.HandleWebhook(ctx, body, razorpayHeader(signature, eventID)))
+		assert.Equal(t, model.PaymentStatusTypeCaptured, stored.Status)
+		mockPaymentRepo.AssertNotCalled(t, "TransitionStatus", mock.Anything, mock.Anything, mock.Anything)
+		mockEventRepo.AssertExpectations(t)
+	})
+
+	t.Run("Dashboard refund is recorded and refunds the payment", func(t *testing.T) {
+		paymentService, _, mockRideRepo, mockEventRepo, mockRefundRepo, stored, gp := setup(t)
+		stored.Status = model.PaymentStatusTypeCaptured
+		refund, err := client.CreateRefund(ctx, gp.ID, razorpay.CreateRefundRequest{})
+		require.NoError(t, err)
+		body, signature, eventID, err := gw.Webhook(razorpay.EventRefundProcessed, gp.ID, refund.ID)
+		require.NoError(t, err)
+
+		mockEventRepo.On("Claim", mock.Anything, mock.Anything).Return(false, nil).Once()
+		mockEventRepo.On("MarkProcessed", mock.Anything, eventID, mock.Anything).Return(nil).Once()
+		mockRefundRepo.On("GetByGatewayRefundID", mock.Anything, refund.ID).Return(nil, nil).Once()
+		mockRefundRepo.On("Create", mock.Anything, mock.MatchedBy(func(r *model.Refund) bool {
+			return r.Source == model.RefundSourceGateway && r.Amount == 250 && *r.GatewayRefundID == refund.ID
+		})).Return(nil).Once()
+		mockRefundRepo.On("TransitionStatus", mock.Anything, mock.MatchedBy(func(r *model.Refund) bool {
+			return r.Status == model.RefundStatusProcessed
+		}), model.RefundStatusPending).Return(true, nil).Once()
+		mockRefundRepo.On("ProcessedTotal", mock.Anything, paymentID).Return(250.0, nil).Once()
+		mockRideRepo.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusRefunded, paymentID).Return(nil).Once()
+
+		require.NoError(t, paymentService.HandleWebhook(ctx, body, razorpayHeader(signature, eventID)))
+		assert.Equal(t, model.PaymentStatusTypeRefunded, stored.Status)
+		mockRefundRepo.AssertExpectations(t)
+		mockRideRepo.AssertExpectations(t)
+	})
+}
+
+func TestPaymentAmount(t *testing.T) {
+	gw := razorpaytest.NewServer("rzp_test_key", "secret")
+	defer gw.Close()
+	ctx := context.Background()
+
+	const userID = "user-1"
+	const rideID = "ride-1"
+	provider := "razorpay"
+
+	newRide := func(method model.PaymentMethod) *model.Ride {
+		return &model.Ride{ID: rideID, UserID: userID, Status: model.RideStatusCompleted, PaymentMethod: &method}
+	}
+
+	// The ledger and earnings of the last setup
+	var mockLedgerRepo *testutil.MockLedgerRepository
+	var mockEarningsRepo *testutil.MockEarningsRepository
+
+	setup := func(ride *model.Ride, charges *model.RideCharges) (service.PaymentService, *testutil.MockPaymentRepository, *testutil.MockRideRepository) {
+		mockPaymentRepo := new(testutil.MockPaymentRepository)
+		mockRideRepo := new(testutil.MockRideRepository)
+		mockLedgerRepo = new(testutil.MockLedgerRepository)
+		mockEarningsRepo = new(testutil.MockEarningsRepository)
+		mockRideRepo.On("GetByID", mock.Anything, rideID).Return(ride, nil)
+		mockRideRepo.On("GetCharges", mock.Anything, rideID).Return(charges, nil).Maybe()
+		mockPaymentRepo.On("SetGatewayOrder", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+		paymentService := service.NewPaymentService(mockPaymentRepo, mockRideRepo, new(testutil.MockPaymentEventRepository), nil, nil, mockLedgerRepo,
+			mockEarningsRepo, nil, config.DefaultEarningsConfig(), &config.DefaultPaymentConfig().Reconciliation, gateway.NewRazorpay(razorpay.NewClient(gw.Config())), nil, nil)
+		return paymentService, mockPaymentRepo, mockRideRepo
+	}
+
+	expectCreate := func(mockPaymentRepo *testutil.MockPaymentRepository, amount float64) {
+		mockPaymentRepo.On("Create", mock.Anything, mock.MatchedBy(func(p *model.Payment) bool {
+			return p.Amount == amount
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*model.Payment).ID = "new-payment"
+		}).Return(nil).Once()
+	}
+
+	expectCreateWithTip := func(mockPaymentRepo *testutil.MockPaymentRepository, amount, tip float64) {
+		mockPaymentRepo.On("CreateWithTip", mock.Anything, mock.MatchedBy(func(p *model.Payment) bool {
+			return p.Amount == amount
+		}), tip).Run(func(args mock.Arguments) {
+			args.Get(1).(*model.Payment).ID = "new-payment"
+		}).Return(nil).Once()
+	}
+
+	assertStatus := func(t *testing.T, err error, status int) {
+		t.Helper()
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, status, httpErr.Status)
+	}
+
+	t.Run("Amount comes from the ride charges", func(t *testing.T) {
+		paymentService, mockPaymentRepo, _ := setup(newRide(model.PaymentMethodCard),
+			&model.RideCharges{Fare: 400, WalletApplied: 50, PromoDiscount: 25.5})
+		mockPaymentRepo.On("GetOutstandingByRideID", mock.Anything, rideID).Return(nil, nil)
+		expectCreateWithTip(mockPaymentRepo, 344.5, 20)
+
+		resp, err := paymentService.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{
+			RideID:        rideID,
+			PaymentMethod: "card",
+			Tip:           20,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 344.5, resp.Amount)
+		assert.Equal(t, 20.0, resp.Breakdown.Tip)
+
+		order, ok := gw.Order(*resp.GatewayOrderID)
+		require.True(t, ok)
+		assert.Equal(t, int64(34450), order.Amount)
+		mockPaymentRepo.AssertExpectations(t)
+	})
+
+	t.Run("Discounts never make the fare negative", func(t *testing.T) {
+		charges := model.RideCharges{Fare: 80, WalletApplied: 60, PromoDiscount: 50, Tip: 10}
+		assert.Equal(t, 10.0, charges.Payable())
+	})
+
+	t.Run("Rides that are not completed cannot be paid", func(t *testing.T) {
+		ride := newRide(model.PaymentMethodUPI)
+		ride.Status = model.RideStatusInProgress
+		paymentService, _, _ := setup(ride, &model.RideCharges{Fare: 100})
+
+		_, err := paymentService.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{RideID: rideID, PaymentMethod: "upi"})
+		assertStatus(t, err, http.StatusBadRequest)
+	})
+
+	t.Run("Payment method must match the ride", func(t *testing.T) {
+		paymentService, _, _ := setup(newRide(model.PaymentMethodCash), &model.RideCharges{Fare: 100})
+
+		_, err := paymentService.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{RideID: rideID, PaymentMethod: "upi"})
+		assertStatus(t, err, http.StatusBadRequest)
+	})
+
+	t.Run("Paid rides are not charged again", func(t *testing.T) {
+		ride := newRide(model.PaymentMethodUPI)
+		ride.PaymentStatus = model.PaymentStatusCompleted
+		paymentService, mockPaymentRepo, _ := setup(ride, &model.RideCharges{Fare: 100})
+
+		_, err := paymentService.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{RideID: rideID, PaymentMethod: "upi", Tip: 15})
+		assertStatus(t, err, http.StatusBadRequest)
+		mockPaymentRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		mockPaymentRepo.AssertNotCalled(t, "CreateWithTip", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Outstanding order for the same amount is returned", func(t *testing.T) {
+		paymentService, mockPaymentRepo, _ := setup(newRide(model.PaymentMethodUPI), &model.RideCharges{Fare: 100})
+		orderID := "order_existing"
+		mockPaymentRepo.On("GetOutstandingByRideID", mock.Anything, rideID).Return(&model.Payment{
+			ID: "existing", RideID: rideID, Amount: 100, Currency: "INR", Provider: &provider, GatewayOrderID: &orderID,
+			Status: model.PaymentStatusTypeCreated, PaymentMethod: "upi",
+		}, nil)
+
+		resp, err := paymentService.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{RideID: rideID, PaymentMethod: "upi"})
+		require.NoError(t, err)
+		assert.Equal(t, "existing", resp.PaymentID)
+		assert.Equal(t, orderID, *resp.GatewayOrderID)
+		mockPaymentRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Changed tip replaces an unattempted order", func(t *testing.T) {
+		paymentService, mockPaymentRepo, _ := setup(newRide(model.PaymentMethodUPI), &model.RideCharges{Fare: 100})
+		order, err := razorpay.NewClient(gw.Config()).CreateOrder(ctx, razorpay.CreateOrderRequest{Amount: 10000, Currency: "INR"})
+		require.NoError(t, err)
+		mockPaymentRepo.On("GetOutstandingByRideID", mock.Anything, rideID).Return(&model.Payment{
+			ID: "existing", RideID: rideID, Amount: 100, Currency: "INR", Provider: &provider, GatewayOrderID: &order.ID,
+			Status: model.PaymentStatusTypeCreated, PaymentMethod: "upi",
+		}, nil)
+		mockPaymentRepo.On("TransitionStatus", mock.Anything, mock.MatchedBy(func(p *model.Payment) bool {
+			return p.ID == "existing" && p.Status == model.PaymentStatusTypeFailed
+		}), model.PaymentStatusTypeCreated).Return(true, nil).Once()
+		expectCreateWithTip(mockPaymentRepo, 115, 15)
+
+		resp, err := paymentService.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{RideID: rideID, PaymentMethod: "upi", Tip: 15})
+		require.NoError(t, err)
+		assert.Equal(t, "new-payment", resp.PaymentID)
+		assert.NotEqual(t, order.ID, *resp.GatewayOrderID)
+		mockPaymentRepo.AssertExpectations(t)
+	})
+
+	t.Run("Attempted order is not replaced", func(t *testing.T) {
+		paymentService, mockPaymentRepo, _ := setup(newRide(model.PaymentMethodUPI), &model.RideCharges{Fare: 100})
+		order, err := razorpay.NewClient(gw.Config()).CreateOrder(ctx, razorpay.CreateOrderRequest{Amount: 10000, Currency: "INR"})
+		require.NoError(t, err)
+		_, err = gw.Decline(order.ID, "upi")
+		require.NoError(t, err)
+		mockPaymentRepo.On("GetOutstandingByRideID", mock.Anything, rideID).Return(&model.Payment{
+			ID: "existing", RideID: rideID, Amount: 100, Currency: "INR", Provider: &provider, GatewayOrderID: &order.ID,
+			Status: model.PaymentStatusTypeCreated, PaymentMethod: "upi",
+		}, nil)
+
+		_, err = paymentService.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{RideID: rideID, PaymentMethod: "upi", Tip: 15})
+		assertStatus(t, err, http.StatusBadRequest)
+		// The refused tip is not saved
+		mockPaymentRepo.AssertNotCalled(t, "CreateWithTip", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Fully discounted ride is settled without an order", func(t *testing.T) {
+		ride := newRide(model.PaymentMethodUPI)
+		driverID := "driver-1"
+		ride.DriverID = &driverID
+		paymentService, mockPaymentRepo, mockRideRepo := setup(ride,
+			&model.RideCharges{Fare: 100, WalletApplied: 40, PromoDiscount: 60})
+		mockPaymentRepo.On("GetOutstandingByRideID", mock.Anything, rideID).Return(nil, nil)
+		expectCreate(mockPaymentRepo, 0)
+		mockPaymentRepo.On("TransitionStatus", mock.Anything, mock.Anything, model.PaymentStatusTypeCreated).Return(true, nil).Once()
+		mockRideRepo.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusCompleted, "new-payment").Return(nil).Once()
+		// The platform pays the driver for the promo
+		mockLedgerRepo.On("Post", mock.Anything, mock.MatchedBy(func(e *model.JournalEntry) bool {
+			return len(e.Postings) == 3 &&
+				e.Postings[0].AccountType == model.LedgerAccountPlatformPromotions && e.Postings[0].Amount == -6000 &&
+				e.Postings[1].AccountType == model.LedgerAccountDriverEarnings && e.Postings[1].Amount == 4800 &&
+				e.Postings[2].AccountType == model.LedgerAccountPlatformCommission && e.Postings[2].Amount == 1200
+		})).Return(true, nil).Once()
+		mockEarningsRepo.On("Record", mock.Anything, mock.MatchedBy(func(e *model.RideEarning) bool {
+			return e.Fare == 6000 && e.Promo == 6000 && e.Commission == 1200 && e.Net == 4800
+		})).Return(nil).Once()
+
+		resp, err := paymentService.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{RideID: rideID, PaymentMethod: "upi"})
+		require.NoError(t, err)
+		assert.Equal(t, string(model.PaymentStatusTypeCaptured), resp.Status)
+		assert.Nil(t, resp.GatewayOrderID)
+		mockRideRepo.AssertExpectations(t)
+		mockLedgerRepo.AssertExpectations(t)
+		mockEarningsRepo.AssertExpectations(t)
+	})
+
+	t.Run("Concurrent order for the ride is refused", func(t *testing.T) {
+		paymentService, mockPaymentRepo, _ := setup(newRide(model.PaymentMethodUPI), &model.RideCharges{Fare: 100})
+		mockPaymentRepo.On("GetOutstandingByRideID", mock.Anything, rideID).Return(nil, nil)
+		mockPaymentRepo.On("Create", mock.Anything, mock.Anything).Return(repository.ErrOutstandingPayment).Once()
+
+		_, err := paymentService.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{RideID: rideID, PaymentMethod: "upi"})
+		assertStatus(t, err, http.StatusBadRequest)
+	})
+}
+
+// razorpayHeader holds the headers Razorpay delivers a webhook with
+func razorpayHeader(signature, eventID string) http.Header {
+	header := http.Header{}
+	header.Set("X-Razorpay-Signature", signature)
+	header.Set("X-Razorpay-Event-Id", eventID)
+	return header
+}
+
+// ridePaidRecorder records the ride payments the payment service reports captured
+type ridePaidRecorder struct {
+	payments []string
+}
+
+func (r *ridePaidRecorder) RidePaid(ctx context.Context, payment *model.Payment) {
+	r.payments = append(r.payments, payment.ID)
+}
+
+func TestPaymentUPI(t *testing.T) {
+	ctx := context.Background()
+	const userID = "user-1"
+	const paymentID = "payment-1"
+
+	setup := func(status model.PaymentStatusType) (service.PaymentService, *testutil.MockPaymentRepository) {
+		mockPaymentRepo := new(testutil.MockPaymentRepository)
+		mockPaymentRepo.On("GetByID", mock.Anything, paymentID).Return(&model.Payment{
+			ID: paymentID, RideID: "ride-1", UserID: userID, Amount: 220, Currency: "INR", Status: status, PaymentMethod: "upi",
+		}, nil)
+		paymentService := service.NewPaymentService(mockPaymentRepo, new(testutil.MockRideRepository), new(testutil.MockPaymentEventRepository), nil, nil,
+			nil, nil, nil, config.DefaultEarningsConfig(), &config.DefaultPaymentConfig().Reconciliation, nil, nil, nil)
+		return paymentService, mockPaymentRepo
+	}
+
+	t.Run("New payment is marked pending", func(t *testing.T) {
+		paymentService, mockPaymentRepo := setup(model.PaymentStatusTypeCreated)
+		mockPaymentRepo.On("TransitionStatus", mock.Anything, mock.MatchedBy(func(p *model.Payment) bool {
+			return p.Status == model.PaymentStatusTypePending
+		}), model.PaymentStatusTypeCreated).Return(true, nil).Once()
+
+		require.NoError(t, paymentService.ProcessUPIPayment(ctx, userID, &model.UPIPaymentRequest{PaymentID: paymentID}))
+		mockPaymentRepo.AssertExpectations(t)
+	})
+
+	for _, status := range []model.PaymentStatusType{model.PaymentStatusTypePending, model.PaymentStatusTypeCaptured, model.PaymentStatusTypeRefunded} {
+		t.Run("Payment "+string(status)+" is refused", func(t *testing.T) {
+			paymentService, mockPaymentRepo := setup(status)
+
+			err := paymentService.ProcessUPIPayment(ctx, userID, &model.UPIPaymentRequest{PaymentID: paymentID})
+			var httpErr *errs.HTTPError
+			require.ErrorAs(t, err, &httpErr)
+			assert.Equal(t, "PAYMENT_STATE_CONFLICT", httpErr.Code)
+			mockPaymentRepo.AssertNotCalled(t, "TransitionStatus", mock.Anything, mock.Anything, mock.Anything)
+			mockPaymentRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		})
+	}
+}
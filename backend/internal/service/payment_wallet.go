@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+)
+
+const (
+	// Wallet transactions returned with the balance
+	walletTransactionsShown = 20
+	walletStatementDays     = 30
+	walletStatementMaxDays  = 92
+	// Far more than a rider makes in a statement period, guards the query
+	walletStatementLines = 1000
+)
+
+var codeInsufficientWalletBalance = "INSUFFICIENT_WALLET_BALANCE"
+
+func insufficientWalletBalance() error {
+	return errs.NewBadRequestError("insufficient wallet balance", false, &codeInsufficientWalletBalance, nil, nil)
+}
+
+func (s *paymentService) GetWallet(ctx context.Context, userID string) (*model.Wallet, error) {
+	account, err := s.ledgerRepo.GetAccount(ctx, model.LedgerAccountRiderWallet, userID)
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+	postings, err := s.ledgerRepo.ListRecentPostings(ctx, model.LedgerAccountRiderWallet, userID, walletTransactionsShown)
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+
+	return &model.Wallet{
+		UserID:       userID,
+		Balance:      gateway.FromPaise(account.Balance),
+		Currency:     account.Currency,
+		Transactions: walletTransactions(postings),
+	}, nil
+}
+
+// GetWalletStatement lists the wallet transactions of a period with the balances it opened and
+// closed with. Days are UTC.
+func (s *paymentService) GetWalletStatement(ctx context.Context, userID string, req *model.WalletStatementRequest) (*model.WalletStatement, error) {
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if req.To != "" {
+		to, _ = time.Parse(time.DateOnly, req.To)
+	}
+	from := to.AddDate(0, 0, 1-walletStatementDays)
+	if req.From != "" {
+		from, _ = time.Parse(time.DateOnly, req.From)
+	}
+	if from.After(to) {
+		return nil, errs.NewBadRequest("statement period starts after it ends")
+	}
+	end := to.AddDate(0, 0, 1)
+	if end.Sub(from) > walletStatementMaxDays*24*time.Hour {
+		return nil, errs.NewBadRequest("statement period cannot exceed 92 days")
+	}
+
+	opening, err := s.ledgerRepo.BalanceAt(ctx, model.LedgerAccountRiderWallet, userID, from)
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+	closing, err := s.ledgerRepo.BalanceAt(ctx, model.LedgerAccountRiderWallet, userID, end)
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+	postings, err := s.ledgerRepo.ListPostings(ctx, model.LedgerAccountRiderWallet, userID, from, end, walletStatementLines)
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+
+	statement := &model.WalletStatement{
+		UserID:         userID,
+		Currency:       "INR",
+		From:           from.Format(time.DateOnly),
+		To:             to.Format(time.DateOnly),
+		OpeningBalance: gateway.FromPaise(opening),
+		ClosingBalance: gateway.FromPaise(closing),
+		Transactions:   walletTransactions(postings),
+	}
+	var credits, debits int64
+	for _, p := range postings {
+		if p.Amount > 0 {
+			credits += p.Amount
+		} else {
+			debits -= p.Amount
+		}
+	}
+	statement.TotalCredits = gateway.FromPaise(credits)
+	statement.TotalDebits = gateway.FromPaise(debits)
+	return statement, nil
+}
+
+func walletTransactions(postings []*model.LedgerPosting) []*model.WalletTransaction {
+	transactions := make([]*model.WalletTransaction, 0, len(postings))
+	for _, p := range postings {
+		transactions = append(transactions, &model.WalletTransaction{
+			ID:           p.ID,
+			Type:         p.EntryType,
+			ReferenceID:  p.ReferenceID,
+			Description:  p.Description,
+			Amount:       gateway.FromPaise(p.Amount),
+			BalanceAfter: gateway.FromPaise(p.BalanceAfter),
+			CreatedAt:    p.CreatedAt,
+		})
+	}
+	return transactions
+}
+
+// payFromWallet captures a ride payment from the rider's wallet. A wallet that cannot cover it
+// fails the payment.
+func (s *paymentService) payFromWallet(ctx context.Context, payment *model.Payment, charges *model.RideCharges) (*model.CreatePaymentOrderResponse, error) {
+	err := s.transitionPayment(ctx, payment, model.PaymentStatusTypeCaptured, nil, nil)
+	if errors.Is(err, repository.ErrInsufficientBalance) {
+		s.transitionPayment(ctx, payment, model.PaymentStatusTypeFailed, nil, nil)
+		return nil, insufficientWalletBalance()
+	}
+	if errors.Is(err, errPaymentTransition) {
+		return nil, paymentConflict("a payment for this ride is already in progress")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.orderResponse(payment, charges), nil
+}
+
+// CreateWalletTopup opens a gateway order for adding money to the caller's wallet
+func (s *paymentService) CreateWalletTopup(ctx context.Context, userID string, req *model.CreateWalletTopupRequest) (*model.CreateWalletTopupResponse, error) {
+	if s.gateway == nil {
+		return nil, errs.NewServiceUnavailableError("payment gateway is not configured", true)
+	}
+
+	topup := &model.WalletTopup{
+		UserID:   userID,
+		Amount:   math.Round(req.Amount*100) / 100,
+		Currency: "INR",
+		Status:   model.WalletTopupStatusCreated,
+	}
+	if err := s.walletRepo.CreateTopup(ctx, topup); err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+
+	order, err := s.gateway.CreateOrder(ctx, gateway.OrderRequest{
+		Amount:   gateway.ToPaise(topup.Amount),
+		Currency: topup.Currency,
+		Receipt:  topup.ID,
+		Notes:    map[string]string{"topup_id": topup.ID},
+	})
+	if err != nil {
+		topup.Status = model.WalletTopupStatusFailed
+		_, _ = s.walletRepo.TransitionTopup(ctx, topup, model.WalletTopupStatusCreated)
+		return nil, errs.NewServiceUnavailableError("payment gateway is unavailable, please try again", true)
+	}
+
+	if err := s.walletRepo.SetTopupOrder(ctx, topup.ID, s.gateway.Name(), order.ID); err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+
+	return &model.CreateWalletTopupResponse{
+		TopupID:        topup.ID,
+		Provider:       s.gateway.Name(),
+		GatewayOrderID: order.ID,
+		GatewayKeyID:   s.gateway.KeyID(),
+		Amount:         topup.Amount,
+		Currency:       topup.Currency,
+	}, nil
+}
+
+// VerifyWalletTopup confirms the checkout of a top-up like VerifyPayment and credits the wallet.
+// It returns the wallet with the new balance.
+func (s *paymentService) VerifyWalletTopup(ctx context.Context, userID string, req *model.VerifyWalletTopupRequest) (*model.Wallet, error) {
+	topup, err := s.walletRepo.GetTopup(ctx, req.TopupID)
+	if err != nil {
+		return nil, errs.NewBadRequest("top-up not found")
+	}
+	if topup.UserID != userID {
+		return nil, errs.NewUnauthorized("unauthorized access to top-up")
+	}
+
+	// A retried verify, or one racing the webhook, of a credited top-up succeeds
+	if topup.Status == model.WalletTopupStatusCaptured &&
+		topup.GatewayPaymentID != nil && *topup.GatewayPaymentID == req.GatewayPaymentID {
+		return s.GetWallet(ctx, userID)
+	}
+
+	if topup.GatewayOrderID == nil || *topup.GatewayOrderID != req.GatewayOrderID {
+		return nil, errs.NewBadRequest("order does not belong to this top-up")
+	}
+	gw := s.gatewayOf(topup.Provider)
+	if gw == nil {
+		return nil, errs.NewServiceUnavailableError("payment gateway is not configured", true)
+	}
+	if !gw.VerifyPaymentSignature(req.GatewayOrderID, req.GatewayPaymentID, req.GatewaySignature) {
+		s.failTopup(ctx, topup)
+		return nil, errs.NewBadRequest("invalid payment signature")
+	}
+
+	err = s.captureGatewayPayment(ctx, gw, *topup.GatewayOrderID, topup.Amount, req.GatewayPaymentID)
+	if errors.Is(err, errGatewayPaymentFailed) {
+		s.failTopup(ctx, topup)
+		return nil, errs.NewBadRequest("payment was not successful")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.creditTopup(ctx, topup, req.GatewayPaymentID); err != nil {
+		return nil, err
+	}
+	return s.GetWallet(ctx, userID)
+}
+
+// creditTopup credits a paid top-up to the wallet. The entry is posted before the status changes,
+// a credit interrupted in between is completed by the next verify or webhook.
+func (s *paymentService) creditTopup(ctx context.Context, topup *model.WalletTopup, gatewayPaymentID string) error {
+	if topup.Status == model.WalletTopupStatusCaptured {
+		return nil
+	}
+
+	entry := transfer(model.JournalEntryWalletTopup, topup.ID, "Wallet top-up", topup.Currency,
+		gateway.ToPaise(topup.Amount), gatewayClearing(), riderWallet(topup.UserID))
+	if err := s.postEntry(ctx, entry); err != nil {
+		return err
+	}
+
+	// A failed top-up can still be paid, gateways accept further attempts on the order
+	from := topup.Status
+	topup.Status = model.WalletTopupStatusCaptured
+	topup.GatewayPaymentID = &gatewayPaymentID
+	if _, err := s.walletRepo.TransitionTopup(ctx, topup, from); err != nil {
+		return errs.NewInternalServerError()
+	}
+	return nil
+}
+
+func (s *paymentService) failTopup(ctx context.Context, topup *model.WalletTopup) {
+	if topup.Status != model.WalletTopupStatusCreated {
+		return
+	}
+	topup.Status = model.WalletTopupStatusFailed
+	_, _ = s.walletRepo.TransitionTopup(ctx, topup, model.WalletTopupStatusCreated)
+}
+
+// applyWebhookTopup credits or fails the top-up paid through the order of a payment event
+func (s *paymentService) applyWebhookTopup(ctx context.Context, event string, gp *gateway.Payment) error {
+	topup, err := s.walletRepo.GetTopupByGatewayOrderID(ctx, gp.OrderID)
+	if err != nil {
+		return errs.Wrap(err, "failed to find top-up for webhook")
+	}
+	if topup == nil || gp.Amount != gateway.ToPaise(topup.Amount) {
+		return nil
+	}
+
+	switch event {
+	case gateway.EventPaymentCaptured:
+		return s.creditTopup(ctx, topup, gp.ID)
+	case gateway.EventPaymentFailed:
+		s.failTopup(ctx, topup)
+	}
+	return nil
+}
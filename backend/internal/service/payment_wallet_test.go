@@ -0,0 +1,251 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/razorpay"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/razorpay/razorpaytest"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaymentWallet(t *testing.T) {
+	gw := razorpaytest.NewServer("rzp_test_key", "secret")
+	defer gw.Close()
+	client := razorpay.NewClient(gw.Config())
+	ctx := context.Background()
+
+	const userID = "user-1"
+	const rideID = "ride-1"
+	const topupID = "6c2d8e1f-4a3b-4c5d-9e8f-0a1b2c3d4e5f"
+	driverID := "driver-1"
+
+	type fixture struct {
+		service  service.PaymentService
+		payments *testutil.MockPaymentRepository
+		rides    *testutil.MockRideRepository
+		events   *testutil.MockPaymentEventRepository
+		wallets  *testutil.MockWalletRepository
+		ledger   *testutil.MockLedgerRepository
+		earnings *testutil.MockEarningsRepository
+	}
+
+	setup := func() *fixture {
+		f := &fixture{
+			payments: new(testutil.MockPaymentRepository),
+			rides:    new(testutil.MockRideRepository),
+			events:   new(testutil.MockPaymentEventRepository),
+			wallets:  new(testutil.MockWalletRepository),
+			ledger:   new(testutil.MockLedgerRepository),
+			earnings: new(testutil.MockEarningsRepository),
+		}
+		f.ledger.On("ListRecentPostings", mock.Anything, model.LedgerAccountRiderWallet, userID, mock.Anything).Return([]*model.LedgerPosting{}, nil).Maybe()
+		f.earnings.On("Record", mock.Anything, mock.Anything).Return(nil).Maybe()
+		f.service = service.NewPaymentService(f.payments, f.rides, f.events, new(testutil.MockRefundRepository), f.wallets, f.ledger,
+			f.earnings, nil, config.DefaultEarningsConfig(), &config.DefaultPaymentConfig().Reconciliation, gateway.NewRazorpay(client), nil, nil)
+		return f
+	}
+
+	// balance sets the wallet balance in paise reported by the ledger
+	balance := func(f *fixture, paise int64) {
+		f.ledger.On("GetAccount", mock.Anything, model.LedgerAccountRiderWallet, userID).
+			Return(&model.LedgerAccount{Type: model.LedgerAccountRiderWallet, Currency: "INR", Balance: paise}, nil)
+	}
+
+	// createTopup starts a top-up of ₹500 and returns it as stored
+	createTopup := func(t *testing.T, f *fixture) (*model.WalletTopup, *model.CreateWalletTopupResponse) {
+		stored := &model.WalletTopup{}
+		f.wallets.On("CreateTopup", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			topup := args.Get(1).(*model.WalletTopup)
+			topup.ID = topupID
+			*stored = *topup
+		}).Return(nil).Once()
+		f.wallets.On("SetTopupOrder", mock.Anything, topupID, "razorpay", mock.Anything).Run(func(args mock.Arguments) {
+			provider, orderID := args.String(2), args.String(3)
+			stored.Provider, stored.GatewayOrderID = &provider, &orderID
+		}).Return(nil).Once()
+		f.wallets.On("GetTopup", mock.Anything, topupID).Return(stored, nil).Maybe()
+		f.wallets.On("TransitionTopup", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*stored = *args.Get(1).(*model.WalletTopup)
+		}).Return(true, nil).Maybe()
+
+		resp, err := f.service.CreateWalletTopup(ctx, userID, &model.CreateWalletTopupRequest{Amount: 500})
+		require.NoError(t, err)
+		return stored, resp
+	}
+
+	isTopupEntry := mock.MatchedBy(func(e *model.JournalEntry) bool {
+		return e.Type == model.JournalEntryWalletTopup && e.ReferenceID == topupID &&
+			e.Postings[0].AccountType == model.LedgerAccountGatewayClearing && e.Postings[0].Amount == -50000 &&
+			e.Postings[1].AccountType == model.LedgerAccountRiderWallet && e.Postings[1].Amount == 50000
+	})
+
+	t.Run("Top-up is credited on verify", func(t *testing.T) {
+		f := setup()
+		stored, resp := createTopup(t, f)
+		order, ok := gw.Order(resp.GatewayOrderID)
+		require.True(t, ok)
+		assert.Equal(t, int64(50000), order.Amount)
+		assert.Equal(t, topupID, order.Receipt)
+
+		gp, signature, err := gw.Pay(order.ID, "upi")
+		require.NoError(t, err)
+		f.ledger.On("Post", mock.Anything, isTopupEntry).Return(true, nil).Once()
+		balance(f, 50000)
+
+		wallet, err := f.service.VerifyWalletTopup(ctx, userID, &model.VerifyWalletTopupRequest{
+			TopupID:          topupID,
+			GatewayOrderID:   order.ID,
+			GatewayPaymentID: gp.ID,
+			GatewaySignature: signature,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 500.0, wallet.Balance)
+		assert.Equal(t, model.WalletTopupStatusCaptured, stored.Status)
+
+		// A retried verify does not credit again
+		_, err = f.service.VerifyWalletTopup(ctx, userID, &model.VerifyWalletTopupRequest{
+			TopupID:          topupID,
+			GatewayOrderID:   order.ID,
+			GatewayPaymentID: gp.ID,
+			GatewaySignature: signature,
+		})
+		require.NoError(t, err)
+		f.ledger.AssertExpectations(t)
+	})
+
+	t.Run("Forged top-up signature credits nothing", func(t *testing.T) {
+		f := setup()
+		stored, resp := createTopup(t, f)
+		gp, _, err := gw.Pay(resp.GatewayOrderID, "upi")
+		require.NoError(t, err)
+
+		_, err = f.service.VerifyWalletTopup(ctx, userID, &model.VerifyWalletTopupRequest{
+			TopupID:          topupID,
+			GatewayOrderID:   resp.GatewayOrderID,
+			GatewayPaymentID: gp.ID,
+			GatewaySignature: razorpay.PaymentSignature(resp.GatewayOrderID, gp.ID, "not-the-secret"),
+		})
+		require.Error(t, err)
+		assert.Equal(t, model.WalletTopupStatusFailed, stored.Status)
+		f.ledger.AssertNotCalled(t, "Post", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Captured webhook credits a top-up", func(t *testing.T) {
+		f := setup()
+		stored, resp := createTopup(t, f)
+		gp, _, err := gw.Pay(resp.GatewayOrderID, "upi")
+		require.NoError(t, err)
+		body, signature, eventID, err := gw.Webhook(razorpay.EventPaymentCaptured, gp.ID, "")
+		require.NoError(t, err)
+
+		f.events.On("Claim", mock.Anything, mock.Anything).Return(false, nil).Once()
+		f.events.On("MarkProcessed", mock.Anything, eventID, (*string)(nil)).Return(nil).Once()
+		f.payments.On("GetByGatewayOrderID", mock.Anything, resp.GatewayOrderID).Return(nil, nil).Once()
+		f.wallets.On("GetTopupByGatewayOrderID", mock.Anything, resp.GatewayOrderID).Return(stored, nil).Once()
+		f.ledger.On("Post", mock.Anything, isTopupEntry).Return(true, nil).Once()
+
+		require.NoError(t, f.service.HandleWebhook(ctx, body, razorpayHeader(signature, eventID)))
+		assert.Equal(t, model.WalletTopupStatusCaptured, stored.Status)
+		assert.Equal(t, gp.ID, *stored.GatewayPaymentID)
+		f.ledger.AssertExpectations(t)
+	})
+
+	walletRide := func(f *fixture, fare float64) {
+		method := model.PaymentMethodWallet
+		f.rides.On("GetByID", mock.Anything, rideID).Return(&model.Ride{
+			ID: rideID, UserID: userID, DriverID: &driverID, Status: model.RideStatusCompleted, PaymentMethod: &method,
+		}, nil)
+		f.rides.On("GetCharges", mock.Anything, rideID).Return(&model.RideCharges{Fare: fare}, nil)
+		f.payments.On("GetOutstandingByRideID", mock.Anything, rideID).Return(nil, nil)
+		f.payments.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			args.Get(1).(*model.Payment).ID = "wallet-payment"
+		}).Return(nil).Once()
+	}
+
+	t.Run("Ride is paid from the wallet", func(t *testing.T) {
+		f := setup()
+		walletRide(f, 180)
+		balance(f, 50000)
+		f.ledger.On("Post", mock.Anything, mock.MatchedBy(func(e *model.JournalEntry) bool {
+			return e.Type == model.JournalEntryRidePayment && e.ReferenceID == "wallet-payment" &&
+				e.Postings[0].AccountType == model.LedgerAccountRiderWallet && e.Postings[0].Amount == -18000 &&
+				e.Postings[1].AccountType == model.LedgerAccountDriverEarnings && *e.Postings[1].OwnerID == driverID && e.Postings[1].Amount == 14400 &&
+				e.Postings[2].AccountType == model.LedgerAccountPlatformCommission && e.Postings[2].Amount == 3600
+		})).Return(true, nil).Once()
+		f.payments.On("TransitionStatus", mock.Anything, mock.Anything, model.PaymentStatusTypeCreated).Return(true, nil).Once()
+		f.rides.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusCompleted, "wallet-payment").Return(nil).Once()
+
+		resp, err := f.service.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{RideID: rideID, PaymentMethod: "wallet"})
+		require.NoError(t, err)
+		assert.Equal(t, string(model.PaymentStatusTypeCaptured), resp.Status)
+		assert.Nil(t, resp.GatewayOrderID)
+		f.ledger.AssertExpectations(t)
+		f.rides.AssertExpectations(t)
+	})
+
+	t.Run("Wallet that cannot cover the ride is refused", func(t *testing.T) {
+		f := setup()
+		walletRide(f, 180)
+		balance(f, 10000)
+
+		_, err := f.service.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{RideID: rideID, PaymentMethod: "wallet"})
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, "INSUFFICIENT_WALLET_BALANCE", httpErr.Code)
+		f.payments.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Balance spent concurrently fails the payment", func(t *testing.T) {
+		f := setup()
+		walletRide(f, 180)
+		balance(f, 50000)
+		f.ledger.On("Post", mock.Anything, mock.Anything).Return(false, repository.ErrInsufficientBalance).Once()
+		f.payments.On("TransitionStatus", mock.Anything, mock.MatchedBy(func(p *model.Payment) bool {
+			return p.Status == model.PaymentStatusTypeFailed
+		}), model.PaymentStatusTypeCreated).Return(true, nil).Once()
+		f.rides.On("UpdatePaymentStatus", mock.Anything, rideID, model.PaymentStatusFailed, "wallet-payment").Return(nil).Once()
+
+		_, err := f.service.CreatePaymentOrder(ctx, userID, &model.CreatePaymentOrderRequest{RideID: rideID, PaymentMethod: "wallet"})
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusBadRequest, httpErr.Status)
+		assert.Equal(t, "INSUFFICIENT_WALLET_BALANCE", httpErr.Code)
+		f.payments.AssertExpectations(t)
+	})
+
+	t.Run("Statement totals the period", func(t *testing.T) {
+		f := setup()
+		from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+		end := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+		f.ledger.On("BalanceAt", mock.Anything, model.LedgerAccountRiderWallet, userID, from).Return(int64(2000), nil)
+		f.ledger.On("BalanceAt", mock.Anything, model.LedgerAccountRiderWallet, userID, end).Return(int64(34000), nil)
+		f.ledger.On("ListPostings", mock.Anything, model.LedgerAccountRiderWallet, userID, from, end, mock.Anything).Return([]*model.LedgerPosting{
+			{ID: "p1", Amount: 50000, BalanceAfter: 52000, EntryType: model.JournalEntryWalletTopup},
+			{ID: "p2", Amount: -18000, BalanceAfter: 34000, EntryType: model.JournalEntryRidePayment},
+		}, nil)
+
+		statement, err := f.service.GetWalletStatement(ctx, userID, &model.WalletStatementRequest{From: "2026-03-01", To: "2026-03-31"})
+		require.NoError(t, err)
+		assert.Equal(t, 20.0, statement.OpeningBalance)
+		assert.Equal(t, 340.0, statement.ClosingBalance)
+		assert.Equal(t, 500.0, statement.TotalCredits)
+		assert.Equal(t, 180.0, statement.TotalDebits)
+		require.Len(t, statement.Transactions, 2)
+		assert.Equal(t, -180.0, statement.Transactions[1].Amount)
+
+		_, err = f.service.GetWalletStatement(ctx, userID, &model.WalletStatementRequest{From: "2026-01-01", To: "2026-06-30"})
+		require.Error(t, err)
+	})
+}
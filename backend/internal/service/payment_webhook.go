@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+// HandleWebhook applies a webhook delivery of the configured gateway, authenticated by its headers.
+// Deliveries are deduplicated by event id, a redelivery of an applied event is acknowledged without
+// changes. Events about unknown orders or outdated by a later state are recorded and acknowledged.
+// Errors make the gateway redeliver.
+func (s *paymentService) HandleWebhook(ctx context.Context, body []byte, header http.Header) error {
+	if s.gateway == nil {
+		return errs.NewServiceUnavailableError("payment webhooks are not configured", false)
+	}
+	event, err := s.gateway.ParseWebhook(body, header)
+	switch {
+	case errors.Is(err, gateway.ErrWebhookNotConfigured):
+		return errs.NewServiceUnavailableError("payment webhooks are not configured", false)
+	case errors.Is(err, gateway.ErrInvalidSignature):
+		return errs.NewUnauthorized("invalid webhook signature")
+	case err != nil:
+		return errs.NewBadRequest("invalid webhook payload")
+	}
+
+	// Identical bodies are the same event when the gateway sends no event id
+	eventID := event.ID
+	if eventID == "" {
+		sum := sha256.Sum256(body)
+		eventID = "body_" + hex.EncodeToString(sum[:])
+	}
+
+	processed, err := s.eventRepo.Claim(ctx, &model.PaymentEvent{
+		ID:        eventID,
+		EventType: event.Type,
+		Payload:   body,
+	})
+	if err != nil {
+		return errs.Wrap(err, "failed to record payment event")
+	}
+	if processed {
+		return nil
+	}
+
+	paymentID, err := s.applyWebhookEvent(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	if err := s.eventRepo.MarkProcessed(ctx, eventID, paymentID); err != nil {
+		return errs.Wrap(err, "failed to mark payment event processed")
+	}
+	return nil
+}
+
+// applyWebhookEvent moves the payment of the event's order through the shared transition path and
+// returns its id, nil when the event is not about one of our payments
+func (s *paymentService) applyWebhookEvent(ctx context.Context, event *gateway.WebhookEvent) (*string, error) {
+	if event.Payment == nil {
+		return nil, nil
+	}
+	gp := event.Payment
+
+	payment, err := s.paymentRepo.GetByGatewayOrderID(ctx, gp.OrderID)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to find payment for webhook")
+	}
+	if payment == nil {
+		// Not a ride payment, the order may pay a wallet top-up
+		return nil, s.applyWebhookTopup(ctx, event.Type, gp)
+	}
+	if gp.Amount != gateway.ToPaise(payment.Amount) {
+		// Never settle a payment for a different amount than was ordered
+		return &payment.ID, nil
+	}
+
+	switch event.Type {
+	case gateway.EventPaymentCaptured:
+		err = s.transitionPayment(ctx, payment, model.PaymentStatusTypeCaptured, &gp.ID, nil)
+	case gateway.EventPaymentFailed:
+		err = s.transitionPayment(ctx, payment, model.PaymentStatusTypeFailed, &gp.ID, nil)
+	case gateway.EventRefundProcessed, gateway.EventRefundFailed:
+		if event.Refund != nil {
+			err = s.applyWebhookRefund(ctx, payment, event.Type, event.Refund)
+		}
+	}
+
+	// An event overtaken by a later state, e.g. a failed attempt after the capture, changes nothing
+	if errors.Is(err, errPaymentTransition) {
+		err = nil
+	}
+	return &payment.ID, err
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+)
+
+const defaultPromotionLimit = 50
+
+var codePromoNotApplicable = "PROMO_NOT_APPLICABLE"
+
+func promoNotApplicable(message string) error {
+	return errs.NewBadRequestError(message, false, &codePromoNotApplicable, nil, nil)
+}
+
+// PromotionService manages promo codes and applies them to fares. A promo is checked when the
+// fare is quoted and again when the ride is requested, the ride and its redemption are then
+// written together.
+type PromotionService struct {
+	server *server.Server
+	repo   *repository.Repositories
+}
+
+func NewPromotionService(s *server.Server, repo *repository.Repositories) *PromotionService {
+	return &PromotionService{
+		server: s,
+		repo:   repo,
+	}
+}
+
+// Quote estimates the fare of a trip with the rider's promo code applied
+func (p *PromotionService) Quote(ctx context.Context, userID string, req *model.FareQuoteRequest) (*model.FareQuote, error) {
+	distance, duration := estimateTrip(req.PickupLocation, req.DropoffLocation)
+	breakdown := fareBreakdown(distance, duration)
+	quote := &model.FareQuote{
+		DistanceKm:      distance,
+		DurationMinutes: duration,
+		VehicleType:     req.VehicleType,
+		Breakdown:       breakdown,
+	}
+
+	if req.PromoCode != "" {
+		promotion, discount, err := p.Apply(ctx, userID, req.PromoCode, req.VehicleType, req.PickupLocation, breakdown.Fare)
+		if err != nil {
+			return nil, err
+		}
+		quote.PromoCode = &promotion.Code
+		quote.Breakdown = withPromoDiscount(breakdown, discount)
+	}
+	return quote, nil
+}
+
+// Apply checks that a rider can use a promo code on a fare and returns the promotion with the
+// discount it gives. The usage limits are checked again when the redemption is written.
+func (p *PromotionService) Apply(ctx context.Context, userID, code string, vehicleType model.VehicleType, pickup model.Location, fare float64) (*model.Promotion, float64, error) {
+	promotion, err := p.repo.Promotion.GetByCode(ctx, strings.TrimSpace(code))
+	if err != nil {
+		return nil, 0, errs.NewInternalServerError()
+	}
+	if promotion == nil || !promotion.Active || (promotion.GrantedTo != nil && *promotion.GrantedTo != userID) {
+		return nil, 0, promoNotApplicable("promo code is not valid")
+	}
+
+	now := time.Now()
+	if now.Before(promotion.StartsAt) {
+		return nil, 0, promoNotApplicable("promo code is not active yet")
+	}
+	if promotion.EndsAt != nil && !now.Before(*promotion.EndsAt) {
+		return nil, 0, promoNotApplicable("promo code has expired")
+	}
+	if promotion.UsageLimit != nil && promotion.UsageCount >= *promotion.UsageLimit {
+		return nil, 0, promoNotApplicable(repository.ErrPromoUsageLimit.Error())
+	}
+	if len(promotion.VehicleTypes) > 0 && !slices.Contains(promotion.VehicleTypes, string(vehicleType)) {
+		return nil, 0, promoNotApplicable(fmt.Sprintf("promo code is not valid for %s rides", vehicleType))
+	}
+	if promotion.Zone != nil && calculateDistance(promotion.Zone.Center, pickup) > promotion.Zone.RadiusKm {
+		return nil, 0, promoNotApplicable("promo code is not valid in this area")
+	}
+	if fare < promotion.MinFare {
+		return nil, 0, promoNotApplicable(fmt.Sprintf("promo code applies to fares of at least ₹%.2f", promotion.MinFare))
+	}
+
+	redeemed, err := p.repo.Promotion.CountUserRedemptions(ctx, promotion.ID, userID)
+	if err != nil {
+		return nil, 0, errs.NewInternalServerError()
+	}
+	if redeemed >= promotion.PerUserLimit {
+		return nil, 0, promoNotApplicable(repository.ErrPromoUserLimit.Error())
+	}
+
+	discount := promotion.Discount(fare)
+	if discount <= 0 {
+		return nil, 0, promoNotApplicable("promo code gives no discount on this fare")
+	}
+	return promotion, discount, nil
+}
+
+// Release gives back the promo of a cancelled ride so the rider can use it again
+func (p *PromotionService) Release(ctx context.Context, rideID string) {
+	released, err := p.repo.Promotion.Release(ctx, rideID)
+	if err != nil {
+		p.server.Logger.Error().Err(err).Str("ride_id", rideID).Msg("Failed to release promo redemption")
+		return
+	}
+	if released {
+		p.server.Logger.Info().Str("ride_id", rideID).Msg("Promo redemption released")
+	}
+}
+
+// CreatePromotion adds a promo code on behalf of an admin
+func (p *PromotionService) CreatePromotion(ctx context.Context, adminID string, req *model.PromotionRequest) (*model.Promotion, error) {
+	promotion, err := promotionFromRequest(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	promotion.CreatedBy = &adminID
+
+	if err := p.repo.Promotion.Create(ctx, promotion); err != nil {
+		if errors.Is(err, repository.ErrPromoCodeTaken) {
+			return nil, errs.NewBadRequest(err.Error())
+		}
+		return nil, errs.NewInternalServerError()
+	}
+	return promotion, nil
+}
+
+// UpdatePromotion replaces the terms of a promotion. Omitted starts_at, per_user_limit and active
+// keep their current values. Redemptions already made keep their discount.
+func (p *PromotionService) UpdatePromotion(ctx context.Context, req *model.UpdatePromotionRequest) (*model.Promotion, error) {
+	existing, err := p.GetPromotion(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	promotion, err := promotionFromRequest(&req.PromotionRequest, existing)
+	if err != nil {
+		return nil, err
+	}
+	promotion.ID = req.ID
+
+	if err := p.repo.Promotion.Update(ctx, promotion); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, errs.NewNotFoundError("promotion not found", false, nil)
+		case errors.Is(err, repository.ErrPromoCodeTaken):
+			return nil, errs.NewBadRequest(err.Error())
+		}
+		return nil, errs.NewInternalServerError()
+	}
+	return promotion, nil
+}
+
+// Grant gives a user a flat promo code only they can redeem, once and before it expires. Granting
+// a code again returns the promotion granted before.
+func (p *PromotionService) Grant(ctx context.Context, userID, code, description string, amount float64, validity time.Duration) (*model.Promotion, error) {
+	startsAt := time.Now()
+	endsAt := startsAt.Add(validity)
+	usageLimit := 1
+	promotion := &model.Promotion{
+		Code:          code,
+		Description:   description,
+		DiscountType:  model.DiscountTypeFlat,
+		DiscountValue: amount,
+		StartsAt:      startsAt,
+		EndsAt:        &endsAt,
+		UsageLimit:    &usageLimit,
+		PerUserLimit:  1,
+		Active:        true,
+		GrantedTo:     &userID,
+	}
+
+	err := p.repo.Promotion.Create(ctx, promotion)
+	if errors.Is(err, repository.ErrPromoCodeTaken) {
+		granted, err := p.repo.Promotion.GetByCode(ctx, code)
+		if err != nil || granted == nil || granted.GrantedTo == nil || *granted.GrantedTo != userID {
+			return nil, errs.NewInternalServerError()
+		}
+		return granted, nil
+	}
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+	return promotion, nil
+}
+
+func (p *PromotionService) GetPromotion(ctx context.Context, id string) (*model.Promotion, error) {
+	promotion, err := p.repo.Promotion.GetByID(ctx, id)
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+	if promotion == nil {
+		return nil, errs.NewNotFoundError("promotion not found", false, nil)
+	}
+	return promotion, nil
+}
+
+// ListPromotions returns the latest promotions, newest first
+func (p *PromotionService) ListPromotions(ctx context.Context, req *model.ListPromotionsRequest) ([]*model.Promotion, error) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultPromotionLimit
+	}
+	promotions, err := p.repo.Promotion.List(ctx, req.Active, limit)
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+	if promotions == nil {
+		promotions = []*model.Promotion{}
+	}
+	return promotions, nil
+}
+
+// DeletePromotion deactivates a promotion. It is kept for the rides it was redeemed on.
+func (p *PromotionService) DeletePromotion(ctx context.Context, id string) error {
+	found, err := p.repo.Promotion.Deactivate(ctx, id)
+	if err != nil {
+		return errs.NewInternalServerError()
+	}
+	if !found {
+		return errs.NewNotFoundError("promotion not found", false, nil)
+	}
+	return nil
+}
+
+// promotionFromRequest checks the terms the request tags cannot and fills in the omitted fields,
+// from the existing promotion when updating one and with the defaults otherwise
+func promotionFromRequest(req *model.PromotionRequest, existing *model.Promotion) (*model.Promotion, error) {
+	if req.DiscountType == model.DiscountTypePercentage && req.DiscountValue > 100 {
+		return nil, errs.NewBadRequest("percentage discount cannot exceed 100")
+	}
+	if req.DiscountType == model.DiscountTypeFlat && req.MaxDiscount != nil {
+		return nil, errs.NewBadRequest("max_discount only applies to percentage discounts")
+	}
+
+	startsAt := time.Now()
+	perUserLimit := 1
+	active := true
+	if existing != nil {
+		startsAt = existing.StartsAt
+		perUserLimit = existing.PerUserLimit
+		active = existing.Active
+	}
+
+	if req.StartsAt != nil {
+		startsAt = *req.StartsAt
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(startsAt) {
+		return nil, errs.NewBadRequest("ends_at must be after starts_at")
+	}
+	if req.PerUserLimit != 0 {
+		perUserLimit = req.PerUserLimit
+	}
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	return &model.Promotion{
+		Code:          strings.ToUpper(req.Code),
+		Description:   req.Description,
+		DiscountType:  req.DiscountType,
+		DiscountValue: req.DiscountValue,
+		MaxDiscount:   req.MaxDiscount,
+		MinFare:       req.MinFare,
+		StartsAt:      startsAt,
+		EndsAt:        req.EndsAt,
+		UsageLimit:    req.UsageLimit,
+		PerUserLimit:  perUserLimit,
+		VehicleTypes:  req.VehicleTypes,
+		Zone:          req.Zone,
+		Active:        active,
+	}, nil
+}
+
+// withPromoDiscount takes a promo discount off a fare breakdown
+func withPromoDiscount(breakdown model.FareBreakdown, discount float64) model.FareBreakdown {
+	breakdown.PromoDiscount = discount
+	breakdown.Payable = math.Round((breakdown.Fare-discount)*100) / 100
+	return breakdown
+}
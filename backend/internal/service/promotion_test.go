@@ -0,0 +1,307 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromotionDiscount(t *testing.T) {
+	maxDiscount := 50.0
+	tests := []struct {
+		name      string
+		promotion model.Promotion
+		fare      float64
+		want      float64
+	}{
+		{"Percentage", model.Promotion{DiscountType: model.DiscountTypePercentage, DiscountValue: 20}, 180, 36},
+		{"Percentage is capped", model.Promotion{DiscountType: model.DiscountTypePercentage, DiscountValue: 20, MaxDiscount: &maxDiscount}, 400, 50},
+		{"Percentage is rounded", model.Promotion{DiscountType: model.DiscountTypePercentage, DiscountValue: 15}, 123.45, 18.52},
+		{"Flat", model.Promotion{DiscountType: model.DiscountTypeFlat, DiscountValue: 75}, 180, 75},
+		{"Flat never exceeds the fare", model.Promotion{DiscountType: model.DiscountTypeFlat, DiscountValue: 75}, 60, 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.promotion.Discount(tt.fare))
+		})
+	}
+}
+
+func TestPromotionApply(t *testing.T) {
+	ctx := context.Background()
+	userID := "rider-1"
+	pickup := model.Location{Latitude: 12.9716, Longitude: 77.5946}
+
+	setup := func() (*service.PromotionService, *testutil.MockPromotionRepository) {
+		logger := zerolog.Nop()
+		promotions := new(testutil.MockPromotionRepository)
+		return service.NewPromotionService(&server.Server{Logger: &logger}, &repository.Repositories{Promotion: promotions}), promotions
+	}
+	promo := func() *model.Promotion {
+		return &model.Promotion{
+			ID:            "promo-1",
+			Code:          "RIDE20",
+			DiscountType:  model.DiscountTypePercentage,
+			DiscountValue: 20,
+			StartsAt:      time.Now().Add(-time.Hour),
+			PerUserLimit:  1,
+			Active:        true,
+		}
+	}
+	refused := func(t *testing.T, err error, message string) {
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, "PROMO_NOT_APPLICABLE", httpErr.Code)
+		assert.Equal(t, message, httpErr.Message)
+	}
+
+	t.Run("Valid code gives its discount", func(t *testing.T) {
+		svc, promotions := setup()
+		promotions.On("GetByCode", mock.Anything, "ride20").Return(promo(), nil).Once()
+		promotions.On("CountUserRedemptions", mock.Anything, "promo-1", userID).Return(0, nil).Once()
+
+		promotion, discount, err := svc.Apply(ctx, userID, " ride20 ", model.VehicleTypeAuto, pickup, 180)
+		require.NoError(t, err)
+		assert.Equal(t, "promo-1", promotion.ID)
+		assert.Equal(t, 36.0, discount)
+		promotions.AssertExpectations(t)
+	})
+
+	t.Run("Unknown code is refused", func(t *testing.T) {
+		svc, promotions := setup()
+		promotions.On("GetByCode", mock.Anything, "NOPE").Return(nil, nil).Once()
+
+		_, _, err := svc.Apply(ctx, userID, "NOPE", model.VehicleTypeAuto, pickup, 180)
+		refused(t, err, "promo code is not valid")
+	})
+
+	limit := 100
+	endedAt := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name    string
+		edit    func(p *model.Promotion)
+		fare    float64
+		message string
+	}{
+		{"Inactive code is refused", func(p *model.Promotion) { p.Active = false }, 180, "promo code is not valid"},
+		{"Code before its window is refused", func(p *model.Promotion) { p.StartsAt = time.Now().Add(time.Hour) }, 180, "promo code is not active yet"},
+		{"Expired code is refused", func(p *model.Promotion) { p.EndsAt = &endedAt }, 180, "promo code has expired"},
+		{"Exhausted code is refused", func(p *model.Promotion) { p.UsageLimit = &limit; p.UsageCount = limit }, 180, repository.ErrPromoUsageLimit.Error()},
+		{"Other vehicle types are refused", func(p *model.Promotion) { p.VehicleTypes = []string{"bike"} }, 180, "promo code is not valid for auto rides"},
+		{"Pickup outside the zone is refused", func(p *model.Promotion) {
+			// Around Chennai, the pickup is in Bengaluru
+			p.Zone = &model.PromoZone{Name: "Chennai", Center: model.Location{Latitude: 13.0827, Longitude: 80.2707}, RadiusKm: 25}
+		}, 180, "promo code is not valid in this area"},
+		{"Fare below the minimum is refused", func(p *model.Promotion) { p.MinFare = 200 }, 180, "promo code applies to fares of at least ₹200.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, promotions := setup()
+			p := promo()
+			tt.edit(p)
+			promotions.On("GetByCode", mock.Anything, "RIDE20").Return(p, nil).Once()
+
+			_, _, err := svc.Apply(ctx, userID, "RIDE20", model.VehicleTypeAuto, pickup, tt.fare)
+			refused(t, err, tt.message)
+			promotions.AssertNotCalled(t, "CountUserRedemptions", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("Pickup inside the zone is accepted", func(t *testing.T) {
+		svc, promotions := setup()
+		p := promo()
+		p.VehicleTypes = []string{"auto", "car"}
+		p.Zone = &model.PromoZone{Name: "Bengaluru", Center: model.Location{Latitude: 12.9352, Longitude: 77.6245}, RadiusKm: 10}
+		promotions.On("GetByCode", mock.Anything, "RIDE20").Return(p, nil).Once()
+		promotions.On("CountUserRedemptions", mock.Anything, "promo-1", userID).Return(0, nil).Once()
+
+		_, _, err := svc.Apply(ctx, userID, "RIDE20", model.VehicleTypeAuto, pickup, 180)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Rider who used the code up is refused", func(t *testing.T) {
+		svc, promotions := setup()
+		promotions.On("GetByCode", mock.Anything, "RIDE20").Return(promo(), nil).Once()
+		promotions.On("CountUserRedemptions", mock.Anything, "promo-1", userID).Return(1, nil).Once()
+
+		_, _, err := svc.Apply(ctx, userID, "RIDE20", model.VehicleTypeAuto, pickup, 180)
+		refused(t, err, repository.ErrPromoUserLimit.Error())
+	})
+}
+
+func TestPromotionAdmin(t *testing.T) {
+	ctx := context.Background()
+	setup := func() (*service.PromotionService, *testutil.MockPromotionRepository) {
+		logger := zerolog.Nop()
+		promotions := new(testutil.MockPromotionRepository)
+		return service.NewPromotionService(&server.Server{Logger: &logger}, &repository.Repositories{Promotion: promotions}), promotions
+	}
+
+	t.Run("Create fills in the defaults", func(t *testing.T) {
+		svc, promotions := setup()
+		promotions.On("Create", mock.Anything, mock.MatchedBy(func(p *model.Promotion) bool {
+			return p.Code == "WELCOME50" && p.PerUserLimit == 1 && p.Active && *p.CreatedBy == "admin-1"
+		})).Return(nil).Once()
+
+		promotion, err := svc.CreatePromotion(ctx, "admin-1", &model.PromotionRequest{
+			Code:          "welcome50",
+			DiscountType:  model.DiscountTypeFlat,
+			DiscountValue: 50,
+		})
+		require.NoError(t, err)
+		assert.False(t, promotion.StartsAt.IsZero())
+		promotions.AssertExpectations(t)
+	})
+
+	t.Run("Create refuses a percentage over 100", func(t *testing.T) {
+		svc, promotions := setup()
+		_, err := svc.CreatePromotion(ctx, "admin-1", &model.PromotionRequest{
+			Code:          "FREE",
+			DiscountType:  model.DiscountTypePercentage,
+			DiscountValue: 120,
+		})
+		assert.Error(t, err)
+		promotions.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Create refuses a taken code", func(t *testing.T) {
+		svc, promotions := setup()
+		promotions.On("Create", mock.Anything, mock.Anything).Return(repository.ErrPromoCodeTaken).Once()
+
+		_, err := svc.CreatePromotion(ctx, "admin-1", &model.PromotionRequest{
+			Code:          "RIDE20",
+			DiscountType:  model.DiscountTypePercentage,
+			DiscountValue: 20,
+		})
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, repository.ErrPromoCodeTaken.Error(), httpErr.Message)
+	})
+
+	t.Run("Update keeps the omitted start and state", func(t *testing.T) {
+		svc, promotions := setup()
+		startsAt := time.Now().Add(-48 * time.Hour).UTC()
+		promotions.On("GetByID", mock.Anything, "promo-1").Return(&model.Promotion{
+			ID: "promo-1", Code: "RIDE20", StartsAt: startsAt, PerUserLimit: 3, Active: false,
+		}, nil).Once()
+		promotions.On("Update", mock.Anything, mock.MatchedBy(func(p *model.Promotion) bool {
+			return p.ID == "promo-1" && p.StartsAt.Equal(startsAt) && p.PerUserLimit == 3 && !p.Active && p.DiscountValue == 25
+		})).Return(nil).Once()
+
+		_, err := svc.UpdatePromotion(ctx, &model.UpdatePromotionRequest{ID: "promo-1", PromotionRequest: model.PromotionRequest{
+			Code:          "RIDE20",
+			DiscountType:  model.DiscountTypePercentage,
+			DiscountValue: 25,
+		}})
+		require.NoError(t, err)
+		promotions.AssertExpectations(t)
+	})
+
+	t.Run("Update of an unknown promotion is not found", func(t *testing.T) {
+		svc, promotions := setup()
+		promotions.On("GetByID", mock.Anything, "promo-1").Return(nil, nil).Once()
+
+		_, err := svc.UpdatePromotion(ctx, &model.UpdatePromotionRequest{ID: "promo-1", PromotionRequest: model.PromotionRequest{
+			Code:          "RIDE20",
+			DiscountType:  model.DiscountTypeFlat,
+			DiscountValue: 20,
+		}})
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, 404, httpErr.Status)
+		promotions.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Delete of an unknown promotion is not found", func(t *testing.T) {
+		svc, promotions := setup()
+		promotions.On("Deactivate", mock.Anything, "promo-1").Return(false, nil).Once()
+
+		err := svc.DeletePromotion(ctx, "promo-1")
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, 404, httpErr.Status)
+	})
+}
+
+func TestCreateRideRequestWithPromo(t *testing.T) {
+	ctx := context.Background()
+	userID := "rider-1"
+	req := model.RideRequest{
+		PickupLocation:  model.Location{Latitude: 12.9716, Longitude: 77.5946},
+		DropoffLocation: model.Location{Latitude: 12.9352, Longitude: 77.6245},
+		VehicleType:     "auto",
+		PaymentMethod:   "cash",
+		PromoCode:       "FLAT30",
+	}
+
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	setup := func() (*service.RideService, *testutil.MockRideRepository, *testutil.MockPromotionRepository) {
+		logger := zerolog.Nop()
+		srv := &server.Server{Logger: &logger, Redis: redis.NewClient(&redis.Options{Addr: mr.Addr()})}
+		rides := new(testutil.MockRideRepository)
+		promotions := new(testutil.MockPromotionRepository)
+		repos := &repository.Repositories{Ride: rides, Promotion: promotions}
+		promotions.On("GetByCode", mock.Anything, "FLAT30").Return(&model.Promotion{
+			ID:            "promo-1",
+			Code:          "FLAT30",
+			DiscountType:  model.DiscountTypeFlat,
+			DiscountValue: 30,
+			StartsAt:      time.Now().Add(-time.Hour),
+			PerUserLimit:  1,
+			Active:        true,
+		}, nil)
+		promotions.On("CountUserRedemptions", mock.Anything, "promo-1", userID).Return(0, nil)
+		return service.NewRideService(srv, repos, nil, nil, service.NewPromotionService(srv, repos)), rides, promotions
+	}
+
+	t.Run("Ride is created with the redemption", func(t *testing.T) {
+		svc, rides, _ := setup()
+		rides.On("CreateWithRedemption", mock.Anything, mock.MatchedBy(func(r *model.Ride) bool {
+			return r.UserID == userID && r.PromoDiscount == 30
+		}), mock.MatchedBy(func(p *model.PromoRedemption) bool {
+			return p.PromotionID == "promo-1" && p.Discount == 30
+		})).Return(nil).Once()
+
+		resp, err := svc.CreateRideRequest(ctx, userID, req)
+		require.NoError(t, err)
+		require.NotNil(t, resp.FareBreakdown)
+		assert.Equal(t, 30.0, resp.FareBreakdown.PromoDiscount)
+		assert.InDelta(t, resp.FareBreakdown.Fare-30, resp.FareBreakdown.Payable, 0.001)
+		// The parts add up to the fare stored on the ride
+		assert.Equal(t, *resp.Fare, resp.FareBreakdown.Fare)
+		assert.InDelta(t, resp.FareBreakdown.Fare,
+			resp.FareBreakdown.BaseFare+resp.FareBreakdown.DistanceFare+resp.FareBreakdown.TimeFare, 0.001)
+		rides.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		rides.AssertExpectations(t)
+
+		// Allow the broadcast goroutine to finish
+		time.Sleep(10 * time.Millisecond)
+	})
+
+	t.Run("Code used up concurrently is refused", func(t *testing.T) {
+		svc, rides, _ := setup()
+		rides.On("CreateWithRedemption", mock.Anything, mock.Anything, mock.Anything).Return(repository.ErrPromoUsageLimit).Once()
+
+		resp, err := svc.CreateRideRequest(ctx, userID, req)
+		assert.Nil(t, resp)
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, "PROMO_NOT_APPLICABLE", httpErr.Code)
+	})
+}
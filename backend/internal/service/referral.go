@@ -0,0 +1,374 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/job"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/push"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+)
+
+const (
+	// Pending referrals rewarded per sweep run, the rest wait for the next one
+	referralSweepBatchSize = 100
+)
+
+var codeInvalidReferralCode = "INVALID_REFERRAL_CODE"
+
+// referralNotifier tells users about their referral rewards
+type referralNotifier interface {
+	Notify(ctx context.Context, userID string, n push.Notification) error
+}
+
+// ReferralService runs the referral program. Riders sign up with another user's code and both are
+// rewarded with wallet credits or promo codes once the rider's first ride is paid. Referrals from
+// the referrer's own email, phone or device, or paid by a ride the referrer drove, are rejected.
+type ReferralService struct {
+	server           *server.Server
+	repo             *repository.Repositories
+	cfg              *config.ReferralConfig
+	promotionService *PromotionService
+	// notifier is optional, nobody is notified without it
+	notifier referralNotifier
+}
+
+func NewReferralService(s *server.Server, repo *repository.Repositories, cfg *config.ReferralConfig, promotionService *PromotionService, notifier referralNotifier) *ReferralService {
+	return &ReferralService{
+		server:           s,
+		repo:             repo,
+		cfg:              cfg,
+		promotionService: promotionService,
+		notifier:         notifier,
+	}
+}
+
+// Register adds the reward job and schedules the sweep for rewards whose job was lost
+func (r *ReferralService) Register() error {
+	r.server.Job.HandleFunc(job.TaskReferralReward, r.handleRewardTask)
+	r.server.Job.HandleFunc(job.TaskReferralSweep, r.handleSweepTask)
+	if err := r.server.Job.Schedule(r.cfg.SweepSchedule, job.NewReferralSweepTask()); err != nil {
+		return fmt.Errorf("failed to schedule referral sweep: %w", err)
+	}
+	return nil
+}
+
+func (r *ReferralService) handleRewardTask(ctx context.Context, t *asynq.Task) error {
+	var p job.ReferralRewardPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("failed to unmarshal referral reward payload: %w: %w", err, asynq.SkipRetry)
+	}
+	if err := r.RewardReferral(ctx, p.RefereeID); err != nil {
+		r.server.Logger.Error().Err(err).Str("referee_id", p.RefereeID).Msg("Referral reward failed")
+		return err
+	}
+	return nil
+}
+
+func (r *ReferralService) handleSweepTask(ctx context.Context, t *asynq.Task) error {
+	rewarded, err := r.SweepReferrals(ctx)
+	if err != nil {
+		r.server.Logger.Error().Err(err).Msg("Referral sweep failed")
+		return err
+	}
+	if rewarded > 0 {
+		r.server.Logger.Info().Int("referrals", rewarded).Msg("Settled paid referrals")
+	}
+	return nil
+}
+
+// Referrer returns the user a referral code belongs to
+func (r *ReferralService) Referrer(ctx context.Context, code string) (*model.User, error) {
+	referrer, err := r.repo.Referral.GetReferrer(ctx, strings.TrimSpace(code))
+	if err != nil {
+		return nil, errs.NewInternalServerError()
+	}
+	if referrer == nil {
+		return nil, errs.NewBadRequestError("referral code is not valid", false, &codeInvalidReferralCode, nil, nil)
+	}
+	return referrer, nil
+}
+
+// Attach records that a rider signed up with the referrer's code. A sign up from the referrer's
+// email, phone or device is recorded rejected.
+func (r *ReferralService) Attach(ctx context.Context, referrer, referee *model.User) (*model.Referral, error) {
+	referral := &model.Referral{
+		ReferrerID: referrer.ID.String(),
+		RefereeID:  referee.ID.String(),
+		Code:       referrer.ReferralCode,
+		Status:     model.ReferralStatusPending,
+	}
+	if reason := signupFraud(referrer, referee); reason != "" {
+		referral.Status = model.ReferralStatusRejected
+		referral.RejectReason = &reason
+		r.server.Logger.Warn().
+			Str("referrer_id", referral.ReferrerID).
+			Str("referee_id", referral.RefereeID).
+			Str("reason", reason).
+			Msg("Referral rejected at sign up")
+	}
+
+	if err := r.repo.Referral.Create(ctx, referral); err != nil {
+		return nil, errs.Wrap(err, "failed to record referral")
+	}
+	return referral, nil
+}
+
+// signupFraud returns why a sign up cannot count as a referral, empty when it can
+func signupFraud(referrer, referee *model.User) string {
+	switch {
+	case referrer.ID == referee.ID || canonicalEmail(referrer.Email) == canonicalEmail(referee.Email):
+		return model.ReferralRejectSelf
+	case referrer.Phone != nil && referee.Phone != nil && *referrer.Phone == *referee.Phone:
+		return model.ReferralRejectPhone
+	case referrer.SignupDeviceID != nil && referee.SignupDeviceID != nil && *referrer.SignupDeviceID == *referee.SignupDeviceID:
+		return model.ReferralRejectDevice
+	}
+	return ""
+}
+
+// canonicalEmail folds the spellings of one mailbox: case, +tags and the dots Gmail ignores
+func canonicalEmail(email string) string {
+	local, domain, ok := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	if !ok {
+		return email
+	}
+	local, _, _ = strings.Cut(local, "+")
+	if domain == "gmail.com" || domain == "googlemail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+	return local + "@" + domain
+}
+
+// RidePaid queues the referral reward of a rider whose ride payment was captured. Riders without a
+// pending referral are skipped, a reward that fails to queue is left to the sweep.
+func (r *ReferralService) RidePaid(ctx context.Context, payment *model.Payment) {
+	referral, err := r.repo.Referral.GetByReferee(ctx, payment.UserID)
+	if err != nil {
+		r.server.Logger.Error().Err(err).Str("user_id", payment.UserID).Msg("Failed to look up referral")
+		return
+	}
+	if referral == nil || referral.Status != model.ReferralStatusPending {
+		return
+	}
+
+	task, err := job.NewReferralRewardTask(payment.UserID)
+	if err != nil {
+		r.server.Logger.Error().Err(err).Str("referral_id", referral.ID).Msg("Failed to create referral reward task")
+		return
+	}
+	if _, err := r.server.Job.Client.EnqueueContext(ctx, task); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		r.server.Logger.Error().Err(err).Str("referral_id", referral.ID).Msg("Failed to queue referral reward")
+	}
+}
+
+// SweepReferrals rewards pending referrals whose rider already paid a ride. It returns the number
+// of referrals settled, rewarded or rejected.
+func (r *ReferralService) SweepReferrals(ctx context.Context) (int, error) {
+	refereeIDs, err := r.repo.Referral.ListPendingPaid(ctx, referralSweepBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list paid referrals: %w", err)
+	}
+
+	settled := 0
+	for _, refereeID := range refereeIDs {
+		if err := r.RewardReferral(ctx, refereeID); err != nil {
+			r.server.Logger.Error().Err(err).Str("referee_id", refereeID).Msg("Referral reward failed")
+			continue
+		}
+		settled++
+	}
+	return settled, nil
+}
+
+// RewardReferral rewards the referral of a rider who paid a ride, or rejects it when the referrer
+// drove the ride. Running it again is safe: wallet credits are posted once per referral and promo
+// codes are derived from the referral, so a retry grants the same ones.
+func (r *ReferralService) RewardReferral(ctx context.Context, refereeID string) error {
+	referral, err := r.repo.Referral.GetByReferee(ctx, refereeID)
+	if err != nil {
+		return fmt.Errorf("failed to get referral: %w", err)
+	}
+	if referral == nil || referral.Status != model.ReferralStatusPending {
+		return nil
+	}
+	ride, err := r.repo.Referral.FirstPaidRide(ctx, refereeID)
+	if err != nil {
+		return fmt.Errorf("failed to get first paid ride: %w", err)
+	}
+	if ride == nil {
+		return nil
+	}
+	referral.RideID = &ride.RideID
+
+	if ride.DriverUserID != nil && *ride.DriverUserID == referral.ReferrerID {
+		reason := model.ReferralRejectRideDrive
+		referral.RejectReason = &reason
+		if _, err := r.repo.Referral.Reject(ctx, referral); err != nil {
+			return fmt.Errorf("failed to reject referral: %w", err)
+		}
+		r.server.Logger.Warn().
+			Str("referral_id", referral.ID).
+			Str("ride_id", ride.RideID).
+			Msg("Referral rejected, the referrer drove the ride")
+		return nil
+	}
+
+	rewardType := r.cfg.RewardType
+	referral.RewardType = &rewardType
+	referral.ReferrerReward = r.cfg.ReferrerReward
+	referral.RefereeReward = r.cfg.RefereeReward
+	if rewardType == config.ReferralRewardPromo {
+		err = r.grantPromos(ctx, referral)
+	} else {
+		err = r.creditWallets(ctx, referral)
+	}
+	if err != nil {
+		return err
+	}
+
+	rewarded, err := r.repo.Referral.Reward(ctx, referral)
+	if err != nil {
+		return fmt.Errorf("failed to mark referral rewarded: %w", err)
+	}
+	if rewarded {
+		r.notifyReward(ctx, referral.ReferrerID, referral.ReferrerReward, referral.ReferrerPromoCode, referral)
+		r.notifyReward(ctx, referral.RefereeID, referral.RefereeReward, referral.RefereePromoCode, referral)
+	}
+	return nil
+}
+
+// creditWallets posts both rewards to the wallets, paid for by the platform's promotions
+func (r *ReferralService) creditWallets(ctx context.Context, referral *model.Referral) error {
+	referrerReward := gateway.ToPaise(referral.ReferrerReward)
+	refereeReward := gateway.ToPaise(referral.RefereeReward)
+	entry := journalEntry(model.JournalEntryReferralReward, referral.ID, "Referral reward", "INR",
+		debit(platformPromotions(), referrerReward+refereeReward),
+		credit(riderWallet(referral.ReferrerID), referrerReward),
+		credit(riderWallet(referral.RefereeID), refereeReward))
+	if len(entry.Postings) == 0 {
+		return nil
+	}
+	if _, err := r.repo.Ledger.Post(ctx, entry); err != nil {
+		return fmt.Errorf("failed to post referral reward: %w", err)
+	}
+	return nil
+}
+
+// grantPromos gives each side with a reward a flat promo code of it
+func (r *ReferralService) grantPromos(ctx context.Context, referral *model.Referral) error {
+	grant := func(userID, side string, amount float64) (*string, error) {
+		if amount <= 0 {
+			return nil, nil
+		}
+		promotion, err := r.promotionService.Grant(ctx, userID, referralPromoCode(referral.ID, side),
+			"Referral reward", amount, r.cfg.PromoValidity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to grant %s promo: %w", side, err)
+		}
+		return &promotion.Code, nil
+	}
+
+	var err error
+	if referral.ReferrerPromoCode, err = grant(referral.ReferrerID, "referrer", referral.ReferrerReward); err != nil {
+		return err
+	}
+	if referral.RefereePromoCode, err = grant(referral.RefereeID, "referee", referral.RefereeReward); err != nil {
+		return err
+	}
+	return nil
+}
+
+// referralPromoCode derives the promo code of one side of a referral, the same on every retry
+func referralPromoCode(referralID, side string) string {
+	sum := sha256.Sum256([]byte(referralID + ":" + side))
+	return "REF" + base32.StdEncoding.EncodeToString(sum[:])[:9]
+}
+
+func (r *ReferralService) notifyReward(ctx context.Context, userID string, amount float64, promoCode *string, referral *model.Referral) {
+	if r.notifier == nil || amount <= 0 {
+		return
+	}
+	body := fmt.Sprintf("₹%.2f has been added to your wallet for your referral", amount)
+	if promoCode != nil {
+		body = fmt.Sprintf("Use %s for ₹%.2f off a ride, a reward for your referral", *promoCode, amount)
+	}
+	// A missed notification does not undo the reward, the user still sees it in the app
+	_ = r.notifier.Notify(ctx, userID, push.Notification{
+		Title: "Referral reward",
+		Body:  body,
+		Data: map[string]string{
+			"type":        "referral_rewarded",
+			"referral_id": referral.ID,
+		},
+	})
+}
+
+// Status returns the user's referral code with the referrals they made, their own referral and
+// the current offer
+func (r *ReferralService) Status(ctx context.Context, userID string) (*model.ReferralSummary, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errs.NewBadRequest("invalid user id")
+	}
+	user, err := r.repo.User.GetByID(ctx, id)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to get user")
+	}
+	return r.summary(ctx, user)
+}
+
+func (r *ReferralService) summary(ctx context.Context, user *model.User) (*model.ReferralSummary, error) {
+	summary := &model.ReferralSummary{
+		ReferralCode:   user.ReferralCode,
+		RewardType:     r.cfg.RewardType,
+		ReferrerReward: r.cfg.ReferrerReward,
+		RefereeReward:  r.cfg.RefereeReward,
+		Referrals:      []*model.Referral{},
+	}
+
+	referrals, err := r.repo.Referral.ListByReferrer(ctx, user.ID.String())
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list referrals")
+	}
+	for _, referral := range referrals {
+		switch referral.Status {
+		case model.ReferralStatusPending:
+			summary.Pending++
+		case model.ReferralStatusRewarded:
+			summary.Rewarded++
+			referral.Reward = referral.ReferrerReward
+			referral.PromoCode = referral.ReferrerPromoCode
+			summary.Earned += referral.ReferrerReward
+		case model.ReferralStatusRejected:
+			summary.Rejected++
+		}
+		summary.Referrals = append(summary.Referrals, referral)
+	}
+
+	own, err := r.repo.Referral.GetByReferee(ctx, user.ID.String())
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to get referral")
+	}
+	if own != nil {
+		if own.Status == model.ReferralStatusRewarded {
+			own.Reward = own.RefereeReward
+			own.PromoCode = own.RefereePromoCode
+		}
+		summary.ReferredBy = own
+	}
+	return summary, nil
+}
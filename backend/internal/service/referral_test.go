@@ -0,0 +1,191 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/config"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/service"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferralAttach(t *testing.T) {
+	ctx := context.Background()
+	phone := "+919876543210"
+	device := "install-1"
+	otherDevice := "install-2"
+
+	referrer := func() *model.User {
+		return &model.User{Base: model.Base{ID: uuid.New()}, Email: "asha.rao@gmail.com", Phone: &phone, SignupDeviceID: &device, ReferralCode: "ASHA2345"}
+	}
+	tests := []struct {
+		name    string
+		referee func(referrer *model.User) *model.User
+		reason  string
+	}{
+		{"Other rider is pending", func(*model.User) *model.User {
+			return &model.User{Base: model.Base{ID: uuid.New()}, Email: "ravi@example.com", SignupDeviceID: &otherDevice}
+		}, ""},
+		{"Same account is self referral", func(r *model.User) *model.User { return r }, model.ReferralRejectSelf},
+		{"Spelling of the same mailbox is self referral", func(*model.User) *model.User {
+			return &model.User{Base: model.Base{ID: uuid.New()}, Email: "Asha.Rao+rides@googlemail.com"}
+		}, model.ReferralRejectSelf},
+		{"Same phone is rejected", func(*model.User) *model.User {
+			samePhone := phone
+			return &model.User{Base: model.Base{ID: uuid.New()}, Email: "ravi@example.com", Phone: &samePhone}
+		}, model.ReferralRejectPhone},
+		{"Same device is rejected", func(*model.User) *model.User {
+			sameDevice := device
+			return &model.User{Base: model.Base{ID: uuid.New()}, Email: "ravi@example.com", SignupDeviceID: &sameDevice}
+		}, model.ReferralRejectDevice},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := zerolog.Nop()
+			referrals := new(testutil.MockReferralRepository)
+			svc := service.NewReferralService(&server.Server{Logger: &logger}, &repository.Repositories{Referral: referrals},
+				config.DefaultReferralConfig(), nil, nil)
+			r := referrer()
+			referee := tt.referee(r)
+			referrals.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+
+			referral, err := svc.Attach(ctx, r, referee)
+			require.NoError(t, err)
+			assert.Equal(t, r.ID.String(), referral.ReferrerID)
+			assert.Equal(t, "ASHA2345", referral.Code)
+			if tt.reason == "" {
+				assert.Equal(t, model.ReferralStatusPending, referral.Status)
+				assert.Nil(t, referral.RejectReason)
+			} else {
+				assert.Equal(t, model.ReferralStatusRejected, referral.Status)
+				assert.Equal(t, tt.reason, *referral.RejectReason)
+			}
+			referrals.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReferralReward(t *testing.T) {
+	ctx := context.Background()
+	referrerID := "referrer-1"
+	refereeID := "referee-1"
+	rideID := "ride-1"
+	driverUserID := "driver-user-1"
+
+	setup := func(cfg *config.ReferralConfig) (*service.ReferralService, *testutil.MockReferralRepository, *testutil.MockLedgerRepository, *testutil.MockPromotionRepository) {
+		logger := zerolog.Nop()
+		srv := &server.Server{Logger: &logger}
+		referrals := new(testutil.MockReferralRepository)
+		ledger := new(testutil.MockLedgerRepository)
+		promotions := new(testutil.MockPromotionRepository)
+		repos := &repository.Repositories{Referral: referrals, Ledger: ledger, Promotion: promotions}
+		return service.NewReferralService(srv, repos, cfg, service.NewPromotionService(srv, repos), nil), referrals, ledger, promotions
+	}
+	pending := func() *model.Referral {
+		return &model.Referral{ID: "referral-1", ReferrerID: referrerID, RefereeID: refereeID, Code: "ASHA2345", Status: model.ReferralStatusPending}
+	}
+	paidRide := &repository.PaidRide{RideID: rideID, DriverUserID: &driverUserID}
+
+	t.Run("Wallet rewards are credited from platform promotions", func(t *testing.T) {
+		svc, referrals, ledger, _ := setup(config.DefaultReferralConfig())
+		referrals.On("GetByReferee", mock.Anything, refereeID).Return(pending(), nil).Once()
+		referrals.On("FirstPaidRide", mock.Anything, refereeID).Return(paidRide, nil).Once()
+		ledger.On("Post", mock.Anything, mock.MatchedBy(func(e *model.JournalEntry) bool {
+			return e.Type == model.JournalEntryReferralReward && e.ReferenceID == "referral-1" && len(e.Postings) == 3 &&
+				e.Postings[0].AccountType == model.LedgerAccountPlatformPromotions && e.Postings[0].Amount == -15000 &&
+				e.Postings[1].AccountType == model.LedgerAccountRiderWallet && *e.Postings[1].OwnerID == referrerID && e.Postings[1].Amount == 10000 &&
+				e.Postings[2].AccountType == model.LedgerAccountRiderWallet && *e.Postings[2].OwnerID == refereeID && e.Postings[2].Amount == 5000
+		})).Return(true, nil).Once()
+		referrals.On("Reward", mock.Anything, mock.MatchedBy(func(r *model.Referral) bool {
+			return *r.RideID == rideID && *r.RewardType == config.ReferralRewardWallet && r.ReferrerReward == 100 && r.RefereeReward == 50
+		})).Return(true, nil).Once()
+
+		require.NoError(t, svc.RewardReferral(ctx, refereeID))
+		referrals.AssertExpectations(t)
+		ledger.AssertExpectations(t)
+	})
+
+	t.Run("Promo rewards are granted to each side", func(t *testing.T) {
+		cfg := config.DefaultReferralConfig()
+		cfg.RewardType = config.ReferralRewardPromo
+		svc, referrals, ledger, promotions := setup(cfg)
+		referrals.On("GetByReferee", mock.Anything, refereeID).Return(pending(), nil).Once()
+		referrals.On("FirstPaidRide", mock.Anything, refereeID).Return(paidRide, nil).Once()
+		promotions.On("Create", mock.Anything, mock.MatchedBy(func(p *model.Promotion) bool {
+			return *p.GrantedTo == referrerID && p.DiscountType == model.DiscountTypeFlat && p.DiscountValue == 100 && *p.UsageLimit == 1
+		})).Return(nil).Once()
+		// The referee's code was granted by an earlier attempt
+		promotions.On("Create", mock.Anything, mock.MatchedBy(func(p *model.Promotion) bool {
+			return *p.GrantedTo == refereeID
+		})).Return(repository.ErrPromoCodeTaken).Once()
+		promotions.On("GetByCode", mock.Anything, mock.Anything).Return(&model.Promotion{Code: "REFGRANTED", GrantedTo: &refereeID}, nil).Once()
+		referrals.On("Reward", mock.Anything, mock.MatchedBy(func(r *model.Referral) bool {
+			return *r.RewardType == config.ReferralRewardPromo && r.ReferrerPromoCode != nil && r.RefereePromoCode != nil &&
+				*r.ReferrerPromoCode != "REFGRANTED" && *r.RefereePromoCode == "REFGRANTED"
+		})).Return(true, nil).Once()
+
+		require.NoError(t, svc.RewardReferral(ctx, refereeID))
+		referrals.AssertExpectations(t)
+		promotions.AssertExpectations(t)
+		ledger.AssertNotCalled(t, "Post", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Ride driven by the referrer is rejected", func(t *testing.T) {
+		svc, referrals, ledger, _ := setup(config.DefaultReferralConfig())
+		referrals.On("GetByReferee", mock.Anything, refereeID).Return(pending(), nil).Once()
+		referrals.On("FirstPaidRide", mock.Anything, refereeID).Return(&repository.PaidRide{RideID: rideID, DriverUserID: &referrerID}, nil).Once()
+		referrals.On("Reject", mock.Anything, mock.MatchedBy(func(r *model.Referral) bool {
+			return *r.RejectReason == model.ReferralRejectRideDrive && *r.RideID == rideID
+		})).Return(true, nil).Once()
+
+		require.NoError(t, svc.RewardReferral(ctx, refereeID))
+		referrals.AssertExpectations(t)
+		ledger.AssertNotCalled(t, "Post", mock.Anything, mock.Anything)
+		referrals.AssertNotCalled(t, "Reward", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Rider without a paid ride waits", func(t *testing.T) {
+		svc, referrals, ledger, _ := setup(config.DefaultReferralConfig())
+		referrals.On("GetByReferee", mock.Anything, refereeID).Return(pending(), nil).Once()
+		referrals.On("FirstPaidRide", mock.Anything, refereeID).Return(nil, nil).Once()
+
+		require.NoError(t, svc.RewardReferral(ctx, refereeID))
+		ledger.AssertNotCalled(t, "Post", mock.Anything, mock.Anything)
+		referrals.AssertNotCalled(t, "Reward", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Settled referral is not rewarded again", func(t *testing.T) {
+		svc, referrals, ledger, _ := setup(config.DefaultReferralConfig())
+		rewarded := pending()
+		rewarded.Status = model.ReferralStatusRewarded
+		referrals.On("GetByReferee", mock.Anything, refereeID).Return(rewarded, nil).Once()
+
+		require.NoError(t, svc.RewardReferral(ctx, refereeID))
+		referrals.AssertNotCalled(t, "FirstPaidRide", mock.Anything, mock.Anything)
+		ledger.AssertNotCalled(t, "Post", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Sweep carries on past a failed reward", func(t *testing.T) {
+		svc, referrals, ledger, _ := setup(config.DefaultReferralConfig())
+		referrals.On("ListPendingPaid", mock.Anything, mock.Anything).Return([]string{"referee-0", refereeID}, nil).Once()
+		referrals.On("GetByReferee", mock.Anything, "referee-0").Return(nil, errors.New("connection reset")).Once()
+		referrals.On("GetByReferee", mock.Anything, refereeID).Return(pending(), nil).Once()
+		referrals.On("FirstPaidRide", mock.Anything, refereeID).Return(paidRide, nil).Once()
+		ledger.On("Post", mock.Anything, mock.Anything).Return(true, nil).Once()
+		referrals.On("Reward", mock.Anything, mock.Anything).Return(true, nil).Once()
+
+		settled, err := svc.SweepReferrals(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, settled)
+		referrals.AssertExpectations(t)
+	})
+}
@@ -2,20 +2,25 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/redis/go-redis/v9"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/push"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
 )
 
 const (
+	// Redis geo index of rides waiting for a driver, kept in sync with the rides table by the geo reconciler
+	rideRequestedGeoKey = "rides:requested"
 	// Base fare in INR
 	baseFare = 30.0
 	// Per km rate in INR
@@ -27,16 +32,20 @@ const (
 )
 
 type RideService struct {
-	server          *server.Server
-	repo            *repository.Repositories
-	locationService *LocationService
+	server              *server.Server
+	repo                *repository.Repositories
+	locationService     *LocationService
+	notificationService *NotificationService
+	promotionService    *PromotionService
 }
 
-func NewRideService(s *server.Server, repo *repository.Repositories, locationService *LocationService) *RideService {
+func NewRideService(s *server.Server, repo *repository.Repositories, locationService *LocationService, notificationService *NotificationService, promotionService *PromotionService) *RideService {
 	return &RideService{
-		server:          s,
-		repo:            repo,
-		locationService: locationService,
+		server:              s,
+		repo:                repo,
+		locationService:     locationService,
+		notificationService: notificationService,
+		promotionService:    promotionService,
 	}
 }
 
@@ -85,9 +94,8 @@ func NewRideService(s *server.Server, repo *repository.Repositories, locationSer
 
 // New Logic
 func (r *RideService) CreateRideRequest(ctx context.Context, userID string, req model.RideRequest) (*model.RideResponse, error) {
-	dist := calculateDistance(req.PickupLocation, req.DropoffLocation)
-	estimatedTime := int(math.Ceil((dist / averageSpeed) * 60))
-	fare := calculateFare(dist, estimatedTime)
+	dist, estimatedTime := estimateTrip(req.PickupLocation, req.DropoffLocation)
+	fare := fareBreakdown(dist, estimatedTime).Fare
 	ride := &model.Ride{
 		UserID:          userID,
 		PickupLocation:  req.PickupLocation,
@@ -103,16 +111,35 @@ func (r *RideService) CreateRideRequest(ctx context.Context, userID string, req
 		PaymentStatus:   model.PaymentStatusPending,
 	}
 
-	if err := r.repo.Ride.Create(ctx, ride); err != nil {
-		return nil, err
+	if req.PromoCode == "" {
+		if err := r.repo.Ride.Create(ctx, ride); err != nil {
+			return nil, err
+		}
+	} else {
+		promotion, discount, err := r.promotionService.Apply(ctx, userID, req.PromoCode, req.VehicleType, req.PickupLocation, fare)
+		if err != nil {
+			return nil, err
+		}
+		ride.PromoDiscount = discount
+		redemption := &model.PromoRedemption{PromotionID: promotion.ID, Discount: discount}
+		err = r.repo.Ride.CreateWithRedemption(ctx, ride, redemption)
+		if errors.Is(err, repository.ErrPromoUsageLimit) || errors.Is(err, repository.ErrPromoUserLimit) {
+			return nil, promoNotApplicable(err.Error())
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Add to Redis Geospatial Index
+	// Add to Redis Geospatial Index, drivers search rides with PostGIS while it is unavailable
 	go func() {
+		if !r.server.RedisAvailable() {
+			return
+		}
 		// Use a background context or specific timeout context for Redis op
 		// to avoid holding up the response significantly, or handle error gracefully
 		bgCtx := context.Background()
-		cmd := r.server.Redis.GeoAdd(bgCtx, "rides:requested", &redis.GeoLocation{
+		cmd := r.server.Redis.GeoAdd(bgCtx, rideRequestedGeoKey, &redis.GeoLocation{
 			Name:      ride.ID,
 			Longitude: ride.PickupLocation.Longitude,
 			Latitude:  ride.PickupLocation.Latitude,
@@ -135,35 +162,15 @@ func (r *RideService) CreateRideRequest(ctx context.Context, userID string, req
 	// Broadcast new ride request to all nearby online drivers
 	go func() {
 		bgCtx := context.Background()
-		// Find drivers within 10km of pickup
-		nearbyDrivers, err := r.server.Redis.GeoRadius(bgCtx,
-			"drivers:geo",
-			ride.PickupLocation.Longitude,
-			ride.PickupLocation.Latitude,
-			&redis.GeoRadiusQuery{
-				Radius:    10,
-				Unit:      "km",
-				Count:     50,
-				Sort:      "ASC",
-				WithCoord: true,
-				WithDist:  true,
-			},
-		).Result()
+		nearbyDrivers, err := r.findDriversForBroadcast(bgCtx, ride.PickupLocation)
 		if err != nil {
 			r.server.Logger.Error().Err(err).Msg("Failed to find nearby drivers for broadcast")
 			return
 		}
 
-		for _, driver := range nearbyDrivers {
-			// Check if driver is online
-			onlineKey := "driver:online:" + driver.Name
-			exists, err := r.server.Redis.Get(bgCtx, onlineKey).Result()
-			if err != nil || exists == "" {
-				continue
-			}
-
-			// driver.Name is the user_id (set by WebSocket handler)
-			r.server.Hub.BroadcastToUser(driver.Name, "new_ride_request", resp)
+		for _, driverUserID := range nearbyDrivers {
+			r.notifyUser(bgCtx, driverUserID, "new_ride_request", resp,
+				"New ride request", fmt.Sprintf("Pickup at %s", resp.PickupAddress))
 		}
 		r.server.Logger.Info().
 			Int("nearby_drivers", len(nearbyDrivers)).
@@ -175,6 +182,52 @@ func (r *RideService) CreateRideRequest(ctx context.Context, userID string, req
 
 }
 
+// findDriversForBroadcast returns the user ids of online drivers within 10km of the pickup.
+// Without redis the persisted positions in drivers.location are searched instead.
+func (r *RideService) findDriversForBroadcast(ctx context.Context, pickup model.Location) ([]string, error) {
+	if !r.server.RedisAvailable() {
+		drivers, err := r.repo.DriverLocation.FindNearby(ctx, pickup, 10, 50, time.Now().Add(-degradedLocationMaxAge))
+		if err != nil {
+			return nil, err
+		}
+		userIDs := make([]string, 0, len(drivers))
+		for _, driver := range drivers {
+			userIDs = append(userIDs, driver.ID)
+		}
+		return userIDs, nil
+	}
+
+	// Find drivers within 10km of pickup
+	nearbyDrivers, err := r.server.Redis.GeoRadius(ctx,
+		driverGeoKey,
+		pickup.Longitude,
+		pickup.Latitude,
+		&redis.GeoRadiusQuery{
+			Radius:    10,
+			Unit:      "km",
+			Count:     50,
+			Sort:      "ASC",
+			WithCoord: true,
+			WithDist:  true,
+		},
+	).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]string, 0, len(nearbyDrivers))
+	for _, driver := range nearbyDrivers {
+		// Check if driver is online
+		exists, err := r.server.Redis.Get(ctx, driverOnlinePrefix+driver.Name).Result()
+		if err != nil || exists == "" {
+			continue
+		}
+		// driver.Name is the user_id (set by WebSocket handler)
+		userIDs = append(userIDs, driver.Name)
+	}
+	return userIDs, nil
+}
+
 // AcceptRide allows a driver to accept a ride request
 // func (s *RideService) AcceptRide(ctx context.Context, driverID, rideID string) (*model.RideResponse, error) {
 // 	// Check if driver has an active ride
@@ -298,14 +351,23 @@ func (r *RideService) AcceptRide(ctx context.Context, driverId, rideID string) (
 
 	// Remove from Redis Geospatial Index
 	go func() {
-		if err := r.server.Redis.ZRem(context.Background(), "rides:requested", rideID).Err(); err != nil {
+		if !r.server.RedisAvailable() {
+			return
+		}
+		if err := r.server.Redis.ZRem(context.Background(), rideRequestedGeoKey, rideID).Err(); err != nil {
 			r.server.Logger.Error().Err(err).Str("ride_id", rideID).Msg("Failed to remove ride from Redis GEO index")
 		}
 	}()
 
+	// Stream the driver's live position to the rider until the ride ends
+	if err := r.locationService.TrackActiveRide(ctx, driverId, rideID, rideResult.UserID); err != nil {
+		r.server.Logger.Error().Err(err).Str("ride_id", rideID).Msg("Failed to start driver location streaming")
+	}
+
 	// Broadcast to Rider
 	resp, _ := r.buildRideResponse(ctx, rideResult)
-	r.server.Hub.BroadcastToUser(rideResult.UserID, "ride_accepted", resp)
+	r.notifyUser(ctx, rideResult.UserID, "ride_accepted", resp,
+		"Driver on the way", "Your ride has been accepted")
 
 	return resp, nil
 
@@ -404,9 +466,12 @@ func (r *RideService) StartRide(ctx context.Context, driverId, rideID string) (*
 		return nil, errs.Wrap(err, "failed to get ride")
 	}
 
+	r.startTripTrace(ctx, rideResult)
+
 	// Broadcast to Rider
 	resp, _ := r.buildRideResponse(ctx, rideResult)
-	r.server.Hub.BroadcastToUser(rideResult.UserID, "ride_started", resp)
+	r.notifyUser(ctx, rideResult.UserID, "ride_started", resp,
+		"Ride started", "Enjoy your trip")
 
 	return resp, nil
 }
@@ -450,9 +515,23 @@ func (r *RideService) StartRide(ctx context.Context, driverId, rideID string) (*
 // }
 
 func (r *RideService) CompleteRide(ctx context.Context, driverID, rideID string) (*model.RideResponse, error) {
+	// The driven distance replaces the straight line estimate when the trip was traced. The fare
+	// stays the one quoted when the ride was requested, it is what the rider agreed to and was charged.
+	var tracedDistance *float64
+	if r.locationService != nil {
+		distance, ok, err := r.locationService.TripDistanceKm(ctx, rideID)
+		if err != nil {
+			r.server.Logger.Error().Err(err).Str("ride_id", rideID).Msg("Failed to reconstruct trip distance")
+		} else if ok {
+			distance = math.Round(distance*100) / 100
+			tracedDistance = &distance
+		}
+	}
+
 	result, err := r.server.DB.Pool.Exec(ctx, `
 	UPDATE rides
 	SET status = @status,
+	distance_km = COALESCE(@distance_km, distance_km),
 	completed_at =  NOW(),
 	updated_at = NOW()
 	WHERE id = @ride_id 
@@ -463,6 +542,7 @@ func (r *RideService) CompleteRide(ctx context.Context, driverID, rideID string)
 			"driver_id":   driverID,
 			"status":      model.RideStatusCompleted,
 			"in_progress": model.RideStatusInProgress,
+			"distance_km": tracedDistance,
 		})
 
 	if err != nil {
@@ -477,9 +557,12 @@ func (r *RideService) CompleteRide(ctx context.Context, driverID, rideID string)
 		return nil, errs.Wrap(err, "failed to get ride")
 	}
 
+	r.stopLocationStreaming(ctx, rideResult)
+
 	// Broadcast to Rider
 	resp, _ := r.buildRideResponse(ctx, rideResult)
-	r.server.Hub.BroadcastToUser(rideResult.UserID, "ride_completed", resp)
+	r.notifyUser(ctx, rideResult.UserID, "ride_completed", resp,
+		"Ride completed", "You have reached your destination")
 
 	return resp, nil
 }
@@ -505,23 +588,28 @@ func (s *RideService) CancelRide(ctx context.Context, userID, rideID string) err
 
 	s.server.Logger.Info().Str("ride_id", rideID).Msg("Ride cancelled")
 
+	if ride.PromoDiscount > 0 {
+		s.promotionService.Release(ctx, rideID)
+	}
+
 	// Remove from Redis Geospatial Index
 	go func() {
-		if err := s.server.Redis.ZRem(context.Background(), "rides:requested", rideID).Err(); err != nil {
+		if !s.server.RedisAvailable() {
+			return
+		}
+		if err := s.server.Redis.ZRem(context.Background(), rideRequestedGeoKey, rideID).Err(); err != nil {
 			s.server.Logger.Error().Err(err).Str("ride_id", rideID).Msg("Failed to remove ride from Redis GEO index")
 		}
 	}()
 
 	// Notify driver if assigned
-	if ride.DriverID != nil {
-		driverUUID, err := uuid.Parse(*ride.DriverID)
-		if err == nil {
-			d, err := s.repo.Driver.GetByID(context.Background(), driverUUID)
-			if err == nil && d != nil {
-				// Broadcast to Driver
-				s.server.Hub.BroadcastToUser(d.UserID.String(), "ride_cancelled", ride)
-			}
+	if driverUserID := s.driverUserID(context.Background(), ride); driverUserID != "" {
+		if err := s.locationService.UntrackActiveRide(ctx, driverUserID); err != nil {
+			s.server.Logger.Error().Err(err).Str("ride_id", rideID).Msg("Failed to stop driver location streaming")
 		}
+		// Broadcast to Driver
+		s.notifyUser(ctx, driverUserID, "ride_cancelled", ride,
+			"Ride cancelled", "The rider cancelled the ride")
 	}
 
 	// Also broadcast update to Rider (themselves) to confirm cancellation state?
@@ -616,6 +704,10 @@ func (s *RideService) buildRideResponse(ctx context.Context, ride *model.Ride) (
 	if ride.OTP != nil {
 		response.OTP = *ride.OTP
 	}
+	if ride.Fare != nil && ride.DurationMinutes != nil {
+		breakdown := withPromoDiscount(quotedFareBreakdown(*ride.Fare, *ride.DurationMinutes), ride.PromoDiscount)
+		response.FareBreakdown = &breakdown
+	}
 
 	// Add driver info if assigned
 	if ride.DriverID != nil {
@@ -653,6 +745,70 @@ func (s *RideService) buildRideResponse(ctx context.Context, ride *model.Ride) (
 	return response, nil
 }
 
+// driverUserID resolves the user id of the driver assigned to a ride, or "" when none is assigned
+func (s *RideService) driverUserID(ctx context.Context, ride *model.Ride) string {
+	return resolveDriverUserID(ctx, s.repo, ride)
+}
+
+// resolveDriverUserID maps rides.driver_id, a drivers row id, to the driver's user id
+func resolveDriverUserID(ctx context.Context, repo *repository.Repositories, ride *model.Ride) string {
+	if ride.DriverID == nil {
+		return ""
+	}
+	driverUUID, err := uuid.Parse(*ride.DriverID)
+	if err != nil {
+		return ""
+	}
+	d, err := repo.Driver.GetByID(ctx, driverUUID)
+	if err != nil || d == nil {
+		return ""
+	}
+	return d.UserID.String()
+}
+
+// notifyUser sends a ride event over the hub and falls back to a push notification when the
+// user has no live connection
+func (s *RideService) notifyUser(ctx context.Context, userID, event string, payload interface{}, title, body string) {
+	s.server.Hub.BroadcastToUser(userID, event, payload)
+
+	if s.notificationService == nil {
+		return
+	}
+	data := map[string]string{"type": event}
+	switch p := payload.(type) {
+	case *model.RideResponse:
+		data["ride_id"] = p.ID
+	case *model.Ride:
+		data["ride_id"] = p.ID
+	}
+	s.notificationService.NotifyIfOffline(ctx, userID, push.Notification{Title: title, Body: body, Data: data})
+}
+
+// stopLocationStreaming stops forwarding driver_location events once a ride has ended
+func (s *RideService) stopLocationStreaming(ctx context.Context, ride *model.Ride) {
+	driverUserID := s.driverUserID(ctx, ride)
+	if driverUserID == "" {
+		return
+	}
+	if err := s.locationService.UntrackActiveRide(ctx, driverUserID); err != nil {
+		s.server.Logger.Error().Err(err).Str("ride_id", ride.ID).Msg("Failed to stop driver location streaming")
+	}
+}
+
+// startTripTrace starts recording the driver's fixes for distance reconstruction
+func (s *RideService) startTripTrace(ctx context.Context, ride *model.Ride) {
+	if s.locationService == nil || ride.StartedAt == nil {
+		return
+	}
+	driverUserID := s.driverUserID(ctx, ride)
+	if driverUserID == "" {
+		return
+	}
+	if err := s.locationService.MarkRideStarted(ctx, driverUserID, *ride.StartedAt); err != nil {
+		s.server.Logger.Error().Err(err).Str("ride_id", ride.ID).Msg("Failed to start trip trace")
+	}
+}
+
 // updateDriverRating updates a driver's average rating
 func (s *RideService) updateDriverRating(ctx context.Context, driverID string) {
 	query := `
@@ -686,10 +842,38 @@ func calculateDistance(from, to model.Location) float64 {
 	return earthRadius * c
 }
 
-// calculateFare calculates the fare for a ride
-func calculateFare(distanceKm float64, durationMinutes int) float64 {
-	fare := baseFare + (distanceKm * perKmRate) + (float64(durationMinutes) * perMinuteRate)
-	return math.Round(fare*100) / 100 // Round to 2 decimal places
+// estimateTrip estimates the distance in km and the duration in minutes of a trip. The distance is
+// rounded like the stored one, so the fare can be broken down again from a stored ride.
+func estimateTrip(from, to model.Location) (float64, int) {
+	distance := math.Round(calculateDistance(from, to)*100) / 100
+	return distance, int(math.Ceil((distance / averageSpeed) * 60))
+}
+
+// fareBreakdown calculates the fare for a ride from its parts, each rounded to 2 decimal places
+// so they add up to the fare
+func fareBreakdown(distanceKm float64, durationMinutes int) model.FareBreakdown {
+	breakdown := model.FareBreakdown{
+		BaseFare:     baseFare,
+		DistanceFare: math.Round(distanceKm*perKmRate*100) / 100,
+		TimeFare:     math.Round(float64(durationMinutes)*perMinuteRate*100) / 100,
+	}
+	breakdown.Fare = math.Round((breakdown.BaseFare+breakdown.DistanceFare+breakdown.TimeFare)*100) / 100
+	breakdown.Payable = breakdown.Fare
+	return breakdown
+}
+
+// quotedFareBreakdown breaks down the fare stored on a ride, the one quoted when it was requested
+// and charged. The distance of a completed ride is the driven one and no longer adds up to it, so
+// the distance fare is what remains of the fare after the base and time fares.
+func quotedFareBreakdown(fare float64, durationMinutes int) model.FareBreakdown {
+	breakdown := model.FareBreakdown{
+		BaseFare: baseFare,
+		TimeFare: math.Round(float64(durationMinutes)*perMinuteRate*100) / 100,
+		Fare:     fare,
+		Payable:  fare,
+	}
+	breakdown.DistanceFare = math.Round((fare-breakdown.BaseFare-breakdown.TimeFare)*100) / 100
+	return breakdown
 }
 
 // GetNearbyRides finds active ride requests near a location
@@ -43,7 +43,7 @@ func TestCreateRideRequest(t *testing.T) {
 
 	// Setup Service
 	// Passing nil for LocationService as it's not used in CreateRideRequest (unless driver is assigned)
-	rideService := service.NewRideService(srv, mockRepo, nil)
+	rideService := service.NewRideService(srv, mockRepo, nil, nil, nil)
 
 	t.Run("Success", func(t *testing.T) {
 		userID := uuid.New().String()
@@ -68,7 +68,7 @@ func TestCreateRideRequest(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotNil(t, resp)
 		assert.Equal(t, model.RideStatusRequested, resp.Status)
-		assert.Equal(t, "auto", resp.VehicleType)
+		assert.Equal(t, model.VehicleTypeAuto, resp.VehicleType)
 
 		// Verify mock expectations
 		mockRideRepo.AssertExpectations(t)
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/errs"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+)
+
+const (
+	// Redis key prefix of the sorted set holding the fixes of an in-progress trip, scored by fix time
+	rideTracePrefix = "ride:trace:"
+	// Traces outlive the ride so late uploads of buffered fixes still land before the ride is settled
+	rideTraceTTL = 24 * time.Hour
+	// Fixes implying a faster move than this are GPS jumps, not driving
+	maxPlausibleSpeedKmh = 200.0
+)
+
+// tracePoint is the member stored in a ride trace. Identical fixes uploaded twice map to the same member.
+type tracePoint struct {
+	Latitude   float64 `json:"lat"`
+	Longitude  float64 `json:"lng"`
+	RecordedAt int64   `json:"t"`
+}
+
+// MarkRideStarted starts collecting the trip trace of the driver's active ride from startedAt
+func (s *LocationService) MarkRideStarted(ctx context.Context, driverUserID string, startedAt time.Time) error {
+	if !s.server.RedisAvailable() {
+		return nil
+	}
+	key := driverActiveRidePrefix + driverUserID
+	if err := s.server.Redis.HSet(ctx, key, "started_at", strconv.FormatInt(startedAt.UnixMilli(), 10)).Err(); err != nil {
+		return errs.Wrap(err, "failed to mark active ride started in redis")
+	}
+	return nil
+}
+
+// appendTrace adds the fixes recorded after the trip started to the trace of the active ride.
+// Failures are logged only, like forwarding, a lost trace point never fails a location update.
+func (s *LocationService) appendTrace(ctx context.Context, active map[string]string, fixes []model.LocationFix) {
+	rideID := active["ride_id"]
+	startedAt, err := strconv.ParseInt(active["started_at"], 10, 64)
+	if rideID == "" || err != nil {
+		return
+	}
+
+	members := make([]redis.Z, 0, len(fixes))
+	for _, fix := range fixes {
+		at := fix.RecordedAt.UnixMilli()
+		if at < startedAt {
+			continue
+		}
+		point, _ := json.Marshal(tracePoint{
+			Latitude:   fix.Location.Latitude,
+			Longitude:  fix.Location.Longitude,
+			RecordedAt: at,
+		})
+		members = append(members, redis.Z{Score: float64(at), Member: point})
+	}
+	if len(members) == 0 {
+		return
+	}
+
+	key := rideTracePrefix + rideID
+	pipe := s.server.Redis.TxPipeline()
+	pipe.ZAdd(ctx, key, members...)
+	pipe.Expire(ctx, key, rideTraceTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.server.Logger.Error().Err(err).Str("ride_id", rideID).Msg("Failed to append ride trace")
+	}
+}
+
+// TripDistanceKm reconstructs the distance driven on a ride from its trace. Fixes are ordered by the
+// time they were recorded, not received, so buffered uploads after a connectivity gap fill in the
+// route. Segments implying an implausible speed are skipped. ok is false when the trace has no
+// plausible segment and the estimate should be kept.
+func (s *LocationService) TripDistanceKm(ctx context.Context, rideID string) (distance float64, ok bool, err error) {
+	if !s.server.RedisAvailable() {
+		return 0, false, nil
+	}
+
+	members, err := s.server.Redis.ZRange(ctx, rideTracePrefix+rideID, 0, -1).Result()
+	if err != nil {
+		return 0, false, errs.Wrap(err, "failed to read ride trace")
+	}
+
+	points := make([]tracePoint, 0, len(members))
+	for _, member := range members {
+		var point tracePoint
+		if err := json.Unmarshal([]byte(member), &point); err != nil {
+			continue
+		}
+		points = append(points, point)
+	}
+	if len(points) < 2 {
+		return 0, false, nil
+	}
+
+	prev := points[0]
+	segments := 0
+	for _, point := range points[1:] {
+		segment := calculateDistance(
+			model.Location{Latitude: prev.Latitude, Longitude: prev.Longitude},
+			model.Location{Latitude: point.Latitude, Longitude: point.Longitude},
+		)
+		if !plausibleMove(segment, time.Duration(point.RecordedAt-prev.RecordedAt)*time.Millisecond) {
+			continue
+		}
+		distance += segment
+		prev = point
+		segments++
+	}
+	if segments == 0 {
+		return 0, false, nil
+	}
+	return distance, true, nil
+}
+
+// plausibleMove reports whether covering distanceKm in elapsed is possible by road
+func plausibleMove(distanceKm float64, elapsed time.Duration) bool {
+	if elapsed <= 0 {
+		return distanceKm == 0
+	}
+	return distanceKm/elapsed.Hours() <= maxPlausibleSpeedKmh
+}
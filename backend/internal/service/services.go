@@ -1,29 +1,78 @@
 package service
 
 import (
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/lib/gateway"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/server"
 )
 
 type Services struct {
-	Auth     *AuthService
-	Driver   *DriverService
-	Location *LocationService
-	Ride     *RideService
-	Payment  PaymentService
+	Auth         *AuthService
+	Driver       *DriverService
+	Location     *LocationService
+	Ride         *RideService
+	Payment      PaymentService
+	Chat         *ChatService
+	Notification *NotificationService
+	Fraud        *FraudService
+	Heatmap      *HeatmapService
+	Earnings     *EarningsService
+	Promotion    *PromotionService
+	Referral     *ReferralService
 }
 
 func NewServices(s *server.Server, repos *repository.Repositories) (*Services, error) {
-	authService := NewAuthService(s, repos)
 	driverService := NewDriverService(s, repos)
-	locationService := NewLocationService(s, repos)
-	rideService := NewRideService(s, repos, locationService)
-	paymentService := NewPaymentService(repos.Payment, *repos.Ride)
+	locationWriter := NewLocationWriter(s.Logger, s.Config.Location, repos.DriverLocation)
+	if err := locationWriter.Register(s); err != nil {
+		return nil, err
+	}
+	notificationService, err := NewNotificationService(s, repos)
+	if err != nil {
+		return nil, err
+	}
+	fraudService := NewFraudService(s, repos, s.Config.Location, notificationService)
+	locationService := NewLocationService(s, repos, locationWriter, fraudService)
+	if err := NewGeoIndexReconciler(s, repos).Register(); err != nil {
+		return nil, err
+	}
+	heatmapService := NewHeatmapService(s, repos, s.Config.Location)
+	if err := heatmapService.Register(); err != nil {
+		return nil, err
+	}
+	promotionService := NewPromotionService(s, repos)
+	referralService := NewReferralService(s, repos, s.Config.Referral, promotionService, notificationService)
+	if err := referralService.Register(); err != nil {
+		return nil, err
+	}
+	authService := NewAuthService(s, repos, referralService)
+	rideService := NewRideService(s, repos, locationService, notificationService, promotionService)
+	paymentGateway, err := gateway.New(s.Config.Payment)
+	if err != nil {
+		return nil, err
+	}
+	paymentService := NewPaymentService(repos.Payment, repos.Ride, repos.PaymentEvent, repos.Refund, repos.Wallet,
+		repos.Ledger, repos.Earnings, repos.Discrepancy, s.Config.Earnings, &s.Config.Payment.Reconciliation, paymentGateway, notificationService, referralService)
+	if err := paymentService.Register(s); err != nil {
+		return nil, err
+	}
+	earningsService := NewEarningsService(s, repos, s.Config.Earnings)
+	if err := earningsService.Register(); err != nil {
+		return nil, err
+	}
+	chatService := NewChatService(s, repos)
 	return &Services{
-		Auth:     authService,
-		Driver:   driverService,
-		Location: locationService,
-		Ride:     rideService,
-		Payment:  paymentService,
+		Auth:         authService,
+		Driver:       driverService,
+		Location:     locationService,
+		Ride:         rideService,
+		Payment:      paymentService,
+		Chat:         chatService,
+		Notification: notificationService,
+		Fraud:        fraudService,
+		Heatmap:      heatmapService,
+		Earnings:     earningsService,
+		Promotion:    promotionService,
+		Referral:     referralService,
 	}, nil
 }
@@ -0,0 +1,53 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockDriverLocationRepository is a mock implementation of the DriverLocationRepository interface
+type MockDriverLocationRepository struct {
+	mock.Mock
+}
+
+func (m *MockDriverLocationRepository) UpdateLatest(ctx context.Context, samples []model.DriverLocationSample) error {
+	args := m.Called(ctx, samples)
+	return args.Error(0)
+}
+
+func (m *MockDriverLocationRepository) InsertHistory(ctx context.Context, samples []model.DriverLocationSample) error {
+	args := m.Called(ctx, samples)
+	return args.Error(0)
+}
+
+func (m *MockDriverLocationRepository) EnsureHistoryPartitions(ctx context.Context, from time.Time, days int) error {
+	args := m.Called(ctx, from, days)
+	return args.Error(0)
+}
+
+func (m *MockDriverLocationRepository) DropHistoryPartitionsBefore(ctx context.Context, cutoff time.Time) ([]string, error) {
+	args := m.Called(ctx, cutoff)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockDriverLocationRepository) FindNearby(ctx context.Context, location model.Location, radiusKm float64, limit int, updatedSince time.Time) ([]model.NearByDriversResponseFromRedis, error) {
+	args := m.Called(ctx, location, radiusKm, limit, updatedSince)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.NearByDriversResponseFromRedis), args.Error(1)
+}
+
+func (m *MockDriverLocationRepository) GetLatest(ctx context.Context, driverUserID string, updatedSince time.Time) (*model.Location, error) {
+	args := m.Called(ctx, driverUserID, updatedSince)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Location), args.Error(1)
+}
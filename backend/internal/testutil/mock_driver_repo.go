@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model/driver"
 	"github.com/stretchr/testify/mock"
 )
@@ -38,3 +39,16 @@ func (m *MockDriverRepository) Update(ctx context.Context, d *driver.Driver) err
 	args := m.Called(ctx, d)
 	return args.Error(0)
 }
+
+func (m *MockDriverRepository) SetAvailability(ctx context.Context, userID uuid.UUID, available bool) error {
+	args := m.Called(ctx, userID, available)
+	return args.Error(0)
+}
+
+func (m *MockDriverRepository) GetNearbyProfiles(ctx context.Context, userIDs []string) (map[string]model.NearbyDriver, error) {
+	args := m.Called(ctx, userIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]model.NearbyDriver), args.Error(1)
+}
@@ -0,0 +1,35 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockEarningsRepository is a mock implementation of the EarningsRepository interface
+type MockEarningsRepository struct {
+	mock.Mock
+}
+
+func (m *MockEarningsRepository) Record(ctx context.Context, earning *model.RideEarning) error {
+	args := m.Called(ctx, earning)
+	return args.Error(0)
+}
+
+func (m *MockEarningsRepository) GetRideEarning(ctx context.Context, paymentID string) (*model.RideEarning, error) {
+	args := m.Called(ctx, paymentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RideEarning), args.Error(1)
+}
+
+func (m *MockEarningsRepository) DailyTotals(ctx context.Context, driverID string, from, to time.Time) ([]*model.EarningsTotals, error) {
+	args := m.Called(ctx, driverID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.EarningsTotals), args.Error(1)
+}
@@ -0,0 +1,56 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockLedgerRepository is a mock implementation of the LedgerRepository interface
+type MockLedgerRepository struct {
+	mock.Mock
+}
+
+func (m *MockLedgerRepository) Post(ctx context.Context, entry *model.JournalEntry) (bool, error) {
+	args := m.Called(ctx, entry)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockLedgerRepository) GetAccount(ctx context.Context, accountType model.LedgerAccountType, ownerID string) (*model.LedgerAccount, error) {
+	args := m.Called(ctx, accountType, ownerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerAccount), args.Error(1)
+}
+
+func (m *MockLedgerRepository) BalanceAt(ctx context.Context, accountType model.LedgerAccountType, ownerID string, at time.Time) (int64, error) {
+	args := m.Called(ctx, accountType, ownerID, at)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockLedgerRepository) ListPostings(ctx context.Context, accountType model.LedgerAccountType, ownerID string, from, to time.Time, limit int) ([]*model.LedgerPosting, error) {
+	args := m.Called(ctx, accountType, ownerID, from, to, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.LedgerPosting), args.Error(1)
+}
+
+func (m *MockLedgerRepository) ListRecentPostings(ctx context.Context, accountType model.LedgerAccountType, ownerID string, limit int) ([]*model.LedgerPosting, error) {
+	args := m.Called(ctx, accountType, ownerID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.LedgerPosting), args.Error(1)
+}
+
+func (m *MockLedgerRepository) ListAccountsWithBalance(ctx context.Context, accountType model.LedgerAccountType, minBalance int64) ([]*model.LedgerAccount, error) {
+	args := m.Called(ctx, accountType, minBalance)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.LedgerAccount), args.Error(1)
+}
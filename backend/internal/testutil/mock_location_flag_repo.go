@@ -0,0 +1,35 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockLocationFlagRepository is a mock implementation of the LocationFlagRepository interface
+type MockLocationFlagRepository struct {
+	mock.Mock
+}
+
+func (m *MockLocationFlagRepository) Create(ctx context.Context, flag *model.LocationFlag, scoreSince time.Time) (int, error) {
+	args := m.Called(ctx, flag, scoreSince)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockLocationFlagRepository) TopScores(ctx context.Context, since time.Time, limit int) ([]model.DriverFraudScore, error) {
+	args := m.Called(ctx, since, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.DriverFraudScore), args.Error(1)
+}
+
+func (m *MockLocationFlagRepository) ListByDriver(ctx context.Context, driverUserID string, limit int) ([]*model.LocationFlag, error) {
+	args := m.Called(ctx, driverUserID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.LocationFlag), args.Error(1)
+}
@@ -0,0 +1,34 @@
+package testutil
+
+import (
+	"context"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPaymentDiscrepancyRepository is a mock implementation of the PaymentDiscrepancyRepository interface
+type MockPaymentDiscrepancyRepository struct {
+	mock.Mock
+}
+
+func (m *MockPaymentDiscrepancyRepository) Record(ctx context.Context, discrepancy *model.PaymentDiscrepancy) (bool, error) {
+	args := m.Called(ctx, discrepancy)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPaymentDiscrepancyRepository) List(ctx context.Context, status model.DiscrepancyStatus, limit int) ([]*model.PaymentDiscrepancy, error) {
+	args := m.Called(ctx, status, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.PaymentDiscrepancy), args.Error(1)
+}
+
+func (m *MockPaymentDiscrepancyRepository) Resolve(ctx context.Context, id, resolvedBy, note string) (*model.PaymentDiscrepancy, error) {
+	args := m.Called(ctx, id, resolvedBy, note)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PaymentDiscrepancy), args.Error(1)
+}
@@ -0,0 +1,23 @@
+package testutil
+
+import (
+	"context"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPaymentEventRepository is a mock implementation of the PaymentEventRepository interface
+type MockPaymentEventRepository struct {
+	mock.Mock
+}
+
+func (m *MockPaymentEventRepository) Claim(ctx context.Context, event *model.PaymentEvent) (bool, error) {
+	args := m.Called(ctx, event)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPaymentEventRepository) MarkProcessed(ctx context.Context, id string, paymentID *string) error {
+	args := m.Called(ctx, id, paymentID)
+	return args.Error(0)
+}
@@ -0,0 +1,92 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPaymentRepository is a mock implementation of the PaymentRepository interface
+type MockPaymentRepository struct {
+	mock.Mock
+}
+
+func (m *MockPaymentRepository) Create(ctx context.Context, payment *model.Payment) error {
+	args := m.Called(ctx, payment)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepository) CreateWithTip(ctx context.Context, payment *model.Payment, tip float64) error {
+	args := m.Called(ctx, payment, tip)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepository) GetByID(ctx context.Context, id string) (*model.Payment, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Payment), args.Error(1)
+}
+
+func (m *MockPaymentRepository) GetByRideID(ctx context.Context, rideID string) (*model.Payment, error) {
+	args := m.Called(ctx, rideID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Payment), args.Error(1)
+}
+
+func (m *MockPaymentRepository) GetOutstandingByRideID(ctx context.Context, rideID string) (*model.Payment, error) {
+	args := m.Called(ctx, rideID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Payment), args.Error(1)
+}
+
+func (m *MockPaymentRepository) Update(ctx context.Context, payment *model.Payment) error {
+	args := m.Called(ctx, payment)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepository) SetGatewayOrder(ctx context.Context, id, provider, orderID string) error {
+	args := m.Called(ctx, id, provider, orderID)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepository) GetByGatewayOrderID(ctx context.Context, orderID string) (*model.Payment, error) {
+	args := m.Called(ctx, orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Payment), args.Error(1)
+}
+
+func (m *MockPaymentRepository) TransitionStatus(ctx context.Context, payment *model.Payment, from model.PaymentStatusType) (bool, error) {
+	args := m.Called(ctx, payment, from)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPaymentRepository) GetUserPayments(ctx context.Context, userID string, limit, offset int) ([]*model.Payment, error) {
+	args := m.Called(ctx, userID, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Payment), args.Error(1)
+}
+
+func (m *MockPaymentRepository) ListUnsettled(ctx context.Context, createdBefore, failedAfter time.Time, limit int) ([]*model.Payment, error) {
+	args := m.Called(ctx, createdBefore, failedAfter, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Payment), args.Error(1)
+}
+
+func (m *MockPaymentRepository) MarkReconciled(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
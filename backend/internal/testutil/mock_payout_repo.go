@@ -0,0 +1,58 @@
+package testutil
+
+import (
+	"context"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPayoutRepository is a mock implementation of the PayoutRepository interface
+type MockPayoutRepository struct {
+	mock.Mock
+}
+
+func (m *MockPayoutRepository) OpenBatch(ctx context.Context) (*model.PayoutBatch, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PayoutBatch), args.Error(1)
+}
+
+func (m *MockPayoutRepository) CloseBatch(ctx context.Context, batchID string) (*model.PayoutBatch, error) {
+	args := m.Called(ctx, batchID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PayoutBatch), args.Error(1)
+}
+
+func (m *MockPayoutRepository) GetBatch(ctx context.Context, batchID string) (*model.PayoutBatch, error) {
+	args := m.Called(ctx, batchID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PayoutBatch), args.Error(1)
+}
+
+func (m *MockPayoutRepository) ListBatches(ctx context.Context, limit int) ([]*model.PayoutBatch, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.PayoutBatch), args.Error(1)
+}
+
+func (m *MockPayoutRepository) CreatePayout(ctx context.Context, payout *model.Payout) (bool, error) {
+	args := m.Called(ctx, payout)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPayoutRepository) ListPayouts(ctx context.Context, batchID string) ([]*model.Payout, error) {
+	args := m.Called(ctx, batchID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Payout), args.Error(1)
+}
@@ -0,0 +1,62 @@
+package testutil
+
+import (
+	"context"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPromotionRepository is a mock implementation of the PromotionRepository interface
+type MockPromotionRepository struct {
+	mock.Mock
+}
+
+func (m *MockPromotionRepository) Create(ctx context.Context, promotion *model.Promotion) error {
+	args := m.Called(ctx, promotion)
+	return args.Error(0)
+}
+
+func (m *MockPromotionRepository) Update(ctx context.Context, promotion *model.Promotion) error {
+	args := m.Called(ctx, promotion)
+	return args.Error(0)
+}
+
+func (m *MockPromotionRepository) GetByID(ctx context.Context, id string) (*model.Promotion, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Promotion), args.Error(1)
+}
+
+func (m *MockPromotionRepository) GetByCode(ctx context.Context, code string) (*model.Promotion, error) {
+	args := m.Called(ctx, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Promotion), args.Error(1)
+}
+
+func (m *MockPromotionRepository) List(ctx context.Context, active *bool, limit int) ([]*model.Promotion, error) {
+	args := m.Called(ctx, active, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Promotion), args.Error(1)
+}
+
+func (m *MockPromotionRepository) Deactivate(ctx context.Context, id string) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPromotionRepository) CountUserRedemptions(ctx context.Context, promotionID, userID string) (int, error) {
+	args := m.Called(ctx, promotionID, userID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPromotionRepository) Release(ctx context.Context, rideID string) (bool, error) {
+	args := m.Called(ctx, rideID)
+	return args.Bool(0), args.Error(1)
+}
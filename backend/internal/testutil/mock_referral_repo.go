@@ -0,0 +1,69 @@
+package testutil
+
+import (
+	"context"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/repository"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockReferralRepository is a mock implementation of the ReferralRepository interface
+type MockReferralRepository struct {
+	mock.Mock
+}
+
+func (m *MockReferralRepository) GetReferrer(ctx context.Context, code string) (*model.User, error) {
+	args := m.Called(ctx, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockReferralRepository) Create(ctx context.Context, referral *model.Referral) error {
+	args := m.Called(ctx, referral)
+	return args.Error(0)
+}
+
+func (m *MockReferralRepository) GetByReferee(ctx context.Context, refereeID string) (*model.Referral, error) {
+	args := m.Called(ctx, refereeID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Referral), args.Error(1)
+}
+
+func (m *MockReferralRepository) ListByReferrer(ctx context.Context, referrerID string) ([]*model.Referral, error) {
+	args := m.Called(ctx, referrerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Referral), args.Error(1)
+}
+
+func (m *MockReferralRepository) ListPendingPaid(ctx context.Context, limit int) ([]string, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockReferralRepository) FirstPaidRide(ctx context.Context, userID string) (*repository.PaidRide, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.PaidRide), args.Error(1)
+}
+
+func (m *MockReferralRepository) Reward(ctx context.Context, referral *model.Referral) (bool, error) {
+	args := m.Called(ctx, referral)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockReferralRepository) Reject(ctx context.Context, referral *model.Referral) (bool, error) {
+	args := m.Called(ctx, referral)
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,66 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRefundRepository is a mock implementation of the RefundRepository interface
+type MockRefundRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefundRepository) Create(ctx context.Context, refund *model.Refund) error {
+	args := m.Called(ctx, refund)
+	return args.Error(0)
+}
+
+func (m *MockRefundRepository) GetByID(ctx context.Context, id string) (*model.Refund, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Refund), args.Error(1)
+}
+
+func (m *MockRefundRepository) GetByGatewayRefundID(ctx context.Context, gatewayRefundID string) (*model.Refund, error) {
+	args := m.Called(ctx, gatewayRefundID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Refund), args.Error(1)
+}
+
+func (m *MockRefundRepository) ListByPayment(ctx context.Context, paymentID string) ([]*model.Refund, error) {
+	args := m.Called(ctx, paymentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Refund), args.Error(1)
+}
+
+func (m *MockRefundRepository) ListPending(ctx context.Context, createdBefore time.Time, limit int) ([]*model.Refund, error) {
+	args := m.Called(ctx, createdBefore, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Refund), args.Error(1)
+}
+
+func (m *MockRefundRepository) SetGatewayRefundID(ctx context.Context, id, gatewayRefundID string) error {
+	args := m.Called(ctx, id, gatewayRefundID)
+	return args.Error(0)
+}
+
+func (m *MockRefundRepository) TransitionStatus(ctx context.Context, refund *model.Refund, from model.RefundStatus) (bool, error) {
+	args := m.Called(ctx, refund, from)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRefundRepository) ProcessedTotal(ctx context.Context, paymentID string) (float64, error) {
+	args := m.Called(ctx, paymentID)
+	return args.Get(0).(float64), args.Error(1)
+}
@@ -0,0 +1,35 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRideMessageRepository is a mock implementation of the RideMessageRepository interface
+type MockRideMessageRepository struct {
+	mock.Mock
+}
+
+func (m *MockRideMessageRepository) Create(ctx context.Context, message *model.RideMessage) error {
+	args := m.Called(ctx, message)
+	return args.Error(0)
+}
+
+func (m *MockRideMessageRepository) ListByRide(ctx context.Context, rideID string, before *time.Time, limit int) ([]*model.RideMessage, error) {
+	args := m.Called(ctx, rideID, before, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.RideMessage), args.Error(1)
+}
+
+func (m *MockRideMessageRepository) MarkReceipt(ctx context.Context, rideID, recipientID string, messageIDs []string, status model.ReceiptStatus, at time.Time) ([]string, error) {
+	args := m.Called(ctx, rideID, recipientID, messageIDs, status, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
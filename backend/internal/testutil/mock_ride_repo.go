@@ -2,6 +2,7 @@ package testutil
 
 import (
 	"context"
+	"time"
 
 	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
 	"github.com/stretchr/testify/mock"
@@ -17,6 +18,11 @@ func (m *MockRideRepository) Create(ctx context.Context, ride *model.Ride) error
 	return args.Error(0)
 }
 
+func (m *MockRideRepository) CreateWithRedemption(ctx context.Context, ride *model.Ride, redemption *model.PromoRedemption) error {
+	args := m.Called(ctx, ride, redemption)
+	return args.Error(0)
+}
+
 func (m *MockRideRepository) GetByID(ctx context.Context, rideID string) (*model.Ride, error) {
 	args := m.Called(ctx, rideID)
 	if args.Get(0) == nil {
@@ -63,3 +69,27 @@ func (m *MockRideRepository) FindNearbyRides(ctx context.Context, lat, lng, radi
 	}
 	return args.Get(0).([]model.Ride), args.Error(1)
 }
+
+func (m *MockRideRepository) ListRequestedPickups(ctx context.Context) (map[string]model.Location, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]model.Location), args.Error(1)
+}
+
+func (m *MockRideRepository) CountRequestsByCell(ctx context.Context, since time.Time, cellDeg float64) (map[model.GridCell]int, error) {
+	args := m.Called(ctx, since, cellDeg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[model.GridCell]int), args.Error(1)
+}
+
+func (m *MockRideRepository) GetCharges(ctx context.Context, rideID string) (*model.RideCharges, error) {
+	args := m.Called(ctx, rideID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RideCharges), args.Error(1)
+}
@@ -0,0 +1,58 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/satya-18-w/RAPID-RIDE/backend/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockWalletRepository is a mock implementation of the WalletRepository interface
+type MockWalletRepository struct {
+	mock.Mock
+}
+
+func (m *MockWalletRepository) CreateTopup(ctx context.Context, topup *model.WalletTopup) error {
+	args := m.Called(ctx, topup)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) GetTopup(ctx context.Context, id string) (*model.WalletTopup, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.WalletTopup), args.Error(1)
+}
+
+func (m *MockWalletRepository) GetTopupByGatewayOrderID(ctx context.Context, orderID string) (*model.WalletTopup, error) {
+	args := m.Called(ctx, orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.WalletTopup), args.Error(1)
+}
+
+func (m *MockWalletRepository) SetTopupOrder(ctx context.Context, id, provider, orderID string) error {
+	args := m.Called(ctx, id, provider, orderID)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) TransitionTopup(ctx context.Context, topup *model.WalletTopup, from model.WalletTopupStatus) (bool, error) {
+	args := m.Called(ctx, topup, from)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWalletRepository) ListUnsettledTopups(ctx context.Context, createdBefore, failedAfter time.Time, limit int) ([]*model.WalletTopup, error) {
+	args := m.Called(ctx, createdBefore, failedAfter, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.WalletTopup), args.Error(1)
+}
+
+func (m *MockWalletRepository) MarkTopupReconciled(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
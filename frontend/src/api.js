@@ -58,6 +58,11 @@ export const getUserProfile = async () => {
     return await api.get('/auth/me');
 }
 
+// Single-use ticket for opening the websocket, valid for 30 seconds
+export const getWSTicket = async () => {
+    return await api.post('/auth/ws/ticket');
+};
+
 export const signup = async (data) => {
     return await api.post('/auth/signup', data);
 };
@@ -75,14 +80,25 @@ export const updateLocation = async (latitude, longitude, heading = 0, speed = 0
     });
 };
 
+// Upload fixes buffered while offline, oldest first: [{ location, heading, speed, recorded_at }]
+export const uploadLocationBatch = async (fixes) => {
+    return await api.post('/location/batch', { fixes });
+};
 
-export const findNearbyDrivers = async (latitude, longitude, radiusKm = 10) => {
+// filters: { vehicle_type, min_rating }
+export const findNearbyDrivers = async (latitude, longitude, radiusKm = 10, filters = {}) => {
     return await api.post('/location/nearby-drivers', {
         location: { latitude, longitude },
-        radius_km: radiusKm
+        radius_km: radiusKm,
+        ...filters
     });
 };
 
+// GeoJSON FeatureCollection of demand cells around a point
+export const getDemandHeatmap = async (lat, lng, radiusKm) => {
+    return await api.get('/drivers/heatmap', { params: { lat, lng, radius_km: radiusKm } });
+};
+
 export const getDriverProfile = async () => {
     return await api.get('/drivers/profile');
 };
@@ -119,14 +135,24 @@ export const reverseGeocode = async (latitude, longitude) => {
 };
 
 // Ride APIs
-export const createRide = async (pickupLocation, pickupAddress, dropoffLocation, dropoffAddress, vehicleType = 'sedan', paymentMethod = 'cash') => {
+export const createRide = async (pickupLocation, pickupAddress, dropoffLocation, dropoffAddress, vehicleType = 'sedan', paymentMethod = 'cash', promoCode = undefined) => {
     return await api.post('/rides', {
         pickup_location: pickupLocation,
         pickup_address: pickupAddress,
         dropoff_location: dropoffLocation,
         dropoff_address: dropoffAddress,
         vehicle_type: vehicleType,
-        payment_method: paymentMethod
+        payment_method: paymentMethod,
+        promo_code: promoCode
+    });
+};
+
+export const quoteFare = async (pickupLocation, dropoffLocation, vehicleType, promoCode = undefined) => {
+    return await api.post('/rides/quote', {
+        pickup_location: pickupLocation,
+        dropoff_location: dropoffLocation,
+        vehicle_type: vehicleType,
+        promo_code: promoCode
     });
 };
 
@@ -155,21 +181,41 @@ export const rateRide = async (rideId, rating, feedback = '') => {
     return await api.post(`/rides/${rideId}/rate`, { rating, feedback });
 };
 
+// Chat APIs
+export const getRideMessages = async (rideId, before = undefined, limit = 50) => {
+    return await api.get(`/rides/${rideId}/messages`, {
+        params: { before, limit }
+    });
+};
+
+export const sendRideMessage = async (rideId, body, quickReplyId = undefined) => {
+    return await api.post(`/rides/${rideId}/messages`, { body, quick_reply_id: quickReplyId });
+};
+
+export const markRideMessages = async (rideId, messageIds, status = 'read') => {
+    return await api.post(`/rides/${rideId}/messages/receipts`, { message_ids: messageIds, status });
+};
+
+export const getQuickReplies = async () => {
+    return await api.get('/rides/quick-replies');
+};
+
 // Payment APIs
-export const createPaymentOrder = async (rideId, amount, paymentMethod) => {
+// The amount is computed by the server from the ride's fare, once the ride is completed
+export const createPaymentOrder = async (rideId, paymentMethod, tip = 0) => {
     return await api.post('/payments/create', {
         ride_id: rideId,
-        amount: amount,
-        payment_method: paymentMethod
+        payment_method: paymentMethod,
+        tip: tip
     });
 };
 
-export const verifyPayment = async (paymentId, razorpayOrderId, razorpayPaymentId, razorpaySignature) => {
+export const verifyPayment = async (paymentId, gatewayOrderId, gatewayPaymentId, gatewaySignature) => {
     return await api.post('/payments/verify', {
         payment_id: paymentId,
-        razorpay_order_id: razorpayOrderId,
-        razorpay_payment_id: razorpayPaymentId,
-        razorpay_signature: razorpaySignature
+        gateway_order_id: gatewayOrderId,
+        gateway_payment_id: gatewayPaymentId,
+        gateway_signature: gatewaySignature
     });
 };
 
@@ -190,6 +236,34 @@ export const getPaymentByRideId = async (rideId) => {
     return await api.get(`/payments/ride/${rideId}`);
 };
 
+// Wallet APIs
+export const getWallet = async () => {
+    return await api.get('/payments/wallet');
+};
+
+// from and to are YYYY-MM-DD, the last 30 days without them
+export const getWalletStatement = async (from, to) => {
+    return await api.get('/payments/wallet/statement', { params: { from, to } });
+};
+
+export const createWalletTopup = async (amount) => {
+    return await api.post('/payments/wallet/topup', { amount });
+};
+
+export const verifyWalletTopup = async (topupId, gatewayOrderId, gatewayPaymentId, gatewaySignature) => {
+    return await api.post('/payments/wallet/topup/verify', {
+        topup_id: topupId,
+        gateway_order_id: gatewayOrderId,
+        gateway_payment_id: gatewayPaymentId,
+        gateway_signature: gatewaySignature
+    });
+};
+
+// Referral APIs
+export const getReferralStatus = async () => {
+    return await api.get('/referrals');
+};
+
 export default api;
 
 
@@ -38,7 +38,7 @@ const PaymentSelector = ({ onSelect, selectedPayment, amount }) => {
             id: 'wallet',
             name: 'Wallet',
             icon: '👛',
-            description: 'Paytm, PhonePe wallet',
+            description: 'Pay from your in-app wallet balance',
             color: 'from-orange-500 to-red-500'
         }
     ];
@@ -1,4 +1,5 @@
 import React, { createContext, useContext, useEffect, useRef, useState } from 'react';
+import { getWSTicket } from '../api';
 
 const WebSocketContext = createContext(null);
 
@@ -7,8 +8,10 @@ export const WebSocketProvider = ({ children }) => {
     const socketRef = useRef(null);
     const listenersRef = useRef({});
     const reconnectTimeoutRef = useRef(null);
+    // Highest event sequence seen, sent as last_seq on reconnect to replay missed events
+    const lastSeqRef = useRef(null);
 
-    const connect = () => {
+    const connect = async () => {
         const token = localStorage.getItem('token');
         if (!token) return;
 
@@ -17,21 +20,23 @@ export const WebSocketProvider = ({ children }) => {
             socketRef.current.close();
         }
 
-        const wsUrl = `ws://localhost:8050/api/v1/auth/ws`; // Adjust URL as needed
-        const ws = new WebSocket(wsUrl);
-
-        // We can't send headers with standard WebSocket API in browser, 
-        // usually auth is done via query param or cookie. 
-        // But our backend expects Header or maybe we can hack it?
-        // Wait, backend `Handler` checks `c.Get("user_id")` which is set by `RequireAuth` middleware.
-        // `RequireAuth` checks `Authorization` header.
-        // Standard WebSocket in JS DOES NOT support custom headers.
-        // We must pass token in Query Param or Protocol.
-        // Let's modify Backend to check Query Param "token".
+        // Browsers cannot set headers on websockets, so exchange the session for a
+        // single-use ticket instead of putting the JWT in the URL
+        let ticket;
+        try {
+            const res = await getWSTicket();
+            ticket = res.data.ticket;
+        } catch (e) {
+            console.error('Failed to get websocket ticket', e);
+            reconnectTimeoutRef.current = setTimeout(connect, 3000);
+            return;
+        }
 
-        // FOR NOW: Assume we modified backend or use a library that supports it (but native WS doesn't).
-        // Let's check `auth.go` middleware. It usually checks Header.
-        // We probably need to update `auth.go` to check Query Param for WS.
+        let wsUrl = `ws://localhost:8050/api/v1/auth/ws?ticket=${encodeURIComponent(ticket)}`; // Adjust URL as needed
+        if (lastSeqRef.current !== null) {
+            wsUrl += `&last_seq=${lastSeqRef.current}`;
+        }
+        const ws = new WebSocket(wsUrl);
 
         ws.onopen = () => {
             console.log('WebSocket Connected');
@@ -53,7 +58,15 @@ export const WebSocketProvider = ({ children }) => {
         ws.onmessage = (event) => {
             try {
                 const message = JSON.parse(event.data);
-                const { type, payload } = message;
+                const { type, payload, seq } = message;
+
+                if (type === 'session' && lastSeqRef.current === null) {
+                    lastSeqRef.current = payload.last_seq;
+                }
+                if (seq) {
+                    if (seq <= lastSeqRef.current) return; // already handled
+                    lastSeqRef.current = seq;
+                }
 
                 // Notify listeners
                 if (listenersRef.current[type]) {
@@ -89,7 +102,10 @@ export const WebSocketProvider = ({ children }) => {
 
     const sendMessage = (type, payload) => {
         if (socketRef.current && socketRef.current.readyState === WebSocket.OPEN) {
-            socketRef.current.send(JSON.stringify({ type, payload }));
+            // Protocol v1 envelope, see REALTIME_PROTOCOL.md
+            const id = `${Date.now()}-${Math.random().toString(36).slice(2, 8)}`;
+            socketRef.current.send(JSON.stringify({ v: 1, id, type, payload }));
+            return id;
         } else {
             console.warn('WebSocket not connected, cannot send message');
         }
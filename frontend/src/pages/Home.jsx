@@ -25,11 +25,11 @@ const Home = () => {
                 if (res.data?.drivers) {
                     setNearbyDrivers(
                         res.data.drivers
-                            .filter(d => d.latitude && d.longitude)
+                            .filter(d => d.location?.latitude && d.location?.longitude)
                             .map(d => ({
-                                latitude: d.latitude,
-                                longitude: d.longitude,
-                                id: d.id,
+                                latitude: d.location.latitude,
+                                longitude: d.location.longitude,
+                                id: d.driver_id,
                                 vehicleType: d.vehicle_type || 'car'
                             }))
                     );
@@ -3,7 +3,7 @@ import { useNavigate } from 'react-router-dom';
 import FuturisticMap from '../components/FuturisticMap';
 import LocationSearchInput from '../components/LocationSearchInput';
 import VehicleSelector from '../components/VehicleSelector';
-import { createRide, getActiveRide, cancelRide, rateRide, createPaymentOrder, processCashPayment, getPaymentByRideId, findNearbyDrivers, getUserProfile } from '../api';
+import { createRide, getActiveRide, cancelRide, rateRide, createPaymentOrder, processCashPayment, findNearbyDrivers, getUserProfile } from '../api';
 import { getRoute, getDriverToPickupRoute, getRouteWaypoints, haversineDistance } from '../utils/routeService';
 
 // ─── RIDE FLOW STEPS ────────────────────────────────────────────
@@ -97,11 +97,11 @@ const UserHome = () => {
                 const res = await findNearbyDrivers(currentLocation.latitude, currentLocation.longitude);
                 if (res.data?.drivers) {
                     setNearbyDrivers(res.data.drivers
-                        .filter(d => d.latitude && d.longitude)
+                        .filter(d => d.location?.latitude && d.location?.longitude)
                         .map(d => ({
-                            latitude: d.latitude,
-                            longitude: d.longitude,
-                            id: d.id,
+                            latitude: d.location.latitude,
+                            longitude: d.location.longitude,
+                            id: d.driver_id,
                             vehicleType: d.vehicle_type || 'car'
                         }))
                     );
@@ -222,8 +222,6 @@ const UserHome = () => {
             const res = await createRide(pickupLocation, pickupAddress, dropoffLocation, dropoffAddress, selectedVehicle.id, selectedPayment.id);
             setActiveRide(res.data);
             setStep(STEPS.SEARCHING_DRIVER);
-            const fare = selectedVehicle.basePrice + (selectedVehicle.pricePerKm * routeDistance);
-            try { await createPaymentOrder(res.data.id, fare, selectedPayment.id); } catch (e) { /* ignore */ }
         } catch (e) {
             alert(e.response?.data?.error || 'Failed to create ride');
         } finally {
@@ -246,10 +244,11 @@ const UserHome = () => {
         if (!activeRide) return;
         try {
             await rateRide(activeRide.id, rating, feedback);
-            if (selectedPayment.id === 'cash') {
+            if (selectedPayment.id === 'cash' || selectedPayment.id === 'wallet') {
                 try {
-                    const pr = await getPaymentByRideId(activeRide.id);
-                    if (pr.data?.id) await processCashPayment(pr.data.id);
+                    // Wallet rides are charged when the order is created
+                    const pr = await createPaymentOrder(activeRide.id, selectedPayment.id);
+                    if (selectedPayment.id === 'cash' && pr.data?.payment_id && pr.data.status !== 'captured') await processCashPayment(pr.data.payment_id);
                 } catch (e) { /* ignore */ }
             }
             resetFlow();